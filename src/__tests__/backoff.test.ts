@@ -0,0 +1,25 @@
+import { test, expect } from 'bun:test';
+import { computeBackoffMs } from '../utils/backoff';
+
+test('computeBackoffMs grows exponentially from the base', () => {
+  const options = { baseMs: 100, maxMs: 10_000, jitter: 0 };
+
+  expect(computeBackoffMs(1, options)).toBe(100);
+  expect(computeBackoffMs(2, options)).toBe(200);
+  expect(computeBackoffMs(3, options)).toBe(400);
+});
+
+test('computeBackoffMs caps the delay', () => {
+  const options = { baseMs: 100, maxMs: 300, jitter: 0 };
+
+  expect(computeBackoffMs(3, options)).toBe(300);
+  expect(computeBackoffMs(20, options)).toBe(300);
+});
+
+test('computeBackoffMs jitter only shortens the delay', () => {
+  const options = { baseMs: 1000, maxMs: 10_000, jitter: 0.5 };
+
+  expect(computeBackoffMs(1, options, () => 0)).toBe(1000);
+  expect(computeBackoffMs(1, options, () => 0.5)).toBe(750);
+  expect(computeBackoffMs(1, options, () => 1)).toBe(500);
+});
@@ -0,0 +1,52 @@
+import { expect, test } from 'bun:test';
+import * as fs from 'node:fs';
+import * as path from 'node:path';
+import {
+  getBuildInfo,
+  isSupportedOpencodeVersion,
+  isVersionMismatch,
+  parseBuildInfo,
+  parseOpencodeVersion,
+} from '../utils/build-info';
+
+test('getBuildInfo falls back to package.json when running from source', () => {
+  const pkg = JSON.parse(
+    fs.readFileSync(path.join(import.meta.dir, '..', '..', 'package.json'), 'utf-8'),
+  ) as { version: string };
+
+  expect(getBuildInfo(import.meta.dir)).toEqual({
+    version: pkg.version,
+    commit: 'unknown',
+    buildDate: 'unknown',
+  });
+  expect(getBuildInfo().version).toBe('dev');
+});
+
+test('parseBuildInfo accepts published build info only', () => {
+  expect(parseBuildInfo('{"version":"1.5.7","commit":"abc1234"}')).toEqual({
+    version: '1.5.7',
+    commit: 'abc1234',
+    buildDate: 'unknown',
+  });
+  expect(parseBuildInfo('')).toBeNull();
+  expect(parseBuildInfo('{"commit":"abc1234"}')).toBeNull();
+});
+
+test('isVersionMismatch compares versions, and commits when both are known', () => {
+  const base = { version: '1.5.7', commit: 'abc1234', buildDate: 'unknown' };
+  expect(isVersionMismatch(base, { ...base })).toBe(false);
+  expect(isVersionMismatch(base, { ...base, version: '1.6.0' })).toBe(true);
+  expect(isVersionMismatch(base, { ...base, commit: 'def5678' })).toBe(true);
+  expect(isVersionMismatch(base, { ...base, commit: 'unknown' })).toBe(false);
+});
+
+test('isSupportedOpencodeVersion rejects releases without attach --session', () => {
+  expect(parseOpencodeVersion('opencode v1.0.2\n')).toBe('1.0.2');
+  expect(parseOpencodeVersion('0.15.3')).toBe('0.15.3');
+  expect(parseOpencodeVersion('dev build')).toBeNull();
+
+  expect(isSupportedOpencodeVersion('0.14.9')).toBe(false);
+  expect(isSupportedOpencodeVersion('0.15.0')).toBe(true);
+  expect(isSupportedOpencodeVersion('1.0.2')).toBe(true);
+  expect(isSupportedOpencodeVersion('dev build')).toBe(true);
+});
@@ -0,0 +1,109 @@
+import { test, expect } from 'bun:test';
+import {
+  flagString,
+  formatCommandHelp,
+  formatHelp,
+  formatUsage,
+  matchCommand,
+  parseArgs,
+  type CommandSpec,
+} from '../utils/cli';
+
+const run = () => 0;
+
+const commands: CommandSpec[] = [
+  { path: ['session', 'stop'], args: '[port]', summary: 'Stop the server', run },
+  {
+    path: ['session', 'rename'],
+    summary: 'Rename a session',
+    flags: [
+      { name: '--id', value: '<session>', description: 'Session id', required: true },
+      { name: '--title', value: '<title>', description: 'New title', required: true },
+      { name: '--json', description: 'Print JSON' },
+    ],
+    run,
+  },
+  {
+    path: ['attach'],
+    summary: 'Attach to a session',
+    flags: [{ name: '--session', value: '<id>', description: 'Session id', required: true }],
+    accepts: (rest) => rest.includes('--session'),
+    run,
+  },
+  {
+    path: ['upgrade'],
+    summary: 'Upgrade',
+    flags: [{ name: '--check', description: 'Only check' }],
+    allowUnknownFlags: true,
+    run,
+  },
+];
+
+test('matchCommand picks the command by its path', () => {
+  const match = matchCommand(commands, ['session', 'rename', '--id', 'ses_1']);
+  expect(match?.command.path).toEqual(['session', 'rename']);
+  expect(match?.rest).toEqual(['--id', 'ses_1']);
+
+  expect(matchCommand(commands, ['session'])).toBeNull();
+  expect(matchCommand(commands, ['run', 'hello'])).toBeNull();
+});
+
+test('matchCommand leaves invocations a command does not accept to opencode', () => {
+  expect(matchCommand(commands, ['attach', 'http://localhost:4096'])).toBeNull();
+  expect(matchCommand(commands, ['attach', '--session', 'ses_1'])?.command.path).toEqual(['attach']);
+});
+
+test('parseArgs reads flags, inline values and positionals', () => {
+  const rename = commands[1];
+  const result = parseArgs(rename, ['--id', 'ses_1', '--title=a = b', '--json']);
+  expect(result.ok).toBe(true);
+  if (!result.ok) return;
+
+  expect(flagString(result.parsed, '--id')).toBe('ses_1');
+  expect(flagString(result.parsed, '--title')).toBe('a = b');
+  expect(result.parsed.flags['--json']).toBe(true);
+  expect(flagString(result.parsed, '--json')).toBeUndefined();
+
+  const stop = parseArgs(commands[0], ['4096']);
+  expect(stop.ok && stop.parsed.positionals).toEqual(['4096']);
+});
+
+test('parseArgs reports unknown flags, missing values and missing required flags', () => {
+  const rename = commands[1];
+  expect(parseArgs(rename, ['--id', 'ses_1', '--title', 'x', '--bogus'])).toEqual({
+    ok: false,
+    error: 'unknown flag --bogus',
+  });
+  expect(parseArgs(rename, ['--id', '--title', 'x'])).toEqual({
+    ok: false,
+    error: '--id expects a value <session>',
+  });
+  expect(parseArgs(rename, ['--id', 'ses_1'])).toEqual({
+    ok: false,
+    error: 'missing required flag --title <title>',
+  });
+});
+
+test('parseArgs keeps unknown flags as positionals when allowed', () => {
+  const result = parseArgs(commands[3], ['--check', '--method', 'npm']);
+  expect(result.ok && result.parsed.positionals).toEqual(['--method', 'npm']);
+  expect(result.ok && result.parsed.flags['--check']).toBe(true);
+});
+
+test('help output lists usage, commands and flags', () => {
+  expect(formatUsage('opentmux', commands[1])).toBe(
+    'opentmux session rename --id <session> --title <title> [--json]',
+  );
+
+  const commandHelp = formatCommandHelp('opentmux', commands[1]);
+  expect(commandHelp).toContain('Usage: opentmux session rename');
+  expect(commandHelp).toContain('--title <title>  New title');
+
+  const help = formatHelp('opentmux', commands, [
+    { name: '--verbose', description: 'Log to stderr' },
+  ]);
+  expect(help).toContain('session stop [port]');
+  expect(help).toContain('Rename a session');
+  expect(help).toContain('--verbose');
+  expect(help).toContain('opencode --help');
+});
@@ -0,0 +1,61 @@
+import { expect, test } from 'bun:test';
+import type { CommandSpec } from '../utils/cli';
+import { completionTree, generateCompletion, isCompletionShell } from '../utils/completion';
+
+const run = () => 0;
+const idFlag = { name: '--id', value: '<session>', description: 'Session id', required: true };
+
+const commands: CommandSpec[] = [
+  { path: ['--reap'], summary: 'Reap', run },
+  { path: ['attach'], summary: 'Attach', flags: [{ ...idFlag, name: '--session' }], run },
+  { path: ['jump'], choices: ['1', 'next', 'prev'], summary: 'Jump', run },
+  { path: ['session', 'stop'], summary: 'Stop', run },
+  { path: ['session', 'focus'], summary: 'Focus', flags: [idFlag], run },
+  {
+    path: ['config', 'init'],
+    summary: 'Init',
+    flags: [{ name: '--force', description: 'Overwrite' }],
+    run,
+  },
+];
+
+test('isCompletionShell accepts bash, zsh and fish only', () => {
+  expect(isCompletionShell('bash')).toBe(true);
+  expect(isCompletionShell('fish')).toBe(true);
+  expect(isCompletionShell('powershell')).toBe(false);
+  expect(isCompletionShell(undefined)).toBe(false);
+});
+
+test('completionTree groups subcommands and skips flag commands', () => {
+  expect(completionTree(commands)).toEqual([
+    { name: 'attach', flags: ['--session'] },
+    { name: 'jump', flags: undefined, subcommands: [{ name: '1' }, { name: 'next' }, { name: 'prev' }] },
+    {
+      name: 'session',
+      subcommands: [
+        { name: 'stop', flags: undefined },
+        { name: 'focus', flags: ['--id'] },
+      ],
+    },
+    { name: 'config', subcommands: [{ name: 'init', flags: ['--force'] }] },
+  ]);
+});
+
+test('completion scripts cover every command', () => {
+  for (const shell of ['bash', 'zsh', 'fish'] as const) {
+    const script = generateCompletion(shell, commands);
+    for (const command of completionTree(commands)) {
+      expect(script).toContain(command.name);
+    }
+    expect(script).not.toContain('--reap');
+  }
+});
+
+test('session id flags complete from open agent panes', () => {
+  expect(generateCompletion('bash', commands)).toContain('--id|--session)');
+  expect(generateCompletion('bash', commands)).toContain('opentmux completion --sessions');
+  expect(generateCompletion('zsh', commands)).toContain('#compdef opentmux');
+  expect(generateCompletion('fish', commands)).toContain(
+    "__fish_seen_subcommand_from focus' -l id -xa '(opentmux completion --sessions 2>/dev/null)'",
+  );
+});
@@ -0,0 +1,172 @@
+import { test, expect, beforeEach, afterEach } from 'bun:test';
+import * as fs from 'node:fs';
+import * as os from 'node:os';
+import * as path from 'node:path';
+import {
+  CONFIG_ENV_VAR,
+  formatUnknownKeys,
+  loadConfigWithSources,
+  mergeConfigLayers,
+  validateConfig,
+  validateConfigFile,
+} from '../utils/config-loader';
+
+let tmpDir: string;
+const originalHome = process.env.HOME;
+
+beforeEach(() => {
+  tmpDir = fs.mkdtempSync(path.join(os.tmpdir(), 'opentmux-config-'));
+});
+
+afterEach(() => {
+  process.env.HOME = originalHome;
+  delete process.env[CONFIG_ENV_VAR];
+  fs.rmSync(tmpDir, { recursive: true, force: true });
+});
+
+function writeGlobalConfig(home: string, data: unknown): string {
+  const dir = path.join(home, '.config', 'opencode');
+  fs.mkdirSync(dir, { recursive: true });
+  const configPath = path.join(dir, 'opentmux.json');
+  fs.writeFileSync(configPath, JSON.stringify(data));
+  return configPath;
+}
+
+test('validateConfig reports unknown keys with suggestions', () => {
+  const result = validateConfig({ spawndelayms: 500, bogus: true });
+
+  expect(result.config).not.toBeNull();
+  expect(result.unknownKeys).toEqual(['spawndelayms', 'bogus']);
+  expect(result.suggestions).toEqual({ spawndelayms: 'spawn_delay_ms' });
+  expect(formatUnknownKeys(result)).toEqual([
+    'unknown key "spawndelayms" (did you mean "spawn_delay_ms"?)',
+    'unknown key "bogus"',
+  ]);
+});
+
+test('validateConfig reports schema errors with field paths', () => {
+  const result = validateConfig({ spawn_delay_ms: 10 });
+
+  expect(result.config).toBeNull();
+  expect(result.errors.length).toBe(1);
+  expect(result.errors[0]).toStartWith('spawn_delay_ms:');
+});
+
+test('validateConfigFile reports malformed JSON', () => {
+  const configPath = path.join(tmpDir, 'opentmux.json');
+  fs.writeFileSync(configPath, '{ "layout": ');
+
+  const result = validateConfigFile(configPath);
+
+  expect(result.config).toBeNull();
+  expect(result.errors[0]).toStartWith('failed to read config:');
+});
+
+test('validateConfigFile normalizes defaults', () => {
+  const configPath = path.join(tmpDir, 'opentmux.json');
+  fs.writeFileSync(configPath, JSON.stringify({ layout: 'tiled' }));
+
+  const result = validateConfigFile(configPath);
+
+  expect(result.errors).toEqual([]);
+  expect(result.config?.layout).toBe('tiled');
+  expect(result.config?.port).toBe(4096);
+});
+
+test('mergeConfigLayers lets later layers override only the keys they set', () => {
+  const merged = mergeConfigLayers([
+    { layout: 'tiled', port: 5000, nested: { a: 1, b: 2 } },
+    { port: 6000, nested: { b: 3 } },
+  ]);
+
+  expect(merged).toEqual({ layout: 'tiled', port: 6000, nested: { a: 1, b: 3 } });
+});
+
+test('loadConfigWithSources overlays project config on global config', () => {
+  const home = path.join(tmpDir, 'home');
+  const project = path.join(tmpDir, 'project');
+  fs.mkdirSync(project, { recursive: true });
+  process.env.HOME = home;
+
+  const globalPath = writeGlobalConfig(home, { layout: 'tiled', port: 5000 });
+  const projectPath = path.join(project, 'opentmux.json');
+  fs.writeFileSync(projectPath, JSON.stringify({ port: 6000 }));
+
+  const { config, sources } = loadConfigWithSources(project);
+
+  expect(sources).toEqual([globalPath, projectPath]);
+  expect(config.layout).toBe('tiled');
+  expect(config.port).toBe(6000);
+});
+
+test('loadConfigWithSources skips an invalid project layer', () => {
+  const home = path.join(tmpDir, 'home');
+  const project = path.join(tmpDir, 'project');
+  fs.mkdirSync(project, { recursive: true });
+  process.env.HOME = home;
+
+  const globalPath = writeGlobalConfig(home, { layout: 'tiled' });
+  fs.writeFileSync(path.join(project, 'opentmux.json'), JSON.stringify({ spawn_delay_ms: 1 }));
+
+  const { config, sources } = loadConfigWithSources(project);
+
+  expect(sources).toEqual([globalPath]);
+  expect(config.layout).toBe('tiled');
+  expect(config.spawn_delay_ms).toBe(300);
+});
+
+test('loadConfigWithSources rejects a layout preset no config file defines', () => {
+  const home = path.join(tmpDir, 'home');
+  const project = path.join(tmpDir, 'project');
+  fs.mkdirSync(project, { recursive: true });
+  process.env.HOME = home;
+
+  const wide = '21be,200x50,0,0{100x50,0,0,0,99x50,101,0[99x25,101,0,1,99x24,101,26,2]}';
+  writeGlobalConfig(home, { layouts: { wide } });
+  const projectPath = path.join(project, 'opentmux.json');
+
+  fs.writeFileSync(projectPath, JSON.stringify({ layout: 'wide', port: 6000 }));
+  expect(loadConfigWithSources(project).errors).toEqual([]);
+
+  fs.writeFileSync(projectPath, JSON.stringify({ layout: 'wdie', port: 6000 }));
+  const { config, sources, errors } = loadConfigWithSources(project);
+
+  expect(errors).toEqual(['layout: unknown layout preset "wdie"']);
+  expect(sources).toEqual([]);
+  expect(config.layout).toBe('main-vertical');
+  expect(config.port).toBe(4096);
+});
+
+test('loadConfigWithSources uses an explicit config file in place of the project config', () => {
+  const home = path.join(tmpDir, 'home');
+  const project = path.join(tmpDir, 'project');
+  fs.mkdirSync(project, { recursive: true });
+  process.env.HOME = home;
+
+  const globalPath = writeGlobalConfig(home, { layout: 'tiled', port: 5000 });
+  fs.writeFileSync(path.join(project, 'opentmux.json'), JSON.stringify({ port: 6000 }));
+  const explicitPath = path.join(tmpDir, 'custom.json');
+  fs.writeFileSync(explicitPath, JSON.stringify({ port: 7000 }));
+  process.env[CONFIG_ENV_VAR] = explicitPath;
+
+  const { config, sources } = loadConfigWithSources(project);
+
+  expect(sources).toEqual([globalPath, explicitPath]);
+  expect(config.layout).toBe('tiled');
+  expect(config.port).toBe(7000);
+});
+
+test('validateConfigFile reads TOML and YAML configs', () => {
+  const tomlPath = path.join(tmpDir, 'opentmux.toml');
+  fs.writeFileSync(tomlPath, 'layout = "tiled"\nspawn_delay_ms = 500\n');
+  const yamlPath = path.join(tmpDir, 'opentmux.yaml');
+  fs.writeFileSync(yamlPath, 'layout: main-horizontal\nmax_ports: 5\n');
+
+  const toml = validateConfigFile(tomlPath);
+  const yaml = validateConfigFile(yamlPath);
+
+  expect(toml.config?.layout).toBe('tiled');
+  expect(toml.config?.spawn_delay_ms).toBe(500);
+  expect(yaml.config?.layout).toBe('main-horizontal');
+  expect(yaml.config?.max_ports).toBe(5);
+});
@@ -1,5 +1,5 @@
 import { test, expect } from "bun:test";
-import { TmuxConfigSchema } from "../config";
+import { layoutReferenceError, TmuxConfigSchema } from "../config";
 
 test("TmuxConfigSchema has new config fields", () => {
   const config = TmuxConfigSchema.parse({});
@@ -28,3 +28,29 @@ test("TmuxConfigSchema validates min/max constraints", () => {
   expect(() => TmuxConfigSchema.parse({ max_agents_per_column: 0 })).toThrow();
   expect(() => TmuxConfigSchema.parse({ max_agents_per_column: 15 })).toThrow();
 });
+
+test("TmuxConfigSchema validates window_rules", () => {
+  expect(
+    TmuxConfigSchema.parse({ window_rules: [{ title_match: "test.*", window: "tests" }] })
+      .window_rules,
+  ).toEqual([{ title_match: "test.*", window: "tests" }]);
+  expect(() => TmuxConfigSchema.parse({ window_rules: [{ window: "tests" }] })).toThrow();
+  expect(() =>
+    TmuxConfigSchema.parse({ window_rules: [{ title_match: "(", window: "tests" }] }),
+  ).toThrow();
+});
+
+test("layout accepts builtins, auto, tmux layout strings and presets", () => {
+  const wide = "21be,200x50,0,0{100x50,0,0,0,99x50,101,0[99x25,101,0,1,99x24,101,26,2]}";
+  expect(TmuxConfigSchema.parse({ layout: wide }).layout).toBe(wide);
+  expect(TmuxConfigSchema.parse({ layout: "auto" }).layout).toBe("auto");
+  expect(() => TmuxConfigSchema.parse({ layout: "0000,200x50,0,0" })).toThrow();
+  expect(() => TmuxConfigSchema.parse({ layouts: { wide: "not a layout" } })).toThrow();
+
+  expect(layoutReferenceError({ layout: "wide", layouts: { wide } })).toBeNull();
+  expect(layoutReferenceError({ layout: "tiled" })).toBeNull();
+  expect(layoutReferenceError({ layout: "auto" })).toBeNull();
+  expect(layoutReferenceError({ layout: "narrow", layouts: { wide } })).toBe(
+    'layout: unknown layout preset "narrow"',
+  );
+});
@@ -0,0 +1,76 @@
+import { test, expect, beforeEach, afterEach, mock, spyOn } from 'bun:test';
+import { createSessionManager } from '../embed';
+import * as utils from '../utils';
+import { FakeClock } from './fake-clock';
+import { FakePaneController } from './fake-pane-controller';
+import { FakeStatusSource } from './fake-status-source';
+
+const client = {
+  session: {
+    status: async () => ({ data: {} }),
+    subscribe: () => () => {},
+  },
+};
+
+beforeEach(() => {
+  spyOn(utils, 'log').mockImplementation(() => {});
+  spyOn(utils, 'isInsideTmux').mockReturnValue(true);
+  spyOn(utils, 'isSpawningPaused').mockResolvedValue(false);
+  spyOn(utils, 'getQueueFlushedAt').mockResolvedValue(null);
+  spyOn(utils, 'getCloseAllRequest').mockResolvedValue(null);
+  spyOn(utils, 'setQueueOption').mockResolvedValue(true);
+  spyOn(utils, 'showTmuxMessage').mockResolvedValue(true);
+});
+
+afterEach(() => {
+  mock.restore();
+});
+
+test('createSessionManager rejects an invalid config', () => {
+  expect(() =>
+    createSessionManager({
+      client,
+      directory: '/test',
+      serverUrl: 'http://localhost:4096',
+      config: { max_queue_depth: -1 },
+    }),
+  ).toThrow();
+});
+
+test('createSessionManager runs the documented lifecycle without touching process signals', async () => {
+  const signalListeners = process.listenerCount('SIGTERM');
+  const clock = new FakeClock();
+  const panes = new FakePaneController();
+  const statuses = new FakeStatusSource();
+  const manager = createSessionManager({
+    client,
+    directory: '/test',
+    serverUrl: 'http://localhost:4096',
+    config: { reaper_enabled: false },
+    clock,
+    panes,
+    statuses,
+  });
+  expect(process.listenerCount('SIGTERM')).toBe(signalListeners);
+
+  statuses.statuses = { child: { type: 'busy' } };
+  const created = manager.handleEvent({
+    type: 'session.created',
+    properties: { info: { id: 'child', parentID: 'parent', title: 'Child' } },
+  });
+  await clock.advance(0);
+  await created;
+  expect([...panes.panes.keys()]).toEqual(['%100']);
+
+  const cleanup = manager.cleanup();
+  await clock.advance(0);
+  await cleanup;
+  expect(panes.closed).toEqual(['%100']);
+
+  await manager.handleEvent({
+    type: 'session.created',
+    properties: { info: { id: 'late', parentID: 'parent', title: 'Late' } },
+  });
+  await clock.advance(1000);
+  expect(panes.panes.size).toBe(0);
+});
@@ -0,0 +1,83 @@
+import type { Clock, ClockTimer } from '../utils/clock';
+
+interface PendingTimer {
+  id: number;
+  at: number;
+  callback: () => void;
+  /** Set for intervals, which are rescheduled after firing */
+  everyMs?: number;
+}
+
+/**
+ * A Clock that only moves when told to. advance() fires due timers in time
+ * order and lets the promise callbacks they start settle in between, so
+ * async work chained off a timer has run by the time it returns.
+ */
+export class FakeClock implements Clock {
+  private current: number;
+  private nextId = 1;
+  private readonly timers = new Map<number, PendingTimer>();
+
+  constructor(start = 1_700_000_000_000) {
+    this.current = start;
+  }
+
+  now(): number {
+    return this.current;
+  }
+
+  setTimeout(callback: () => void, ms: number): ClockTimer {
+    return this.schedule(callback, ms);
+  }
+
+  clearTimeout(timer: ClockTimer | undefined): void {
+    if (typeof timer === 'number') this.timers.delete(timer);
+  }
+
+  setInterval(callback: () => void, ms: number): ClockTimer {
+    return this.schedule(callback, ms, Math.max(1, ms));
+  }
+
+  clearInterval(timer: ClockTimer | undefined): void {
+    this.clearTimeout(timer);
+  }
+
+  /** Timers still waiting to fire */
+  get pendingCount(): number {
+    return this.timers.size;
+  }
+
+  async advance(ms: number): Promise<void> {
+    const target = this.current + ms;
+    await settle();
+    for (;;) {
+      const due = [...this.timers.values()]
+        .filter((timer) => timer.at <= target)
+        .sort((a, b) => a.at - b.at || a.id - b.id)[0];
+      if (!due) break;
+
+      this.current = due.at;
+      if (due.everyMs === undefined) {
+        this.timers.delete(due.id);
+      } else {
+        due.at += due.everyMs;
+      }
+      due.callback();
+      await settle();
+    }
+    this.current = target;
+  }
+
+  private schedule(callback: () => void, ms: number, everyMs?: number): number {
+    const id = this.nextId++;
+    this.timers.set(id, { id, at: this.current + Math.max(0, ms), callback, everyMs });
+    return id;
+  }
+}
+
+/** Lets pending promise callbacks run (mocked I/O resolves within a few turns) */
+async function settle(): Promise<void> {
+  for (let i = 0; i < 20; i++) {
+    await Promise.resolve();
+  }
+}
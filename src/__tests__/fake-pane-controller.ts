@@ -0,0 +1,86 @@
+import type { PaneController } from '../pane-controller';
+import type { AgentPane, PaneStatus } from '../utils';
+
+/**
+ * A PaneController over an in-memory set of panes. Spawns succeed with
+ * increasing pane ids unless nextSpawn says otherwise.
+ */
+export class FakePaneController implements PaneController {
+  /** Live panes by id, with the session each one is attached to */
+  readonly panes = new Map<string, { sessionId: string; title: string }>();
+  readonly closed: string[] = [];
+  /** Last status style set on each pane */
+  readonly styles = new Map<string, PaneStatus>();
+  /** Status-line messages, in order */
+  readonly messages: string[] = [];
+  layoutCount = 0;
+  refreshCount = 0;
+  /** Result for the next spawn only, e.g. a failure */
+  nextSpawn?: Awaited<ReturnType<PaneController['spawnPane']>>;
+  private nextPaneId = 100;
+
+  spawnPane: PaneController['spawnPane'] = async (sessionId, title) => {
+    const override = this.nextSpawn;
+    this.nextSpawn = undefined;
+    if (override) return override;
+
+    const paneId = `%${this.nextPaneId++}`;
+    this.panes.set(paneId, { sessionId, title });
+    return { success: true, paneId, attempts: 1 };
+  };
+
+  closePane: PaneController['closePane'] = async (paneId) => {
+    this.closed.push(paneId);
+    return this.panes.delete(paneId);
+  };
+
+  listAgentPanes: PaneController['listAgentPanes'] = async () =>
+    [...this.panes].map(
+      ([paneId, { sessionId }]): AgentPane => ({
+        paneId,
+        sessionId,
+        serverUrl: 'http://localhost:4096',
+        pid: null,
+        dead: false,
+      }),
+    );
+
+  listPaneIds: PaneController['listPaneIds'] = async () => new Set(this.panes.keys());
+
+  /** What listProcesses returns; null plays a failed ps */
+  processes: Awaited<ReturnType<PaneController['listProcesses']>> = [];
+
+  listProcesses: PaneController['listProcesses'] = async () => this.processes;
+
+  isAttached: PaneController['isAttached'] = (pane) => this.panes.has(pane.paneId);
+
+  setTitle: PaneController['setTitle'] = async (paneId, title) => {
+    const pane = this.panes.get(paneId);
+    if (pane) pane.title = title;
+    return !!pane;
+  };
+
+  setStatusStyle: PaneController['setStatusStyle'] = async (paneId, status) => {
+    this.styles.set(paneId, status);
+    return this.panes.has(paneId);
+  };
+
+  captureOutput: PaneController['captureOutput'] = async (paneId) =>
+    this.panes.has(paneId) ? `output of ${paneId}\n` : null;
+
+  applyLayout: PaneController['applyLayout'] = async () => {
+    this.layoutCount++;
+  };
+
+  refreshLayout: PaneController['refreshLayout'] = async () => {
+    this.refreshCount++;
+    return false;
+  };
+
+  removePlaceholders: PaneController['removePlaceholders'] = async () => {};
+
+  showMessage: PaneController['showMessage'] = async (text) => {
+    this.messages.push(text);
+    return true;
+  };
+}
@@ -0,0 +1,30 @@
+import type { SessionStatuses, StatusSource } from '../status-source';
+
+/**
+ * A StatusSource answering from fields tests set. With `down`, status
+ * requests reject and the server reports itself dead.
+ */
+export class FakeStatusSource implements StatusSource {
+  statuses: SessionStatuses = {};
+  titles: Record<string, string> = {};
+  readonly aborted: string[] = [];
+  down = false;
+
+  async fetchStatuses(): Promise<SessionStatuses> {
+    if (this.down) throw new Error('server down');
+    return { ...this.statuses };
+  }
+
+  async fetchTitle(sessionId: string): Promise<unknown> {
+    return this.titles[sessionId];
+  }
+
+  async abortSession(sessionId: string): Promise<boolean> {
+    this.aborted.push(sessionId);
+    return !this.down;
+  }
+
+  async isServerAlive(): Promise<boolean> {
+    return !this.down;
+  }
+}
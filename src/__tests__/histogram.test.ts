@@ -0,0 +1,17 @@
+import { expect, test } from 'bun:test';
+import { Histogram, percentile } from '../utils/histogram';
+
+test('percentile uses nearest rank', () => {
+  const sorted = [1, 2, 3, 4, 5, 6, 7, 8, 9, 10];
+  expect(percentile(sorted, 50)).toBe(5);
+  expect(percentile(sorted, 95)).toBe(10);
+  expect(percentile([], 50)).toBe(0);
+});
+
+test('Histogram keeps a bounded window of samples', () => {
+  const histogram = new Histogram(3);
+  for (const value of [100, 1, 2, 3]) {
+    histogram.record(value);
+  }
+  expect(histogram.summary()).toEqual({ count: 3, p50: 2, p95: 3, max: 3 });
+});
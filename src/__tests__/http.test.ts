@@ -0,0 +1,32 @@
+import { afterEach, expect, mock, test } from 'bun:test';
+import { requestJson } from '../utils/http';
+
+const originalFetch = globalThis.fetch;
+
+afterEach(() => {
+  globalThis.fetch = originalFetch;
+});
+
+test('requestJson parses JSON bodies and keeps the status', async () => {
+  globalThis.fetch = mock(
+    async () => new Response('{"title":"T"}', { status: 404, statusText: 'Not Found' }),
+  ) as unknown as typeof fetch;
+
+  expect(await requestJson('http://localhost:4096/session/s1')).toEqual({
+    ok: false,
+    status: 404,
+    statusText: 'Not Found',
+    body: { title: 'T' },
+  });
+});
+
+test('requestJson resolves to null when the server never answers', async () => {
+  globalThis.fetch = mock(
+    (_url: string, init?: RequestInit) =>
+      new Promise<Response>((_, reject) => {
+        init?.signal?.addEventListener('abort', () => reject(new Error('aborted')));
+      }),
+  ) as unknown as typeof fetch;
+
+  expect(await requestJson('http://localhost:4096/health', { timeoutMs: 10 })).toBeNull();
+});
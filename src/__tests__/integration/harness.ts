@@ -0,0 +1,103 @@
+import { spawnSync } from 'node:child_process';
+import { mkdtempSync, rmSync } from 'node:fs';
+import { tmpdir } from 'node:os';
+import { join } from 'node:path';
+import { writeFakeOpencodeBin } from '../opencode-fake';
+
+/**
+ * Helpers for integration tests that drive a real tmux server. They only run
+ * with OPENTMUX_INTEGRATION=1 (see `bun run test:integration`), since they need
+ * tmux installed and start real processes.
+ */
+
+export const INTEGRATION_ENABLED = process.env.OPENTMUX_INTEGRATION === '1';
+
+export const TEST_SOCKET = 'opentmux-test';
+
+export interface TmuxTestServer {
+  /** The pane standing in for opencode's own pane */
+  mainPaneId: string;
+  /** Runs a tmux command against the isolated server and returns stdout */
+  tmux(args: string[]): string;
+  /** Pane ids in the test window, in tmux's order */
+  listPanes(): string[];
+  stop(): void;
+}
+
+function runTmux(args: string[], env?: NodeJS.ProcessEnv): string {
+  const result = spawnSync('tmux', ['-L', TEST_SOCKET, ...args], { encoding: 'utf-8', env });
+  if (result.status !== 0) {
+    throw new Error(`tmux ${args.join(' ')} failed: ${result.stderr?.trim()}`);
+  }
+  return result.stdout;
+}
+
+/**
+ * Starts an isolated tmux server (`tmux -L opentmux-test`) with one 200x50
+ * window, and points TMUX/TMUX_PANE at it so opentmux treats the test process
+ * as opencode running in that window.
+ *
+ * Agent panes run a fake `opencode` script that just sleeps, so no opencode
+ * install is needed.
+ */
+export function startTmuxServer(): TmuxTestServer {
+  const binDir = mkdtempSync(join(tmpdir(), 'opentmux-test-bin-'));
+  writeFakeOpencodeBin(binDir);
+
+  const env = { ...process.env, PATH: `${binDir}:${process.env.PATH ?? ''}` };
+  delete env.TMUX;
+  delete env.TMUX_PANE;
+
+  // A server left over from an aborted run
+  spawnSync('tmux', ['-L', TEST_SOCKET, 'kill-server']);
+  runTmux(
+    ['-f', '/dev/null', 'new-session', '-d', '-s', TEST_SOCKET, '-x', '200', '-y', '50'],
+    env,
+  );
+  const [socketPath, serverPid, sessionId, mainPaneId] = runTmux([
+    'display-message',
+    '-p',
+    '-t',
+    TEST_SOCKET,
+    '#{socket_path} #{pid} #{session_id} #{pane_id}',
+  ])
+    .trim()
+    .split(' ');
+
+  const originalTmux = process.env.TMUX;
+  const originalPane = process.env.TMUX_PANE;
+  const originalPath = process.env.PATH;
+  // tmux gives new panes the PATH of the client that created them
+  process.env.PATH = env.PATH;
+  process.env.TMUX = `${socketPath},${serverPid},${sessionId.replace('$', '')}`;
+  process.env.TMUX_PANE = mainPaneId;
+
+  return {
+    mainPaneId,
+    tmux: (args) => runTmux(args),
+    listPanes: () =>
+      runTmux(['list-panes', '-t', mainPaneId, '-F', '#{pane_id}']).trim().split('\n'),
+    stop: () => {
+      spawnSync('tmux', ['-L', TEST_SOCKET, 'kill-server']);
+      rmSync(binDir, { recursive: true, force: true });
+      process.env.PATH = originalPath;
+      if (originalTmux === undefined) delete process.env.TMUX;
+      else process.env.TMUX = originalTmux;
+      if (originalPane === undefined) delete process.env.TMUX_PANE;
+      else process.env.TMUX_PANE = originalPane;
+    },
+  };
+}
+
+/**
+ * Polls until the condition holds, for tmux state that settles asynchronously.
+ */
+export async function waitFor(condition: () => boolean, timeoutMs = 5000): Promise<void> {
+  const start = Date.now();
+  while (!condition()) {
+    if (Date.now() - start > timeoutMs) {
+      throw new Error('waitFor timed out');
+    }
+    await new Promise((r) => setTimeout(r, 50));
+  }
+}
@@ -0,0 +1,148 @@
+import { afterAll, afterEach, beforeAll, beforeEach, describe, expect, test } from 'bun:test';
+import { TmuxConfigSchema, type TmuxConfig } from '../../config';
+import { TmuxSessionManager } from '../../tmux-session-manager';
+import type { PluginInput } from '../../types';
+import { FakeOpencodeServer } from '../opencode-fake';
+import {
+  applyTmuxLayout,
+  closeTmuxPane,
+  resetServerCheck,
+  resetTmuxPathCache,
+  spawnTmuxPane,
+} from '../../utils/tmux';
+import { ZombieReaper } from '../../zombie-reaper';
+import { INTEGRATION_ENABLED, startTmuxServer, waitFor, type TmuxTestServer } from './harness';
+
+function createConfig(overrides?: Partial<TmuxConfig>): TmuxConfig {
+  return TmuxConfigSchema.parse({
+    spawn_delay_ms: 50,
+    layout_debounce_ms: 50,
+    reaper_enabled: false,
+    ...overrides,
+  });
+}
+
+const describeIntegration = INTEGRATION_ENABLED ? describe : describe.skip;
+
+describeIntegration('tmux integration', () => {
+  let server: TmuxTestServer;
+  let opencode: FakeOpencodeServer;
+  let opencodeUrl: string;
+
+  beforeAll(async () => {
+    opencode = new FakeOpencodeServer();
+    opencodeUrl = await opencode.start();
+  });
+
+  afterAll(async () => {
+    await opencode.stop();
+  });
+
+  beforeEach(() => {
+    resetServerCheck();
+    resetTmuxPathCache();
+    server = startTmuxServer();
+  });
+
+  afterEach(() => {
+    server.stop();
+  });
+
+  test('spawnTmuxPane opens an agent pane and closeTmuxPane removes it', async () => {
+    const result = await spawnTmuxPane('ses_spawn', 'Spawn', createConfig(), opencodeUrl);
+
+    expect(result.success).toBe(true);
+    expect(server.listPanes()).toEqual([server.mainPaneId, result.paneId!]);
+    const command = server.tmux(['display-message', '-p', '-t', result.paneId!, '#{pane_start_command}']);
+    expect(command).toContain(`opencode attach ${opencodeUrl} --session ses_spawn`);
+
+    expect(await closeTmuxPane(result.paneId!)).toBe(true);
+    expect(server.listPanes()).toEqual([server.mainPaneId]);
+  });
+
+  test('applyTmuxLayout keeps the main pane on the left at its configured width', async () => {
+    const config = createConfig({ main_pane_size_unit: 'cells', main_pane_width: 120 });
+    await spawnTmuxPane('ses_a', 'A', config, opencodeUrl);
+    await spawnTmuxPane('ses_b', 'B', config, opencodeUrl);
+    await applyTmuxLayout();
+
+    const geometry = server
+      .tmux(['list-panes', '-t', server.mainPaneId, '-F', '#{pane_id} #{pane_left} #{pane_width}'])
+      .trim()
+      .split('\n')
+      .map((line) => line.split(' '));
+    expect(geometry[0]).toEqual([server.mainPaneId, '0', '120']);
+    for (const [, left] of geometry.slice(1)) {
+      expect(Number(left)).toBeGreaterThan(120);
+    }
+  });
+
+  test('group_by_parent gives each parent its own agents window', async () => {
+    const config = createConfig({ group_by_parent: true });
+    const first = { key: 'ses_p1', name: 'Parent one' };
+    const second = { key: 'ses_p2', name: 'Parent two' };
+    const a = await spawnTmuxPane('ses_a', 'A', config, opencodeUrl, undefined, first);
+    const b = await spawnTmuxPane('ses_b', 'B', config, opencodeUrl, undefined, first);
+    const c = await spawnTmuxPane('ses_c', 'C', config, opencodeUrl, undefined, second);
+
+    const windowOf = (paneId: string) =>
+      server.tmux(['display-message', '-p', '-t', paneId, '#{window_id} #{window_name}']).trim();
+    expect(windowOf(a.paneId!)).toBe(windowOf(b.paneId!));
+    expect(windowOf(a.paneId!)).toEndWith('Parent one');
+    expect(windowOf(c.paneId!)).toEndWith('Parent two');
+    expect(server.listPanes()).toEqual([server.mainPaneId]);
+
+    await closeTmuxPane(c.paneId!);
+    const windows = server.tmux(['list-windows', '-F', '#{window_name}']).trim().split('\n');
+    expect(windows).not.toContain('Parent two');
+  });
+
+  test('the manager opens a pane for a child session and closes it on delete', async () => {
+    const ctx: PluginInput = {
+      directory: process.cwd(),
+      serverUrl: opencodeUrl,
+      client: opencode.client(),
+    };
+    const manager = new TmuxSessionManager(ctx, createConfig(), opencodeUrl);
+    const handled: Promise<void>[] = [];
+    const unsubscribe = opencode.subscribe((event) => handled.push(manager.handleEvent(event)));
+
+    try {
+      await opencode.play([
+        { afterMs: 0, action: 'create', id: 'ses_parent' },
+        { afterMs: 0, action: 'create', id: 'ses_child', parentID: 'ses_parent', title: 'Child' },
+      ]);
+      await Promise.all(handled);
+      expect(server.listPanes()).toHaveLength(2);
+
+      await opencode.play([{ afterMs: 0, action: 'delete', id: 'ses_child' }]);
+      await Promise.all(handled);
+      await waitFor(() => server.listPanes().length === 1);
+    } finally {
+      unsubscribe();
+      opencode.sessions.clear();
+      await manager.cleanup();
+    }
+  });
+
+  test('the reaper kills attach processes for sessions the server no longer knows', async () => {
+    opencode.createSession({ id: 'ses_live', parentID: 'ses_parent' });
+    const config = createConfig();
+    const live = await spawnTmuxPane('ses_live', 'Live', config, opencodeUrl);
+    const gone = await spawnTmuxPane('ses_gone', 'Gone', config, opencodeUrl);
+    const reaper = new ZombieReaper(opencodeUrl, {
+      enabled: true,
+      intervalMs: 0,
+      minZombieChecks: 1,
+      gracePeriodMs: 0,
+    });
+
+    try {
+      await reaper.scanOnce();
+      await waitFor(() => !server.listPanes().includes(gone.paneId!));
+      expect(server.listPanes()).toContain(live.paneId!);
+    } finally {
+      opencode.sessions.clear();
+    }
+  });
+});
@@ -0,0 +1,41 @@
+import { test, expect } from 'bun:test';
+import { buildBindKeyCommands, KEY_TABLE, resolveJumpTarget } from '../utils/keybindings';
+
+const panes = [
+  { paneId: '%1', active: false },
+  { paneId: '%2', active: true },
+  { paneId: '%3', active: false },
+];
+
+test('resolveJumpTarget picks agent panes by number', () => {
+  expect(resolveJumpTarget(panes, '1')).toBe('%1');
+  expect(resolveJumpTarget(panes, '3')).toBe('%3');
+  expect(resolveJumpTarget(panes, '4')).toBeNull();
+  expect(resolveJumpTarget([], '1')).toBeNull();
+});
+
+test('resolveJumpTarget cycles with next and prev', () => {
+  expect(resolveJumpTarget(panes, 'next')).toBe('%3');
+  expect(resolveJumpTarget(panes, 'prev')).toBe('%1');
+
+  const lastActive = panes.map((pane) => ({ ...pane, active: pane.paneId === '%3' }));
+  expect(resolveJumpTarget(lastActive, 'next')).toBe('%1');
+});
+
+test('resolveJumpTarget starts from the ends when no agent pane is active', () => {
+  const noneActive = panes.map((pane) => ({ ...pane, active: false }));
+  expect(resolveJumpTarget(noneActive, 'next')).toBe('%1');
+  expect(resolveJumpTarget(noneActive, 'prev')).toBe('%3');
+});
+
+test('buildBindKeyCommands installs a key table that calls back into opentmux', () => {
+  const commands = buildBindKeyCommands('opentmux', 'a');
+
+  expect(commands[0]).toEqual(['bind-key', 'a', 'switch-client', '-T', KEY_TABLE]);
+  expect(commands).toContainEqual([
+    'bind-key', '-T', KEY_TABLE, '1', 'run-shell', "opentmux jump 1 '#{window_id}'",
+  ]);
+  expect(commands).toContainEqual([
+    'bind-key', '-T', KEY_TABLE, 'n', 'run-shell', "opentmux jump next '#{window_id}'",
+  ]);
+});
@@ -1,8 +1,13 @@
 import { expect, test } from 'bun:test';
 
 import {
+  autoLayoutForSize,
   buildMainVerticalMultiColumnLayoutString,
+  groupIntoGridColumns,
   layoutChecksum,
+  mainPaneCells,
+  maxAgentsPerColumnForHeight,
+  narrowestAgentColumnWidth,
 } from '../layout';
 
 test('layoutChecksum matches tmux layout_checksum', () => {
@@ -29,3 +34,53 @@ test('buildMainVerticalMultiColumnLayoutString prefixes correct checksum', () =>
   const computed = layoutChecksum(layout).toString(16).padStart(4, '0');
   expect(checksumHex).toBe(computed);
 });
+
+test('maxAgentsPerColumnForHeight caps stacked panes to the minimum height', () => {
+  expect(maxAgentsPerColumnForHeight(24, 3, 0)).toBe(3);
+  expect(maxAgentsPerColumnForHeight(24, 3, 8)).toBe(2);
+  expect(maxAgentsPerColumnForHeight(24, 3, 40)).toBe(1);
+});
+
+test('narrowestAgentColumnWidth matches the multi-column layout sizing', () => {
+  // 100 cols, 45% main => main 45, right 54, two columns of 26 + 27 with a separator
+  expect(narrowestAgentColumnWidth(100, 45, 2)).toBe(26);
+  expect(narrowestAgentColumnWidth(100, 45, 0)).toBe(0);
+  // An absolute main width of 39 leaves 60 cells => two columns of 29 + 30
+  expect(narrowestAgentColumnWidth(100, 45, 2, 39)).toBe(29);
+});
+
+test('mainPaneCells converts percent and cell sizes and keeps room for agents', () => {
+  expect(mainPaneCells(60, 'percent', 200)).toBe(120);
+  expect(mainPaneCells(100, 'cells', 200)).toBe(100);
+  expect(mainPaneCells(300, 'cells', 200)).toBe(198);
+  expect(mainPaneCells(0, 'percent', 200)).toBe(1);
+});
+
+test('buildMainVerticalMultiColumnLayoutString uses an absolute main pane width', () => {
+  const built = buildMainVerticalMultiColumnLayoutString({
+    windowWidth: 200,
+    windowHeight: 50,
+    mainPaneWpId: 1,
+    columns: [[2]],
+    mainPanePercent: 60,
+    mainPaneWidth: 100,
+  });
+  expect(built).toContain('{100x50,0,0,1,99x50,101,0,2}');
+});
+
+test('groupIntoGridColumns fills columns top to bottom without rebalancing', () => {
+  expect(groupIntoGridColumns(['a', 'b', 'c', 'd'], 2)).toEqual([
+    ['a', 'b'],
+    ['c', 'd'],
+  ]);
+  expect(groupIntoGridColumns(['a', 'b', 'c'], 2)).toEqual([['a', 'b'], ['c']]);
+  expect(groupIntoGridColumns([], 2)).toEqual([]);
+  expect(() => groupIntoGridColumns(['a'], 0)).toThrow();
+});
+
+test('autoLayoutForSize prefers main-vertical, then main-horizontal, then tiled', () => {
+  expect(autoLayoutForSize(200, 50, 160, 40)).toBe('main-vertical');
+  expect(autoLayoutForSize(160, 20, 160, 40)).toBe('main-vertical');
+  expect(autoLayoutForSize(120, 40, 160, 40)).toBe('main-horizontal');
+  expect(autoLayoutForSize(120, 39, 160, 40)).toBe('tiled');
+});
@@ -0,0 +1,64 @@
+import { afterEach, expect, spyOn, test } from 'bun:test';
+import { createSocket } from 'node:dgram';
+import { createMetricsSink } from '../utils/metrics';
+
+const originalFetch = globalThis.fetch;
+
+afterEach(() => {
+  globalThis.fetch = originalFetch;
+});
+
+test('createMetricsSink returns null when unset or unsupported', () => {
+  expect(createMetricsSink(undefined)).toBeNull();
+  expect(createMetricsSink('')).toBeNull();
+  expect(createMetricsSink('graphite://localhost:2003')).toBeNull();
+});
+
+test('statsd sink sends counters and timers as statsd lines over UDP', async () => {
+  const server = createSocket('udp4');
+  const received: string[] = [];
+  await new Promise<void>((resolve) => server.bind(0, '127.0.0.1', resolve));
+  server.on('message', (message) => received.push(message.toString()));
+  const sink = createMetricsSink(`statsd://127.0.0.1:${server.address().port}/ci.agents`);
+
+  try {
+    sink?.increment('spawn.success');
+    sink?.timing('spawn.duration', 120.4);
+    const deadline = Date.now() + 1000;
+    while (received.length < 2 && Date.now() < deadline) {
+      await new Promise((r) => setTimeout(r, 10));
+    }
+  } finally {
+    sink?.close();
+    server.close();
+  }
+
+  expect(received).toEqual(['ci.agents.spawn.success:1|c', 'ci.agents.spawn.duration:120|ms']);
+});
+
+test('pushgateway sink pushes accumulated counters and timings', async () => {
+  const fetchSpy = spyOn(globalThis, 'fetch').mockResolvedValue(new Response('', { status: 200 }));
+  const sink = createMetricsSink('pushgateway+http://gateway:9091');
+
+  sink?.increment('spawn.success');
+  sink?.increment('spawn.success');
+  sink?.increment('spawn.failure');
+  sink?.timing('spawn.duration', 120);
+  sink?.timing('spawn.duration', 80);
+  await sink?.flush();
+
+  expect(fetchSpy).toHaveBeenCalledTimes(1);
+  const [url, init] = fetchSpy.mock.calls[0] as [string, RequestInit];
+  expect(url).toStartWith('http://gateway:9091/metrics/job/opentmux/instance/');
+  expect(init.method).toBe('PUT');
+  expect(init.body).toContain('opentmux_spawn_success_total 2');
+  expect(init.body).toContain('opentmux_spawn_failure_total 1');
+  expect(init.body).toContain('opentmux_spawn_duration_ms_sum 200');
+  expect(init.body).toContain('opentmux_spawn_duration_ms_count 2');
+});
+
+test('pushgateway sink skips the push when nothing was recorded', async () => {
+  const fetchSpy = spyOn(globalThis, 'fetch').mockResolvedValue(new Response('', { status: 200 }));
+  await createMetricsSink('pushgateway+http://gateway:9091')?.flush();
+  expect(fetchSpy).not.toHaveBeenCalled();
+});
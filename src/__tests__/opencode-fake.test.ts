@@ -0,0 +1,81 @@
+import { afterEach, expect, test } from 'bun:test';
+import { get, request } from 'node:http';
+import { FakeOpencodeServer, swarmScript, type FakeEvent } from './opencode-fake';
+
+// Plain node:http, since other test files replace globalThis.fetch
+function call(url: string, method = 'GET'): Promise<{ status: number; body: unknown }> {
+  return new Promise((resolve, reject) => {
+    const req = (method === 'GET' ? get : request)(url, { method }, (res) => {
+      let data = '';
+      res.on('data', (chunk) => (data += chunk));
+      res.on('end', () => resolve({ status: res.statusCode ?? 0, body: JSON.parse(data) }));
+    });
+    req.on('error', reject);
+    if (method !== 'GET') req.end();
+  });
+}
+
+let fake: FakeOpencodeServer | undefined;
+
+afterEach(async () => {
+  await fake?.stop();
+  fake = undefined;
+});
+
+test('play applies scripted transitions and emits plugin events', async () => {
+  fake = new FakeOpencodeServer();
+  const events: FakeEvent[] = [];
+  fake.subscribe((event) => events.push(event));
+
+  await fake.play([
+    { afterMs: 0, action: 'create', id: 'ses_parent' },
+    { afterMs: 0, action: 'create', id: 'ses_a', parentID: 'ses_parent', title: 'A' },
+    { afterMs: 10, action: 'status', id: 'ses_a', status: 'idle' },
+    { afterMs: 0, action: 'delete', id: 'ses_parent' },
+  ]);
+
+  expect(events.map((event) => event.type)).toEqual([
+    'session.created',
+    'session.created',
+    'session.idle',
+    'session.deleted',
+  ]);
+  expect(events[1].properties).toEqual({
+    info: { id: 'ses_a', parentID: 'ses_parent', title: 'A' },
+  });
+  expect(await fake.client().session.status()).toEqual({ data: { ses_a: { type: 'idle' } } });
+});
+
+test('serves health, status, session and abort endpoints', async () => {
+  fake = new FakeOpencodeServer();
+  const url = await fake.start();
+  fake.createSession({ id: 'ses_a', parentID: 'ses_parent', title: 'A' });
+
+  expect((await call(`${url}/health`)).status).toBe(200);
+  expect((await call(`${url}/session/status`)).body).toEqual({ ses_a: { type: 'busy' } });
+  expect((await call(`${url}/session/ses_a`)).body).toEqual({
+    id: 'ses_a',
+    parentID: 'ses_parent',
+    title: 'A',
+  });
+  expect((await call(`${url}/session/ses_missing`)).status).toBe(404);
+
+  expect((await call(`${url}/session/ses_a/abort`, 'POST')).status).toBe(200);
+  expect(fake.sessions.get('ses_a')).toMatchObject({ aborted: true, status: 'idle' });
+});
+
+test('swarmScript starts every agent, ends each once and deletes the parent last', () => {
+  const steps = swarmScript({ agents: 4, maxLifetimeMs: 9000, random: () => 0.5 });
+
+  expect(steps.filter((step) => step.action === 'create')).toHaveLength(5);
+  expect(steps.filter((step) => step.action === 'forget')).toEqual([
+    { afterMs: expect.any(Number), action: 'forget', id: 'ses_demo_3' },
+  ]);
+  expect(steps.filter((step) => step.action === 'status').map((step) => step.id)).toEqual([
+    'ses_demo_1',
+    'ses_demo_2',
+    'ses_demo_4',
+  ]);
+  expect(steps.at(-1)).toMatchObject({ action: 'delete', id: 'ses_demo_parent' });
+  expect(steps.every((step) => step.afterMs >= 0)).toBe(true);
+});
@@ -0,0 +1,27 @@
+import { afterEach, beforeEach, expect, test } from 'bun:test';
+import * as fs from 'node:fs';
+import * as os from 'node:os';
+import * as path from 'node:path';
+import { getPaneOutputPath, readPaneOutput, savePaneOutput } from '../utils/pane-output';
+
+let dir: string;
+
+beforeEach(() => {
+  dir = fs.mkdtempSync(path.join(os.tmpdir(), 'opentmux-output-'));
+});
+
+afterEach(() => {
+  fs.rmSync(dir, { recursive: true, force: true });
+});
+
+test('savePaneOutput round-trips through readPaneOutput', () => {
+  const savedTo = savePaneOutput('ses_abc', 'line 1\nline 2\n', dir);
+
+  expect(savedTo).toBe(path.join(dir, 'ses_abc.log'));
+  expect(readPaneOutput('ses_abc', dir)).toBe('line 1\nline 2\n');
+  expect(readPaneOutput('ses_missing', dir)).toBeNull();
+});
+
+test('getPaneOutputPath keeps session ids inside the output directory', () => {
+  expect(getPaneOutputPath('../../etc/passwd', dir)).toBe(path.join(dir, '______etc_passwd.log'));
+});
@@ -0,0 +1,22 @@
+import { expect, test } from 'bun:test';
+import { passthroughArgs } from '../utils/passthrough';
+
+test('passthroughArgs attaches run to the project server', () => {
+  expect(passthroughArgs(['run', 'fix the tests'], 4097)).toEqual([
+    'run',
+    'fix the tests',
+    '--attach',
+    'http://localhost:4097',
+  ]);
+});
+
+test('passthroughArgs leaves explicit servers and other commands alone', () => {
+  expect(passthroughArgs(['run', '--attach', 'http://devbox:4096', 'hi'], 4097)).toEqual([
+    'run',
+    '--attach',
+    'http://devbox:4096',
+    'hi',
+  ]);
+  expect(passthroughArgs(['run', '--port=5000', 'hi'], 4097)).toEqual(['run', '--port=5000', 'hi']);
+  expect(passthroughArgs(['auth', 'login'], 4097)).toEqual(['auth', 'login']);
+});
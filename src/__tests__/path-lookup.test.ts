@@ -0,0 +1,51 @@
+import { afterEach, beforeEach, expect, test } from 'bun:test';
+import * as fs from 'node:fs';
+import * as os from 'node:os';
+import * as path from 'node:path';
+import { findExecutablesOnPath, realPathOrNull } from '../utils/path-lookup';
+
+let dir: string;
+
+function writeFile(relative: string, mode: number): string {
+  const file = path.join(dir, relative);
+  fs.mkdirSync(path.dirname(file), { recursive: true });
+  fs.writeFileSync(file, '#!/bin/sh\n');
+  fs.chmodSync(file, mode);
+  return file;
+}
+
+beforeEach(() => {
+  dir = fs.mkdtempSync(path.join(os.tmpdir(), 'opentmux-path-'));
+});
+
+afterEach(() => {
+  fs.rmSync(dir, { recursive: true, force: true });
+});
+
+test('findExecutablesOnPath lists executables in PATH order, like which -a', () => {
+  const first = writeFile('a/opencode', 0o755);
+  writeFile('b/opencode', 0o644);
+  fs.mkdirSync(path.join(dir, 'c/opencode'), { recursive: true });
+  const second = writeFile('d/opencode', 0o755);
+  const PATH = ['a', 'b', 'c', '', 'd', 'a', 'missing'].map((d) => d && path.join(dir, d)).join(':');
+
+  expect(findExecutablesOnPath('opencode', { PATH }, 'linux')).toEqual([first, second]);
+  expect(findExecutablesOnPath('opencode', {}, 'linux')).toEqual([]);
+});
+
+test('findExecutablesOnPath tries PATHEXT extensions on Windows', () => {
+  const exe = writeFile('bin/opencode.exe', 0o644);
+  const env = { Path: path.join(dir, 'bin'), PATHEXT: '.EXE;.CMD' };
+
+  expect(findExecutablesOnPath('opencode', env, 'win32')).toEqual([exe]);
+});
+
+test('realPathOrNull resolves symlinks and tolerates missing files', () => {
+  const target = writeFile('real/opentmux', 0o755);
+  const link = path.join(dir, 'opencode');
+  fs.symlinkSync(target, link);
+
+  expect(realPathOrNull(link)).toBe(fs.realpathSync(target));
+  expect(realPathOrNull(path.join(dir, 'missing'))).toBeNull();
+  expect(realPathOrNull(undefined)).toBeNull();
+});
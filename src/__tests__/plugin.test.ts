@@ -0,0 +1,74 @@
+import { test, expect, beforeEach, afterEach, mock, spyOn } from 'bun:test';
+import OpencodeAgentTmux from '../index';
+import { TmuxSessionManager } from '../tmux-session-manager';
+import * as utils from '../utils';
+
+const client = {
+  session: {
+    status: async () => ({ data: {} }),
+    subscribe: () => () => {},
+  },
+};
+
+function initPlugin() {
+  return OpencodeAgentTmux({
+    client,
+    directory: '/nonexistent/opentmux-plugin-test',
+    serverUrl: new URL('http://localhost:4096'),
+  } as unknown as Parameters<typeof OpencodeAgentTmux>[0]);
+}
+
+beforeEach(() => {
+  spyOn(utils, 'log').mockImplementation(() => {});
+  spyOn(utils, 'isInsideTmux').mockReturnValue(false);
+});
+
+afterEach(() => {
+  mock.restore();
+});
+
+test('a failed handover still leaves a working plugin that old hooks forward to', async () => {
+  const first = await initPlugin();
+
+  spyOn(TmuxSessionManager.prototype, 'handOver').mockImplementation(async () => {
+    throw new Error('tmux went away');
+  });
+  const second = await initPlugin();
+
+  const handled: TmuxSessionManager[] = [];
+  spyOn(TmuxSessionManager.prototype, 'handleEvent').mockImplementation(async function (
+    this: TmuxSessionManager,
+  ) {
+    handled.push(this);
+  });
+
+  const event = { type: 'session.idle', properties: { sessionID: 'child' } };
+  await second.event?.({ event } as never);
+  await first.event?.({ event } as never);
+
+  expect(handled).toHaveLength(2);
+  expect(handled[1]).toBe(handled[0]);
+});
+
+test('the plugin pins its tmux session before the manager can run commands', async () => {
+  // Inside tmux for the plugin's own check; the manager then stays disabled
+  spyOn(utils, 'isInsideTmux').mockReturnValueOnce(true).mockReturnValue(false);
+  spyOn(utils, 'startTmuxCheck').mockImplementation(() => {});
+  spyOn(utils, 'setVersionOption').mockResolvedValue(true);
+  let recorded: (session: string) => void = () => {};
+  spyOn(utils, 'recordTmuxSession').mockImplementation(
+    () => new Promise<string>((resolve) => (recorded = resolve)),
+  );
+
+  let initialized = false;
+  const plugin = initPlugin().then((hooks) => {
+    initialized = true;
+    return hooks;
+  });
+  await new Promise((resolve) => setTimeout(resolve, 10));
+  expect(initialized).toBe(false);
+
+  recorded('$3');
+  await plugin;
+  expect(initialized).toBe(true);
+});
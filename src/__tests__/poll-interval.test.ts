@@ -0,0 +1,32 @@
+import { test, expect } from 'bun:test';
+import {
+  computePollInterval,
+  FAST_POLL_WINDOW_MS,
+  SLOW_POLL_AFTER_BUSY_MS,
+} from '../poll-interval';
+
+const bounds = { minMs: 500, maxMs: 10_000 };
+const now = 1_000_000;
+
+test('computePollInterval polls fast right after a spawn', () => {
+  expect(computePollInterval({ now, lastSpawnAt: now - 1000, allBusySince: null }, bounds)).toBe(500);
+});
+
+test('computePollInterval uses the normal interval once the spawn window passes', () => {
+  const activity = { now, lastSpawnAt: now - FAST_POLL_WINDOW_MS, allBusySince: null };
+  expect(computePollInterval(activity, bounds)).toBe(2000);
+});
+
+test('computePollInterval backs off when all sessions stay busy', () => {
+  const activity = { now, lastSpawnAt: null, allBusySince: now - SLOW_POLL_AFTER_BUSY_MS };
+  expect(computePollInterval(activity, bounds)).toBe(10_000);
+
+  const recentlyBusy = { now, lastSpawnAt: null, allBusySince: now - 1000 };
+  expect(computePollInterval(recentlyBusy, bounds)).toBe(2000);
+});
+
+test('computePollInterval keeps the normal interval within the bounds', () => {
+  const activity = { now, lastSpawnAt: null, allBusySince: null };
+  expect(computePollInterval(activity, { minMs: 3000, maxMs: 8000 })).toBe(3000);
+  expect(computePollInterval(activity, { minMs: 200, maxMs: 1000 })).toBe(1000);
+});
@@ -0,0 +1,22 @@
+import { test, expect } from 'bun:test';
+import { PluginConfigSchema } from '../config';
+import { formatPortRange, getCandidatePorts, resolvePortRange } from '../utils/ports';
+
+test('resolvePortRange falls back to port + max_ports', () => {
+  const config = PluginConfigSchema.parse({ port: 5000, max_ports: 3 });
+  expect(resolvePortRange(config)).toEqual({ start: 5000, end: 5003 });
+});
+
+test('getCandidatePorts honors port_range and port_exclude', () => {
+  const config = PluginConfigSchema.parse({
+    port_range: { start: 4096, end: 4102 },
+    port_exclude: [4097, 4100],
+  });
+
+  expect(getCandidatePorts(config)).toEqual([4096, 4098, 4099, 4101, 4102]);
+  expect(formatPortRange(config)).toBe('4096-4102 (excluding 4097, 4100)');
+});
+
+test('PluginConfigSchema rejects an inverted port_range', () => {
+  expect(() => PluginConfigSchema.parse({ port_range: { start: 5000, end: 4000 } })).toThrow();
+});
@@ -4,11 +4,25 @@ import {
   isProcessAlive,
   getProcessCommand,
   getProcessChildren,
+  getProcessGroupId,
   safeKill,
+  terminateProcess,
   waitForProcessExit,
   findProcessIds
 } from '../utils/process';
 
+/** A node process that ignores the given signals, once its handlers are installed */
+async function spawnIgnoring(signals: NodeJS.Signals[]) {
+  const handlers = signals.map((signal) => `process.on('${signal}', () => {});`).join('');
+  const proc = spawn(process.execPath, ['-e', `${handlers}setInterval(() => {}, 1000);`]);
+  await new Promise((resolve) => setTimeout(resolve, 300));
+  return proc;
+}
+
+function exitSignal(proc: ReturnType<typeof spawn>): Promise<NodeJS.Signals | null> {
+  return new Promise((resolve) => proc.once('exit', (_code, signal) => resolve(signal)));
+}
+
 describe('Process Utilities', () => {
   let childPid: number;
   let childProcess: any;
@@ -70,6 +84,42 @@ describe('Process Utilities', () => {
     expect(end - start).toBeLessThan(1100);
   });
   
+  test('terminateProcess escalates to SIGKILL when SIGTERM is ignored', async () => {
+    const proc = await spawnIgnoring(['SIGTERM']);
+    const signal = exitSignal(proc);
+
+    const stopped = await terminateProcess(proc.pid as number, { graceMs: 200, interruptFirst: false });
+
+    expect(stopped).toBe(true);
+    expect(await signal).toBe('SIGKILL');
+  });
+
+  test('terminateProcess sends SIGINT first when asked to', async () => {
+    const proc = await spawnIgnoring(['SIGTERM']);
+    const signal = exitSignal(proc);
+
+    const stopped = await terminateProcess(proc.pid as number, { graceMs: 1000, interruptFirst: true });
+
+    expect(stopped).toBe(true);
+    expect(await signal).toBe('SIGINT');
+  });
+
+  test('terminateProcess with group stops the processes the target started', async () => {
+    for (const detached of [true, false]) {
+      const proc = spawn('sh', ['-c', 'sleep 31 & wait'], { detached });
+      const pid = proc.pid as number;
+      await new Promise((resolve) => setTimeout(resolve, 200));
+      const [grandchild] = getProcessChildren(pid);
+      expect(grandchild).toBeDefined();
+      expect(getProcessGroupId(pid) === pid).toBe(detached);
+
+      const stopped = await terminateProcess(pid, { graceMs: 1000, interruptFirst: false }, { group: true });
+
+      expect(stopped).toBe(true);
+      expect(isProcessAlive(grandchild)).toBe(false);
+    }
+  });
+
   test('findProcessIds returns matching pids', () => {
     const pids = findProcessIds('sleep 103');
     expect(pids).toContain(childPid);
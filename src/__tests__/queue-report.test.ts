@@ -0,0 +1,12 @@
+import { expect, test } from 'bun:test';
+import { parseQueueReport } from '../utils/queue-report';
+
+test('parseQueueReport keeps well-formed entries', () => {
+  const entry = { sessionId: 's1', title: 'T1', state: 'queued', since: 1000, retries: 1 };
+  expect(parseQueueReport(JSON.stringify([entry, { sessionId: 's2' }]))).toEqual([entry]);
+});
+
+test('parseQueueReport rejects anything but a JSON array', () => {
+  expect(parseQueueReport('')).toBeNull();
+  expect(parseQueueReport('{"sessionId":"s1"}')).toBeNull();
+});
@@ -0,0 +1,32 @@
+import { test, expect } from 'bun:test';
+import { TmuxConfigSchema } from '../config';
+import { isQuietTime } from '../utils/quiet-hours';
+
+const at = (hours: number, minutes = 0) => new Date(2026, 0, 15, hours, minutes);
+
+test('isQuietTime handles ranges within a day', () => {
+  expect(isQuietTime(['12:00-13:30'], at(12))).toBe(true);
+  expect(isQuietTime(['12:00-13:30'], at(13, 29))).toBe(true);
+  expect(isQuietTime(['12:00-13:30'], at(13, 30))).toBe(false);
+  expect(isQuietTime(['12:00-13:30'], at(11, 59))).toBe(false);
+});
+
+test('isQuietTime handles ranges that run past midnight', () => {
+  expect(isQuietTime(['22:00-08:00'], at(23))).toBe(true);
+  expect(isQuietTime(['22:00-08:00'], at(0))).toBe(true);
+  expect(isQuietTime(['22:00-08:00'], at(7, 59))).toBe(true);
+  expect(isQuietTime(['22:00-08:00'], at(8))).toBe(false);
+  expect(isQuietTime(['22:00-08:00'], at(21, 59))).toBe(false);
+});
+
+test('isQuietTime checks every range and ignores empty ones', () => {
+  expect(isQuietTime([], at(3))).toBe(false);
+  expect(isQuietTime(['09:00-09:00'], at(9))).toBe(false);
+  expect(isQuietTime(['01:00-02:00', '12:00-13:00'], at(12, 15))).toBe(true);
+});
+
+test('TmuxConfigSchema rejects malformed quiet_hours', () => {
+  expect(TmuxConfigSchema.parse({}).quiet_hours).toEqual([]);
+  expect(() => TmuxConfigSchema.parse({ quiet_hours: ['22:00'] })).toThrow();
+  expect(() => TmuxConfigSchema.parse({ quiet_hours: ['24:00-08:00'] })).toThrow();
+});
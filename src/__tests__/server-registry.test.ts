@@ -0,0 +1,46 @@
+import { afterEach, beforeEach, expect, test } from 'bun:test';
+import { spawn, type ChildProcess } from 'node:child_process';
+import * as fs from 'node:fs';
+import * as os from 'node:os';
+import * as path from 'node:path';
+import { isOpentmuxServer, recordServer } from '../utils/server-registry';
+
+const originalStateHome = process.env.XDG_STATE_HOME;
+let stateHome: string;
+let server: ChildProcess;
+
+beforeEach(() => {
+  stateHome = fs.mkdtempSync(path.join(os.tmpdir(), 'opentmux-registry-'));
+  process.env.XDG_STATE_HOME = stateHome;
+  // Stands in for a server started before servers were tagged with OPENTMUX_OWNER
+  server = spawn('sleep', ['30']);
+});
+
+afterEach(() => {
+  server.kill('SIGKILL');
+  if (originalStateHome === undefined) delete process.env.XDG_STATE_HOME;
+  else process.env.XDG_STATE_HOME = originalStateHome;
+  fs.rmSync(stateHome, { recursive: true, force: true });
+});
+
+test('isOpentmuxServer falls back to the server registry for untagged servers', () => {
+  const pid = server.pid as number;
+  expect(isOpentmuxServer(pid, 4096)).toBe(false);
+
+  recordServer({ port: 4096, pid, project: '/p', launcherPid: process.pid, startedAt: Date.now() });
+  expect(isOpentmuxServer(pid, 4096)).toBe(true);
+  expect(isOpentmuxServer(pid, 4097)).toBe(false);
+});
+
+test('isOpentmuxServer ignores a registry record written long before the process started', () => {
+  const pid = server.pid as number;
+  recordServer({
+    port: 4096,
+    pid: null,
+    project: '/p',
+    launcherPid: process.pid,
+    startedAt: Date.now() - 3_600_000,
+  });
+
+  expect(isOpentmuxServer(pid, 4096)).toBe(false);
+});
@@ -0,0 +1,22 @@
+import { test, expect } from 'bun:test';
+import { isSameServer, parseServerAddress } from '../utils/server-url';
+
+test('parseServerAddress applies scheme defaults and ignores the path', () => {
+  expect(parseServerAddress('localhost:4096')).toEqual({ host: '127.0.0.1', port: 4096 });
+  expect(parseServerAddress('http://example.com/api/')).toEqual({ host: 'example.com', port: 80 });
+  expect(parseServerAddress('https://Example.com')).toEqual({ host: 'example.com', port: 443 });
+  expect(parseServerAddress('')).toBeNull();
+  expect(parseServerAddress('ftp://localhost:21')).toBeNull();
+});
+
+test('isSameServer treats loopback spellings as one host', () => {
+  expect(isSameServer('http://localhost:4096', 'http://127.0.0.1:4096/')).toBe(true);
+  expect(isSameServer('http://[::1]:4096', '0.0.0.0:4096')).toBe(true);
+  expect(isSameServer('http://127.0.1.1:4096', 'localhost:4096')).toBe(true);
+});
+
+test('isSameServer tells different ports and hosts apart', () => {
+  expect(isSameServer('http://localhost:4096', 'http://localhost:4097')).toBe(false);
+  expect(isSameServer('http://10.0.0.2:4096', 'http://localhost:4096')).toBe(false);
+  expect(isSameServer(null, 'http://localhost:4096')).toBe(false);
+});
@@ -0,0 +1,83 @@
+import { afterEach, beforeEach, expect, test } from 'bun:test';
+import * as fs from 'node:fs';
+import * as os from 'node:os';
+import * as path from 'node:path';
+import {
+  MAX_HISTORY_BYTES,
+  MAX_HISTORY_ENTRIES,
+  appendSessionHistory,
+  getHistoryPath,
+  readSessionHistory,
+  type SessionHistoryEntry,
+} from '../utils/session-history';
+
+let stateHome: string;
+const originalStateHome = process.env.XDG_STATE_HOME;
+
+beforeEach(() => {
+  stateHome = fs.mkdtempSync(path.join(os.tmpdir(), 'opentmux-history-'));
+  process.env.XDG_STATE_HOME = stateHome;
+});
+
+afterEach(() => {
+  if (originalStateHome === undefined) delete process.env.XDG_STATE_HOME;
+  else process.env.XDG_STATE_HOME = originalStateHome;
+  fs.rmSync(stateHome, { recursive: true, force: true });
+});
+
+function entry(n: number): SessionHistoryEntry {
+  return {
+    sessionId: `ses_${n}`,
+    parentId: 'ses_parent',
+    title: `Agent ${n}`,
+    paneId: `%${n}`,
+    spawnedAt: 1000 * n,
+    closedAt: 1000 * n + 500,
+    reason: 'idle',
+    attempts: 1,
+  };
+}
+
+test('readSessionHistory returns entries oldest first and honors limit', () => {
+  expect(readSessionHistory()).toEqual([]);
+
+  appendSessionHistory(entry(1));
+  appendSessionHistory(entry(2));
+  appendSessionHistory(entry(3));
+
+  expect(readSessionHistory().map((e) => e.sessionId)).toEqual(['ses_1', 'ses_2', 'ses_3']);
+  expect(readSessionHistory(2).map((e) => e.sessionId)).toEqual(['ses_2', 'ses_3']);
+});
+
+test('readSessionHistory skips corrupt lines', () => {
+  appendSessionHistory(entry(1));
+  fs.appendFileSync(getHistoryPath(), '{"sessionId":\nnot json\n');
+  appendSessionHistory(entry(2));
+
+  expect(readSessionHistory().map((e) => e.sessionId)).toEqual(['ses_1', 'ses_2']);
+});
+
+test('appendSessionHistory compacts to the newest entries once the file is too big', () => {
+  let n = 0;
+  let size = 0;
+  while (true) {
+    appendSessionHistory(entry(++n));
+    const next = fs.statSync(getHistoryPath()).size;
+    if (next < size) break;
+    size = next;
+  }
+
+  expect(size).toBeLessThan(MAX_HISTORY_BYTES);
+  const entries = readSessionHistory();
+  expect(entries).toHaveLength(MAX_HISTORY_ENTRIES);
+  expect(entries[entries.length - 1].sessionId).toBe(`ses_${n}`);
+});
+
+test('appendSessionHistory only appends below the size threshold', () => {
+  fs.mkdirSync(path.dirname(getHistoryPath()), { recursive: true });
+  fs.writeFileSync(getHistoryPath(), 'not json\n');
+
+  appendSessionHistory(entry(1));
+
+  expect(fs.readFileSync(getHistoryPath(), 'utf-8')).toBe(`not json\n${JSON.stringify(entry(1))}\n`);
+});
@@ -1,5 +1,6 @@
 import { test, expect, beforeEach, mock } from 'bun:test';
 import { SpawnQueue, type SpawnRequest, type SpawnResult } from '../spawn-queue';
+import { FakeClock } from './fake-clock';
 
 // Helper to create controlled promises for test synchronization
 function createControlledPromise<T>() {
@@ -76,6 +77,23 @@ test('SpawnQueue processes items sequentially', async () => {
   ]);
 });
 
+test('SpawnQueue flush drops waiting items but lets the in-flight spawn finish', async () => {
+  const ctrl = createControlledPromise<SpawnResult>();
+  const spawnFn = mock(async (): Promise<SpawnResult> => ctrl.promise);
+  const queue = new SpawnQueue({ spawnFn, spawnDelayMs: 0 });
+
+  const first = queue.enqueue({ sessionId: 'running', title: 'Running' });
+  const second = queue.enqueue({ sessionId: 'waiting', title: 'Waiting' });
+  await waitFor(() => spawnFn.mock.calls.length === 1);
+
+  expect(queue.flush()).toBe(1);
+  expect(await second).toEqual({ success: false, reason: 'flushed' });
+
+  ctrl.resolve({ success: true, paneId: '%1' });
+  expect((await first).paneId).toBe('%1');
+  expect(spawnFn).toHaveBeenCalledTimes(1);
+});
+
 test('SpawnQueue retries failures with exponential backoff', async () => {
   const attempts: number[] = [];
   const timestamps: number[] = [];
@@ -90,7 +108,12 @@ test('SpawnQueue retries failures with exponential backoff', async () => {
     return { success: true, paneId: '%1' };
   });
 
-  const queue = new SpawnQueue({ spawnFn, spawnDelayMs: 0, maxRetries: 2 });
+  const queue = new SpawnQueue({
+    spawnFn,
+    spawnDelayMs: 0,
+    maxRetries: 2,
+    backoff: { jitter: 0 },
+  });
 
   queue.enqueue({ sessionId: 'retry-test', title: 'Retry Test' });
   await waitFor(() => queue.getPendingCount() === 0);
@@ -175,6 +198,69 @@ test('SpawnQueue getPendingCount reflects queue state', async () => {
   ctrl.resolve({ success: true, paneId: '%1' });
 });
 
+test('SpawnQueue lists the in-flight item before queued ones', async () => {
+  const clock = new FakeClock();
+  const ctrl = createControlledPromise<SpawnResult>();
+  const spawnFn = mock(async (_req: SpawnRequest): Promise<SpawnResult> => ctrl.promise);
+
+  const queue = new SpawnQueue({ spawnFn, spawnDelayMs: 0, clock });
+  expect(queue.list()).toEqual([]);
+
+  queue.enqueue({ sessionId: 's1', title: 'T1' });
+  await clock.advance(0);
+  queue.enqueue({ sessionId: 's2', title: 'T2' });
+
+  expect(queue.list()).toEqual([
+    { sessionId: 's1', title: 'T1', enqueuedAt: clock.now(), retryCount: 0, inFlight: true },
+    { sessionId: 's2', title: 'T2', enqueuedAt: clock.now(), retryCount: 0, inFlight: false },
+  ]);
+
+  ctrl.resolve({ success: true, paneId: '%1' });
+  await clock.advance(0);
+  expect(queue.list()).toEqual([]);
+});
+
+test('SpawnQueue rejects new items when full', async () => {
+  const clock = new FakeClock();
+  const ctrl = createControlledPromise<SpawnResult>();
+  const spawnFn = mock(async (_req: SpawnRequest): Promise<SpawnResult> => ctrl.promise);
+  const queue = new SpawnQueue({ spawnFn, spawnDelayMs: 0, maxQueueDepth: 1, clock });
+
+  queue.enqueue({ sessionId: 's1', title: 'T1' });
+  await clock.advance(0);
+  queue.enqueue({ sessionId: 's2', title: 'T2' });
+  expect(queue.isFull()).toBe(true);
+
+  expect(await queue.enqueue({ sessionId: 's3', title: 'T3' })).toEqual({
+    success: false,
+    reason: 'overflow',
+  });
+  expect(queue.list().map((entry) => entry.sessionId)).toEqual(['s1', 's2']);
+  ctrl.resolve({ success: true, paneId: '%1' });
+});
+
+test('SpawnQueue drops the oldest queued item when full with drop-oldest', async () => {
+  const clock = new FakeClock();
+  const ctrl = createControlledPromise<SpawnResult>();
+  const spawnFn = mock(async (_req: SpawnRequest): Promise<SpawnResult> => ctrl.promise);
+  const queue = new SpawnQueue({
+    spawnFn,
+    spawnDelayMs: 0,
+    maxQueueDepth: 1,
+    overflow: 'drop-oldest',
+    clock,
+  });
+
+  queue.enqueue({ sessionId: 's1', title: 'T1' });
+  await clock.advance(0);
+  const dropped = queue.enqueue({ sessionId: 's2', title: 'T2' });
+  queue.enqueue({ sessionId: 's3', title: 'T3' });
+
+  expect(await dropped).toEqual({ success: false, reason: 'overflow' });
+  expect(queue.list().map((entry) => entry.sessionId)).toEqual(['s1', 's3']);
+  ctrl.resolve({ success: true, paneId: '%1' });
+});
+
 test('SpawnQueue calls onQueueUpdate callback', async () => {
   const updates: number[] = [];
   const ctrl = createControlledPromise<SpawnResult>();
@@ -292,7 +378,7 @@ test('SpawnQueue.enqueue returns Promise that resolves on success', async () =>
 
   const result = await queue.enqueue({ sessionId: 's1', title: 'T1' });
 
-  expect(result).toEqual({ success: true, paneId: '%42' });
+  expect(result).toEqual({ success: true, paneId: '%42', timing: expect.any(Object) });
 });
 
 test('SpawnQueue.enqueue returns Promise that resolves on final failure', async () => {
@@ -304,7 +390,11 @@ test('SpawnQueue.enqueue returns Promise that resolves on final failure', async
 
   const result = await queue.enqueue({ sessionId: 's1', title: 'T1' });
 
-  expect(result).toEqual({ success: false });
+  expect(result).toEqual({
+    success: false,
+    reason: 'tmux_error',
+    timing: expect.any(Object),
+  });
 });
 
 test('SpawnQueue.enqueue returns correct result for each item', async () => {
@@ -328,9 +418,13 @@ test('SpawnQueue.enqueue returns correct result for each item', async () => {
 
   const [result1, result2, result3] = await Promise.all([promise1, promise2, promise3]);
 
-  expect(result1).toEqual({ success: true, paneId: '%1' });
-  expect(result2).toEqual({ success: false });
-  expect(result3).toEqual({ success: true, paneId: '%3' });
+  expect(result1).toEqual({ success: true, paneId: '%1', timing: expect.any(Object) });
+  expect(result2).toEqual({
+    success: false,
+    reason: 'tmux_error',
+    timing: expect.any(Object),
+  });
+  expect(result3).toEqual({ success: true, paneId: '%3', timing: expect.any(Object) });
 });
 
 test('SpawnQueue.enqueue resolves with success after retry succeeds', async () => {
@@ -348,7 +442,7 @@ test('SpawnQueue.enqueue resolves with success after retry succeeds', async () =
   const result = await queue.enqueue({ sessionId: 's1', title: 'T1' });
 
   expect(attempts).toBe(3);
-  expect(result).toEqual({ success: true, paneId: '%recovered' });
+  expect(result).toEqual({ success: true, paneId: '%recovered', timing: expect.any(Object) });
 });
 
 test('SpawnQueue.enqueue handles spawnFn exceptions gracefully', async () => {
@@ -366,7 +460,7 @@ test('SpawnQueue.enqueue handles spawnFn exceptions gracefully', async () => {
   const result = await queue.enqueue({ sessionId: 's1', title: 'T1' });
 
   expect(callCount).toBe(3);
-  expect(result).toEqual({ success: true, paneId: '%fixed' });
+  expect(result).toEqual({ success: true, paneId: '%fixed', timing: expect.any(Object) });
 });
 
 test('SpawnQueue.enqueue promise settles even when all retries throw', async () => {
@@ -378,7 +472,12 @@ test('SpawnQueue.enqueue promise settles even when all retries throw', async ()
 
   const result = await queue.enqueue({ sessionId: 's1', title: 'T1' });
 
-  expect(result).toEqual({ success: false });
+  expect(result).toEqual({
+    success: false,
+    reason: 'tmux_error',
+    error: 'Error: Always fails',
+    timing: expect.any(Object),
+  });
 });
 
 test('SpawnQueue coalesces duplicate sessionId enqueues', async () => {
@@ -402,12 +501,30 @@ test('SpawnQueue coalesces duplicate sessionId enqueues', async () => {
   ctrl.resolve({ success: true, paneId: '%1' });
   const [r1, r2, r3] = await Promise.all([promise1, promise2, promise3]);
 
-  expect(r1).toEqual({ success: true, paneId: '%1' });
-  expect(r2).toEqual({ success: true, paneId: '%1' });
-  expect(r3).toEqual({ success: true, paneId: '%1' });
+  expect(r1).toEqual({ success: true, paneId: '%1', timing: expect.any(Object) });
+  expect(r2).toEqual({ success: true, paneId: '%1', timing: expect.any(Object) });
+  expect(r3).toEqual({ success: true, paneId: '%1', timing: expect.any(Object) });
   expect(callCount).toBe(1);
 });
 
+test('SpawnQueue delivers one result to every waiter under 1000 concurrent enqueues', async () => {
+  const spawned: string[] = [];
+  const spawnFn = mock(async (req: SpawnRequest): Promise<SpawnResult> => {
+    spawned.push(req.sessionId);
+    await Promise.resolve();
+    return { success: true, paneId: `%${req.sessionId}` };
+  });
+  const queue = new SpawnQueue({ spawnFn, spawnDelayMs: 0, logFn: () => {} });
+
+  const ids = Array.from({ length: 1000 }, (_, i) => `s${i % 100}`);
+  const results = await Promise.all(ids.map((id) => queue.enqueue({ sessionId: id, title: id })));
+
+  expect(spawned).toHaveLength(100);
+  expect(new Set(spawned).size).toBe(100);
+  expect(results.every((result, i) => result.paneId === `%${ids[i]}`)).toBe(true);
+  expect(queue.getPendingCount()).toBe(0);
+});
+
 test('SpawnQueue allows re-enqueue after first completes', async () => {
   let callCount = 0;
   const spawnFn = mock(async (_req: SpawnRequest): Promise<SpawnResult> => {
@@ -426,6 +543,7 @@ test('SpawnQueue allows re-enqueue after first completes', async () => {
 });
 
 test('SpawnQueue skips stale items', async () => {
+  const clock = new FakeClock();
   let callCount = 0;
   const ctrl = createControlledPromise<SpawnResult>();
 
@@ -442,6 +560,7 @@ test('SpawnQueue skips stale items', async () => {
     spawnDelayMs: 0,
     staleThresholdMs: 50,
     logFn: () => {},
+    clock,
   });
 
   const promise1 = queue.enqueue({ sessionId: 's1', title: 'T1' });
@@ -449,14 +568,16 @@ test('SpawnQueue skips stale items', async () => {
 
   await waitFor(() => callCount === 1);
 
-  await new Promise((r) => setTimeout(r, 100));
+  await clock.advance(100);
 
   ctrl.resolve({ success: true, paneId: '%1' });
+  // Runs the spawn delay before s2 is dequeued
+  await clock.advance(0);
 
   const [r1, r2] = await Promise.all([promise1, promise2]);
 
-  expect(r1).toEqual({ success: true, paneId: '%1' });
-  expect(r2).toEqual({ success: false });
+  expect(r1).toEqual({ success: true, paneId: '%1', timing: expect.any(Object) });
+  expect(r2).toEqual({ success: false, reason: 'stale' });
   expect(callCount).toBe(1);
 });
 
@@ -484,8 +605,8 @@ test('SpawnQueue shutdown resolves queued items as failed', async () => {
   const [r1, r2, r3] = await Promise.all([promise1, promise2, promise3]);
 
   expect(r1.success).toBe(true);
-  expect(r2).toEqual({ success: false });
-  expect(r3).toEqual({ success: false });
+  expect(r2).toEqual({ success: false, reason: 'shutdown' });
+  expect(r3).toEqual({ success: false, reason: 'shutdown' });
 });
 
 test('SpawnQueue rejects enqueues after shutdown', async () => {
@@ -499,7 +620,7 @@ test('SpawnQueue rejects enqueues after shutdown', async () => {
 
   const result = await queue.enqueue({ sessionId: 's1', title: 'T1' });
 
-  expect(result).toEqual({ success: false });
+  expect(result).toEqual({ success: false, reason: 'shutdown' });
   expect(spawnFn).not.toHaveBeenCalled();
 });
 
@@ -520,7 +641,7 @@ test('SpawnQueue dispose is alias for shutdown', async () => {
   ctrl.resolve({ success: true, paneId: '%1' });
 
   const r2 = await promise2;
-  expect(r2).toEqual({ success: false });
+  expect(r2).toEqual({ success: false, reason: 'shutdown' });
 });
 
 test('SpawnQueue logs lifecycle events', async () => {
@@ -648,3 +769,185 @@ test('SpawnQueue logs stale item skip', async () => {
   const messages = logs.map((l) => l.message);
   expect(messages).toContain('[spawn-queue] stale item skipped');
 });
+
+test('SpawnQueue drain waits for the in-flight item after shutdown', async () => {
+  const ctrl = createControlledPromise<SpawnResult>();
+  const spawnFn = mock(async (): Promise<SpawnResult> => ctrl.promise);
+  const queue = new SpawnQueue({ spawnFn, spawnDelayMs: 0, logFn: () => {} });
+
+  const inFlight = queue.enqueue({ sessionId: 'in-flight', title: 'Task' });
+  const queued = queue.enqueue({ sessionId: 'queued', title: 'Task' });
+  await waitFor(() => spawnFn.mock.calls.length === 1);
+
+  queue.shutdown();
+  expect(await queued).toEqual({ success: false, reason: 'shutdown' });
+
+  const drainPromise = queue.drain(1000);
+  ctrl.resolve({ success: true, paneId: '%1' });
+
+  expect(await drainPromise).toBe(true);
+  expect(await inFlight).toEqual({ success: true, paneId: '%1', timing: expect.any(Object) });
+});
+
+test('SpawnQueue drain gives up after the timeout', async () => {
+  const clock = new FakeClock();
+  const ctrl = createControlledPromise<SpawnResult>();
+  const spawnFn = mock(async (): Promise<SpawnResult> => ctrl.promise);
+  const queue = new SpawnQueue({ spawnFn, spawnDelayMs: 0, logFn: () => {}, clock });
+
+  queue.enqueue({ sessionId: 'hung', title: 'Task' });
+  await waitFor(() => spawnFn.mock.calls.length === 1);
+  queue.shutdown();
+
+  let drained: boolean | undefined;
+  void queue.drain(60_000).then((result) => (drained = result));
+  await clock.advance(59_999);
+  expect(drained).toBeUndefined();
+  await clock.advance(1);
+  expect(drained).toBe(false);
+  ctrl.resolve({ success: false });
+});
+
+test('SpawnQueue reports attempt timing in the result', async () => {
+  const clock = new FakeClock();
+  let attempts = 0;
+  const spawnFn = mock(async (_req: SpawnRequest): Promise<SpawnResult> => {
+    attempts++;
+    return attempts < 2 ? { success: false } : { success: true, paneId: '%t' };
+  });
+
+  const queue = new SpawnQueue({
+    spawnFn,
+    spawnDelayMs: 0,
+    maxRetries: 2,
+    backoff: { baseMs: 20, jitter: 0 },
+    logFn: () => {},
+    clock,
+  });
+
+  const pending = queue.enqueue({ sessionId: 'timed', title: 'T' });
+  await clock.advance(20);
+  const result = await pending;
+
+  expect(result.success).toBe(true);
+  expect(result.timing?.attempts).toBe(2);
+  expect(result.timing?.attemptDurationsMs.length).toBe(2);
+  expect(result.timing?.backoffMs).toBe(20);
+  expect(result.timing?.totalMs).toBe(20);
+});
+
+test('SpawnQueue counts only the backoff actually waited when a retry is aborted', async () => {
+  const clock = new FakeClock();
+  const spawnFn = mock(async (_req: SpawnRequest): Promise<SpawnResult> => ({ success: false }));
+  const queue = new SpawnQueue({
+    spawnFn,
+    spawnDelayMs: 0,
+    maxRetries: 2,
+    backoff: { baseMs: 1000, jitter: 0 },
+    logFn: () => {},
+    clock,
+  });
+
+  const controller = new AbortController();
+  const pending = queue.enqueue({ sessionId: 'cut-short', title: 'T' }, { signal: controller.signal });
+  await clock.advance(300);
+  controller.abort();
+  const result = await pending;
+
+  expect(result.reason).toBe('aborted');
+  expect(result.timing?.backoffMs).toBe(300);
+});
+
+test('SpawnQueue summarizes queue wait and spawn duration', async () => {
+  const spawnFn = mock(async (_req: SpawnRequest): Promise<SpawnResult> => {
+    await new Promise((r) => setTimeout(r, 20));
+    return { success: true, paneId: '%s' };
+  });
+  const queue = new SpawnQueue({ spawnFn, spawnDelayMs: 0, logFn: () => {} });
+
+  expect(queue.getStats().spawnDurationMs.count).toBe(0);
+
+  const first = queue.enqueue({ sessionId: 'stat-1', title: 'T1' });
+  const second = queue.enqueue({ sessionId: 'stat-2', title: 'T2' });
+  const results = await Promise.all([first, second]);
+
+  const stats = queue.getStats();
+  expect(stats.queueWaitMs.count).toBe(2);
+  expect(stats.spawnDurationMs.count).toBe(2);
+  expect(stats.spawnDurationMs.p50).toBeGreaterThanOrEqual(15);
+  // The second item waited behind the first
+  expect(stats.queueWaitMs.max).toBeGreaterThanOrEqual(15);
+  expect(results[1].timing?.queueWaitMs).toBe(stats.queueWaitMs.max);
+});
+
+test('SpawnQueue drops a queued item when its caller aborts', async () => {
+  const ctrl = createControlledPromise<SpawnResult>();
+  const spawnFn = mock(async (_req: SpawnRequest): Promise<SpawnResult> => ctrl.promise);
+  const queue = new SpawnQueue({ spawnFn, spawnDelayMs: 0, logFn: () => {} });
+
+  const caller = new AbortController();
+  const first = queue.enqueue({ sessionId: 'first', title: 'T1' });
+  const second = queue.enqueue({ sessionId: 'second', title: 'T2' }, { signal: caller.signal });
+  await waitFor(() => spawnFn.mock.calls.length === 1);
+
+  caller.abort();
+  expect(await second).toEqual({ success: false, reason: 'aborted' });
+
+  ctrl.resolve({ success: true, paneId: '%1' });
+  await first;
+
+  expect(spawnFn.mock.calls.length).toBe(1);
+});
+
+test('SpawnQueue aborts the in-flight spawn when its caller aborts', async () => {
+  let receivedSignal: AbortSignal | null = null;
+  const spawnFn = mock(async (req: SpawnRequest): Promise<SpawnResult> => {
+    receivedSignal = req.signal;
+    await new Promise((resolve) => req.signal.addEventListener('abort', resolve, { once: true }));
+    return { success: false };
+  });
+  const queue = new SpawnQueue({ spawnFn, spawnDelayMs: 0, maxRetries: 2, logFn: () => {} });
+
+  const caller = new AbortController();
+  const promise = queue.enqueue({ sessionId: 'cancel-me', title: 'T' }, { signal: caller.signal });
+  await waitFor(() => receivedSignal !== null);
+
+  caller.abort();
+  const result = await promise;
+
+  expect(result.success).toBe(false);
+  expect(receivedSignal!.aborted).toBe(true);
+  // No retries after an abort
+  expect(spawnFn.mock.calls.length).toBe(1);
+});
+
+test('SpawnQueue drain timeout aborts a hung in-flight spawn', async () => {
+  let receivedSignal: AbortSignal | null = null;
+  const spawnFn = mock(async (req: SpawnRequest): Promise<SpawnResult> => {
+    receivedSignal = req.signal;
+    await new Promise((resolve) => req.signal.addEventListener('abort', resolve, { once: true }));
+    return { success: false };
+  });
+  const queue = new SpawnQueue({ spawnFn, spawnDelayMs: 0, logFn: () => {} });
+
+  const promise = queue.enqueue({ sessionId: 'hung', title: 'T' });
+  await waitFor(() => receivedSignal !== null);
+  queue.shutdown();
+
+  expect(await queue.drain(20)).toBe(false);
+  expect(receivedSignal!.aborted).toBe(true);
+  expect((await promise).success).toBe(false);
+});
+
+test('SpawnQueue keeps the spawn function\'s failure reason', async () => {
+  const spawnFn = mock(async (): Promise<SpawnResult> => ({
+    success: false,
+    reason: 'server_unreachable',
+  }));
+  const queue = new SpawnQueue({ spawnFn, spawnDelayMs: 0, maxRetries: 1, logFn: () => {} });
+
+  const result = await queue.enqueue({ sessionId: 'down', title: 'T' });
+
+  expect(result.reason).toBe('server_unreachable');
+  expect(spawnFn.mock.calls.length).toBe(2);
+});
@@ -0,0 +1,53 @@
+import { expect, test } from 'bun:test';
+import { createStatusSource, SharedStatusCache } from '../status-source';
+import type { PluginInput } from '../types';
+import { FakeClock } from './fake-clock';
+import { FakeStatusSource } from './fake-status-source';
+
+function clientWith(status: PluginInput['client']['session']['status']) {
+  return { session: { status, subscribe: () => () => {} } } as PluginInput['client'];
+}
+
+test('createStatusSource returns the statuses the client reports', async () => {
+  const source = createStatusSource(
+    clientWith(async () => ({ data: { s1: { type: 'busy' } } })),
+    'http://localhost:4096',
+  );
+  expect(await source.fetchStatuses()).toEqual({ s1: { type: 'busy' } });
+});
+
+test('createStatusSource gives up on a status request that never answers', async () => {
+  let signal: AbortSignal | undefined;
+  const source = createStatusSource(
+    clientWith((options) => {
+      signal = options?.signal;
+      return new Promise(() => {});
+    }),
+    'http://localhost:4096',
+    { statusMs: 10 },
+  );
+  await expect(source.fetchStatuses()).rejects.toThrow('timed out');
+  expect(signal?.aborted).toBe(true);
+});
+
+test('SharedStatusCache reuses a recent snapshot and coalesces concurrent fetches', async () => {
+  const clock = new FakeClock();
+  const source = new FakeStatusSource();
+  let fetches = 0;
+  const fetchStatuses = source.fetchStatuses.bind(source);
+  source.fetchStatuses = () => {
+    fetches++;
+    return fetchStatuses();
+  };
+  const cache = new SharedStatusCache(source, clock);
+
+  source.statuses = { s1: { type: 'busy' } };
+  await Promise.all([cache.get(), cache.get()]);
+  expect(fetches).toBe(1);
+
+  source.statuses = { s1: { type: 'idle' } };
+  await clock.advance(1000);
+  expect(await cache.get(5000)).toEqual({ s1: { type: 'busy' } });
+  expect(await cache.get()).toEqual({ s1: { type: 'idle' } });
+  expect(fetches).toBe(2);
+});
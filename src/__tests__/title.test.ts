@@ -0,0 +1,42 @@
+import { test, expect } from 'bun:test';
+import { displayWidth, truncateTitle } from '../utils/title';
+
+test('truncateTitle leaves short titles alone', () => {
+  expect(truncateTitle('Explore codebase')).toBe('Explore codebase');
+  expect(truncateTitle('  padded  ')).toBe('padded');
+});
+
+test('truncateTitle keeps the start and the distinguishing suffix', () => {
+  const a = truncateTitle('Investigate flaky integration tests (@general #1)', 30);
+  const b = truncateTitle('Investigate flaky integration tests (@general #2)', 30);
+
+  expect(a.length).toBeLessThanOrEqual(30);
+  expect(a).toStartWith('Investigate flaky');
+  expect(a).toEndWith('#1)');
+  expect(a).not.toBe(b);
+});
+
+test('truncateTitle never splits multi-byte characters or emoji', () => {
+  const title = 'Überprüfe die Datenbankmigrationen für Käufer 🧪 #3';
+  const truncated = truncateTitle(title, 20);
+
+  expect(displayWidth(truncated)).toBeLessThanOrEqual(20);
+  expect(truncated).not.toContain('�');
+  expect(truncated).toEndWith('#3');
+  // Every code point is intact (no lone surrogates)
+  expect(Array.from(truncated).every((ch) => ch.length === 1 || ch.codePointAt(0)! > 0xffff)).toBe(true);
+});
+
+test('truncateTitle measures wide characters by display width', () => {
+  expect(displayWidth('日本語')).toBe(6);
+  expect(displayWidth('👍🏽 ok')).toBe(5);
+
+  const truncated = truncateTitle('日本語のタイトルをテストする長い説明', 11);
+  expect(displayWidth(truncated)).toBeLessThanOrEqual(11);
+  expect(truncated).toContain('…');
+});
+
+test('truncateTitle respects very small widths', () => {
+  expect(truncateTitle('abcdef', 1)).toBe('…');
+  expect(truncateTitle('abcdef', 0)).toBe('');
+});
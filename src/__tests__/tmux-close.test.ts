@@ -46,8 +46,7 @@ beforeEach(() => {
   
   // Mock process utils
   spyOn(processUtils, 'getProcessChildren').mockReturnValue([]);
-  spyOn(processUtils, 'safeKill').mockReturnValue(true);
-  spyOn(processUtils, 'waitForProcessExit').mockResolvedValue(true);
+  spyOn(processUtils, 'terminateProcess').mockResolvedValue(true);
   spyOn(processUtils, 'getProcessCommand').mockReturnValue('opencode attach');
 });
 
@@ -67,8 +66,7 @@ test('closeTmuxPane kills attach process before closing pane', async () => {
   );
 
   spyOn(processUtils, 'getProcessChildren').mockReturnValue([9999]);
-  const safeKillSpy = spyOn(processUtils, 'safeKill');
-  const waitSpy = spyOn(processUtils, 'waitForProcessExit');
+  const terminateSpy = spyOn(processUtils, 'terminateProcess');
 
   const result = await closeTmuxPane('%1');
 
@@ -76,15 +74,14 @@ test('closeTmuxPane kills attach process before closing pane', async () => {
   
   // Verify PID flow
   expect(processUtils.getProcessChildren).toHaveBeenCalledWith(12345); // Shell PID
-  expect(safeKillSpy).toHaveBeenCalledWith(9999, 'SIGTERM');
-  expect(waitSpy).toHaveBeenCalledWith(9999, 2000);
+  expect(terminateSpy).toHaveBeenCalledWith(9999, processUtils.DEFAULT_TERMINATE_POLICY);
   
   // Verify tmux flow
   const killPaneCall = mockSpawnData.calls.find(c => c.command.includes('kill-pane'));
   expect(killPaneCall).toBeDefined();
 });
 
-test('closeTmuxPane sends SIGKILL if SIGTERM fails', async () => {
+test('closeTmuxPane closes the pane even if the attach process survives', async () => {
   mockSpawnData.results.push(
     { exitCode: 0, stdout: '/usr/bin/tmux\n', stderr: '' },
     { exitCode: 0, stdout: 'tmux 3.3\n', stderr: '' },
@@ -94,13 +91,10 @@ test('closeTmuxPane sends SIGKILL if SIGTERM fails', async () => {
   );
 
   spyOn(processUtils, 'getProcessChildren').mockReturnValue([9999]);
-  spyOn(processUtils, 'waitForProcessExit').mockResolvedValue(false); // Timed out
-  const safeKillSpy = spyOn(processUtils, 'safeKill');
+  spyOn(processUtils, 'terminateProcess').mockResolvedValue(false);
 
-  await closeTmuxPane('%1');
-
-  expect(safeKillSpy).toHaveBeenCalledWith(9999, 'SIGTERM');
-  expect(safeKillSpy).toHaveBeenCalledWith(9999, 'SIGKILL');
+  expect(await closeTmuxPane('%1')).toBe(true);
+  expect(mockSpawnData.calls.some((c) => c.command.includes('kill-pane'))).toBe(true);
 });
 
 test('closeTmuxPane handles case where no attach process found', async () => {
@@ -113,9 +107,9 @@ test('closeTmuxPane handles case where no attach process found', async () => {
   );
 
   spyOn(processUtils, 'getProcessChildren').mockReturnValue([]); // No children
-  const safeKillSpy = spyOn(processUtils, 'safeKill');
+  const terminateSpy = spyOn(processUtils, 'terminateProcess');
 
   await closeTmuxPane('%1');
 
-  expect(safeKillSpy).not.toHaveBeenCalled();
+  expect(terminateSpy).not.toHaveBeenCalled();
 });
@@ -0,0 +1,32 @@
+import { expect, test } from 'bun:test';
+import { ControlParser, quoteTmuxArg, type ControlNotification } from '../utils/tmux-control';
+
+test('quoteTmuxArg leaves plain words alone and quotes the rest', () => {
+  expect(quoteTmuxArg('split-window')).toBe('split-window');
+  expect(quoteTmuxArg('%12')).toBe('%12');
+  expect(quoteTmuxArg('#{pane_id}')).toBe('"#{pane_id}"');
+  expect(quoteTmuxArg('')).toBe('""');
+  expect(quoteTmuxArg('say "hi" to $USER \\o/')).toBe('"say \\"hi\\" to \\$USER \\\\o/"');
+});
+
+test('ControlParser reports replies to own commands and notifications', () => {
+  const blocks: Array<{ error: boolean; lines: string[] }> = [];
+  const notifications: ControlNotification[] = [];
+  const parser = new ControlParser(
+    (block) => blocks.push(block),
+    (notification) => notifications.push(notification),
+  );
+
+  parser.feed('%begin 1700000000 264 0\n%end 1700000000 264 0\n');
+  parser.feed('%session-changed $0 main\n%begin 1700000000 265 1\n%0\t1\n%1');
+  parser.feed('\t0\n%end 1700000000 265 1\n');
+  parser.feed('%begin 1700000000 266 1\nparse error: unknown command: bogus\n%error 1700000000 266 1\n');
+  parser.feed('%layout-change @0 b25d,80x24,0,0,0 b25d,80x24,0,0,0 *\n');
+
+  expect(blocks).toEqual([
+    { error: false, lines: ['%0\t1', '%1\t0'] },
+    { error: true, lines: ['parse error: unknown command: bogus'] },
+  ]);
+  expect(notifications.map((n) => n.name)).toEqual(['session-changed', 'layout-change']);
+  expect(notifications[0].args).toEqual(['$0', 'main']);
+});
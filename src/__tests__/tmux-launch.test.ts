@@ -0,0 +1,51 @@
+import { expect, test } from 'bun:test';
+import { expandSessionName, tmuxLaunchArgs } from '../utils/tmux-launch';
+
+test('tmuxLaunchArgs starts an unnamed session without tmux_session_name', () => {
+  expect(tmuxLaunchArgs('opencode', undefined, false)).toEqual(['new-session', 'opencode']);
+});
+
+test('tmuxLaunchArgs names a new session and adds a window to an existing one', () => {
+  expect(tmuxLaunchArgs('opencode', 'work', false)).toEqual([
+    'new-session',
+    '-s',
+    'work',
+    'opencode',
+  ]);
+  expect(tmuxLaunchArgs('opencode', 'work', true)).toEqual([
+    'new-window',
+    '-t',
+    '=work:',
+    'opencode',
+    ';',
+    'attach-session',
+    '-t',
+    '=work',
+  ]);
+});
+
+test('tmuxLaunchArgs starts detached sessions and windows without attaching', () => {
+  expect(tmuxLaunchArgs('opencode', undefined, false, true)).toEqual([
+    'new-session',
+    '-d',
+    '-P',
+    '-F',
+    '#{session_name}',
+    'opencode',
+  ]);
+  expect(tmuxLaunchArgs('opencode', 'work', true, true)).toEqual([
+    'new-window',
+    '-d',
+    '-t',
+    '=work:',
+    'opencode',
+  ]);
+});
+
+test('expandSessionName names the session after the project directory', () => {
+  expect(expandSessionName('oc-{project}', '/home/me/code/foo')).toBe('oc-foo');
+  expect(expandSessionName('oc-{project}', '/home/me/code/My App.v2')).toBe('oc-My-App-v2');
+  expect(expandSessionName('{project}-{project}', '/srv/api')).toBe('api-api');
+  expect(expandSessionName('oc-{project}', '/')).toBe('oc-project');
+  expect(expandSessionName('work', '/home/me/code/foo')).toBe('work');
+});
@@ -1,8 +1,15 @@
 import { test, expect, mock, beforeEach, spyOn, afterEach } from 'bun:test';
 import { TmuxSessionManager } from '../tmux-session-manager';
+import { ZombieReaper } from '../zombie-reaper';
 import type { PluginInput } from '../types';
-import type { TmuxConfig } from '../config';
+import { TmuxConfigSchema, type TmuxConfig } from '../config';
 import * as utils from '../utils';
+import * as paneOutput from '../utils/pane-output';
+import * as processUtils from '../utils/process';
+import * as sessionHistory from '../utils/session-history';
+import { FakeClock } from './fake-clock';
+import { FakePaneController } from './fake-pane-controller';
+import { FakeStatusSource } from './fake-status-source';
 
 // Helper to create controlled promises for test synchronization
 function createControlledPromise<T>() {
@@ -31,7 +38,7 @@ async function waitFor(
 
 // Track spawn calls
 let spawnCalls: Array<{ sessionId: string; title: string }> = [];
-let spawnControllers: Map<string, { resolve: (result: { success: boolean; paneId?: string }) => void }> = new Map();
+let spawnControllers: Map<string, { resolve: (result: utils.SpawnPaneResult) => void }> = new Map();
 let layoutCallCount = 0;
 
 function createMockPluginInput(): PluginInput {
@@ -49,21 +56,13 @@ function createMockPluginInput(): PluginInput {
 
 function createTmuxConfig(overrides?: Partial<TmuxConfig>): TmuxConfig {
   return {
-    enabled: true,
-    layout: 'main-vertical',
-    main_pane_size: 60,
+    ...TmuxConfigSchema.parse({}),
+    // Below the schema's minimum, so queued spawns run back to back
     spawn_delay_ms: 0,
-    max_retry_attempts: 2,
-    layout_debounce_ms: 150,
-    max_agents_per_column: 3,
+    status_line: false,
+    session_history: false,
     reaper_enabled: false,
-    reaper_interval_ms: 30000,
-    reaper_min_zombie_checks: 3,
-    reaper_grace_period_ms: 5000,
-    reaper_auto_self_destruct: true,
     reaper_self_destruct_timeout_ms: 600000,
-    rotate_port: false,
-    max_ports: 10,
     ...overrides,
   };
 }
@@ -77,8 +76,18 @@ beforeEach(() => {
   spyOn(utils, 'log').mockImplementation(() => {});
   
   spyOn(utils, 'isInsideTmux').mockReturnValue(true);
+  spyOn(utils, 'isSpawningPaused').mockResolvedValue(false);
+  spyOn(utils, 'getQueueFlushedAt').mockResolvedValue(null);
+  spyOn(utils, 'getCloseAllRequest').mockResolvedValue(null);
   
   spyOn(utils, 'closeTmuxPane').mockResolvedValue(true);
+  spyOn(utils, 'setPaneTitle').mockResolvedValue(true);
+  spyOn(utils, 'setQueueOption').mockResolvedValue(true);
+  spyOn(utils, 'showTmuxMessage').mockResolvedValue(true);
+  spyOn(utils, 'listAllPaneIds').mockResolvedValue(null);
+  spyOn(utils, 'listAgentPanes').mockResolvedValue([]);
+  spyOn(utils, 'refreshAutoLayout').mockResolvedValue(false);
+  spyOn(processUtils, 'listProcesses').mockResolvedValue([]);
   
   spyOn(utils, 'applyTmuxLayout').mockImplementation(async () => {
     layoutCallCount++;
@@ -86,7 +95,7 @@ beforeEach(() => {
   
   spyOn(utils, 'spawnTmuxPane').mockImplementation(async (sessionId: string, title: string) => {
     spawnCalls.push({ sessionId, title });
-    const ctrl = createControlledPromise<{ success: boolean; paneId?: string }>();
+    const ctrl = createControlledPromise<utils.SpawnPaneResult>();
     spawnControllers.set(sessionId, { resolve: ctrl.resolve });
     return ctrl.promise;
   });
@@ -175,6 +184,24 @@ test('TmuxSessionManager does not track session on spawn failure', async () => {
   expect(spawnCalls[0].sessionId).toBe('fail-test');
 });
 
+test('TmuxSessionManager tells the user why an agent got no pane', async () => {
+  const ctx = createMockPluginInput();
+  const manager = new TmuxSessionManager(ctx, createTmuxConfig(), 'http://localhost:4096');
+
+  const promise = manager.onSessionCreated({
+    type: 'session.created',
+    properties: { info: { id: 'unreachable', parentID: 'parent', title: 'Lost' } },
+  });
+  await waitFor(() => spawnControllers.has('unreachable'));
+  spawnControllers.get('unreachable')?.resolve({ success: false, reason: 'server_unreachable' });
+  await promise;
+
+  expect(utils.showTmuxMessage).toHaveBeenCalledWith(
+    'opentmux: no pane for "Lost": the opencode server isn\'t responding',
+  );
+  await manager.cleanup();
+});
+
 test('TmuxSessionManager ignores non-session.created events', async () => {
   const ctx = createMockPluginInput();
   const config = createTmuxConfig();
@@ -259,6 +286,10 @@ test('TmuxSessionManager uses config spawn_delay_ms and max_retry_attempts', asy
 });
 
 test('TmuxSessionManager applies layout once after queue drains (deferred layout)', async () => {
+  // Earlier tests leave managers whose debounced layouts (150ms) may still fire
+  await new Promise((r) => setTimeout(r, 200));
+  layoutCallCount = 0;
+
   const ctx = createMockPluginInput();
   const config = createTmuxConfig({
     layout_debounce_ms: 50,
@@ -293,3 +324,925 @@ test('TmuxSessionManager applies layout once after queue drains (deferred layout
 
   expect(layoutCallCount).toBeGreaterThan(0);
 });
+
+test('TmuxSessionManager cleanup closes panes from spawns still in flight', async () => {
+  const ctx = createMockPluginInput();
+  const config = createTmuxConfig();
+  const manager = new TmuxSessionManager(ctx, config, 'http://localhost:4096');
+
+  const event = {
+    type: 'session.created',
+    properties: { info: { id: 'late-spawn', parentID: 'parent', title: 'Late' } },
+  };
+
+  const promise = manager.onSessionCreated(event);
+  await waitFor(() => spawnControllers.has('late-spawn'));
+
+  const cleanupPromise = manager.cleanup();
+  spawnControllers.get('late-spawn')?.resolve({ success: true, paneId: '%77' });
+
+  await promise;
+  await cleanupPromise;
+
+  expect(utils.closeTmuxPane).toHaveBeenCalledWith('%77');
+});
+
+test('TmuxSessionManager sweeps orphaned panes for its own server only', async () => {
+  const ctx = createMockPluginInput();
+  ctx.client.session.status = mock(async () => ({ data: { 'live-session': { type: 'busy' } } }));
+  const manager = new TmuxSessionManager(ctx, createTmuxConfig(), 'http://localhost:4096');
+
+  spyOn(utils, 'listAgentPanes').mockResolvedValue([
+    { paneId: '%10', sessionId: 'live-session', serverUrl: 'http://localhost:4096', pid: 10, dead: false },
+    { paneId: '%11', sessionId: 'exited-attach', serverUrl: 'http://localhost:4096', pid: 11, dead: false },
+    { paneId: '%12', sessionId: 'gone-session', serverUrl: 'http://localhost:4096', pid: 12, dead: false },
+    { paneId: '%13', sessionId: 'other-server', serverUrl: 'http://localhost:4097', pid: 13, dead: false },
+  ]);
+  spyOn(utils, 'hasAttachProcess').mockImplementation((pane) => pane.paneId !== '%11');
+
+  const swept = await manager.sweepOrphanedPanes();
+
+  expect(swept).toBe(2);
+  expect(processUtils.listProcesses).toHaveBeenCalledTimes(1);
+  expect(utils.closeTmuxPane).toHaveBeenCalledWith('%11');
+  expect(utils.closeTmuxPane).toHaveBeenCalledWith('%12');
+  expect(utils.closeTmuxPane).not.toHaveBeenCalledWith('%10');
+  expect(utils.closeTmuxPane).not.toHaveBeenCalledWith('%13');
+});
+
+test('TmuxSessionManager skips the pane sweep when ps cannot be read', async () => {
+  const panes = new FakePaneController();
+  const statuses = new FakeStatusSource();
+  const manager = new TmuxSessionManager(
+    createMockPluginInput(),
+    createTmuxConfig(),
+    'http://localhost:4096',
+    new FakeClock(),
+    { panes, statuses },
+  );
+  panes.panes.set('%17', { sessionId: 'gone-session', title: 'Gone' });
+  panes.processes = null;
+
+  expect(await manager.sweepOrphanedPanes()).toBe(0);
+  expect(panes.closed).toEqual([]);
+
+  panes.processes = [];
+  expect(await manager.sweepOrphanedPanes()).toBe(1);
+  expect(panes.closed).toEqual(['%17']);
+  await manager.cleanup();
+});
+
+test('TmuxSessionManager with remote_server sweeps without inspecting processes', async () => {
+  const ctx = createMockPluginInput();
+  ctx.client.session.status = mock(async () => ({ data: { 'live-session': { type: 'busy' } } }));
+  const manager = new TmuxSessionManager(
+    ctx,
+    createTmuxConfig({ remote_server: true }),
+    'http://localhost:4096',
+  );
+
+  spyOn(utils, 'listAgentPanes').mockResolvedValue([
+    { paneId: '%14', sessionId: 'live-session', serverUrl: 'http://localhost:4096', pid: 14, dead: false },
+    { paneId: '%15', sessionId: 'live-session', serverUrl: 'http://localhost:4096', pid: 15, dead: true },
+    { paneId: '%16', sessionId: 'gone-session', serverUrl: 'http://localhost:4096', pid: 16, dead: false },
+  ]);
+  const attachCheck = spyOn(utils, 'hasAttachProcess').mockReturnValue(false);
+
+  const swept = await manager.sweepOrphanedPanes();
+
+  expect(swept).toBe(2);
+  expect(attachCheck).not.toHaveBeenCalled();
+  expect(utils.closeTmuxPane).toHaveBeenCalledWith('%15', { killProcesses: false });
+  expect(utils.closeTmuxPane).toHaveBeenCalledWith('%16', { killProcesses: false });
+  expect(utils.closeTmuxPane).not.toHaveBeenCalledWith('%14', { killProcesses: false });
+});
+
+test('TmuxSessionManager pane sweep only logs in reaper dry-run mode', async () => {
+  const ctx = createMockPluginInput();
+  const manager = new TmuxSessionManager(
+    ctx,
+    createTmuxConfig({ reaper_dry_run: true }),
+    'http://localhost:4096',
+  );
+
+  spyOn(utils, 'listAgentPanes').mockResolvedValue([
+    { paneId: '%20', sessionId: 'gone-session', serverUrl: 'http://localhost:4096', pid: 20, dead: true },
+  ]);
+
+  const swept = await manager.sweepOrphanedPanes();
+
+  expect(swept).toBe(0);
+  expect(utils.closeTmuxPane).not.toHaveBeenCalled();
+});
+
+test('TmuxSessionManager closes child panes when their parent session is deleted', async () => {
+  const ctx = createMockPluginInput();
+  const manager = new TmuxSessionManager(ctx, createTmuxConfig(), 'http://localhost:4096');
+
+  const spawn = async (id: string, parentID: string, paneId: string) => {
+    const promise = manager.handleEvent({
+      type: 'session.created',
+      properties: { info: { id, parentID, title: id } },
+    });
+    await waitFor(() => spawnControllers.has(id));
+    spawnControllers.get(id)?.resolve({ success: true, paneId });
+    await promise;
+  };
+
+  await spawn('child', 'root', '%1');
+  await spawn('grandchild', 'child', '%2');
+  await spawn('unrelated', 'other-root', '%3');
+
+  await manager.handleEvent({ type: 'session.deleted', properties: { info: { id: 'root' } } });
+
+  expect(utils.closeTmuxPane).toHaveBeenCalledWith('%1');
+  expect(utils.closeTmuxPane).toHaveBeenCalledWith('%2');
+  expect(utils.closeTmuxPane).not.toHaveBeenCalledWith('%3');
+
+  await manager.cleanup();
+});
+
+test('TmuxSessionManager closes a pane on a session.idle event without polling', async () => {
+  const ctx = createMockPluginInput();
+  const manager = new TmuxSessionManager(ctx, createTmuxConfig(), 'http://localhost:4096');
+
+  const promise = manager.handleEvent({
+    type: 'session.created',
+    properties: { info: { id: 'pushed', parentID: 'parent', title: 'Pushed' } },
+  });
+  await waitFor(() => spawnControllers.has('pushed'));
+  spawnControllers.get('pushed')?.resolve({ success: true, paneId: '%40' });
+  await promise;
+
+  await manager.handleEvent({ type: 'session.error', properties: { sessionID: 'pushed', error: 'boom' } });
+  expect(utils.closeTmuxPane).not.toHaveBeenCalledWith('%40');
+
+  await manager.handleEvent({ type: 'session.idle', properties: { sessionID: 'pushed' } });
+  expect(utils.closeTmuxPane).toHaveBeenCalledWith('%40');
+  expect(ctx.client.session.status).not.toHaveBeenCalled();
+});
+
+test('TmuxSessionManager logs the name and message of a structured session error', async () => {
+  const manager = new TmuxSessionManager(createMockPluginInput(), createTmuxConfig(), 'http://localhost:4096');
+
+  const promise = manager.handleEvent({
+    type: 'session.created',
+    properties: { info: { id: 'failing', parentID: 'parent', title: 'Failing' } },
+  });
+  await waitFor(() => spawnControllers.has('failing'));
+  spawnControllers.get('failing')?.resolve({ success: true, paneId: '%41' });
+  await promise;
+
+  await manager.handleEvent({
+    type: 'session.error',
+    properties: {
+      sessionID: 'failing',
+      error: { name: 'ProviderAuthError', data: { providerID: 'anthropic', message: 'invalid api key' } },
+    },
+  });
+
+  expect(utils.log).toHaveBeenCalledWith('[tmux-session-manager] session reported an error', {
+    sessionId: 'failing',
+    error: 'ProviderAuthError: invalid api key',
+  });
+  await manager.cleanup();
+});
+
+test('TmuxSessionManager records closed panes when session_history is on', async () => {
+  const ctx = createMockPluginInput();
+  const manager = new TmuxSessionManager(
+    ctx,
+    createTmuxConfig({ session_history: true }),
+    'http://localhost:4096',
+  );
+  const historySpy = spyOn(sessionHistory, 'appendSessionHistory').mockImplementation(() => {});
+
+  const promise = manager.handleEvent({
+    type: 'session.created',
+    properties: { info: { id: 'recorded', parentID: 'parent', title: 'Recorded' } },
+  });
+  await waitFor(() => spawnControllers.has('recorded'));
+  spawnControllers.get('recorded')?.resolve({ success: true, paneId: '%45', attempts: 2 });
+  await promise;
+
+  await manager.handleEvent({ type: 'session.idle', properties: { sessionID: 'recorded' } });
+
+  expect(historySpy).toHaveBeenCalledTimes(1);
+  expect(historySpy.mock.calls[0][0]).toMatchObject({
+    sessionId: 'recorded',
+    parentId: 'parent',
+    paneId: '%45',
+    reason: 'idle_event',
+    attempts: 2,
+  });
+
+  historySpy.mockRestore();
+});
+
+test('TmuxSessionManager captures pane output before closing when enabled', async () => {
+  const ctx = createMockPluginInput();
+  const manager = new TmuxSessionManager(
+    ctx,
+    createTmuxConfig({ capture_output_on_close: true }),
+    'http://localhost:4096',
+  );
+  const order: string[] = [];
+  spyOn(utils, 'capturePaneOutput').mockImplementation(async () => {
+    order.push('capture');
+    return 'agent output\n';
+  });
+  spyOn(utils, 'closeTmuxPane').mockImplementation(async () => {
+    order.push('close');
+    return true;
+  });
+  const saveSpy = spyOn(paneOutput, 'savePaneOutput').mockReturnValue('/tmp/captured.log');
+
+  const promise = manager.handleEvent({
+    type: 'session.created',
+    properties: { info: { id: 'captured', parentID: 'parent', title: 'Captured' } },
+  });
+  await waitFor(() => spawnControllers.has('captured'));
+  spawnControllers.get('captured')?.resolve({ success: true, paneId: '%46' });
+  await promise;
+
+  await manager.handleEvent({ type: 'session.idle', properties: { sessionID: 'captured' } });
+
+  expect(order).toEqual(['capture', 'close']);
+  expect(saveSpy).toHaveBeenCalledWith('captured', 'agent output\n', undefined);
+
+  saveSpy.mockRestore();
+});
+
+test('TmuxSessionManager tints panes by status when pane_status_colors is on', async () => {
+  const ctx = createMockPluginInput();
+  const manager = new TmuxSessionManager(
+    ctx,
+    createTmuxConfig({ pane_status_colors: true }),
+    'http://localhost:4096',
+  );
+  const styleSpy = spyOn(utils, 'setPaneStatusStyle').mockResolvedValue(true);
+
+  const promise = manager.handleEvent({
+    type: 'session.created',
+    properties: { info: { id: 'tinted', parentID: 'parent', title: 'Tinted' } },
+  });
+  await waitFor(() => spawnControllers.has('tinted'));
+  spawnControllers.get('tinted')?.resolve({ success: true, paneId: '%50' });
+  await promise;
+
+  await manager.handleEvent({ type: 'session.error', properties: { sessionID: 'tinted' } });
+
+  expect(styleSpy).toHaveBeenCalledWith('%50', 'working');
+  expect(styleSpy).toHaveBeenCalledWith('%50', 'error');
+
+  await manager.cleanup();
+});
+
+test('TmuxSessionManager replaces the pane title with the server session title', async () => {
+  const originalFetch = globalThis.fetch;
+  const mockFetch = mock(async () =>
+    new Response(JSON.stringify({ id: 'titled', title: 'Review auth middleware' }), { status: 200 }),
+  );
+  globalThis.fetch = mockFetch as unknown as typeof fetch;
+
+  try {
+    const ctx = createMockPluginInput();
+    const manager = new TmuxSessionManager(ctx, createTmuxConfig(), 'http://localhost:4096');
+
+    const promise = manager.handleEvent({
+      type: 'session.created',
+      properties: { info: { id: 'titled', parentID: 'parent', title: 'Subagent' } },
+    });
+    await waitFor(() => spawnControllers.has('titled'));
+    spawnControllers.get('titled')?.resolve({ success: true, paneId: '%60' });
+    await promise;
+
+    await waitFor(() => (utils.setPaneTitle as ReturnType<typeof mock>).mock.calls.length > 0);
+    expect(utils.setPaneTitle).toHaveBeenCalledWith('%60', 'Review auth middleware', 30);
+    expect(mockFetch).toHaveBeenCalledWith('http://localhost:4096/session/titled', expect.anything());
+
+    await manager.cleanup();
+  } finally {
+    globalThis.fetch = originalFetch;
+  }
+});
+
+test('TmuxSessionManager retitles a pane when the session is renamed', async () => {
+  const originalFetch = globalThis.fetch;
+  globalThis.fetch = mock(async () => new Response('', { status: 404 })) as unknown as typeof fetch;
+
+  try {
+    const ctx = createMockPluginInput();
+    const manager = new TmuxSessionManager(ctx, createTmuxConfig(), 'http://localhost:4096');
+
+    const promise = manager.handleEvent({
+      type: 'session.created',
+      properties: { info: { id: 'renamed', parentID: 'parent', title: 'Old title' } },
+    });
+    await waitFor(() => spawnControllers.has('renamed'));
+    spawnControllers.get('renamed')?.resolve({ success: true, paneId: '%47' });
+    await promise;
+
+    const renamed = { type: 'session.updated', properties: { info: { id: 'renamed', title: 'New title' } } };
+    await manager.handleEvent(renamed);
+    await manager.handleEvent(renamed);
+
+    expect(utils.setPaneTitle).toHaveBeenCalledTimes(1);
+    expect(utils.setPaneTitle).toHaveBeenCalledWith('%47', 'New title', 30);
+
+    await manager.cleanup();
+  } finally {
+    globalThis.fetch = originalFetch;
+  }
+});
+
+test('TmuxSessionManager stops tracking panes closed outside opentmux', async () => {
+  const ctx = createMockPluginInput();
+  const manager = new TmuxSessionManager(ctx, createTmuxConfig(), 'http://localhost:4096');
+
+  for (const [id, paneId] of [['kept', '%48'], ['closed', '%49']]) {
+    const promise = manager.onSessionCreated({
+      type: 'session.created',
+      properties: { info: { id, parentID: 'parent', title: id } },
+    });
+    await waitFor(() => spawnControllers.has(id));
+    spawnControllers.get(id)?.resolve({ success: true, paneId });
+    await promise;
+  }
+
+  spyOn(utils, 'listAllPaneIds').mockResolvedValue(new Set(['%0', '%48']));
+
+  expect(await manager.dropClosedPanes()).toBe(1);
+  expect(await manager.dropClosedPanes()).toBe(0);
+
+  await manager.cleanup();
+  expect(utils.closeTmuxPane).toHaveBeenCalledWith('%48');
+  expect(utils.closeTmuxPane).not.toHaveBeenCalledWith('%49');
+});
+
+test('TmuxSessionManager aborts the session of a user-closed pane when configured', async () => {
+  const originalFetch = globalThis.fetch;
+  const mockFetch = mock(async () => new Response('true', { status: 200 }));
+  globalThis.fetch = mockFetch as unknown as typeof fetch;
+
+  try {
+    const ctx = createMockPluginInput();
+    const manager = new TmuxSessionManager(
+      ctx,
+      createTmuxConfig({ abort_session_on_pane_close: true }),
+      'http://localhost:4096',
+    );
+
+    const promise = manager.onSessionCreated({
+      type: 'session.created',
+      properties: { info: { id: 'abandoned', parentID: 'parent', title: 'Abandoned' } },
+    });
+    await waitFor(() => spawnControllers.has('abandoned'));
+    spawnControllers.get('abandoned')?.resolve({ success: true, paneId: '%49' });
+    await promise;
+
+    spyOn(utils, 'listAllPaneIds').mockResolvedValue(new Set(['%0']));
+    await manager.dropClosedPanes();
+
+    expect(mockFetch).toHaveBeenCalledWith(
+      'http://localhost:4096/session/abandoned/abort',
+      expect.objectContaining({ method: 'POST' }),
+    );
+
+    await manager.cleanup();
+  } finally {
+    globalThis.fetch = originalFetch;
+  }
+});
+
+test('TmuxSessionManager holds sessions while paused and spawns them on resume', async () => {
+  const paused = spyOn(utils, 'isSpawningPaused').mockResolvedValue(true);
+  const ctx = createMockPluginInput();
+  const manager = new TmuxSessionManager(
+    ctx,
+    createTmuxConfig({ poll_interval_min_ms: 50 }),
+    'http://localhost:4096',
+  );
+
+  await manager.onSessionCreated({
+    type: 'session.created',
+    properties: { info: { id: 'held', parentID: 'parent', title: 'Held' } },
+  });
+  await manager.onSessionCreated({
+    type: 'session.created',
+    properties: { info: { id: 'dropped', parentID: 'parent', title: 'Dropped' } },
+  });
+  await manager.handleEvent({ type: 'session.deleted', properties: { info: { id: 'dropped' } } });
+  expect(spawnCalls).toHaveLength(0);
+  expect(manager.getQueuedSpawns()).toEqual([
+    expect.objectContaining({ sessionId: 'held', title: 'Held', state: 'held', retries: 0 }),
+  ]);
+
+  paused.mockResolvedValue(false);
+  await waitFor(() => spawnControllers.has('held'));
+  spawnControllers.get('held')?.resolve({ success: true, paneId: '%50' });
+
+  expect(spawnCalls.map((call) => call.sessionId)).toEqual(['held']);
+  await manager.cleanup();
+});
+
+test('TmuxSessionManager reads the pause flag once for a burst of new sessions', async () => {
+  const paused = spyOn(utils, 'isSpawningPaused').mockResolvedValue(true);
+  const clock = new FakeClock();
+  const manager = new TmuxSessionManager(
+    createMockPluginInput(),
+    createTmuxConfig({ poll_interval_min_ms: 500 }),
+    'http://localhost:4096',
+    clock,
+    { panes: new FakePaneController(), statuses: new FakeStatusSource(), handleSignals: false },
+  );
+
+  await Promise.all(
+    ['burst-1', 'burst-2', 'burst-3'].map((id) =>
+      manager.onSessionCreated({
+        type: 'session.created',
+        properties: { info: { id, parentID: 'parent', title: id } },
+      }),
+    ),
+  );
+  expect(paused).toHaveBeenCalledTimes(1);
+
+  await clock.advance(500);
+  expect(paused).toHaveBeenCalledTimes(2);
+  await manager.cleanup();
+});
+
+test('TmuxSessionManager fails spawns queued before a queue flush', async () => {
+  const clock = new FakeClock();
+  spyOn(utils, 'getQueueFlushedAt').mockResolvedValue(clock.now());
+  const ctx = createMockPluginInput();
+  const manager = new TmuxSessionManager(ctx, createTmuxConfig(), 'http://localhost:4096', clock);
+
+  const promise = manager.onSessionCreated({
+    type: 'session.created',
+    properties: { info: { id: 'flushed', parentID: 'parent', title: 'Flushed' } },
+  });
+  await clock.advance(0);
+  await promise;
+
+  expect(spawnCalls).toHaveLength(0);
+  expect(manager.getQueuedSpawns()).toEqual([]);
+  await manager.cleanup();
+});
+
+test('TmuxSessionManager holds sessions while the queue is full with headless-track', async () => {
+  const ctx = createMockPluginInput();
+  const manager = new TmuxSessionManager(
+    ctx,
+    createTmuxConfig({
+      max_queue_depth: 1,
+      queue_overflow_policy: 'headless-track',
+      poll_interval_min_ms: 50,
+    }),
+    'http://localhost:4096',
+  );
+
+  const created = ['first', 'second', 'third'].map((id) =>
+    manager.onSessionCreated({
+      type: 'session.created',
+      properties: { info: { id, parentID: 'parent', title: id } },
+    }),
+  );
+  await waitFor(() => manager.getQueuedSpawns().length === 3);
+  expect(manager.getQueuedSpawns().map((spawn) => spawn.state)).toEqual([
+    'spawning',
+    'queued',
+    'held',
+  ]);
+
+  spawnControllers.get('first')?.resolve({ success: true, paneId: '%70' });
+  await waitFor(() => spawnControllers.has('second'));
+  spawnControllers.get('second')?.resolve({ success: true, paneId: '%71' });
+  await waitFor(() => spawnControllers.has('third'));
+  spawnControllers.get('third')?.resolve({ success: true, paneId: '%72' });
+  await Promise.all(created);
+
+  expect(spawnCalls.map((call) => call.sessionId)).toEqual(['first', 'second', 'third']);
+  await manager.cleanup();
+});
+
+test('TmuxSessionManager records a spawn lost to a full queue as overflow', async () => {
+  const ctx = createMockPluginInput();
+  const manager = new TmuxSessionManager(
+    ctx,
+    createTmuxConfig({ max_queue_depth: 1, queue_overflow_policy: 'reject-new', session_history: true }),
+    'http://localhost:4096',
+  );
+  const historySpy = spyOn(sessionHistory, 'appendSessionHistory').mockImplementation(() => {});
+
+  const created = ['first', 'second', 'third'].map((id) =>
+    manager.onSessionCreated({
+      type: 'session.created',
+      properties: { info: { id, parentID: 'parent', title: id } },
+    }),
+  );
+  await waitFor(() => historySpy.mock.calls.length === 1);
+  expect(historySpy.mock.calls[0][0]).toMatchObject({ sessionId: 'third', reason: 'overflow' });
+
+  spawnControllers.get('first')?.resolve({ success: true, paneId: '%73' });
+  await waitFor(() => spawnControllers.has('second'));
+  spawnControllers.get('second')?.resolve({ success: true, paneId: '%74' });
+  await Promise.all(created);
+  await manager.cleanup();
+});
+
+test('TmuxSessionManager stops holding sessions past its held-session bound', async () => {
+  spyOn(utils, 'isSpawningPaused').mockResolvedValue(true);
+  const ctx = createMockPluginInput();
+  const manager = new TmuxSessionManager(
+    ctx,
+    createTmuxConfig({ session_history: true }),
+    'http://localhost:4096',
+  );
+  const historySpy = spyOn(sessionHistory, 'appendSessionHistory').mockImplementation(() => {});
+
+  for (let i = 0; i <= 200; i++) {
+    await manager.onSessionCreated({
+      type: 'session.created',
+      properties: { info: { id: `held-${i}`, parentID: 'parent', title: `Held ${i}` } },
+    });
+  }
+
+  expect(manager.getQueuedSpawns()).toHaveLength(200);
+  expect(historySpy).toHaveBeenCalledTimes(1);
+  expect(historySpy.mock.calls[0][0]).toMatchObject({ sessionId: 'held-200', reason: 'overflow' });
+  await manager.cleanup();
+});
+
+test('TmuxSessionManager adopts an existing pane instead of spawning a duplicate', async () => {
+  const ctx = createMockPluginInput();
+  const manager = new TmuxSessionManager(ctx, createTmuxConfig(), 'http://localhost:4096');
+  spyOn(utils, 'listAgentPanes').mockResolvedValue([
+    { paneId: '%60', sessionId: 'restarted', serverUrl: 'http://localhost:4096', pid: 60, dead: false },
+  ]);
+  spyOn(utils, 'hasAttachProcess').mockReturnValue(true);
+
+  await manager.onSessionCreated({
+    type: 'session.created',
+    properties: { info: { id: 'restarted', parentID: 'parent', title: 'Restarted' } },
+  });
+
+  expect(spawnCalls).toHaveLength(0);
+  await manager.onSessionDeleted({ type: 'session.deleted', properties: { info: { id: 'restarted' } } });
+  expect(utils.closeTmuxPane).toHaveBeenCalledWith('%60');
+  await manager.cleanup();
+});
+
+test('TmuxSessionManager debounces the layout on the injected clock', async () => {
+  const clock = new FakeClock();
+  const ctx = createMockPluginInput();
+  const manager = new TmuxSessionManager(
+    ctx,
+    createTmuxConfig({ layout_debounce_ms: 150 }),
+    'http://localhost:4096',
+    clock,
+  );
+
+  const promise = manager.onSessionCreated({
+    type: 'session.created',
+    properties: { info: { id: 'clocked', parentID: 'parent', title: 'Clocked' } },
+  });
+  await waitFor(() => spawnControllers.has('clocked'));
+  spawnControllers.get('clocked')?.resolve({ success: true, paneId: '%61' });
+  await promise;
+
+  await clock.advance(149);
+  expect(layoutCallCount).toBe(0);
+  await clock.advance(1);
+  expect(layoutCallCount).toBe(1);
+  await manager.cleanup();
+});
+
+test('TmuxSessionManager runs its pane lifecycle on injected dependencies', async () => {
+  const clock = new FakeClock();
+  const panes = new FakePaneController();
+  const statuses = new FakeStatusSource();
+  const manager = new TmuxSessionManager(
+    createMockPluginInput(),
+    createTmuxConfig(),
+    'http://localhost:4096',
+    clock,
+    { panes, statuses },
+  );
+
+  statuses.statuses = { faked: { type: 'busy' } };
+  const promise = manager.onSessionCreated({
+    type: 'session.created',
+    properties: { info: { id: 'faked', parentID: 'parent', title: 'Faked' } },
+  });
+  await clock.advance(0);
+  await promise;
+  expect([...panes.panes.keys()]).toEqual(['%100']);
+
+  statuses.statuses = { faked: { type: 'idle' } };
+  await clock.advance(10_000);
+  expect(panes.closed).toEqual(['%100']);
+  expect(utils.spawnTmuxPane).not.toHaveBeenCalled();
+  await manager.cleanup();
+});
+
+test('TmuxSessionManager refreshes the auto layout on control-mode layout changes instead of polling', async () => {
+  let notify: Parameters<typeof utils.onTmuxNotification>[0] = () => {};
+  spyOn(utils, 'onTmuxNotification').mockImplementation((listener) => {
+    notify = listener;
+    return () => {};
+  });
+  const connected = spyOn(utils, 'hasTmuxControlClient').mockReturnValue(true);
+  const clock = new FakeClock();
+  const panes = new FakePaneController();
+  const statuses = new FakeStatusSource();
+  const manager = new TmuxSessionManager(
+    createMockPluginInput(),
+    createTmuxConfig({ tmux_control_mode: true }),
+    'http://localhost:4096',
+    clock,
+    { panes, statuses, handleSignals: false },
+  );
+
+  statuses.statuses = { resized: { type: 'busy' } };
+  const created = manager.onSessionCreated({
+    type: 'session.created',
+    properties: { info: { id: 'resized', parentID: 'parent', title: 'Resized' } },
+  });
+  await clock.advance(0);
+  await created;
+  await clock.advance(10_000);
+  expect(panes.refreshCount).toBe(0);
+
+  notify({ name: 'layout-change', args: ['@1', '21be,200x50,0,0,0', '21be,200x50,0,0,0', '*'] });
+  expect(panes.refreshCount).toBe(1);
+
+  connected.mockReturnValue(false);
+  await clock.advance(10_000);
+  expect(panes.refreshCount).toBeGreaterThan(1);
+  await manager.cleanup();
+});
+
+test('TmuxSessionManager styles panes and reports spawn failures through the pane controller', async () => {
+  const clock = new FakeClock();
+  const panes = new FakePaneController();
+  const statuses = new FakeStatusSource();
+  const manager = new TmuxSessionManager(
+    createMockPluginInput(),
+    createTmuxConfig({ pane_status_colors: true }),
+    'http://localhost:4096',
+    clock,
+    { panes, statuses, handleSignals: false },
+  );
+
+  statuses.statuses = { styled: { type: 'busy' } };
+  const styled = manager.onSessionCreated({
+    type: 'session.created',
+    properties: { info: { id: 'styled', parentID: 'parent', title: 'Styled' } },
+  });
+  await clock.advance(0);
+  await styled;
+  await manager.handleEvent({ type: 'session.error', properties: { sessionID: 'styled' } });
+  expect(panes.styles.get('%100')).toBe('error');
+
+  panes.nextSpawn = { success: false, reason: 'tmux_missing', attempts: 1 };
+  const failed = manager.onSessionCreated({
+    type: 'session.created',
+    properties: { info: { id: 'unspawned', parentID: 'parent', title: 'Unspawned' } },
+  });
+  await clock.advance(0);
+  await failed;
+  expect(panes.messages).toEqual([expect.stringContaining('no pane for "Unspawned"')]);
+  expect(utils.showTmuxMessage).not.toHaveBeenCalled();
+  await manager.cleanup();
+});
+
+test('TmuxSessionManager sweeps orphaned panes once per reaper interval, not every poll', async () => {
+  const clock = new FakeClock();
+  const panes = new FakePaneController();
+  const statuses = new FakeStatusSource();
+  const manager = new TmuxSessionManager(
+    createMockPluginInput(),
+    createTmuxConfig({ reaper_enabled: true, reaper_dry_run: true, reaper_interval_ms: 30_000 }),
+    'http://localhost:4096',
+    clock,
+    { panes, statuses, handleSignals: false },
+  );
+  const sweep = spyOn(manager, 'sweepOrphanedPanes');
+
+  statuses.statuses = { polled: { type: 'busy' } };
+  const created = manager.onSessionCreated({
+    type: 'session.created',
+    properties: { info: { id: 'polled', parentID: 'parent', title: 'Polled' } },
+  });
+  await clock.advance(0);
+  await created;
+
+  await clock.advance(20_000);
+  expect(sweep).not.toHaveBeenCalled();
+  await clock.advance(15_000);
+  expect(sweep).toHaveBeenCalledTimes(1);
+  await manager.cleanup();
+});
+
+test('TmuxSessionManager hands its panes over to a replacement instead of closing them', async () => {
+  const clock = new FakeClock();
+  const panes = new FakePaneController();
+  const statuses = new FakeStatusSource();
+  const create = (overrides?: Partial<TmuxConfig>) =>
+    new TmuxSessionManager(
+      createMockPluginInput(),
+      createTmuxConfig(overrides),
+      'http://localhost:4096',
+      clock,
+      { panes, statuses, handleSignals: false },
+    );
+
+  const previous = create();
+  statuses.statuses = { kept: { type: 'busy' } };
+  const created = previous.onSessionCreated({
+    type: 'session.created',
+    properties: { info: { id: 'kept', parentID: 'parent', title: 'Kept' } },
+  });
+  await clock.advance(0);
+  await created;
+
+  const handingOver = previous.handOver();
+  await clock.advance(0);
+  const handover = await handingOver;
+  expect(handover.sessions.map((s) => s.paneId)).toEqual(['%100']);
+  expect(panes.closed).toEqual([]);
+
+  const replacement = create({ pane_title_max_width: 20 });
+  replacement.adopt(handover);
+  statuses.statuses = { kept: { type: 'idle' } };
+  await clock.advance(10_000);
+  expect(panes.closed).toEqual(['%100']);
+  await replacement.cleanup();
+});
+
+test('TmuxSessionManager hands queued spawns over without a final reaper scan', async () => {
+  const clock = new FakeClock();
+  const panes = new FakePaneController();
+  const statuses = new FakeStatusSource();
+  const reaperShutdown = spyOn(ZombieReaper.prototype, 'shutdown').mockResolvedValue(undefined);
+  const create = () =>
+    new TmuxSessionManager(
+      createMockPluginInput(),
+      createTmuxConfig({ reaper_enabled: true }),
+      'http://localhost:4096',
+      clock,
+      { panes, statuses, handleSignals: false },
+    );
+
+  const previous = create();
+  const spawn = panes.spawnPane;
+  const gate = createControlledPromise<void>();
+  panes.spawnPane = async (...args) => {
+    await gate.promise;
+    return spawn(...args);
+  };
+  statuses.statuses = { first: { type: 'busy' }, second: { type: 'busy' } };
+  const created = ['first', 'second'].map((id) =>
+    previous.onSessionCreated({
+      type: 'session.created',
+      properties: { info: { id, parentID: 'parent', title: id } },
+    }),
+  );
+  await clock.advance(0);
+
+  const handingOver = previous.handOver();
+  gate.resolve();
+  await clock.advance(0);
+  const handover = await handingOver;
+  await Promise.all(created);
+  expect(handover.sessions.map((s) => s.sessionId)).toEqual(['first']);
+  expect(handover.queued.map((event) => event.properties?.info?.id)).toEqual(['second']);
+  expect(reaperShutdown).toHaveBeenCalledWith({ finalScan: false });
+
+  panes.spawnPane = spawn;
+  const replacement = create();
+  replacement.adopt(handover);
+  await clock.advance(0);
+  await waitFor(() => panes.panes.size === 2);
+  expect([...panes.panes.values()].map((pane) => pane.sessionId)).toEqual(['first', 'second']);
+  await replacement.cleanup();
+});
+
+test('TmuxSessionManager closes every pane on a close-all request made after it started', async () => {
+  const clock = new FakeClock();
+  const panes = new FakePaneController();
+  const statuses = new FakeStatusSource();
+  const historySpy = spyOn(sessionHistory, 'appendSessionHistory').mockImplementation(() => {});
+  const closeAll = spyOn(utils, 'getCloseAllRequest').mockResolvedValue({
+    at: clock.now() - 1,
+    reason: 'stale',
+    abort: true,
+  });
+  const manager = new TmuxSessionManager(
+    createMockPluginInput(),
+    createTmuxConfig({ session_history: true }),
+    'http://localhost:4096',
+    clock,
+    { panes, statuses, handleSignals: false },
+  );
+
+  statuses.statuses = { first: { type: 'busy' }, second: { type: 'busy' } };
+  for (const id of ['first', 'second']) {
+    const created = manager.onSessionCreated({
+      type: 'session.created',
+      properties: { info: { id, parentID: 'parent', title: id } },
+    });
+    await clock.advance(1000);
+    await created;
+  }
+  await clock.advance(5_000);
+  expect(panes.closed).toEqual([]);
+
+  closeAll.mockResolvedValue({ at: clock.now(), reason: 'demo', abort: true });
+  await clock.advance(10_000);
+
+  expect(panes.closed).toEqual(['%100', '%101']);
+  expect(statuses.aborted).toEqual(['first', 'second']);
+  expect(historySpy.mock.calls.map((call) => [call[0].reason, call[0].note])).toEqual([
+    ['close_all', 'demo'],
+    ['close_all', 'demo'],
+  ]);
+  await manager.cleanup();
+});
+
+test('TmuxSessionManager drops held sessions on close-all and ignores requests for other servers', async () => {
+  const clock = new FakeClock();
+  const panes = new FakePaneController();
+  const statuses = new FakeStatusSource();
+  const historySpy = spyOn(sessionHistory, 'appendSessionHistory').mockImplementation(() => {});
+  const closeAll = spyOn(utils, 'getCloseAllRequest').mockResolvedValue(null);
+  const manager = new TmuxSessionManager(
+    createMockPluginInput(),
+    createTmuxConfig({ session_history: true }),
+    'http://localhost:4096',
+    clock,
+    { panes, statuses, handleSignals: false },
+  );
+
+  statuses.statuses = { open: { type: 'busy' } };
+  const created = manager.onSessionCreated({
+    type: 'session.created',
+    properties: { info: { id: 'open', parentID: 'parent', title: 'Open' } },
+  });
+  await clock.advance(0);
+  await created;
+  spyOn(utils, 'isSpawningPaused').mockResolvedValue(true);
+  await clock.advance(500);
+  await manager.onSessionCreated({
+    type: 'session.created',
+    properties: { info: { id: 'held', parentID: 'parent', title: 'Held' } },
+  });
+
+  closeAll.mockResolvedValue({ at: clock.now(), serverUrls: ['http://localhost:4097'], abort: false });
+  await clock.advance(10_000);
+  expect(panes.closed).toEqual([]);
+
+  closeAll.mockResolvedValue({ at: clock.now(), serverUrls: ['http://127.0.0.1:4096'], abort: false });
+  await clock.advance(10_000);
+  expect(panes.closed).toEqual(['%100']);
+  expect(historySpy.mock.calls.map((call) => [call[0].sessionId, call[0].reason])).toEqual([
+    ['held', 'flushed'],
+    ['open', 'close_all'],
+  ]);
+  await manager.cleanup();
+});
+
+test('TmuxSessionManager routes agents to the window of the first matching rule', async () => {
+  const ctx = createMockPluginInput();
+  const manager = new TmuxSessionManager(
+    ctx,
+    createTmuxConfig({
+      window_rules: [
+        { title_match: '^review', window: 'reviews' },
+        { title_match: 'test', window: 'tests' },
+      ],
+    }),
+    'http://localhost:4096',
+  );
+
+  const routed = manager.onSessionCreated({
+    type: 'session.created',
+    properties: { info: { id: 'tester', parentID: 'parent', title: 'Write the Tests' } },
+  });
+  await waitFor(() => spawnControllers.has('tester'));
+  spawnControllers.get('tester')?.resolve({ success: true, paneId: '%62' });
+  await routed;
+
+  const plain = manager.onSessionCreated({
+    type: 'session.created',
+    properties: { info: { id: 'explorer', parentID: 'parent', title: 'Explore' } },
+  });
+  await waitFor(() => spawnControllers.has('explorer'));
+  spawnControllers.get('explorer')?.resolve({ success: true, paneId: '%63' });
+  await plain;
+
+  const groups = (utils.spawnTmuxPane as ReturnType<typeof spyOn>).mock.calls.map((call) => call[5]);
+  expect(groups).toEqual([{ key: 'rule:tests', name: 'tests' }, undefined]);
+  await manager.cleanup();
+});
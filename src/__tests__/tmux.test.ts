@@ -1,13 +1,24 @@
 import { test, expect, beforeEach, afterEach, mock } from 'bun:test';
 import {
+  applyTmuxLayout,
+  closeTmuxPane,
+  focusTmuxPane,
+  formatStatusLine,
+  previewTmuxLayout,
+  setStatusLineText,
+  showTmuxMessage,
   spawnTmuxPane,
   setSpawnAsyncFn,
   resetSpawnAsyncFn,
   resetServerCheck,
   resetTmuxPathCache,
+  resetTmuxSession,
+  recordTmuxSession,
+  refreshAutoLayout,
+  spawnAsyncFn,
   type SpawnPaneResult,
 } from '../utils/tmux';
-import type { TmuxConfig } from '../config';
+import { TmuxConfigSchema, type TmuxConfig } from '../config';
 
 interface SpawnResult {
   exitCode: number;
@@ -42,32 +53,24 @@ function createMockSpawnFn(): {
 
 function createTestConfig(overrides: Partial<TmuxConfig> = {}): TmuxConfig {
   return {
-    enabled: true,
-    layout: 'main-vertical',
-    main_pane_size: 60,
-    spawn_delay_ms: 300,
-    max_retry_attempts: 2,
-    layout_debounce_ms: 150,
-    max_agents_per_column: 3,
-    reaper_enabled: true,
-    reaper_interval_ms: 30000,
-    reaper_min_zombie_checks: 3,
-    reaper_grace_period_ms: 5000,
-    reaper_auto_self_destruct: true,
+    ...TmuxConfigSchema.parse({}),
+    status_line: false,
+    session_history: false,
     reaper_self_destruct_timeout_ms: 600000,
-    rotate_port: false,
-    max_ports: 10,
     ...overrides,
   };
 }
 
 const originalEnv = process.env.TMUX;
+const originalPane = process.env.TMUX_PANE;
 let mockData: ReturnType<typeof createMockSpawnFn>;
 
 beforeEach(() => {
   process.env.TMUX = '/tmp/tmux-1000/default,12345,0';
+  delete process.env.TMUX_PANE;
   resetServerCheck();
   resetTmuxPathCache();
+  resetTmuxSession();
   resetSpawnAsyncFn();
   mockData = createMockSpawnFn();
 });
@@ -78,6 +81,11 @@ afterEach(() => {
   } else {
     delete process.env.TMUX;
   }
+  if (originalPane) {
+    process.env.TMUX_PANE = originalPane;
+  } else {
+    delete process.env.TMUX_PANE;
+  }
   resetSpawnAsyncFn();
 });
 
@@ -142,7 +150,7 @@ test('spawnTmuxPane retries on failure with exponential backoff', async () => {
   globalThis.fetch = mockFetch as unknown as typeof fetch;
 
   try {
-    const config = createTestConfig({ max_retry_attempts: 2 });
+    const config = createTestConfig({ max_retry_attempts: 2, spawn_backoff_jitter: 0 });
     const result = await spawnTmuxPane('session-2', 'Retry Task', config, 'http://localhost:4096');
 
     expect(result.success).toBe(true);
@@ -190,6 +198,7 @@ test('spawnTmuxPane returns failure after max retries exhausted', async () => {
 
     expect(result.success).toBe(false);
     expect(result.paneId).toBeUndefined();
+    expect(result.error).toBe('fail 3');
     expect(splitCallCount).toBe(3);
   } finally {
     globalThis.fetch = originalFetch;
@@ -261,7 +270,7 @@ test('spawnTmuxPane returns early when config.enabled is false', async () => {
   const config = createTestConfig({ enabled: false });
   const result = await spawnTmuxPane('session-6', 'Disabled', config, 'http://localhost:4096');
 
-  expect(result.success).toBe(false);
+  expect(result).toEqual({ success: false, reason: 'disabled' });
   expect(mockData.calls.length).toBe(0);
 });
 
@@ -274,3 +283,524 @@ test('spawnTmuxPane returns early when not inside tmux', async () => {
   expect(result.success).toBe(false);
   expect(mockData.calls.length).toBe(0);
 });
+
+test('spawnTmuxPane restores focus when the new pane steals it', async () => {
+  mockData.results.push(
+    { exitCode: 0, stdout: '/usr/bin/tmux\n', stderr: '' },
+    { exitCode: 0, stdout: 'tmux 3.3\n', stderr: '' },
+    { exitCode: 0, stdout: '%5\n', stderr: '' },
+    { exitCode: 0, stdout: '', stderr: '' },
+    { exitCode: 0, stdout: '0 %1\n1 %5\n', stderr: '' },
+    { exitCode: 0, stdout: '', stderr: '' },
+    { exitCode: 0, stdout: '', stderr: '' },
+  );
+
+  setSpawnAsyncFn(mockData.fn);
+
+  const mockFetch = mock(async () => new Response('ok', { status: 200 }));
+  const originalFetch = globalThis.fetch;
+  globalThis.fetch = mockFetch as unknown as typeof fetch;
+
+  try {
+    const config = createTestConfig({ focus_on_spawn: 'never' });
+    const result = await spawnTmuxPane('session-8', 'Focus', config, 'http://localhost:4096');
+
+    expect(result.success).toBe(true);
+    const restoreCall = mockData.calls.find(
+      (c) => c.command.includes('select-pane') && c.command.includes('-l'),
+    );
+    expect(restoreCall).toBeDefined();
+  } finally {
+    globalThis.fetch = originalFetch;
+  }
+});
+
+test('spawnTmuxPane focuses the first agent pane when focus_on_spawn is first', async () => {
+  mockData.results.push(
+    { exitCode: 0, stdout: '/usr/bin/tmux\n', stderr: '' },
+    { exitCode: 0, stdout: 'tmux 3.3\n', stderr: '' },
+    { exitCode: 0, stdout: '%5\n', stderr: '' },
+    { exitCode: 0, stdout: '', stderr: '' },
+    { exitCode: 0, stdout: '1 %1\n0 %5\n', stderr: '' },
+    { exitCode: 0, stdout: '', stderr: '' },
+    { exitCode: 0, stdout: '', stderr: '' },
+  );
+
+  setSpawnAsyncFn(mockData.fn);
+
+  const mockFetch = mock(async () => new Response('ok', { status: 200 }));
+  const originalFetch = globalThis.fetch;
+  globalThis.fetch = mockFetch as unknown as typeof fetch;
+
+  try {
+    const config = createTestConfig({ focus_on_spawn: 'first' });
+    const result = await spawnTmuxPane('session-9', 'Focus', config, 'http://localhost:4096');
+
+    expect(result.success).toBe(true);
+    const focusCall = mockData.calls.find(
+      (c) => c.command.join(' ') === '/usr/bin/tmux select-pane -t %5',
+    );
+    expect(focusCall).toBeDefined();
+  } finally {
+    globalThis.fetch = originalFetch;
+  }
+});
+
+test('spawnTmuxPane tags the new pane with its session and server', async () => {
+  mockData.results.push(
+    { exitCode: 0, stdout: '/usr/bin/tmux\n', stderr: '' },
+    { exitCode: 0, stdout: 'tmux 3.3\n', stderr: '' },
+    { exitCode: 0, stdout: '%5\n', stderr: '' },
+    { exitCode: 0, stdout: '', stderr: '' },
+    { exitCode: 0, stdout: '', stderr: '' },
+    { exitCode: 0, stdout: '', stderr: '' },
+  );
+
+  setSpawnAsyncFn(mockData.fn);
+
+  const mockFetch = mock(async () => new Response('ok', { status: 200 }));
+  const originalFetch = globalThis.fetch;
+  globalThis.fetch = mockFetch as unknown as typeof fetch;
+
+  try {
+    const result = await spawnTmuxPane('session-10', 'Tag', createTestConfig(), 'http://localhost:4096');
+
+    expect(result.success).toBe(true);
+    const tagCall = mockData.calls.find((c) => c.command.includes('set-option'));
+    expect(tagCall?.command).toContain('@opentmux_session');
+    expect(tagCall?.command).toContain('session-10');
+    expect(tagCall?.command).toContain('http://localhost:4096');
+  } finally {
+    globalThis.fetch = originalFetch;
+  }
+});
+
+test('spawnTmuxPane runs the attach command behind attach_command_prefix', async () => {
+  mockData.results.push(
+    { exitCode: 0, stdout: '/usr/bin/tmux\n', stderr: '' },
+    { exitCode: 0, stdout: 'tmux 3.3\n', stderr: '' },
+    { exitCode: 0, stdout: '%5\n', stderr: '' },
+    { exitCode: 0, stdout: '', stderr: '' },
+    { exitCode: 0, stdout: '', stderr: '' },
+    { exitCode: 0, stdout: '', stderr: '' },
+  );
+
+  setSpawnAsyncFn(mockData.fn);
+
+  const mockFetch = mock(async () => new Response('ok', { status: 200 }));
+  const originalFetch = globalThis.fetch;
+  globalThis.fetch = mockFetch as unknown as typeof fetch;
+
+  try {
+    const config = createTestConfig({ attach_command_prefix: 'ssh devbox --' });
+    const result = await spawnTmuxPane('session-11', 'Remote', config, 'http://localhost:4096');
+
+    expect(result.success).toBe(true);
+    const splitCall = mockData.calls.find((c) => c.command.includes('split-window'));
+    expect(splitCall?.command).toContain(
+      'ssh devbox -- opencode attach http://localhost:4096 --session session-11',
+    );
+  } finally {
+    globalThis.fetch = originalFetch;
+  }
+});
+
+test('spawnAsync kills commands that exceed their timeout', async () => {
+  const startedAt = Date.now();
+  const result = await spawnAsyncFn(['sleep', '5'], { timeoutMs: 50 });
+
+  expect(result.timedOut).toBe(true);
+  expect(result.exitCode).toBe(1);
+  expect(Date.now() - startedAt).toBeLessThan(2000);
+});
+
+test('formatStatusLine summarizes agents and queued spawns', () => {
+  expect(formatStatusLine(0, 0)).toBe('');
+  expect(formatStatusLine(1, 0)).toBe('⚙ 1 agent');
+  expect(formatStatusLine(3, 1)).toBe('⚙ 3 agents | 1 queued');
+});
+
+test('focusTmuxPane unzooms the window when another pane is zoomed', async () => {
+  mockData.results.push(
+    { exitCode: 0, stdout: '/usr/bin/tmux\n', stderr: '' },
+    { exitCode: 0, stdout: 'tmux 3.3\n', stderr: '' },
+    { exitCode: 0, stdout: '1 0\n', stderr: '' },
+    { exitCode: 0, stdout: '', stderr: '' },
+    { exitCode: 0, stdout: '', stderr: '' },
+  );
+  setSpawnAsyncFn(mockData.fn);
+
+  expect(await focusTmuxPane('%7')).toBe(true);
+
+  const commands = mockData.calls.map((c) => c.command.slice(1).join(' '));
+  expect(commands).toContain('resize-pane -Z -t %7');
+  expect(commands).toContain('select-window -t %7 ; select-pane -t %7');
+});
+
+test('focusTmuxPane fails for an unknown pane', async () => {
+  mockData.results.push(
+    { exitCode: 0, stdout: '/usr/bin/tmux\n', stderr: '' },
+    { exitCode: 0, stdout: 'tmux 3.3\n', stderr: '' },
+    { exitCode: 1, stdout: '', stderr: "can't find pane: %99" },
+  );
+  setSpawnAsyncFn(mockData.fn);
+
+  expect(await focusTmuxPane('%99')).toBe(false);
+  expect(mockData.calls).toHaveLength(3);
+});
+
+test('grid mode fills a free slot in place and frees it again on close', async () => {
+  const commands: string[] = [];
+  setSpawnAsyncFn(async (command) => {
+    const args = command.slice(1).join(' ');
+    commands.push(args);
+    if (command[0] === 'which') return { exitCode: 0, stdout: '/usr/bin/tmux\n', stderr: '' };
+    if (args === 'display-message -p #{pane_id}') return { exitCode: 0, stdout: '%0\n', stderr: '' };
+    if (args.startsWith('list-panes -F #{pane_id}\t')) {
+      // Slot order is by position, not by pane id
+      const panes = ['%0\t0\t0\t', '%4\t121\t0\t', '%3\t121\t26\t1', '%2\t161\t0\t1', '%1\t161\t26\t1'];
+      return { exitCode: 0, stdout: `${panes.join('\n')}\n`, stderr: '' };
+    }
+    if (args.startsWith('list-panes -t')) return { exitCode: 1, stdout: '', stderr: '' };
+    return { exitCode: 0, stdout: '', stderr: '' };
+  });
+
+  const mockFetch = mock(async () => new Response('ok', { status: 200 }));
+  const originalFetch = globalThis.fetch;
+  globalThis.fetch = mockFetch as unknown as typeof fetch;
+
+  try {
+    const config = createTestConfig({ layout_mode: 'grid' });
+    const result = await spawnTmuxPane('session-grid', 'Grid', config, 'http://localhost:4096');
+
+    expect(result).toEqual({ success: true, paneId: '%3', attempts: 1 });
+    expect(commands).toContain(
+      'respawn-pane -k -t %3 opencode attach http://localhost:4096 --session session-grid',
+    );
+    expect(commands).toContain('set-option -p -u -t %3 @opentmux_placeholder');
+    expect(commands.some((c) => c.startsWith('split-window') || c.startsWith('select-layout'))).toBe(
+      false,
+    );
+
+    commands.length = 0;
+    expect(await closeTmuxPane('%3')).toBe(true);
+    expect(commands.some((c) => c.startsWith('respawn-pane -k -t %3 sh -c'))).toBe(true);
+    expect(commands).toContain(
+      'set-option -p -t %3 @opentmux_placeholder 1 ; select-pane -t %3 -T waiting for agent',
+    );
+    expect(commands.some((c) => c.startsWith('kill-pane') || c.startsWith('select-layout'))).toBe(
+      false,
+    );
+  } finally {
+    globalThis.fetch = originalFetch;
+  }
+});
+
+test('grid mode kills a closed pane outside the grid instead of keeping it as a placeholder', async () => {
+  const commands: string[] = [];
+  setSpawnAsyncFn(async (command) => {
+    const args = command.slice(1).join(' ');
+    commands.push(args);
+    if (command[0] === 'which') return { exitCode: 0, stdout: '/usr/bin/tmux\n', stderr: '' };
+    if (args === 'display-message -p #{pane_id}') return { exitCode: 0, stdout: '%0\n', stderr: '' };
+    if (args.startsWith('new-window')) return { exitCode: 0, stdout: '%9\n', stderr: '' };
+    if (args.startsWith('list-panes -F #{pane_id}\t')) {
+      const panes = ['%0\t0\t0\t', '%4\t121\t0\t', '%3\t121\t26\t1'];
+      return { exitCode: 0, stdout: `${panes.join('\n')}\n`, stderr: '' };
+    }
+    if (args.startsWith('list-panes -t')) return { exitCode: 1, stdout: '', stderr: '' };
+    return { exitCode: 0, stdout: '', stderr: '' };
+  });
+
+  const mockFetch = mock(async () => new Response('ok', { status: 200 }));
+  const originalFetch = globalThis.fetch;
+  globalThis.fetch = mockFetch as unknown as typeof fetch;
+
+  try {
+    const config = createTestConfig({ layout_mode: 'grid' });
+    const result = await spawnTmuxPane(
+      'session-grouped',
+      'Grouped',
+      config,
+      'http://localhost:4096',
+      undefined,
+      { key: 'parent-1', name: 'Parent' },
+    );
+    expect(result).toMatchObject({ success: true, paneId: '%9' });
+
+    commands.length = 0;
+    expect(await closeTmuxPane('%9')).toBe(true);
+    expect(commands).toContain('kill-pane -t %9');
+    expect(commands.some((c) => c.startsWith('respawn-pane'))).toBe(false);
+  } finally {
+    globalThis.fetch = originalFetch;
+  }
+});
+
+test('layout and split commands target the window opencode runs in', async () => {
+  process.env.TMUX_PANE = '%0';
+  const commands: string[] = [];
+  setSpawnAsyncFn(async (command) => {
+    const args = command.slice(1).join(' ');
+    commands.push(args);
+    if (command[0] === 'which') return { exitCode: 0, stdout: '/usr/bin/tmux\n', stderr: '' };
+    if (args.includes('#{window_width}')) return { exitCode: 0, stdout: '200 50\n', stderr: '' };
+    if (args.includes('#{window_zoomed_flag}')) return { exitCode: 0, stdout: '0\n', stderr: '' };
+    if (args.startsWith('list-panes -t %0 -F #{pane_id}')) return { exitCode: 0, stdout: '%0\n%5\n', stderr: '' };
+    if (args.startsWith('split-window')) return { exitCode: 0, stdout: '%5\n', stderr: '' };
+    return { exitCode: 0, stdout: '', stderr: '' };
+  });
+
+  const mockFetch = mock(async () => new Response('ok', { status: 200 }));
+  const originalFetch = globalThis.fetch;
+  globalThis.fetch = mockFetch as unknown as typeof fetch;
+
+  try {
+    await spawnTmuxPane('session-window', 'Window', createTestConfig(), 'http://localhost:4096');
+    await applyTmuxLayout();
+
+    expect(commands.find((c) => c.startsWith('split-window'))).toStartWith('split-window -h -t %0 ');
+    const layoutCommands = commands.filter((c) =>
+      /^(select-layout|list-panes|display-message|set-window-option)/.test(c),
+    );
+    expect(layoutCommands.length).toBeGreaterThan(0);
+    for (const command of layoutCommands) {
+      expect(command).toContain('-t %0');
+    }
+  } finally {
+    globalThis.fetch = originalFetch;
+  }
+});
+
+test('main_pane_height in cells sets an absolute main pane height', async () => {
+  const commands: string[] = [];
+  setSpawnAsyncFn(async (command) => {
+    const args = command.slice(1).join(' ');
+    commands.push(args);
+    if (command[0] === 'which') return { exitCode: 0, stdout: '/usr/bin/tmux\n', stderr: '' };
+    if (args.includes('#{window_zoomed_flag}')) return { exitCode: 0, stdout: '0\n', stderr: '' };
+    if (args.startsWith('split-window')) return { exitCode: 0, stdout: '%5\n', stderr: '' };
+    return { exitCode: 0, stdout: '', stderr: '' };
+  });
+
+  const mockFetch = mock(async () => new Response('ok', { status: 200 }));
+  const originalFetch = globalThis.fetch;
+  globalThis.fetch = mockFetch as unknown as typeof fetch;
+
+  try {
+    const config = createTestConfig({
+      layout: 'main-horizontal',
+      main_pane_size_unit: 'cells',
+      main_pane_height: 15,
+    });
+    await spawnTmuxPane('session-height', 'Height', config, 'http://localhost:4096');
+    await applyTmuxLayout();
+
+    expect(commands).toContain('set-window-option main-pane-height 15');
+  } finally {
+    globalThis.fetch = originalFetch;
+  }
+});
+
+test('a layout preset is applied as a custom layout string', async () => {
+  const wide = '21be,200x50,0,0{100x50,0,0,0,99x50,101,0[99x25,101,0,1,99x24,101,26,2]}';
+  const commands: string[] = [];
+  setSpawnAsyncFn(async (command) => {
+    const args = command.slice(1).join(' ');
+    commands.push(args);
+    if (command[0] === 'which') return { exitCode: 0, stdout: '/usr/bin/tmux\n', stderr: '' };
+    if (args.includes('#{window_zoomed_flag}')) return { exitCode: 0, stdout: '0\n', stderr: '' };
+    if (args.startsWith('split-window')) return { exitCode: 0, stdout: '%5\n', stderr: '' };
+    return { exitCode: 0, stdout: '', stderr: '' };
+  });
+
+  const mockFetch = mock(async () => new Response('ok', { status: 200 }));
+  const originalFetch = globalThis.fetch;
+  globalThis.fetch = mockFetch as unknown as typeof fetch;
+
+  try {
+    const config = createTestConfig({ layout: 'wide', layouts: { wide } });
+    await spawnTmuxPane('session-preset', 'Preset', config, 'http://localhost:4096');
+    commands.length = 0;
+    await applyTmuxLayout();
+
+    expect(commands).toContain(`select-layout ${wide}`);
+    expect(commands.some((args) => args.includes('main-pane'))).toBe(false);
+  } finally {
+    globalThis.fetch = originalFetch;
+  }
+});
+
+test('auto layout follows the window size and switches when it is resized', async () => {
+  let windowSize = '120 50';
+  const commands: string[] = [];
+  setSpawnAsyncFn(async (command) => {
+    const args = command.slice(1).join(' ');
+    commands.push(args);
+    if (command[0] === 'which') return { exitCode: 0, stdout: '/usr/bin/tmux\n', stderr: '' };
+    if (args.includes('#{window_zoomed_flag}')) return { exitCode: 0, stdout: '0\n', stderr: '' };
+    if (args.includes('#{window_width} #{window_height}')) {
+      return { exitCode: 0, stdout: `${windowSize}\n`, stderr: '' };
+    }
+    if (args.startsWith('split-window')) return { exitCode: 0, stdout: '%5\n', stderr: '' };
+    return { exitCode: 0, stdout: '', stderr: '' };
+  });
+
+  const mockFetch = mock(async () => new Response('ok', { status: 200 }));
+  const originalFetch = globalThis.fetch;
+  globalThis.fetch = mockFetch as unknown as typeof fetch;
+
+  try {
+    const config = createTestConfig({ layout: 'auto' });
+    await spawnTmuxPane('session-auto', 'Auto', config, 'http://localhost:4096');
+    commands.length = 0;
+    await applyTmuxLayout();
+    expect(commands).toContain('select-layout main-horizontal');
+
+    commands.length = 0;
+    expect(await refreshAutoLayout()).toBe(false);
+    expect(commands.some((args) => args.startsWith('select-layout'))).toBe(false);
+
+    windowSize = '80 30';
+    expect(await refreshAutoLayout()).toBe(true);
+    expect(commands).toContain('select-layout tiled');
+  } finally {
+    globalThis.fetch = originalFetch;
+  }
+});
+
+test('previewTmuxLayout reports the layout it would apply without applying it', async () => {
+  const commands: string[] = [];
+  setSpawnAsyncFn(async (command) => {
+    const args = command.slice(1).join(' ');
+    commands.push(args);
+    if (command[0] === 'which') return { exitCode: 0, stdout: '/usr/bin/tmux\n', stderr: '' };
+    if (args.includes('#{window_width} #{window_height}')) return { exitCode: 0, stdout: '100 30\n', stderr: '' };
+    if (args.includes('#{window_layout}')) return { exitCode: 0, stdout: 'abcd,100x30,0,0,1\n', stderr: '' };
+    if (args.startsWith('list-panes')) return { exitCode: 0, stdout: '%1\n%2\n%3\n', stderr: '' };
+    return { exitCode: 0, stdout: '', stderr: '' };
+  });
+
+  const preview = await previewTmuxLayout(createTestConfig({ layout: 'auto' }));
+
+  expect(preview).toEqual({
+    width: 100,
+    height: 30,
+    panes: 3,
+    current: 'abcd,100x30,0,0,1',
+    next: 'tiled',
+  });
+  expect(commands.some((args) => args.startsWith('select-layout'))).toBe(false);
+});
+
+test('commands target the tmux session recorded at init', async () => {
+  const commands: string[] = [];
+  setSpawnAsyncFn(async (command) => {
+    const args = command.slice(1).join(' ');
+    commands.push(args);
+    if (command[0] === 'which') return { exitCode: 0, stdout: '/usr/bin/tmux\n', stderr: '' };
+    if (args.includes('#{session_id} #{pane_id}')) return { exitCode: 0, stdout: '$3 %7\n', stderr: '' };
+    if (args.startsWith('split-window')) return { exitCode: 0, stdout: '%8\n', stderr: '' };
+    return { exitCode: 0, stdout: '', stderr: '' };
+  });
+
+  const mockFetch = mock(async () => new Response('ok', { status: 200 }));
+  const originalFetch = globalThis.fetch;
+  globalThis.fetch = mockFetch as unknown as typeof fetch;
+
+  try {
+    expect(await recordTmuxSession()).toBe('$3');
+    await spawnTmuxPane('session-pinned', 'Pinned', createTestConfig(), 'http://localhost:4096');
+    await setStatusLineText('⚙ 1 agent');
+
+    expect(commands.find((c) => c.startsWith('split-window'))).toStartWith('split-window -h -t %7 ');
+    expect(commands).toContain('set-option -q -t $3 @opentmux_status ⚙ 1 agent');
+  } finally {
+    globalThis.fetch = originalFetch;
+  }
+});
+
+test('showTmuxMessage prints a title containing a tmux format instead of running it', async () => {
+  const commands: string[][] = [];
+  setSpawnAsyncFn(async (command) => {
+    commands.push(command);
+    if (command[0] === 'which') return { exitCode: 0, stdout: '/usr/bin/tmux\n', stderr: '' };
+    return { exitCode: 0, stdout: '', stderr: '' };
+  });
+
+  expect(await showTmuxMessage('✗ #(touch x) failed')).toBe(true);
+
+  const message = commands.find((c) => c[1] === 'display-message');
+  expect(message?.at(-1)).toBe('✗ ##(touch x) failed');
+});
+
+test('spawnTmuxPane escapes tmux formats in group window names and pane titles', async () => {
+  const commands: string[][] = [];
+  setSpawnAsyncFn(async (command) => {
+    commands.push(command);
+    if (command[0] === 'which') return { exitCode: 0, stdout: '/usr/bin/tmux\n', stderr: '' };
+    if (command[1] === 'new-window') return { exitCode: 0, stdout: '%9\n', stderr: '' };
+    return { exitCode: 0, stdout: '', stderr: '' };
+  });
+
+  const mockFetch = mock(async () => new Response('ok', { status: 200 }));
+  const originalFetch = globalThis.fetch;
+  globalThis.fetch = mockFetch as unknown as typeof fetch;
+
+  try {
+    const result = await spawnTmuxPane(
+      'session-grouped',
+      'Child #(touch x)',
+      createTestConfig(),
+      'http://localhost:4096',
+      undefined,
+      { key: 'parent-1', name: 'Parent #(touch x)' },
+    );
+
+    expect(result.success).toBe(true);
+    const newWindow = commands.find((c) => c[1] === 'new-window') ?? [];
+    expect(newWindow[newWindow.indexOf('-n') + 1]).toBe('Parent ##(touch x)');
+    const title = commands.find((c) => c[1] === 'select-pane' && c.includes('-T')) ?? [];
+    expect(title.at(-1)).toBe('Child ##(touch x)');
+  } finally {
+    globalThis.fetch = originalFetch;
+  }
+});
+
+test('an agent that would break min_agent_pane_width opens in the overflow window', async () => {
+  const spawnWith = async (panes: string[]) => {
+    const commands: string[] = [];
+    setSpawnAsyncFn(async (command) => {
+      const args = command.slice(1).join(' ');
+      commands.push(args);
+      if (command[0] === 'which') return { exitCode: 0, stdout: '/usr/bin/tmux\n', stderr: '' };
+      if (args.includes('#{window_width} #{window_height}')) {
+        return { exitCode: 0, stdout: '120 40\n', stderr: '' };
+      }
+      if (args.endsWith('-F #{pane_id}')) return { exitCode: 0, stdout: panes.join('\n'), stderr: '' };
+      if (args.startsWith('split-window') || args.startsWith('new-window')) {
+        return { exitCode: 0, stdout: '%9\n', stderr: '' };
+      }
+      return { exitCode: 0, stdout: '', stderr: '' };
+    });
+    const config = createTestConfig({ max_agents_per_column: 1, min_agent_pane_width: 40 });
+    await spawnTmuxPane('session-wide', 'Wide', config, 'http://localhost:4096');
+    resetTmuxPathCache();
+    return commands;
+  };
+
+  const mockFetch = mock(async () => new Response('ok', { status: 200 }));
+  const originalFetch = globalThis.fetch;
+  globalThis.fetch = mockFetch as unknown as typeof fetch;
+
+  try {
+    const roomy = await spawnWith(['%1']);
+    expect(roomy.some((c) => c.startsWith('split-window -h'))).toBe(true);
+
+    const crowded = await spawnWith(['%1', '%2', '%3']);
+    expect(crowded.some((c) => c.startsWith('split-window'))).toBe(false);
+    expect(crowded.find((c) => c.startsWith('new-window'))).toContain('-n agents');
+  } finally {
+    globalThis.fetch = originalFetch;
+  }
+});
@@ -0,0 +1,54 @@
+import { afterEach, beforeEach, expect, mock, test } from 'bun:test';
+import * as fs from 'node:fs';
+import * as os from 'node:os';
+import * as path from 'node:path';
+import { exportTranscript, getTranscriptPath, renderTranscript } from '../utils/transcript';
+
+let dir: string;
+const originalFetch = globalThis.fetch;
+
+beforeEach(() => {
+  dir = fs.mkdtempSync(path.join(os.tmpdir(), 'opentmux-transcript-'));
+});
+
+afterEach(() => {
+  globalThis.fetch = originalFetch;
+  fs.rmSync(dir, { recursive: true, force: true });
+});
+
+const messages = [
+  { info: { role: 'user' }, parts: [{ type: 'text', text: 'Find the bug' }] },
+  {
+    info: { role: 'assistant' },
+    parts: [
+      { type: 'tool', tool: 'grep' },
+      { type: 'text', text: 'Fixed it.' },
+    ],
+  },
+];
+
+test('renderTranscript renders text parts and tool notes', () => {
+  const markdown = renderTranscript('ses_1', 'Bug hunt', messages);
+
+  expect(markdown).toContain('# Bug hunt');
+  expect(markdown).toContain('## User\n\nFind the bug');
+  expect(markdown).toContain('_Tool: grep_');
+  expect(markdown).toContain('Fixed it.');
+});
+
+test('exportTranscript writes the transcript under .opentmux/transcripts', async () => {
+  globalThis.fetch = mock(async () => Response.json(messages)) as unknown as typeof fetch;
+
+  const savedTo = await exportTranscript('http://localhost:4096', dir, 'ses_1', 'Bug hunt', 1000);
+
+  expect(savedTo).toBe(getTranscriptPath(dir, 'ses_1'));
+  expect(savedTo).toBe(path.join(dir, '.opentmux', 'transcripts', 'ses_1.md'));
+  expect(fs.readFileSync(savedTo!, 'utf-8')).toContain('Fixed it.');
+});
+
+test('exportTranscript returns null when the server has no such session', async () => {
+  globalThis.fetch = mock(async () => new Response('', { status: 404 })) as unknown as typeof fetch;
+
+  expect(await exportTranscript('http://localhost:4096', dir, 'ses_x', 'X', 1000)).toBeNull();
+  expect(fs.existsSync(getTranscriptPath(dir, 'ses_x'))).toBe(false);
+});
@@ -0,0 +1,37 @@
+import { afterEach, expect, mock, test } from 'bun:test';
+import { compareVersions, fetchLatestRelease } from '../utils/upgrade';
+
+const originalFetch = globalThis.fetch;
+
+afterEach(() => {
+  globalThis.fetch = originalFetch;
+});
+
+test('compareVersions orders numerically and puts prereleases first', () => {
+  expect(compareVersions('1.10.0', '1.9.2')).toBeGreaterThan(0);
+  expect(compareVersions('1.5.7', '1.5.7')).toBe(0);
+  expect(compareVersions('v1.5.7', '1.5.8')).toBeLessThan(0);
+  expect(compareVersions('2.0.0-beta.1', '2.0.0')).toBeLessThan(0);
+  expect(compareVersions('2.0.0', '2.0.0-beta.1')).toBeGreaterThan(0);
+});
+
+test('fetchLatestRelease reads the latest version from the registry', async () => {
+  const mockFetch = mock(async () =>
+    Response.json({ version: '1.6.0', dist: { integrity: 'sha512-abc' } }),
+  );
+  globalThis.fetch = mockFetch as unknown as typeof fetch;
+
+  expect(await fetchLatestRelease('https://registry.example.com/')).toEqual({ version: '1.6.0' });
+  expect(mockFetch).toHaveBeenCalledWith(
+    'https://registry.example.com/opentmux/latest',
+    expect.anything(),
+  );
+});
+
+test('fetchLatestRelease returns null when the registry is unreachable', async () => {
+  globalThis.fetch = mock(async () => {
+    throw new Error('offline');
+  }) as unknown as typeof fetch;
+
+  expect(await fetchLatestRelease()).toBeNull();
+});
@@ -1,6 +1,7 @@
 import { test, expect, beforeEach, afterEach, mock, spyOn } from 'bun:test';
-import { ZombieReaper } from '../zombie-reaper';
+import { ZombieReaper, formatReapCandidate } from '../zombie-reaper';
 import * as processUtils from '../utils/process';
+import { FakeClock } from './fake-clock';
 
 // Mock dependencies
 const mockFetch = mock();
@@ -23,7 +24,8 @@ beforeEach(() => {
   // Default mocks
   spyOn(processUtils, 'findProcessIds').mockReturnValue([]);
   spyOn(processUtils, 'getProcessCommand').mockReturnValue('opencode attach --session ses_123');
-  spyOn(processUtils, 'safeKill').mockReturnValue(true);
+  spyOn(processUtils, 'terminateProcess').mockResolvedValue(true);
+  spyOn(processUtils, 'getConnectedPorts').mockReturnValue([]);
   
   reaper = new ZombieReaper('http://localhost:4096', DEFAULT_OPTIONS);
 });
@@ -76,7 +78,7 @@ test('scanOnce filters by serverUrl', async () => {
   });
 
   // Spy on kill
-  const safeKillSpy = spyOn(processUtils, 'safeKill');
+  const terminateSpy = spyOn(processUtils, 'terminateProcess');
   
   // Mock Date.now to force kill condition
   spyOn(Date, 'now').mockReturnValue(1000000);
@@ -112,6 +114,17 @@ test('classifyProcess identifies zombie sessions', async () => {
   expect(status).toBe('zombie');
 });
 
+test('classifyProcess uses shared active sessions instead of fetching', async () => {
+  const shared = new ZombieReaper('http://localhost:4096', {
+    ...DEFAULT_OPTIONS,
+    activeSessions: async () => new Set(['ses_shared']),
+  });
+
+  expect(await shared.classifyProcess('ses_shared')).toBe('active');
+  expect(await shared.classifyProcess('ses_other')).toBe('zombie');
+  expect(mockFetch).not.toHaveBeenCalled();
+});
+
 test('classifyProcess returns unknown if server fails', async () => {
   mockFetch.mockRejectedValue(new Error('Network error'));
 
@@ -143,6 +156,17 @@ test('grace period prevents killing new processes', async () => {
   expect(reaper.shouldKill(pid)).toBe(false);
 });
 
+test('grace period elapses on the injected clock', async () => {
+  const clock = new FakeClock();
+  const clockedReaper = new ZombieReaper('url', { ...DEFAULT_OPTIONS, minZombieChecks: 1, clock });
+  clockedReaper.markAsZombie(321);
+
+  await clock.advance(4999);
+  expect(clockedReaper.shouldKill(321)).toBe(false);
+  await clock.advance(1);
+  expect(clockedReaper.shouldKill(321)).toBe(true);
+});
+
 test('scanOnce kills confirmed zombies', async () => {
   // Setup: 1 zombie process
   spyOn(processUtils, 'findProcessIds').mockReturnValue([500]);
@@ -151,7 +175,7 @@ test('scanOnce kills confirmed zombies', async () => {
   // Server says no sessions
   mockFetch.mockImplementation(async () => new Response(JSON.stringify({ data: {} }), { status: 200 }));
   
-  const safeKillSpy = spyOn(processUtils, 'safeKill');
+  const terminateSpy = spyOn(processUtils, 'terminateProcess');
   
   // Mock Date.now
   let time = 1000000;
@@ -159,22 +183,57 @@ test('scanOnce kills confirmed zombies', async () => {
   
   // 1st scan
   await reaper.scanOnce();
-  expect(safeKillSpy).not.toHaveBeenCalled();
+  expect(terminateSpy).not.toHaveBeenCalled();
   
   // 2nd scan
   await reaper.scanOnce();
-  expect(safeKillSpy).not.toHaveBeenCalled();
+  expect(terminateSpy).not.toHaveBeenCalled();
   
   // 3rd scan
   await reaper.scanOnce();
-  expect(safeKillSpy).not.toHaveBeenCalled();
+  expect(terminateSpy).not.toHaveBeenCalled();
   
   // Advance time > 5s
   time += 6000;
   
   // 4th scan
   await reaper.scanOnce();
-  expect(safeKillSpy).toHaveBeenCalledWith(500, 'SIGTERM');
+  expect(terminateSpy).toHaveBeenCalledWith(500, processUtils.DEFAULT_TERMINATE_POLICY);
+});
+
+test('scanOnce treats loopback spellings of the server URL as the same server', async () => {
+  spyOn(processUtils, 'findProcessIds').mockReturnValue([510, 511]);
+  spyOn(processUtils, 'getProcessCommand').mockImplementation((pid) =>
+    pid === 510
+      ? 'opencode attach http://[::1]:4096/ --session ses_zombie'
+      : 'opencode attach 127.0.0.1:4096 --session ses_zombie2',
+  );
+  mockFetch.mockImplementation(async () => new Response(JSON.stringify({ data: {} }), { status: 200 }));
+  const markSpy = spyOn(reaper, 'markAsZombie');
+
+  await reaper.scanOnce();
+
+  expect(markSpy).toHaveBeenCalledWith(510);
+  expect(markSpy).toHaveBeenCalledWith(511);
+});
+
+test('scanOnce leaves processes connected to another server alone', async () => {
+  spyOn(processUtils, 'findProcessIds').mockReturnValue([520]);
+  spyOn(processUtils, 'getProcessCommand').mockReturnValue('opencode attach http://localhost:4096 --session ses_other');
+  spyOn(processUtils, 'getConnectedPorts').mockReturnValue([4100]);
+  mockFetch.mockImplementation(async () => new Response(JSON.stringify({ data: {} }), { status: 200 }));
+  const terminateSpy = spyOn(processUtils, 'terminateProcess');
+  const markSpy = spyOn(reaper, 'markAsZombie');
+
+  let time = 1000000;
+  spyOn(Date, 'now').mockImplementation(() => time);
+  for (let i = 0; i < 4; i++) {
+    await reaper.scanOnce();
+    time += 6000;
+  }
+
+  expect(markSpy).not.toHaveBeenCalled();
+  expect(terminateSpy).not.toHaveBeenCalled();
 });
 
 test('reapAll (manual CLI) kills zombies immediately without grace period', async () => {
@@ -192,14 +251,72 @@ test('reapAll (manual CLI) kills zombies immediately without grace period', asyn
     return new Response(JSON.stringify({ data: {} }), { status: 200 });
   });
   
-  // Spy on process.kill since reapAll uses forceKill (direct process.kill) instead of safeKill
-  const killSpy = spyOn(process, 'kill');
+  const terminateSpy = spyOn(processUtils, 'terminateProcess');
 
   await ZombieReaper.reapAll();
 
   // Should kill 800 (zombie)
-  expect(killSpy).toHaveBeenCalledWith(800, 'SIGTERM');
+  expect(terminateSpy).toHaveBeenCalledWith(800, processUtils.DEFAULT_TERMINATE_POLICY);
   
   // Should NOT kill 801 (active)
-  expect(killSpy).not.toHaveBeenCalledWith(801, 'SIGTERM');
+  expect(terminateSpy).not.toHaveBeenCalledWith(801, expect.anything());
+});
+
+test('reapAll dry run reports zombies without killing them', async () => {
+  spyOn(processUtils, 'findProcessIds').mockReturnValue([900]);
+  spyOn(processUtils, 'getProcessCommand').mockReturnValue('opencode attach http://localhost:4096 --session ses_zombie');
+  spyOn(processUtils, 'getProcessStartTime').mockReturnValue(null);
+  mockFetch.mockImplementation(async () => new Response(JSON.stringify({ data: {} }), { status: 200 }));
+
+  const terminateSpy = spyOn(processUtils, 'terminateProcess');
+  const logSpy = spyOn(console, 'log').mockImplementation(() => {});
+
+  await ZombieReaper.reapAll({ ports: [], dryRun: true });
+
+  expect(terminateSpy).not.toHaveBeenCalled();
+  expect(logSpy).toHaveBeenCalledWith(expect.stringContaining('Would reap: PID 900  session ses_zombie'));
+});
+
+test('scanOnce in dry-run mode never kills', async () => {
+  reaper = new ZombieReaper('http://localhost:4096', { ...DEFAULT_OPTIONS, minZombieChecks: 1, gracePeriodMs: 0, dryRun: true });
+  spyOn(processUtils, 'findProcessIds').mockReturnValue([501]);
+  spyOn(processUtils, 'getProcessCommand').mockReturnValue('opencode attach http://localhost:4096 --session ses_zombie');
+  mockFetch.mockImplementation(async () => new Response(JSON.stringify({ data: {} }), { status: 200 }));
+
+  const terminateSpy = spyOn(processUtils, 'terminateProcess');
+
+  await reaper.scanOnce();
+  await reaper.scanOnce();
+
+  expect(terminateSpy).not.toHaveBeenCalled();
+});
+
+test('scanOnce reports each reaped zombie to onReaped', async () => {
+  const reaped: string[] = [];
+  reaper = new ZombieReaper('http://localhost:4096', {
+    ...DEFAULT_OPTIONS,
+    minZombieChecks: 1,
+    gracePeriodMs: 0,
+    onReaped: (sessionId) => reaped.push(sessionId),
+  });
+  spyOn(processUtils, 'findProcessIds').mockReturnValue([502]);
+  spyOn(processUtils, 'getProcessCommand').mockReturnValue('opencode attach http://localhost:4096 --session ses_zombie');
+  mockFetch.mockImplementation(async () => new Response(JSON.stringify({ data: {} }), { status: 200 }));
+
+  await reaper.scanOnce();
+
+  expect(reaped).toEqual(['ses_zombie']);
+});
+
+test('formatReapCandidate includes reason and age', () => {
+  const line = formatReapCandidate({
+    pid: 42,
+    command: 'opencode serve --port 4097',
+    sessionId: null,
+    port: 4097,
+    reason: 'inactive-server',
+    ageMs: (2 * 60 + 5) * 60_000,
+  });
+
+  expect(line).toBe('PID 42  session -  port 4097  reason inactive-server  age 2h5m  cmd opencode serve --port 4097');
 });
@@ -0,0 +1,237 @@
+import { chmodSync, mkdirSync, writeFileSync } from "node:fs";
+import { createServer, type IncomingMessage, type Server, type ServerResponse } from "node:http";
+import type { AddressInfo } from "node:net";
+import { join } from "node:path";
+import type { PluginInput } from "../types";
+
+/**
+ * The fake opencode server `opentmux demo` plays a swarm against. It serves
+ * the endpoints opentmux calls from in-memory sessions and emits the plugin
+ * events a real server would send. Tests use their own fake in
+ * src/__tests__/opencode-fake.ts.
+ */
+
+type DemoSessionStatus = "busy" | "idle" | "error";
+
+interface DemoSession {
+  id: string;
+  parentID?: string;
+  title: string;
+  status: DemoSessionStatus;
+}
+
+export interface DemoEvent {
+  type: string;
+  properties?: unknown;
+}
+
+/** One scripted state transition, applied afterMs after the previous step */
+type DemoStep =
+  | { afterMs: number; action: "create"; id: string; parentID?: string; title?: string }
+  | { afterMs: number; action: "status"; id: string; status: DemoSessionStatus }
+  | { afterMs: number; action: "delete"; id: string }
+  | { afterMs: number; action: "forget"; id: string };
+
+interface SwarmOptions {
+  agents: number;
+  /** Longest agent lifetime; lifetimes are random between a third of this and this */
+  maxLifetimeMs: number;
+  /** Delay between agents starting */
+  staggerMs?: number;
+}
+
+export class DemoOpencodeServer {
+  private readonly sessions = new Map<string, DemoSession>();
+  private readonly listeners = new Set<(event: DemoEvent) => void>();
+  private server: Server | null = null;
+  private stopped = false;
+
+  /** Starts listening on a free 127.0.0.1 port and returns the server URL */
+  async start(): Promise<string> {
+    const server = createServer((req, res) => this.handle(req, res));
+    await new Promise<void>((resolve, reject) => {
+      server.once("error", reject);
+      server.listen(0, "127.0.0.1", resolve);
+    });
+    this.server = server;
+    return `http://127.0.0.1:${(server.address() as AddressInfo).port}`;
+  }
+
+  async stop(): Promise<void> {
+    this.stopped = true;
+    const server = this.server;
+    this.server = null;
+    if (server) {
+      await new Promise<void>((resolve) => server.close(() => resolve()));
+    }
+  }
+
+  subscribe(listener: (event: DemoEvent) => void): () => void {
+    this.listeners.add(listener);
+    return () => {
+      this.listeners.delete(listener);
+    };
+  }
+
+  /**
+   * Applies the steps in order, waiting afterMs before each. Stops early once
+   * the server is stopped.
+   */
+  async play(steps: DemoStep[]): Promise<void> {
+    for (const step of steps) {
+      if (step.afterMs > 0) {
+        await new Promise((r) => setTimeout(r, step.afterMs));
+      }
+      if (this.stopped) return;
+
+      switch (step.action) {
+        case "create": {
+          const session: DemoSession = {
+            id: step.id,
+            parentID: step.parentID,
+            title: step.title ?? "Subagent",
+            status: "busy",
+          };
+          this.sessions.set(session.id, session);
+          this.emit({
+            type: "session.created",
+            properties: { info: { id: session.id, parentID: session.parentID, title: session.title } },
+          });
+          break;
+        }
+        case "status": {
+          const session = this.sessions.get(step.id);
+          if (!session) break;
+          session.status = step.status;
+          if (step.status === "idle") {
+            this.emit({ type: "session.idle", properties: { sessionID: step.id } });
+          } else if (step.status === "error") {
+            this.emit({ type: "session.error", properties: { sessionID: step.id, error: "demo error" } });
+          }
+          break;
+        }
+        case "delete":
+          if (this.sessions.delete(step.id)) {
+            this.emit({ type: "session.deleted", properties: { info: { id: step.id } } });
+          }
+          break;
+        case "forget":
+          // Dropped without an event, so its pane becomes a zombie for the reaper
+          this.sessions.delete(step.id);
+          break;
+      }
+    }
+  }
+
+  /** A plugin client backed by this server, for driving TmuxSessionManager */
+  client(): PluginInput["client"] {
+    return {
+      session: {
+        status: async () => ({ data: this.statuses() }),
+        subscribe: (callback) => this.subscribe(callback),
+      },
+    };
+  }
+
+  private statuses(): Record<string, { type: string }> {
+    return Object.fromEntries(
+      [...this.sessions.values()].map((session) => [session.id, { type: session.status }]),
+    );
+  }
+
+  private emit(event: DemoEvent): void {
+    for (const listener of this.listeners) {
+      listener(event);
+    }
+  }
+
+  private handle(req: IncomingMessage, res: ServerResponse): void {
+    const path = new URL(req.url ?? "/", "http://localhost").pathname;
+    const json = (status: number, body: unknown) => {
+      res.writeHead(status, { "content-type": "application/json" });
+      res.end(JSON.stringify(body));
+    };
+
+    if (path === "/health") return json(200, { healthy: true, version: "demo" });
+    if (path === "/session/status") return json(200, this.statuses());
+
+    const match = /^\/session\/([^/]+)(\/message|\/abort)?$/.exec(path);
+    const session = match ? this.sessions.get(decodeURIComponent(match[1])) : undefined;
+    if (!match || !session) return json(404, { error: "not found" });
+
+    if (match[2] === "/message") return json(200, []);
+    if (match[2] === "/abort") {
+      if (req.method !== "POST") return json(405, { error: "method not allowed" });
+      session.status = "idle";
+      return json(200, true);
+    }
+    return json(200, { id: session.id, parentID: session.parentID, title: session.title });
+  }
+}
+
+const SWARM_TITLES = [
+  "Explore the codebase",
+  "Write the tests",
+  "Review the diff",
+  "Update the docs",
+  "Fix the build",
+  "Profile the hot path",
+  "Triage the issues",
+  "Refactor the parser",
+];
+
+/**
+ * Scripts a parent session spawning a swarm of agents with random lifetimes.
+ * Most agents go idle; every fourth fails, and with three or more agents one
+ * is forgotten by the server so the reaper has a zombie to clean up. The
+ * parent is deleted last, closing whatever is left.
+ */
+export function swarmScript(options: SwarmOptions): DemoStep[] {
+  const staggerMs = options.staggerMs ?? 700;
+  const parentId = "ses_demo_parent";
+  const zombieIndex = options.agents >= 3 ? Math.floor(Math.random() * options.agents) : -1;
+
+  const timed: Array<{ at: number; step: DemoStep }> = [
+    { at: 0, step: { afterMs: 0, action: "create", id: parentId, title: "Demo" } },
+  ];
+  let end = 0;
+  for (let i = 0; i < options.agents; i++) {
+    const id = `ses_demo_${i + 1}`;
+    const start = 500 + i * staggerMs;
+    const lifetime = Math.round(
+      options.maxLifetimeMs / 3 + Math.random() * ((options.maxLifetimeMs * 2) / 3),
+    );
+    const title = SWARM_TITLES[i % SWARM_TITLES.length];
+    timed.push({ at: start, step: { afterMs: 0, action: "create", id, parentID: parentId, title } });
+
+    const finish: DemoStep =
+      i === zombieIndex
+        ? { afterMs: 0, action: "forget", id }
+        : { afterMs: 0, action: "status", id, status: i % 4 === 3 ? "error" : "idle" };
+    timed.push({ at: start + lifetime, step: finish });
+    end = Math.max(end, start + lifetime);
+  }
+  timed.push({ at: end + 3000, step: { afterMs: 0, action: "delete", id: parentId } });
+
+  // Stable sort keeps each agent's create ahead of its finish
+  timed.sort((a, b) => a.at - b.at);
+  let previous = 0;
+  return timed.map(({ at, step }) => {
+    const delayed = { ...step, afterMs: at - previous };
+    previous = at;
+    return delayed;
+  });
+}
+
+/**
+ * Writes an `opencode` script into dir that stands in for `opencode attach`:
+ * it prints its arguments and waits to be killed. Put dir first on PATH so
+ * agent panes run it instead of a real opencode.
+ */
+export function writeDemoOpencodeBin(dir: string): string {
+  mkdirSync(dir, { recursive: true });
+  const file = join(dir, "opencode");
+  writeFileSync(file, '#!/bin/sh\necho "fake opencode: $*"\nwhile :; do sleep 1; done\n');
+  chmodSync(file, 0o755);
+  return file;
+}
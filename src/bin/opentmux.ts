@@ -1,31 +1,112 @@
 #!/usr/bin/env node
 
-import { spawn, execSync } from "node:child_process";
+import { spawn, spawnSync, execSync, type ChildProcess } from "node:child_process";
+import { randomUUID } from "node:crypto";
 import { createServer } from "node:net";
 import { env, platform, exit, argv } from "node:process";
-import { existsSync, appendFileSync } from "node:fs";
-import { join, dirname } from "node:path";
-import { homedir } from "node:os";
+import { existsSync, appendFileSync, mkdirSync, mkdtempSync, renameSync, rmSync, writeFileSync } from "node:fs";
+import { createInterface } from "node:readline/promises";
+import { join, dirname, resolve } from "node:path";
+import { homedir, tmpdir } from "node:os";
 import { fileURLToPath } from "node:url";
+import {
+  LayoutSchema,
+  layoutReferenceError,
+  PluginConfigSchema,
+  TmuxConfigSchema,
+  TmuxLayoutSchema,
+} from "../config";
+import { TmuxSessionManager } from "../tmux-session-manager";
 import { ZombieReaper } from "../zombie-reaper";
-import { loadConfig } from "../utils/config-loader";
+import {
+  CONFIG_ENV_VAR,
+  formatUnknownKeys,
+  getConfigPaths,
+  getGlobalConfigPaths,
+  loadConfig,
+  loadConfigWithSources,
+  readConfigLayers,
+  validateConfigFile,
+} from "../utils/config-loader";
 import {
   safeExec,
   getListeningPids,
-  isProcessAlive,
   getProcessCommand,
-  safeKill,
-  waitForProcessExit,
   getProcessStartTime,
+  OWNER_ENV_VAR,
+  terminatePolicyFromConfig,
+  terminateProcess,
 } from "../utils/process";
-
-// Load config
-const config = loadConfig();
-const OPENCODE_PORT_START =
-  config.port || parseInt(env.OPENCODE_PORT || "4096", 10);
-const OPENCODE_PORT_MAX = OPENCODE_PORT_START + (config.max_ports || 10);
+import {
+  flagString,
+  formatCommandHelp,
+  formatHelp,
+  formatUsage,
+  matchCommand,
+  parseArgs,
+  type CommandSpec,
+  type FlagSpec,
+} from "../utils/cli";
+import {
+  formatBuildInfo,
+  getBuildInfo,
+  isSupportedOpencodeVersion,
+  isVersionMismatch,
+  MIN_OPENCODE_VERSION,
+  parseBuildInfo,
+  type BuildInfo,
+} from "../utils/build-info";
+import { COMPLETION_SHELLS, generateCompletion, isCompletionShell } from "../utils/completion";
+import { buildBindKeyCommands, resolveJumpTarget } from "../utils/keybindings";
+import { formatPortRange, getCandidatePorts } from "../utils/ports";
+import { isSameServer } from "../utils/server-url";
+import { getPaneOutputPath, readPaneOutput } from "../utils/pane-output";
+import { passthroughArgs } from "../utils/passthrough";
+import { findExecutablesOnPath, realPathOrNull } from "../utils/path-lookup";
+import { expandSessionName, tmuxLaunchArgs } from "../utils/tmux-launch";
+import { readSessionHistory, type SessionHistoryEntry } from "../utils/session-history";
+import { parseQueueReport, type QueuedSpawn } from "../utils/queue-report";
+import {
+  PACKAGE_NAME,
+  compareVersions,
+  fetchLatestRelease,
+  getInstalledVersion,
+} from "../utils/upgrade";
+import {
+  applyTmuxLayout,
+  focusTmuxPane,
+  isInsideTmux,
+  listAgentPanes,
+  previewTmuxLayout,
+  QUEUE_OPTION,
+  setCloseAllRequest,
+  setPaneTitle,
+  setQueueFlushedAt,
+  setSpawningPaused,
+  spawnTmuxPane,
+  VERSION_OPTION,
+} from "../utils/tmux";
+import {
+  acquirePortLock,
+  findServerRecords,
+  isOpentmuxServer,
+  readServerRecords,
+  recordServer,
+  releasePortLock,
+  removeServerRecord,
+} from "../utils/server-registry";
+import { DemoOpencodeServer, swarmScript, writeDemoOpencodeBin } from "./demo-server";
+
+// Load config (project overlay from the launch directory over global).
+// Reloaded by applyGlobalFlags() when --config names a file.
+let config = loadConfig(process.cwd());
+let CANDIDATE_PORTS = getCandidatePorts(config);
+let verbose = false;
+let forceNewSession = false;
+let detached = false;
 const LOG_FILE = "/tmp/opentmux.log";
 const HEALTH_TIMEOUT_MS = 1000;
+const PORT_HANDOFF_TIMEOUT_MS = 30_000;
 
 const __filename = fileURLToPath(import.meta.url);
 const __dirname = dirname(__filename);
@@ -33,6 +114,7 @@ const __dirname = dirname(__filename);
 function log(...args: unknown[]): void {
   const timestamp = new Date().toISOString();
   const message = `[${timestamp}] ${args.join(" ")}\n`;
+  if (verbose) process.stderr.write(message);
   try {
     appendFileSync(LOG_FILE, message);
   } catch {}
@@ -57,20 +139,50 @@ function spawnPluginUpdater(): void {
   } catch (error) {}
 }
 
-function findOpencodeBin(): string | null {
-  try {
-    const cmd = platform === "win32" ? "where opencode" : "which -a opencode";
-    const output = execSync(cmd, { encoding: "utf-8" }).trim().split("\n");
+/**
+ * Upgrades opentmux itself from the npm registry (npm verifies the tarball's
+ * integrity hash and replaces the install in one step), then hands off to
+ * `opencode upgrade` as before. `--check` only reports.
+ */
+async function runUpgrade(checkOnly: boolean, opencodeArgs: string[]): Promise<number> {
+  const installed = getInstalledVersion(__dirname);
+  const latest = await fetchLatestRelease();
+
+  if (!latest) {
+    console.error("❌ Could not reach the npm registry to check for opentmux updates.");
+  } else if (installed && compareVersions(latest.version, installed) <= 0) {
+    console.log(`✅ opentmux ${installed} is up to date.`);
+  } else if (checkOnly) {
+    console.log(`⬆️  opentmux ${latest.version} is available (installed: ${installed ?? "unknown"}).`);
+  } else {
+    console.log(`⬆️  Upgrading opentmux ${installed ?? "unknown"} → ${latest.version}...`);
+    const npmCmd = platform === "win32" ? "npm.cmd" : "npm";
+    const result = spawnSync(npmCmd, ["install", "-g", `${PACKAGE_NAME}@${latest.version}`], {
+      stdio: "inherit",
+    });
+    if (result.status !== 0) {
+      console.error("❌ npm install failed; opentmux was left unchanged.");
+      return 1;
+    }
+    console.log(`✅ opentmux upgraded to ${latest.version}.`);
+  }
 
-    const currentScript = argv[1];
+  if (checkOnly) return latest ? 0 : 1;
 
-    for (const bin of output) {
-      const normalizedBin = bin.trim();
-      if (normalizedBin.includes("opentmux") || normalizedBin === currentScript)
-        continue;
-      if (normalizedBin) return normalizedBin;
-    }
-  } catch (e) {}
+  const opencodeBin = findOpencodeBin();
+  if (!opencodeBin) return latest ? 0 : 1;
+  return spawnSync(opencodeBin, ["upgrade", ...opencodeArgs], { stdio: "inherit" }).status ?? 1;
+}
+
+function findOpencodeBin(): string | null {
+  // Skip opentmux itself, e.g. when it is installed as an `opencode` shim
+  const self = new Set(
+    [realPathOrNull(argv[1]), realPathOrNull(process.execPath)].filter(Boolean),
+  );
+  for (const bin of findExecutablesOnPath("opencode")) {
+    if (bin.includes("opentmux") || self.has(realPathOrNull(bin))) continue;
+    return bin;
+  }
 
   const commonPaths = [
     join(
@@ -249,7 +361,7 @@ async function tryReclaimPort(
     return false;
   }
 
-  let attemptedKill = false;
+  const stale: number[] = [];
   for (const pid of pids) {
     const command = getProcessCommand(pid);
     const tty = getProcessTty(pid);
@@ -303,41 +415,47 @@ async function tryReclaimPort(
       }
     }
 
+    if (!isOpentmuxServer(pid, port)) {
+      log(
+        "Port held by a process opentmux didn't start, skipping:",
+        port.toString(),
+        pid.toString(),
+      );
+      continue;
+    }
+
     log(
-      "Attempting to stop stale or non-opencode process:",
+      "Attempting to stop stale opentmux-owned process:",
       port.toString(),
       pid.toString(),
     );
-    attemptedKill = true;
-    try {
-      process.kill(pid, "SIGTERM");
-    } catch {}
+    stale.push(pid);
   }
 
-  if (!attemptedKill) return false;
-
-  await new Promise((resolve) => setTimeout(resolve, 700));
+  if (stale.length === 0) return false;
 
-  for (const pid of pids) {
-    if (isProcessAlive(pid)) {
-      log(
-        "Process still alive, sending SIGKILL:",
-        port.toString(),
-        pid.toString(),
-      );
-      try {
-        process.kill(pid, "SIGKILL");
-      } catch {}
-    }
-  }
+  const policy = terminatePolicyFromConfig(config);
+  const stopped = await Promise.all(stale.map((pid) => terminateProcess(pid, policy, { group: true })));
+  stale.forEach((pid, i) => {
+    if (!stopped[i]) log("Process survived SIGKILL:", port.toString(), pid.toString());
+  });
 
-  await new Promise((resolve) => setTimeout(resolve, 400));
   return checkPort(port);
 }
 
+/**
+ * Finds a free port and reserves it with a lock file, so concurrent launches
+ * can't both pick it between the free-port check and opencode binding it.
+ * The caller releases the reservation once the server is healthy.
+ */
 async function findAvailablePort(): Promise<number | null> {
   let tmuxPanePids: Set<number> | null = null;
-  for (let port = OPENCODE_PORT_START; port <= OPENCODE_PORT_MAX; port++) {
+  for (const port of CANDIDATE_PORTS) {
+    if (!acquirePortLock(port)) {
+      log("Port reserved by another launcher, skipping:", port);
+      continue;
+    }
+
     if (await checkPort(port)) return port;
 
     if (!tmuxPanePids) {
@@ -346,10 +464,776 @@ async function findAvailablePort(): Promise<number | null> {
 
     const reclaimed = await tryReclaimPort(port, tmuxPanePids);
     if (reclaimed && (await checkPort(port))) return port;
+
+    releasePortLock(port);
   }
   return null;
 }
 
+/**
+ * Holds the port reservation until opencode answers on it (or gives up).
+ */
+async function releasePortLockWhenHealthy(port: number): Promise<void> {
+  const deadline = Date.now() + PORT_HANDOFF_TIMEOUT_MS;
+  while (Date.now() < deadline) {
+    if (await isOpencodeHealthy(port)) break;
+    await new Promise((resolve) => setTimeout(resolve, 250));
+  }
+  releasePortLock(port);
+  log("Port reservation released:", port);
+}
+
+/**
+ * Stops the opencode server listening on a port and forgets its record.
+ * Servers opentmux didn't start are left running. Returns the number of
+ * processes signalled.
+ */
+async function stopServer(port: number): Promise<number> {
+  let stopped = 0;
+
+  for (const pid of getListeningPids(port)) {
+    const command = getProcessCommand(pid);
+    if (!command || !command.includes("opencode")) {
+      log("Not stopping non-opencode listener:", port, pid, command ?? "unknown");
+      continue;
+    }
+    if (!isOpentmuxServer(pid, port)) {
+      log("Not stopping server opentmux didn't start:", port, pid);
+      continue;
+    }
+
+    log("Stopping opencode server:", port, pid);
+    await terminateProcess(pid, terminatePolicyFromConfig(config), { group: true });
+    stopped++;
+  }
+
+  removeServerRecord(port);
+  return stopped;
+}
+
+/**
+ * Keeps the launcher alive until its foreground child exits. Keyboard signals
+ * (Ctrl-C, Ctrl-\) already reach the child through the terminal, so they are
+ * only swallowed here; signals sent to the launcher alone are passed on.
+ */
+function forwardSignals(child: ChildProcess): void {
+  for (const signal of ["SIGINT", "SIGQUIT"] as const) {
+    process.on(signal, () => {});
+  }
+  for (const signal of ["SIGTERM", "SIGHUP"] as const) {
+    process.on(signal, () => child.kill(signal));
+  }
+}
+
+/**
+ * Exits the way the child did: with its exit code, or by dying of the same
+ * signal, so scripts see the status they would get from the child itself.
+ */
+function exitLikeChild(code: number | null, signal: NodeJS.Signals | null): void {
+  if (signal) {
+    process.removeAllListeners(signal);
+    process.kill(process.pid, signal);
+    return;
+  }
+  exit(code ?? 0);
+}
+
+/**
+ * Called when the launcher's child exits. Stops a lingering server when
+ * kill_server_on_exit is set. When checkTmux is set (the child was a tmux
+ * client), a server still running inside a tmux pane is left alone because
+ * the user most likely just detached.
+ */
+async function handleServerOnExit(port: number, checkTmux: boolean): Promise<void> {
+  const pids = getListeningPids(port);
+  if (pids.length === 0) {
+    removeServerRecord(port);
+    return;
+  }
+
+  if (!config.kill_server_on_exit) return;
+
+  if (checkTmux) {
+    const tmuxPanePids = getTmuxPanePids();
+    if (pids.some((pid) => tmuxPanePids.size > 0 && isDescendantOf(pid, tmuxPanePids))) {
+      log("Server still running inside tmux, leaving it:", port);
+      return;
+    }
+  }
+
+  const stopped = await stopServer(port);
+  log("Stopped lingering servers on exit:", port, stopped);
+}
+
+/**
+ * Asks the plugins attached to this project's servers to close every agent
+ * pane they track at their next poll, and to drop agents still waiting for a
+ * pane. opencode and the plugin keep running, and agents that start
+ * afterwards still get panes.
+ */
+async function runSessionCloseAll(reason: string | undefined, abort: boolean): Promise<number> {
+  if (!isInsideTmux()) {
+    console.error("❌ opentmux session close-all must run inside tmux.");
+    return 1;
+  }
+
+  const records = findServerRecords(process.cwd());
+  const serverUrls = (records.length > 0 ? records.map((r) => r.port) : [config.port]).map(
+    (port) => `http://localhost:${port}`,
+  );
+  const panes = (await listAgentPanes()).filter((pane) =>
+    serverUrls.some((url) => isSameServer(url, pane.serverUrl)),
+  );
+  const waiting = readQueuedSpawns().filter((entry) => entry.state !== "spawning");
+  if (panes.length === 0 && waiting.length === 0) {
+    console.log("No agent panes open for this project.");
+    return 0;
+  }
+
+  if (!(await setCloseAllRequest({ at: Date.now(), serverUrls, reason, abort }))) {
+    console.error("❌ Could not update the tmux session.");
+    return 1;
+  }
+
+  const count = `${panes.length} agent pane${panes.length === 1 ? "" : "s"}`;
+  console.log(
+    abort
+      ? `Closing ${count} and aborting their sessions.`
+      : `Closing ${count}. Their sessions keep running on the server.`,
+  );
+  if (waiting.length > 0) {
+    console.log("Agents still waiting for a pane are dropped; `opentmux queue retry --id <session>` opens one later.");
+  }
+  return 0;
+}
+
+async function runSessionStop(target?: string): Promise<number> {
+  const port = target === undefined ? undefined : parsePortArg(target, "The port");
+  if (port === null) return 1;
+  const records = port
+    ? readServerRecords().filter((r) => r.port === port)
+    : findServerRecords(process.cwd());
+
+  if (records.length === 0) {
+    console.log(
+      target
+        ? `No opentmux-managed server recorded on port ${target}.`
+        : `No opentmux-managed servers recorded for ${process.cwd()}.`,
+    );
+    return 0;
+  }
+
+  for (const record of records) {
+    const stopped = await stopServer(record.port);
+    if (stopped > 0) {
+      console.log(`Stopped opencode server on port ${record.port}.`);
+    } else if (getListeningPids(record.port).length > 0) {
+      console.log(
+        `Port ${record.port} is held by a server opentmux didn't start; left it running and removed the stale record.`,
+      );
+    } else {
+      console.log(`No running server on port ${record.port}; removed stale record.`);
+    }
+  }
+  return 0;
+}
+
+function formatDuration(ms: number): string {
+  const seconds = Math.max(0, Math.round(ms / 1000));
+  if (seconds < 60) return `${seconds}s`;
+  const minutes = Math.floor(seconds / 60);
+  if (minutes < 60) return `${minutes}m${seconds % 60}s`;
+  return `${Math.floor(minutes / 60)}h${minutes % 60}m`;
+}
+
+function formatHistoryEntry(entry: SessionHistoryEntry): string {
+  const closed = new Date(entry.closedAt).toISOString().replace("T", " ").slice(0, 19);
+  return [
+    closed,
+    entry.sessionId,
+    entry.reason.padEnd(16),
+    formatDuration(entry.closedAt - entry.spawnedAt).padStart(7),
+    (entry.paneId ?? "-").padEnd(5),
+    `attempts ${entry.attempts}`,
+    entry.note ? `${entry.title} (${entry.note})` : entry.title,
+  ].join("  ");
+}
+
+function runSessionHistory(limitText: string | undefined, json: boolean): number {
+  const limit = limitText === undefined ? 20 : Number.parseInt(limitText, 10);
+  if (!Number.isInteger(limit) || limit < 1) {
+    console.error("❌ --limit expects a positive integer");
+    return 1;
+  }
+
+  const entries = readSessionHistory(limit);
+  if (json) {
+    console.log(JSON.stringify(entries, null, 2));
+    return 0;
+  }
+
+  if (entries.length === 0) {
+    console.log("No agent sessions recorded yet.");
+    return 0;
+  }
+
+  for (const entry of entries) {
+    console.log(formatHistoryEntry(entry));
+  }
+  return 0;
+}
+
+/**
+ * Reads a tmux user option of the current session, or of session when
+ * given. Null when tmux can't be run or the option can't be read.
+ */
+function readTmuxOption(option: string, session?: string): string | null {
+  const result = spawnSync(
+    "tmux",
+    ["show-options", "-qv", ...(session ? ["-t", session] : []), option],
+    { encoding: "utf-8", stdio: ["ignore", "pipe", "ignore"] },
+  );
+  if (result.error || result.status !== 0) return null;
+  return result.stdout.trim();
+}
+
+function readPluginBuildInfo(): BuildInfo | null {
+  if (!isInsideTmux()) return null;
+  const text = readTmuxOption(VERSION_OPTION);
+  return text === null ? null : parseBuildInfo(text);
+}
+
+function readOpencodeVersion(opencodeBin = findOpencodeBin()): string | null {
+  if (!opencodeBin) return null;
+  const result = spawnSync(opencodeBin, ["--version"], {
+    encoding: "utf-8",
+    timeout: 5000,
+  });
+  return result.status === 0 ? result.stdout.trim() || null : null;
+}
+
+/**
+ * Prints the launcher's build info, the plugin version opencode loaded in
+ * this tmux session, and the opencode version. Warns when launcher and
+ * plugin differ, since opencode installs its own copy of the plugin.
+ */
+function runVersion(json: boolean): number {
+  const launcher = getBuildInfo(__dirname);
+  const plugin = readPluginBuildInfo();
+  const opencode = readOpencodeVersion();
+
+  if (json) {
+    console.log(JSON.stringify({ opentmux: launcher, plugin, opencode }, null, 2));
+    return 0;
+  }
+
+  console.log(`opentmux ${formatBuildInfo(launcher)}`);
+  if (plugin) console.log(`plugin   ${formatBuildInfo(plugin)}`);
+  console.log(`opencode ${opencode ?? "not found"}`);
+
+  if (plugin && isVersionMismatch(launcher, plugin)) {
+    console.warn(
+      `⚠️  opencode loaded opentmux ${plugin.version} but the launcher is ${launcher.version}.`,
+    );
+    console.warn("   Run `opentmux upgrade` and restart opencode so both match.");
+  }
+  if (opencode && !isSupportedOpencodeVersion(opencode)) {
+    console.warn(
+      `⚠️  opencode ${opencode} is older than ${MIN_OPENCODE_VERSION}; agent panes may fail to attach.`,
+    );
+    console.warn("   Run `opencode upgrade`.");
+  }
+  return 0;
+}
+
+/**
+ * Prints the opencode servers opentmux started, grouped by project, then
+ * hands over to `opencode stats` for usage statistics.
+ */
+async function runStats(opencodeArgs: string[]): Promise<number> {
+  const records = readServerRecords().sort((a, b) => a.port - b.port);
+  const inRange = new Set(CANDIDATE_PORTS);
+  const now = Date.now();
+
+  console.log(`opentmux servers (port range ${formatPortRange(config)})`);
+  if (records.length === 0) {
+    console.log("  none recorded");
+  }
+
+  const byProject = new Map<string, typeof records>();
+  for (const record of records) {
+    const group = byProject.get(record.project) ?? [];
+    group.push(record);
+    byProject.set(record.project, group);
+  }
+
+  for (const [project, group] of byProject) {
+    console.log(`  ${project}`);
+    for (const record of group) {
+      const pid = record.pid ?? getListeningPids(record.port)[0] ?? null;
+      const healthy = await isOpencodeHealthy(record.port);
+      console.log(
+        [
+          `    :${record.port}`,
+          `pid ${pid ?? "-"}`.padEnd(11),
+          `up ${formatDuration(now - record.startedAt)}`.padEnd(10),
+          healthy ? "running" : "not responding",
+          inRange.has(record.port) ? "" : "(outside port range)",
+        ]
+          .join("  ")
+          .trimEnd(),
+      );
+    }
+  }
+
+  const used = records.filter((record) => inRange.has(record.port)).length;
+  console.log(`  ${used} of ${inRange.size} ports in use`);
+
+  const opencodeBin = findOpencodeBin();
+  if (!opencodeBin) {
+    console.error("❌ Could not find opencode for its usage statistics.");
+    return 1;
+  }
+  console.log("");
+  return spawnSync(opencodeBin, ["stats", ...opencodeArgs], { stdio: "inherit" }).status ?? 1;
+}
+
+function runStatusLine(session?: string): number {
+  // Not in tmux, or nothing published yet: print nothing
+  process.stdout.write(readTmuxOption("@opentmux_status", session) ?? "");
+  return 0;
+}
+
+function readQueuedSpawns(): QueuedSpawn[] {
+  const text = readTmuxOption(QUEUE_OPTION);
+  // Nothing published yet
+  if (!text) return [];
+  return parseQueueReport(text) ?? [];
+}
+
+/**
+ * Lists agents waiting for a pane, as the plugin last published them on this
+ * tmux session.
+ */
+function runQueue(json: boolean): number {
+  if (!isInsideTmux()) {
+    console.error("❌ opentmux queue must run inside tmux.");
+    return 1;
+  }
+
+  const entries = readQueuedSpawns();
+  if (json) {
+    console.log(JSON.stringify(entries, null, 2));
+    return 0;
+  }
+
+  if (entries.length === 0) {
+    console.log("No agents waiting for a pane.");
+    return 0;
+  }
+
+  const now = Date.now();
+  for (const entry of entries) {
+    console.log(
+      [
+        entry.sessionId,
+        entry.state.padEnd(8),
+        formatDuration(now - entry.since).padStart(7),
+        `retries ${entry.retries}`,
+        entry.title,
+      ].join("  "),
+    );
+  }
+  return 0;
+}
+
+/**
+ * Gives up on every agent waiting for a pane. The plugin fails queued ones as
+ * they come up and drops held ones when spawning resumes; the one being
+ * spawned right now still opens.
+ */
+async function runQueueFlush(): Promise<number> {
+  if (!isInsideTmux()) {
+    console.error("❌ opentmux queue flush must run inside tmux.");
+    return 1;
+  }
+
+  const waiting = readQueuedSpawns().filter((entry) => entry.state !== "spawning");
+  if (!(await setQueueFlushedAt(Date.now()))) {
+    console.error("❌ Could not update the tmux session.");
+    return 1;
+  }
+
+  console.log(
+    waiting.length === 0
+      ? "No agents waiting for a pane."
+      : `Flushed ${waiting.length} waiting agent${waiting.length === 1 ? "" : "s"}. \`opentmux queue retry --id <session>\` opens one later.`,
+  );
+  return 0;
+}
+
+/**
+ * Opens a pane for an agent whose spawn failed, overflowed or was flushed,
+ * the way `opentmux attach` does.
+ */
+async function runQueueRetry(sessionId: string, portFlag?: string): Promise<number> {
+  const failed = readSessionHistory()
+    .reverse()
+    .find((entry) => entry.sessionId === sessionId);
+  if (!failed || !["spawn_failed", "flushed", "overflow"].includes(failed.reason)) {
+    console.error(`❌ No failed, flushed or overflowed spawn recorded for ${sessionId}.`);
+    console.error("   See `opentmux session history`, or open it with `opentmux attach --session`.");
+    return 1;
+  }
+  return runAttach(sessionId, portFlag);
+}
+
+async function runPause(paused: boolean): Promise<number> {
+  if (!isInsideTmux()) {
+    console.error(`❌ opentmux ${paused ? "pause" : "resume"} must run inside tmux.`);
+    return 1;
+  }
+  if (!(await setSpawningPaused(paused))) {
+    console.error("❌ Could not update the tmux session.");
+    return 1;
+  }
+
+  console.log(
+    paused
+      ? "⏸  Paused agent panes. New agents are held until `opentmux resume`."
+      : "▶️  Resumed agent panes. Held agents will open shortly.",
+  );
+  return 0;
+}
+
+/**
+ * Re-applies the agent layout to this window, e.g. after moving panes by
+ * hand. A layout argument is used instead of config.layout for this run.
+ */
+async function runLayout(layout: string | undefined, preview: boolean): Promise<number> {
+  if (!isInsideTmux()) {
+    console.error("❌ opentmux layout must run inside tmux.");
+    return 1;
+  }
+
+  // Asked for explicitly, so apply even while the window is zoomed
+  const effective = { ...config, layout: layout ?? config.layout, defer_layout_when_zoomed: false };
+  const parsed = LayoutSchema.safeParse(effective.layout);
+  const error = parsed.success ? layoutReferenceError(effective) : parsed.error.issues[0]?.message;
+  if (error) {
+    console.error(`❌ ${error}`);
+    return 1;
+  }
+
+  const before = await previewTmuxLayout(effective);
+  if (!before) {
+    console.error("❌ Could not read the tmux window.");
+    return 1;
+  }
+
+  if (preview) {
+    console.log(`Window ${before.width}x${before.height} with ${before.panes} panes`);
+    console.log(`Current layout: ${before.current}`);
+    console.log(`Would apply:    ${before.next}`);
+    return 0;
+  }
+
+  await applyTmuxLayout(effective);
+  console.log(`✅ Applied ${before.next} to ${before.panes} panes.`);
+  return 0;
+}
+
+/**
+ * Command line that re-invokes this launcher, for use inside tmux bindings.
+ */
+function selfCommand(isRuntime: boolean): string {
+  const parts = isRuntime ? [argv[0], argv[1]] : [argv[0]];
+  return parts.map((part) => JSON.stringify(part)).join(" ");
+}
+
+function runBindKeys(key: string, isRuntime: boolean): number {
+  for (const command of buildBindKeyCommands(selfCommand(isRuntime), key)) {
+    const result = spawnSync("tmux", command, { stdio: "inherit" });
+    if (result.status !== 0) {
+      console.error(`❌ tmux ${command.join(" ")} failed`);
+      return 1;
+    }
+  }
+
+  console.log(
+    `✅ Installed agent jump keys: prefix + ${key}, then 1-9 to jump or n/p to cycle.`,
+  );
+  console.log("   Add `run-shell 'opentmux bind-keys'` to ~/.tmux.conf to keep them.");
+  return 0;
+}
+
+function runJump(target: string | undefined, windowId?: string): number {
+  if (!target) {
+    console.error("Usage: opentmux jump <1-9|next|prev> [window]");
+    return 1;
+  }
+
+  const result = spawnSync(
+    "tmux",
+    [
+      "list-panes",
+      ...(windowId ? ["-t", windowId] : []),
+      "-F",
+      "#{pane_id}\t#{pane_active}\t#{@opentmux_session}",
+    ],
+    { encoding: "utf-8" },
+  );
+  if (result.status !== 0) return 1;
+
+  const panes = result.stdout
+    .split("\n")
+    .map((line) => line.split("\t"))
+    .filter(([paneId, , sessionId]) => paneId && sessionId)
+    .map(([paneId, active]) => ({ paneId, active: active === "1" }));
+
+  const paneId = resolveJumpTarget(panes, target);
+  if (!paneId) {
+    spawnSync("tmux", ["display-message", `opentmux: no agent pane ${target}`]);
+    return 1;
+  }
+
+  spawnSync("tmux", ["select-pane", "-t", paneId]);
+  return 0;
+}
+
+/**
+ * Parses a port given on the command line. Returns null, after printing an
+ * error, unless it is an integer from 1 to 65535.
+ */
+function parsePortArg(text: string, name = "--port"): number | null {
+  const port = /^\d+$/.test(text.trim()) ? Number.parseInt(text, 10) : Number.NaN;
+  if (!Number.isInteger(port) || port < 1 || port > 65535) {
+    console.error(`❌ ${name} must be an integer from 1 to 65535, got "${text}"`);
+    return null;
+  }
+  return port;
+}
+
+/**
+ * Opens an agent pane for an existing session, or focuses the one already open.
+ * Works for any session on the server, not just ones spawned by the plugin.
+ */
+async function runAttach(sessionId: string, portFlag?: string): Promise<number> {
+  const flagPort = portFlag === undefined ? undefined : parsePortArg(portFlag);
+  if (flagPort === null) return 1;
+
+  if (!isInsideTmux()) {
+    console.error("❌ opentmux attach must be run inside tmux.");
+    return 1;
+  }
+
+  const existing = (await listAgentPanes()).find((pane) => pane.sessionId === sessionId);
+  if (existing && (await focusTmuxPane(existing.paneId))) {
+    console.log(`Focused existing pane ${existing.paneId} for ${sessionId}.`);
+    return 0;
+  }
+
+  const port = flagPort ?? findServerRecords(process.cwd())[0]?.port ?? config.port;
+  const serverUrl = `http://localhost:${port}`;
+
+  let title = sessionId;
+  try {
+    const response = await fetch(`${serverUrl}/session/${encodeURIComponent(sessionId)}`, {
+      signal: AbortSignal.timeout(2000),
+    });
+    if (response.status === 404) {
+      console.error(`❌ No session ${sessionId} on ${serverUrl}.`);
+      return 1;
+    }
+    if (response.ok) {
+      const info = (await response.json()) as { title?: unknown };
+      if (typeof info.title === "string" && info.title) title = info.title;
+    }
+  } catch {
+    console.error(`❌ No opencode server reachable at ${serverUrl}.`);
+    return 1;
+  }
+
+  const result = await spawnTmuxPane(sessionId, title, config, serverUrl);
+  if (!result.success || !result.paneId) {
+    console.error(`❌ Failed to open a pane for ${sessionId}.`);
+    return 1;
+  }
+
+  await applyTmuxLayout();
+  console.log(`Opened pane ${result.paneId} for ${sessionId}.`);
+  return 0;
+}
+
+async function runSessionFocus(sessionId: string): Promise<number> {
+  const pane = (await listAgentPanes()).find((p) => p.sessionId === sessionId);
+  if (!pane) {
+    console.error(`❌ No agent pane open for ${sessionId}.`);
+    return 1;
+  }
+
+  return (await focusTmuxPane(pane.paneId)) ? 0 : 1;
+}
+
+/**
+ * Renames a session on the server and retitles its pane. A running plugin also
+ * picks up the rename from the server's session.updated event.
+ */
+async function runSessionRename(
+  sessionId: string,
+  rawTitle: string,
+  portFlag?: string,
+): Promise<number> {
+  const title = rawTitle.trim();
+  if (!title) {
+    console.error("❌ --title must not be empty");
+    return 1;
+  }
+  const flagPort = portFlag === undefined ? undefined : parsePortArg(portFlag);
+  if (flagPort === null) return 1;
+
+  const pane = isInsideTmux()
+    ? (await listAgentPanes()).find((p) => p.sessionId === sessionId)
+    : undefined;
+  const serverUrl =
+    pane?.serverUrl ||
+    `http://localhost:${flagPort ?? findServerRecords(process.cwd())[0]?.port ?? config.port}`;
+
+  let renamed = false;
+  try {
+    const url = new URL(`/session/${encodeURIComponent(sessionId)}`, serverUrl);
+    const response = await fetch(url, {
+      method: "PATCH",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ title }),
+      signal: AbortSignal.timeout(2000),
+    });
+    renamed = response.ok;
+    if (!renamed) {
+      console.error(`⚠️  Server did not rename ${sessionId} (HTTP ${response.status}).`);
+    }
+  } catch {
+    console.error(`⚠️  No opencode server reachable at ${serverUrl}; only the pane was renamed.`);
+  }
+
+  if (pane) {
+    await setPaneTitle(pane.paneId, title, config.pane_title_max_width);
+  }
+
+  if (!renamed && !pane) {
+    console.error(`❌ Could not rename ${sessionId}.`);
+    return 1;
+  }
+
+  console.log(`Renamed ${sessionId} to "${title}".`);
+  return 0;
+}
+
+function runSessionOutput(sessionId: string): number {
+  const output = readPaneOutput(sessionId, config.pane_output_dir);
+  if (output === null) {
+    console.error(
+      `❌ No captured output at ${getPaneOutputPath(sessionId, config.pane_output_dir)}.`,
+    );
+    if (!config.capture_output_on_close) {
+      console.error("   Set capture_output_on_close: true to capture panes as they close.");
+    }
+    return 1;
+  }
+
+  process.stdout.write(output);
+  return 0;
+}
+
+async function runCompletion(target: string, commands: CommandSpec[]): Promise<number> {
+  if (target === "--sessions") {
+    const sessionIds = new Set((await listAgentPanes()).map((pane) => pane.sessionId));
+    for (const sessionId of sessionIds) console.log(sessionId);
+    return 0;
+  }
+
+  if (!isCompletionShell(target)) return 1;
+  process.stdout.write(generateCompletion(target, commands));
+  return 0;
+}
+
+const DEMO_MAX_AGENTS = 20;
+
+/**
+ * Shows off pane behavior without opencode: a fake server plays a swarm of
+ * agent sessions with random lifetimes, and agent panes run a stand-in
+ * `opencode`. Uses the current config, so layout changes can be tried safely.
+ */
+async function runDemo(
+  agentsText: string | undefined,
+  lifetimeText: string | undefined,
+): Promise<number> {
+  const agents = agentsText === undefined ? 6 : Number.parseInt(agentsText, 10);
+  if (!Number.isInteger(agents) || agents < 1 || agents > DEMO_MAX_AGENTS) {
+    console.error(`❌ --agents expects an integer from 1 to ${DEMO_MAX_AGENTS}`);
+    return 1;
+  }
+  const lifetimeSeconds = lifetimeText === undefined ? 15 : Number.parseInt(lifetimeText, 10);
+  if (!Number.isInteger(lifetimeSeconds) || lifetimeSeconds < 1) {
+    console.error("❌ --lifetime expects a positive number of seconds");
+    return 1;
+  }
+
+  if (!isInsideTmux()) {
+    console.error("❌ opentmux demo must run inside tmux.");
+    return 1;
+  }
+
+  const fake = new DemoOpencodeServer();
+  const serverUrl = await fake.start();
+  const binDir = mkdtempSync(join(tmpdir(), "opentmux-demo-"));
+  writeDemoOpencodeBin(binDir);
+  // tmux starts panes with the PATH of the client that opened them
+  env.PATH = `${binDir}:${env.PATH ?? ""}`;
+
+  const tmuxConfig = TmuxConfigSchema.parse({
+    ...config,
+    enabled: true,
+    // Fast enough to catch the swarm's zombie while the demo runs
+    reaper_enabled: true,
+    reaper_interval_ms: 2000,
+    reaper_min_zombie_checks: 2,
+    reaper_grace_period_ms: 1000,
+    reaper_dry_run: false,
+    reaper_auto_self_destruct: false,
+    session_history: false,
+    abort_session_on_pane_close: false,
+    metrics_sink: undefined,
+  });
+  const manager = new TmuxSessionManager(
+    { directory: process.cwd(), serverUrl, client: fake.client() },
+    tmuxConfig,
+    serverUrl,
+  );
+
+  const handled: Promise<void>[] = [];
+  const unsubscribe = fake.subscribe((event) => {
+    const properties = event.properties as { sessionID?: string; info?: { id?: string } };
+    console.log(`  ${event.type} ${properties.sessionID ?? properties.info?.id ?? ""}`);
+    handled.push(manager.handleEvent(event));
+  });
+
+  console.log(`Playing a swarm of ${agents} agents against a fake opencode server at ${serverUrl}`);
+  try {
+    await fake.play(swarmScript({ agents, maxLifetimeMs: lifetimeSeconds * 1000 }));
+    await Promise.all(handled);
+    await new Promise((r) => setTimeout(r, 2000));
+  } finally {
+    unsubscribe();
+    await manager.cleanup();
+    await fake.stop();
+    rmSync(binDir, { recursive: true, force: true });
+  }
+
+  console.log("Demo finished.");
+  return 0;
+}
+
 function hasTmux(): boolean {
   try {
     execSync("tmux -V", { stdio: "ignore" });
@@ -359,6 +1243,481 @@ function hasTmux(): boolean {
   }
 }
 
+function runConfigValidate(target?: string): number {
+  const configPath =
+    target ?? env[CONFIG_ENV_VAR] ?? getConfigPaths(process.cwd()).find((p) => existsSync(p));
+
+  if (!configPath) {
+    console.log("No config file found. Using defaults:");
+    console.log(JSON.stringify(loadConfig(process.cwd()), null, 2));
+    return 0;
+  }
+
+  const result = validateConfigFile(configPath);
+  console.log(`Config: ${configPath}`);
+
+  for (const warning of formatUnknownKeys(result)) {
+    console.warn(`⚠️  ${warning}`);
+  }
+
+  if (!result.config) {
+    for (const error of result.errors) {
+      console.error(`❌ ${error}`);
+    }
+    return 1;
+  }
+
+  // The preset may be defined in another config file, but must exist in one
+  const presets = readConfigLayers(process.cwd())
+    .filter((layer) => resolve(layer.path) !== resolve(configPath))
+    .map((layer) => layer.raw.layouts as Record<string, string> | undefined);
+  const layoutError = layoutReferenceError({
+    layout: result.config.layout,
+    layouts: Object.assign({}, ...presets, result.config.layouts),
+  });
+  if (layoutError) {
+    console.error(`❌ ${layoutError}`);
+    return 1;
+  }
+
+  console.log("Effective config:");
+  console.log(JSON.stringify(result.config, null, 2));
+  return 0;
+}
+
+function runConfigShow(): number {
+  const { config: effective, sources, errors } = loadConfigWithSources(process.cwd());
+  if (errors.length > 0) {
+    for (const error of errors) console.error(`❌ ${error}`);
+    console.error("The merged config is invalid, so opentmux uses the defaults:");
+    console.log(JSON.stringify(effective, null, 2));
+    return 1;
+  }
+
+  if (sources.length === 0) {
+    console.log("No config files found. Using defaults.");
+  } else {
+    console.log("Merged config files (later entries override earlier ones):");
+    for (const source of sources) {
+      console.log(`  ${source}`);
+    }
+  }
+
+  console.log(JSON.stringify(effective, null, 2));
+  return 0;
+}
+
+/**
+ * The keys `opentmux config init` asks about, and the only ones it writes,
+ * so every other option keeps following the defaults of later releases.
+ */
+const CONFIG_INIT_KEYS = ["layout", "port", "max_ports", "reaper_enabled", "reaper_interval_ms"] as const;
+
+async function promptConfig(
+  defaults: Record<string, unknown>,
+): Promise<Record<string, unknown>> {
+  const rl = createInterface({ input: process.stdin, output: process.stdout });
+  const answers: Record<string, unknown> = { ...defaults };
+
+  const ask = async (question: string, key: string): Promise<string> => {
+    const answer = await rl.question(`${question} [${String(defaults[key])}]: `);
+    return answer.trim();
+  };
+
+  try {
+    const layouts = TmuxLayoutSchema.options.join(", ");
+    const layout = await ask(`Layout (${layouts})`, "layout");
+    if (layout) answers.layout = layout;
+
+    const port = await ask("First port for opencode servers", "port");
+    if (port) answers.port = Number.parseInt(port, 10);
+
+    const maxPorts = await ask("Number of ports to try", "max_ports");
+    if (maxPorts) answers.max_ports = Number.parseInt(maxPorts, 10);
+
+    const reaper = await ask("Enable zombie reaper (y/n)", "reaper_enabled");
+    if (reaper) answers.reaper_enabled = /^y/i.test(reaper);
+
+    if (answers.reaper_enabled) {
+      const interval = await ask("Reaper interval in ms", "reaper_interval_ms");
+      if (interval) answers.reaper_interval_ms = Number.parseInt(interval, 10);
+    }
+  } finally {
+    rl.close();
+  }
+
+  return answers;
+}
+
+async function runConfigInit(useDefaults: boolean, force: boolean): Promise<number> {
+  const configPath = getGlobalConfigPaths()[0];
+  const existing = getGlobalConfigPaths().find((p) => existsSync(p));
+
+  if (existing && !force) {
+    console.error(`Config already exists: ${existing}`);
+    console.error("Re-run with --force to overwrite it.");
+    return 1;
+  }
+
+  const defaults = PluginConfigSchema.parse({});
+  const answers = useDefaults
+    ? defaults
+    : await promptConfig(defaults);
+
+  const result = PluginConfigSchema.safeParse(answers);
+  if (!result.success) {
+    for (const issue of result.error.issues) {
+      console.error(`❌ ${issue.path.join(".")}: ${issue.message}`);
+    }
+    return 1;
+  }
+
+  // A global config in another format would still be read (or win) next to
+  // the new file, so move it out of the way
+  for (const old of getGlobalConfigPaths()) {
+    if (old === configPath || !existsSync(old)) continue;
+    renameSync(old, `${old}.bak`);
+    console.log(`Moved ${old} to ${old}.bak`);
+  }
+
+  const written = Object.fromEntries(CONFIG_INIT_KEYS.map((key) => [key, result.data[key]]));
+  mkdirSync(dirname(configPath), { recursive: true });
+  writeFileSync(configPath, `${JSON.stringify(written, null, 2)}\n`);
+  console.log(`✅ Wrote ${configPath}`);
+  return 0;
+}
+
+const BIN_NAME = "opentmux";
+
+/** Flags accepted before any command or opencode arguments */
+const GLOBAL_FLAGS: FlagSpec[] = [
+  { name: "--config", value: "<file>", description: "Use this config file instead of the project config" },
+  { name: "--verbose", description: "Also print launcher log lines to stderr" },
+  { name: "--new-session", description: "Start a new tmux session even if tmux_session_name exists" },
+  { name: "--detached", description: "Start opencode in tmux without attaching, and print how to attach" },
+];
+
+/**
+ * The launcher's own commands. Anything that doesn't match one of these is
+ * handed to opencode.
+ */
+function buildCommands(isRuntime: boolean): CommandSpec[] {
+  const portFlag: FlagSpec = {
+    name: "--port",
+    value: "<port>",
+    description: "opencode server port (default: the server started in this directory)",
+  };
+  const idFlag: FlagSpec = {
+    name: "--id",
+    value: "<session>",
+    description: "Agent session id",
+    required: true,
+  };
+
+  const commands: CommandSpec[] = [
+    {
+      path: ["--reap"],
+      summary: "Clean up orphaned opencode servers",
+      flags: [
+        { name: "--force", description: "Also reap servers opentmux did not start" },
+        { name: "--dry-run", description: "Only report what would be reaped" },
+      ],
+      run: async (parsed) => {
+        await ZombieReaper.reapAll({
+          ports: CANDIDATE_PORTS,
+          force: parsed.flags["--force"] === true,
+          dryRun: parsed.flags["--dry-run"] === true || config.reaper_dry_run,
+          terminatePolicy: terminatePolicyFromConfig(config),
+        });
+        return 0;
+      },
+    },
+    {
+      path: ["config", "validate"],
+      args: "[file]",
+      summary: "Validate a config file and print the effective config",
+      run: (parsed) => runConfigValidate(parsed.positionals[0]),
+    },
+    {
+      path: ["config", "show"],
+      summary: "Print the merged config and the files it came from",
+      run: () => runConfigShow(),
+    },
+    {
+      path: ["config", "init"],
+      summary: "Write a config file interactively",
+      flags: [
+        { name: "--defaults", description: "Write the defaults without prompting" },
+        { name: "--force", description: "Overwrite an existing config file" },
+      ],
+      run: (parsed) =>
+        runConfigInit(parsed.flags["--defaults"] === true, parsed.flags["--force"] === true),
+    },
+    {
+      path: ["bind-keys"],
+      summary: "Install tmux key bindings for jumping between agent panes",
+      flags: [{ name: "--key", value: "<key>", description: "Key after the prefix (default: a)" }],
+      run: (parsed) => runBindKeys(flagString(parsed, "--key") ?? "a", isRuntime),
+    },
+    {
+      path: ["jump"],
+      args: "<1-9|next|prev> [window]",
+      choices: ["1", "2", "3", "4", "5", "6", "7", "8", "9", "next", "prev"],
+      summary: "Select an agent pane (used by the key bindings)",
+      run: (parsed) => runJump(parsed.positionals[0], parsed.positionals[1]),
+    },
+    {
+      path: ["statusline"],
+      args: "[session]",
+      summary: "Print the agent summary for the tmux status line",
+      run: (parsed) => runStatusLine(parsed.positionals[0]),
+    },
+    {
+      path: ["session", "stop"],
+      args: "[port]",
+      summary: "Stop the opencode server started for this directory",
+      run: (parsed) => runSessionStop(parsed.positionals[0]),
+    },
+    {
+      path: ["session", "close-all"],
+      summary: "Close every agent pane without stopping opencode",
+      flags: [
+        { name: "--reason", value: "<text>", description: "Note recorded in session history" },
+        { name: "--abort", description: "Also abort the agents' sessions" },
+      ],
+      run: (parsed) => runSessionCloseAll(flagString(parsed, "--reason"), parsed.flags["--abort"] === true),
+    },
+    {
+      path: ["session", "history"],
+      summary: "List recently closed agent sessions",
+      flags: [
+        { name: "--limit", value: "<n>", description: "Number of entries (default: 20)" },
+        { name: "--json", description: "Print entries as JSON" },
+      ],
+      run: (parsed) => runSessionHistory(flagString(parsed, "--limit"), parsed.flags["--json"] === true),
+    },
+    {
+      path: ["session", "focus"],
+      summary: "Select an agent's pane",
+      flags: [idFlag],
+      run: (parsed) => runSessionFocus(flagString(parsed, "--id")!),
+    },
+    {
+      path: ["session", "rename"],
+      summary: "Rename an agent session and its pane",
+      flags: [
+        idFlag,
+        { name: "--title", value: "<title>", description: "New title", required: true },
+        portFlag,
+      ],
+      run: (parsed) =>
+        runSessionRename(
+          flagString(parsed, "--id")!,
+          flagString(parsed, "--title")!,
+          flagString(parsed, "--port"),
+        ),
+    },
+    {
+      path: ["session", "output"],
+      summary: "Print the output captured when an agent's pane closed",
+      flags: [idFlag],
+      run: (parsed) => runSessionOutput(flagString(parsed, "--id")!),
+    },
+    {
+      path: ["attach"],
+      summary: "Open an agent pane for an existing session",
+      flags: [
+        { name: "--session", value: "<id>", description: "Session id", required: true },
+        portFlag,
+      ],
+      // `opencode attach <url>` itself is passed through untouched
+      accepts: (rest) =>
+        rest.some((arg) => arg === "--session" || arg.startsWith("--session=")) &&
+        !/^https?:\/\//.test(rest[0] ?? ""),
+      run: (parsed) => runAttach(flagString(parsed, "--session")!, flagString(parsed, "--port")),
+    },
+    {
+      path: ["completion"],
+      args: `<${COMPLETION_SHELLS.join("|")}>`,
+      choices: [...COMPLETION_SHELLS],
+      summary: "Print a shell completion script",
+      flags: [{ name: "--sessions", description: "List open agent session ids" }],
+      // Other `completion` invocations go to opencode's own completion command
+      accepts: (rest) => isCompletionShell(rest[0]) || rest[0] === "--sessions",
+      run: (parsed) =>
+        runCompletion(
+          parsed.flags["--sessions"] === true ? "--sessions" : parsed.positionals[0],
+          commands,
+        ),
+    },
+    {
+      path: ["pause"],
+      summary: "Hold new agent panes until resumed",
+      run: () => runPause(true),
+    },
+    {
+      path: ["resume"],
+      summary: "Open agent panes held by pause",
+      run: () => runPause(false),
+    },
+    {
+      path: ["queue"],
+      summary: "List agents waiting for a pane",
+      flags: [{ name: "--json", description: "Print entries as JSON" }],
+      run: (parsed) => runQueue(parsed.flags["--json"] === true),
+    },
+    {
+      path: ["queue", "flush"],
+      summary: "Give up on every agent waiting for a pane",
+      run: () => runQueueFlush(),
+    },
+    {
+      path: ["queue", "retry"],
+      summary: "Open a pane for an agent whose spawn failed or was flushed",
+      flags: [idFlag, portFlag],
+      run: (parsed) => runQueueRetry(flagString(parsed, "--id")!, flagString(parsed, "--port")),
+    },
+    {
+      path: ["layout"],
+      args: "[layout]",
+      summary: "Re-apply the agent pane layout to this window",
+      flags: [
+        { name: "--preview", description: "Print the current layout and the one that would be applied" },
+      ],
+      run: (parsed) => runLayout(parsed.positionals[0], parsed.flags["--preview"] === true),
+    },
+    {
+      path: ["demo"],
+      summary: "Simulate an agent swarm to show panes, layouts and the reaper",
+      flags: [
+        { name: "--agents", value: "<n>", description: "Agents to spawn (default 6)" },
+        {
+          name: "--lifetime",
+          value: "<seconds>",
+          description: "Longest agent lifetime (default 15)",
+        },
+      ],
+      run: (parsed) => runDemo(flagString(parsed, "--agents"), flagString(parsed, "--lifetime")),
+    },
+    {
+      path: ["version"],
+      summary: "Print opentmux, plugin and opencode versions",
+      flags: [{ name: "--json", description: "Print versions as JSON" }],
+      run: (parsed) => runVersion(parsed.flags["--json"] === true),
+    },
+    {
+      path: ["stats"],
+      args: "[opencode stats arguments...]",
+      summary: "Show opentmux servers and ports, then opencode usage statistics",
+      allowUnknownFlags: true,
+      run: (parsed) => runStats(parsed.positionals),
+    },
+    {
+      path: ["upgrade"],
+      args: "[opencode upgrade arguments...]",
+      summary: "Upgrade opentmux, then opencode",
+      flags: [{ name: "--check", description: "Only report whether an update is available" }],
+      allowUnknownFlags: true,
+      run: (parsed) => runUpgrade(parsed.flags["--check"] === true, parsed.positionals),
+    },
+  ];
+
+  commands.push({
+    path: ["help"],
+    args: "[command...]",
+    choices: [...new Set(commands.map((command) => command.path[0]))].filter(
+      (name) => !name.startsWith("-"),
+    ),
+    summary: "Show help for opentmux commands",
+    run: (parsed) => runHelp(commands, parsed.positionals),
+  });
+  return commands;
+}
+
+/**
+ * Strips the global flags from the front of the arguments and applies them.
+ * Returns the remaining arguments, or null after reporting an error.
+ */
+function applyGlobalFlags(args: string[]): string[] | null {
+  const rest = [...args];
+
+  while (rest.length > 0) {
+    const arg = rest[0];
+    if (arg === "--verbose") {
+      verbose = true;
+      rest.shift();
+      continue;
+    }
+
+    if (arg === "--new-session") {
+      forceNewSession = true;
+      rest.shift();
+      continue;
+    }
+
+    if (arg === "--detached") {
+      detached = true;
+      rest.shift();
+      continue;
+    }
+
+    if (arg === "--config" || arg.startsWith("--config=")) {
+      const file = arg === "--config" ? rest[1] : arg.slice("--config=".length);
+      rest.splice(0, arg === "--config" ? 2 : 1);
+      if (!file) {
+        console.error("❌ --config expects a value <file>");
+        return null;
+      }
+
+      const configPath = resolve(file);
+      if (!existsSync(configPath)) {
+        console.error(`❌ Config file not found: ${configPath}`);
+        return null;
+      }
+
+      const result = validateConfigFile(configPath);
+      if (!result.config) {
+        for (const error of result.errors) console.error(`❌ ${error}`);
+        return null;
+      }
+
+      env[CONFIG_ENV_VAR] = configPath;
+      config = loadConfig(process.cwd());
+      CANDIDATE_PORTS = getCandidatePorts(config);
+      log("Using config file:", configPath);
+      continue;
+    }
+
+    break;
+  }
+
+  return rest;
+}
+
+/**
+ * Runs `opentmux help [command...]`.
+ */
+function runHelp(commands: CommandSpec[], topic: string[]): number {
+  if (topic.length === 0) {
+    process.stdout.write(formatHelp(BIN_NAME, commands, GLOBAL_FLAGS));
+    return 0;
+  }
+
+  const command = commands.find((candidate) => candidate.path.join(" ") === topic.join(" "));
+  if (!command) {
+    const prefix = commands.filter((candidate) => candidate.path[0] === topic[0]);
+    if (prefix.length === 0) {
+      console.error(`❌ Unknown command: ${topic.join(" ")}`);
+      return 1;
+    }
+    process.stdout.write(formatHelp(BIN_NAME, prefix, GLOBAL_FLAGS));
+    return 0;
+  }
+
+  process.stdout.write(formatCommandHelp(BIN_NAME, command));
+  return 0;
+}
+
 async function main() {
   // Check if running as a script (node script.js) or a compiled binary
   // In script mode: argv[0]=node, argv[1]=script, argv[2]=arg1 -> slice(2)
@@ -369,19 +1728,48 @@ async function main() {
   // In script mode, argv[1] is the script file path.
   // In compiled/binary mode, argv[0] is the binary, and argv[1] is the first user argument.
   // If we are running via node/bun, we are ALWAYS in script mode for this wrapper.
-  const args = isRuntime ? argv.slice(2) : argv.slice(1);
+  const globalArgs = applyGlobalFlags(isRuntime ? argv.slice(2) : argv.slice(1));
+  if (!globalArgs) exit(1);
+  const args = globalArgs;
 
-  // Check for opentmux-specific flags first
-  if (args.includes("--reap") || args.includes("-reap")) {
-    await ZombieReaper.reapAll();
-    exit(0);
+  const commands = buildCommands(isRuntime);
+
+  // opentmux's help, which points to opencode's, rather than opencode's alone
+  if (args[0] === "--help" || args[0] === "-h") {
+    exit(runHelp(commands, []));
+  }
+
+  // opentmux's versions, which include opencode's, rather than opencode's alone
+  if (args[0] === "--version" || args[0] === "-v" || args[0] === "-V") {
+    exit(runVersion(args.includes("--json")));
+  }
+
+  // --reap may appear anywhere among the arguments
+  const reapIndex = args.findIndex((arg) => arg === "--reap" || arg === "-reap");
+  const commandArgs =
+    reapIndex === -1 ? args : ["--reap", ...args.filter((_, index) => index !== reapIndex)];
+
+  const match = matchCommand(commands, commandArgs);
+  if (match) {
+    const { command, rest } = match;
+    if (rest.includes("--help")) {
+      process.stdout.write(formatCommandHelp(BIN_NAME, command));
+      exit(0);
+    }
+
+    const result = parseArgs(command, rest);
+    if (!result.ok) {
+      console.error(`❌ ${result.error}`);
+      console.error(`Usage: ${formatUsage(BIN_NAME, command)}`);
+      exit(1);
+    }
+    exit(await command.run(result.parsed));
   }
 
   // Define known CLI commands that should NOT trigger a tmux session
   // These are commands that either:
   // 1. Run quickly and exit (CLI tools)
   // 2. Are server/daemon processes that manage their own lifecycle
-  // 3. Are help/version flags
   const NON_TUI_COMMANDS = [
     // Core CLI commands
     "auth",
@@ -390,7 +1778,6 @@ async function main() {
     "update",
     "upgrade",
     "completion",
-    "stats",
     "run",
     "exec",
     "doctor",
@@ -412,12 +1799,6 @@ async function main() {
     "acp",
     "mcp",
     "models",
-
-    // Flags
-    "--version",
-    "-v",
-    "--help",
-    "-h",
   ];
 
   const isCliCommand = args.length > 0 && NON_TUI_COMMANDS.includes(args[0]);
@@ -433,15 +1814,38 @@ async function main() {
       exit(1);
     }
 
-    const bypassArgs = [...args];
+    // Point the command at the server opentmux started for this project, so it
+    // doesn't reach another project's server when several are running
+    const bypassEnv = { ...process.env };
+    let bypassArgs = [...args];
+    if (!bypassEnv.OPENCODE_PORT) {
+      for (const record of findServerRecords(process.cwd())) {
+        if (!(await isOpencodeHealthy(record.port))) continue;
+        log("Passing through with project server:", record.port);
+        bypassEnv.OPENCODE_PORT = String(record.port);
+        bypassArgs = passthroughArgs(bypassArgs, record.port);
+        break;
+      }
+    }
+
     const hasPrintLogs = args.includes("--print-logs");
-    if (!hasPrintLogs && !args.some((arg) => arg.startsWith("--log-level"))) {
+    const quietLogs = !hasPrintLogs && !args.some((arg) => arg.startsWith("--log-level"));
+    if (quietLogs) {
       bypassArgs.push("--log-level", "ERROR");
     }
 
+    // Nothing to clean up afterwards, so become opencode where the runtime
+    // allows: signals, job control and the exit status are then opencode's own.
+    // Only when no models.dev INFO lines can reach stderr, since nothing would
+    // be left to filter them out.
+    if (quietLogs && platform !== "win32" && typeof process.execve === "function") {
+      log("Exec'ing opencode for passthrough command");
+      process.execve(opencodeBin, [opencodeBin, ...bypassArgs], bypassEnv);
+    }
+
     const child = spawn(opencodeBin, bypassArgs, {
       stdio: ["inherit", "inherit", "pipe"],
-      env: process.env,
+      env: bypassEnv,
     });
 
     child.stderr?.on("data", (data) => {
@@ -453,12 +1857,18 @@ async function main() {
       process.stderr.write(filtered.join("\n"));
     });
 
-    child.on("close", (code) => {
-      exit(code ?? 0);
-    });
+    child.on("close", (code, signal) => exitLikeChild(code, signal));
+    forwardSignals(child);
     return;
   }
 
+  const { errors: configErrors } = loadConfigWithSources(process.cwd());
+  if (configErrors.length > 0) {
+    for (const error of configErrors) console.error(`❌ ${error}`);
+    console.error("Fix the config or check it with: opentmux config validate");
+    exit(1);
+  }
+
   log("=== OpenCode Tmux Wrapper Started ===");
   log("Process argv:", JSON.stringify(argv));
   log("Current directory:", process.cwd());
@@ -474,28 +1884,38 @@ async function main() {
     exit(1);
   }
 
+  const opencodeVersion = readOpencodeVersion(opencodeBin);
+  log("opencode version:", opencodeVersion);
+  if (opencodeVersion && !isSupportedOpencodeVersion(opencodeVersion)) {
+    console.warn(
+      `⚠️  opencode ${opencodeVersion} (${opencodeBin}) may be too old for opentmux: agent panes need \`opencode attach --session\`, expected from ${MIN_OPENCODE_VERSION}.`,
+    );
+    console.warn("   If agent panes fail to attach, run `opencode upgrade` or put a newer opencode first on PATH.");
+    log("WARN: opencode may be too old:", opencodeVersion);
+  }
+
   spawnPluginUpdater();
 
   let port = await findAvailablePort();
   log("Found available port:", port);
 
   if (!port) {
-    if (config.rotate_port) {
+    if (config.rotate_port && config.remote_server) {
+      console.warn(
+        "⚠️  rotate_port is ignored with remote_server: opentmux can't see the server's processes.",
+      );
+    }
+    if (config.rotate_port && !config.remote_server) {
       log("Port rotation enabled. Finding oldest session to kill...");
       let oldestPid: number | null = null;
       let oldestTime = Date.now();
       let targetPort = -1;
 
-      for (let p = OPENCODE_PORT_START; p <= OPENCODE_PORT_MAX; p++) {
+      for (const p of CANDIDATE_PORTS) {
         const pids = getListeningPids(p);
         for (const pid of pids) {
           const cmd = getProcessCommand(pid);
-          if (
-            cmd &&
-            (cmd.includes("opencode") ||
-              cmd.includes("node") ||
-              cmd.includes("bun"))
-          ) {
+          if (cmd && cmd.includes("opencode") && isOpentmuxServer(pid, p)) {
             const startTime = getProcessStartTime(pid);
             if (startTime && startTime < oldestTime) {
               oldestTime = startTime;
@@ -507,16 +1927,18 @@ async function main() {
       }
 
       if (oldestPid && targetPort !== -1) {
+        if (!acquirePortLock(targetPort)) {
+          console.error(
+            `Error: Port ${targetPort} is being claimed by another opentmux launch.`,
+          );
+          exit(1);
+        }
+
         log("Rotating port:", targetPort, "Killing oldest PID:", oldestPid);
         console.log(
           `♻️  Port rotation: Killing oldest session (PID ${oldestPid}) on port ${targetPort} to make room...`,
         );
-        safeKill(oldestPid, "SIGTERM");
-        await waitForProcessExit(oldestPid, 2000);
-        if (isProcessAlive(oldestPid)) {
-          safeKill(oldestPid, "SIGKILL");
-          await waitForProcessExit(oldestPid, 1000);
-        }
+        await terminateProcess(oldestPid, terminatePolicyFromConfig(config), { group: true });
 
         // Re-check the port to confirm it's free
         if (await checkPort(targetPort)) {
@@ -536,9 +1958,12 @@ async function main() {
       }
     } else {
       console.error(
-        `Error: No available ports found in range ${OPENCODE_PORT_START}-${OPENCODE_PORT_MAX}.`,
+        `Error: No available ports found in range ${formatPortRange(config)}.`,
       );
       console.error('Tip: Run "opentmux -reap" to clean up stuck sessions.');
+      console.error(
+        '     Add --force to also reap opencode servers opentmux did not start.',
+      );
       console.error(
         '     Or enable "rotate_port": true in config to automatically recycle oldest sessions.',
       );
@@ -549,6 +1974,7 @@ async function main() {
 
   const env2 = { ...process.env };
   env2.OPENCODE_PORT = port.toString();
+  env2[OWNER_ENV_VAR] = randomUUID();
 
   log("User args:", JSON.stringify(args));
 
@@ -557,11 +1983,27 @@ async function main() {
 
   const inTmux = !!env2.TMUX;
   const tmuxAvailable = hasTmux();
+  if (detached && !tmuxAvailable) {
+    console.error("❌ --detached needs tmux, which was not found.");
+    releasePortLock(port);
+    exit(1);
+  }
+  const serverPort = port;
+  process.on("exit", () => releasePortLock(serverPort));
+  void releasePortLockWhenHealthy(serverPort);
+
+  recordServer({
+    port: serverPort,
+    pid: null,
+    project: process.cwd(),
+    launcherPid: process.pid,
+    startedAt: Date.now(),
+  });
 
   log("In tmux?", inTmux);
   log("Tmux available?", tmuxAvailable);
 
-  if (inTmux || !tmuxAvailable) {
+  if ((inTmux && !detached) || !tmuxAvailable) {
     log("Running directly (in tmux or no tmux available)");
 
     const child = spawn(opencodeBin, childArgs, {
@@ -569,17 +2011,26 @@ async function main() {
       env: env2,
     });
 
+    if (child.pid) {
+      recordServer({
+        port: serverPort,
+        pid: child.pid,
+        project: process.cwd(),
+        launcherPid: process.pid,
+        startedAt: Date.now(),
+      });
+    }
+
     child.on("error", (err) => {
       log("ERROR spawning child:", err.message);
     });
 
-    child.on("close", (code) => {
-      log("Child exited with code:", code);
-      exit(code ?? 0);
+    child.on("close", async (code, signal) => {
+      log("Child exited with code:", code, signal ?? "");
+      await handleServerOnExit(serverPort, false);
+      exitLikeChild(code, signal);
     });
-
-    process.on("SIGINT", () => child.kill("SIGINT"));
-    process.on("SIGTERM", () => child.kill("SIGTERM"));
+    forwardSignals(child);
   } else {
     console.log("🚀 Launching tmux session...");
     log("Launching tmux session");
@@ -594,24 +2045,53 @@ async function main() {
       return arg;
     });
 
-    const shellCommand = `${escapedBin} ${escapedArgs.join(" ")} || { echo "Exit code: $?"; echo "Press Enter to close..."; read; }`;
+    // The tmux server may not pass our environment on, so set these inline
+    const configOverride = env2[CONFIG_ENV_VAR]
+      ? `${CONFIG_ENV_VAR}='${env2[CONFIG_ENV_VAR].replace(/'/g, "'\\''")}' `
+      : "";
+    const shellCommand = `${OWNER_ENV_VAR}=${env2[OWNER_ENV_VAR]} ${configOverride}${escapedBin} ${escapedArgs.join(" ")} || { echo "Exit code: $?"; echo "Press Enter to close..."; read; }`;
 
     log("Shell command for tmux:", shellCommand);
 
-    const tmuxArgs = ["new-session", shellCommand];
+    const sessionName = forceNewSession
+      ? undefined
+      : expandSessionName(config.tmux_session_name, process.cwd());
+    const sessionExists =
+      !!sessionName &&
+      spawnSync("tmux", ["has-session", "-t", `=${sessionName}`], { stdio: "ignore" }).status === 0;
+    if (sessionExists) {
+      console.log(`🪟 Opening a new window in tmux session "${sessionName}"...`);
+    }
+    const tmuxArgs = tmuxLaunchArgs(shellCommand, sessionName, sessionExists, detached);
 
     log("Tmux args:", JSON.stringify(tmuxArgs));
 
+    if (detached) {
+      const result = spawnSync("tmux", tmuxArgs, { encoding: "utf-8", env: env2 });
+      const startedIn = sessionName ?? result.stdout.trim();
+      if (result.status !== 0 || !startedIn) {
+        console.error(`❌ tmux could not start the session: ${result.stderr.trim()}`);
+        exit(1);
+      }
+      console.log(`✅ opencode is running in tmux session "${startedIn}" on port ${serverPort}.`);
+      console.log(`   Attach with: tmux attach -t ${JSON.stringify(startedIn)}`);
+      // Keep the port reserved until the server has taken it
+      await releasePortLockWhenHealthy(serverPort);
+      exit(0);
+    }
+
     const child = spawn("tmux", tmuxArgs, { stdio: "inherit", env: env2 });
 
     child.on("error", (err) => {
       log("ERROR spawning tmux:", err.message);
     });
 
-    child.on("close", (code) => {
-      log("Tmux exited with code:", code);
-      exit(code ?? 0);
+    child.on("close", async (code, signal) => {
+      log("Tmux exited with code:", code, signal ?? "");
+      await handleServerOnExit(serverPort, true);
+      exitLikeChild(code, signal);
     });
+    forwardSignals(child);
   }
 }
 
@@ -1,4 +1,6 @@
 import { z } from 'zod';
+import { isTmuxLayoutString } from './layout';
+import { QUIET_HOURS_PATTERN } from './utils/quiet-hours';
 
 export const TmuxLayoutSchema = z.enum([
   'main-horizontal',
@@ -10,60 +12,231 @@ export const TmuxLayoutSchema = z.enum([
 
 export type TmuxLayout = z.infer<typeof TmuxLayoutSchema>;
 
-export const TmuxConfigSchema = z.object({
-  enabled: z.boolean().default(true),
-  layout: TmuxLayoutSchema.default('main-vertical'),
-  main_pane_size: z.number().min(20).max(80).default(60),
-  spawn_delay_ms: z.number().min(50).max(2000).default(300),
-  max_retry_attempts: z.number().min(0).max(5).default(2),
-  layout_debounce_ms: z.number().min(50).max(1000).default(150),
-  max_agents_per_column: z.number().min(1).max(10).default(3),
-  
-  // Reaper config
-  reaper_enabled: z.boolean().default(true),
-  reaper_interval_ms: z.number().default(30000),
-  reaper_min_zombie_checks: z.number().default(3),
-  reaper_grace_period_ms: z.number().default(5000),
-  
-  // Auto self-destruct for abandoned servers
-  reaper_auto_self_destruct: z.boolean().default(true),
-  reaper_self_destruct_timeout_ms: z.number().default(60 * 60 * 1000), // 1 hour
-  
-  // Port management
-  rotate_port: z.boolean().default(false),
-  max_ports: z.number().min(1).max(100).default(10),
-});
+/** Names usable for presets in `layouts` */
+const LAYOUT_PRESET_NAME = /^[A-Za-z][\w-]*$/;
 
-export type TmuxConfig = z.infer<typeof TmuxConfigSchema>;
+/** Picks a builtin layout from the window size (see auto_layout_min_*) */
+export const AUTO_LAYOUT = 'auto';
+
+/**
+ * `layout`: a builtin tmux layout, `auto`, a raw tmux layout string, or the
+ * name of a preset in `layouts` (checked against the merged config at load).
+ */
+export const LayoutSchema = z.string().refine(
+  (value) =>
+    TmuxLayoutSchema.safeParse(value).success ||
+    value === AUTO_LAYOUT ||
+    isTmuxLayoutString(value) ||
+    LAYOUT_PRESET_NAME.test(value),
+  { message: 'layout must be a builtin layout, auto, a tmux layout string or a preset name' },
+);
+
+export const LayoutPresetsSchema = z.record(
+  z.string().regex(LAYOUT_PRESET_NAME),
+  z.string().refine(isTmuxLayoutString, {
+    message: 'layouts entries must be tmux layout strings (see #{window_layout})',
+  }),
+);
+
+/**
+ * Why config.layout doesn't resolve to a layout, or null if it does. Needs
+ * the merged config, since a preset may come from another config file.
+ */
+export function layoutReferenceError(config: {
+  layout?: string;
+  layouts?: Record<string, string>;
+}): string | null {
+  const layout = config.layout;
+  if (
+    !layout ||
+    layout === AUTO_LAYOUT ||
+    TmuxLayoutSchema.safeParse(layout).success ||
+    isTmuxLayoutString(layout)
+  ) {
+    return null;
+  }
+  return config.layouts?.[layout] ? null : `layout: unknown layout preset "${layout}"`;
+}
+
+export const LayoutModeSchema = z.enum(['auto', 'grid']);
+
+export type LayoutMode = z.infer<typeof LayoutModeSchema>;
+
+export const MainPaneSizeUnitSchema = z.enum(['percent', 'cells']);
+
+export type MainPaneSizeUnit = z.infer<typeof MainPaneSizeUnitSchema>;
+
+export const FocusOnSpawnSchema = z.enum(['never', 'first', 'always']);
+
+export type FocusOnSpawn = z.infer<typeof FocusOnSpawnSchema>;
+
+export const QueueOverflowPolicySchema = z.enum(['reject-new', 'drop-oldest', 'headless-track']);
+
+export type QueueOverflowPolicy = z.infer<typeof QueueOverflowPolicySchema>;
+
+export const PortRangeSchema = z
+  .object({
+    start: z.number().int().min(1).max(65535),
+    end: z.number().int().min(1).max(65535),
+  })
+  .refine((range) => range.end >= range.start, {
+    message: 'port_range.end must be greater than or equal to port_range.start',
+  });
+
+function isValidRegex(pattern: string): boolean {
+  try {
+    new RegExp(pattern);
+    return true;
+  } catch {
+    return false;
+  }
+}
+
+export const WindowRuleSchema = z
+  .object({
+    // Regex tested against the agent's title, case-insensitively
+    title_match: z
+      .string()
+      .refine(isValidRegex, { message: 'title_match must be a valid regex' })
+      .optional(),
+    // Regex tested against the parent session's title, case-insensitively
+    parent_match: z
+      .string()
+      .refine(isValidRegex, { message: 'parent_match must be a valid regex' })
+      .optional(),
+    window: z.string().min(1),
+  })
+  .refine((rule) => rule.title_match !== undefined || rule.parent_match !== undefined, {
+    message: 'window_rules entries need title_match or parent_match',
+  });
+
+export type WindowRule = z.infer<typeof WindowRuleSchema>;
 
 export const PluginConfigSchema = z.object({
   enabled: z.boolean().default(true),
   port: z.number().default(4096),
-  layout: TmuxLayoutSchema.default('main-vertical'),
+  layout: LayoutSchema.default('main-vertical'),
+  // Named tmux layout strings that `layout` can refer to
+  layouts: LayoutPresetsSchema.default({}),
+  // With layout "auto": main-vertical from this many columns, else main-horizontal
+  // from auto_layout_min_height rows, else tiled
+  auto_layout_min_width: z.number().int().min(20).max(1000).default(160),
+  auto_layout_min_height: z.number().int().min(10).max(500).default(40),
+  // 'grid' keeps a fixed grid of agent slots instead of re-laying out on every spawn/close
+  layout_mode: LayoutModeSchema.default('auto'),
+  grid_rows: z.number().int().min(1).max(6).default(2),
+  grid_columns: z.number().int().min(1).max(6).default(2),
+  // Give each parent session's agents their own window, named after the parent
+  group_by_parent: z.boolean().default(false),
+  // Send matching agents to a dedicated window; the first matching rule wins
+  window_rules: z.array(WindowRuleSchema).default([]),
   main_pane_size: z.number().min(20).max(80).default(60),
+  // Unit for main_pane_width/main_pane_height; main_pane_size is always a percentage
+  main_pane_size_unit: MainPaneSizeUnitSchema.default('percent'),
+  // Main pane width for vertical layouts and height for horizontal ones
+  main_pane_width: z.number().int().min(1).max(1000).optional(),
+  main_pane_height: z.number().int().min(1).max(1000).optional(),
   auto_close: z.boolean().default(true),
   spawn_delay_ms: z.number().min(50).max(2000).default(300),
   max_retry_attempts: z.number().min(0).max(5).default(2),
+  spawn_backoff_base_ms: z.number().min(10).max(5000).default(250),
+  spawn_backoff_max_ms: z.number().min(10).max(60000).default(5000),
+  spawn_backoff_jitter: z.number().min(0).max(1).default(0.2),
+  // Agents waiting in the spawn queue before queue_overflow_policy applies; 0 is unbounded
+  max_queue_depth: z.number().int().min(0).max(1000).default(0),
+  queue_overflow_policy: QueueOverflowPolicySchema.default('reject-new'),
+  tmux_command_timeout_ms: z.number().min(0).max(60000).default(5000),
+  // Run tmux commands over one control-mode (tmux -C) connection
+  tmux_control_mode: z.boolean().default(false),
+  poll_interval_min_ms: z.number().min(100).max(10000).default(500),
+  poll_interval_max_ms: z.number().min(500).max(60000).default(10000),
   layout_debounce_ms: z.number().min(50).max(1000).default(150),
   max_agents_per_column: z.number().min(1).max(10).default(3),
+  min_agent_pane_height: z.number().min(0).max(100).default(0),
+  min_agent_pane_width: z.number().min(0).max(500).default(0),
+  defer_layout_when_zoomed: z.boolean().default(true),
+  focus_on_spawn: FocusOnSpawnSchema.default('never'),
+  // Local "HH:MM-HH:MM" ranges during which agent panes are held, not opened
+  quiet_hours: z
+    .array(
+      z.string().regex(QUIET_HOURS_PATTERN, {
+        message: 'quiet_hours entries must look like "22:00-08:00"',
+      }),
+    )
+    .default([]),
+  pane_status_colors: z.boolean().default(false),
+  status_line: z.boolean().default(true),
+  pane_title_max_width: z.number().int().min(5).max(200).default(30),
+  session_history: z.boolean().default(true),
+  capture_output_on_close: z.boolean().default(false),
+  // Where captured pane output goes; defaults to the opentmux state directory
+  pane_output_dir: z.string().optional(),
+  // Write a markdown transcript to ./.opentmux/transcripts/<id>.md when a pane closes
+  export_on_close: z.boolean().default(false),
+  // Abort the opencode session when the user closes its agent pane
+  abort_session_on_pane_close: z.boolean().default(false),
+  // statsd://host:port[/prefix] or pushgateway+http://host:port
+  metrics_sink: z
+    .string()
+    .regex(/^(statsd:\/\/\S+|pushgateway\+https?:\/\/\S+)$/, {
+      message: 'metrics_sink must be statsd://host:port or pushgateway+http(s)://host:port',
+    })
+    .optional(),
   
+  // opencode runs elsewhere (e.g. a container behind a forwarded port): skip
+  // process-level actions (reaper, killing attach processes, port rotation)
+  // and track agents through the HTTP API only
+  remote_server: z.boolean().default(false),
+  // Prepended to the attach command, e.g. "ssh devbox --" to attach to a server on another machine
+  attach_command_prefix: z.string().trim().min(1).optional(),
+
   // Reaper config
   reaper_enabled: z.boolean().default(true),
   reaper_interval_ms: z.number().default(30000),
   reaper_min_zombie_checks: z.number().default(3),
   reaper_grace_period_ms: z.number().default(5000),
+  // Log what the reaper would kill instead of killing it
+  reaper_dry_run: z.boolean().default(false),
 
   // Auto self-destruct for abandoned servers
   reaper_auto_self_destruct: z.boolean().default(true),
   reaper_self_destruct_timeout_ms: z.number().default(60 * 60 * 1000), // 1 hour
 
+  // How long a process gets after each stop signal before opentmux escalates to the next
+  kill_grace_period_ms: z.number().int().min(0).max(60000).default(2000),
+  // Send SIGINT before SIGTERM when stopping opencode processes
+  kill_interrupt_first: z.boolean().default(false),
+
   // Port management
   rotate_port: z.boolean().default(false),
   max_ports: z.number().min(1).max(100).default(10),
+  port_range: PortRangeSchema.optional(),
+  port_exclude: z.array(z.number().int().min(1).max(65535)).default([]),
+
+  // Launcher
+  kill_server_on_exit: z.boolean().default(false),
+  // Reuse this tmux session (adding a window) instead of starting a new one per
+  // launch; {project} is the project directory's name
+  tmux_session_name: z
+    .string()
+    .regex(/^[^:.]+$/, { message: 'tmux_session_name cannot contain ":" or "."' })
+    .default('oc-{project}'),
 });
 
 export type PluginConfig = z.infer<typeof PluginConfigSchema>;
 
+/** The part of the plugin config TmuxSessionManager uses: everything but launcher options */
+export const TmuxConfigSchema = PluginConfigSchema.omit({
+  port: true,
+  auto_close: true,
+  port_range: true,
+  port_exclude: true,
+  kill_server_on_exit: true,
+  tmux_session_name: true,
+});
+
+export type TmuxConfig = z.infer<typeof TmuxConfigSchema>;
+
 export const POLL_INTERVAL_MS = 2000;
 export const SESSION_TIMEOUT_MS = 10 * 60 * 1000;
 export const SESSION_MISSING_GRACE_MS = POLL_INTERVAL_MS * 3;
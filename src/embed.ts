@@ -0,0 +1,82 @@
+/**
+ * Public API for running opentmux's agent pane management inside another
+ * program, imported as `opentmux/embed`. Everything exported here follows
+ * semver; other modules are internal and may change in any release.
+ *
+ * Lifecycle of a session manager:
+ * 1. createSessionManager() validates the config and starts the reaper and
+ *    the orphaned pane sweep. Nothing happens outside tmux, or with
+ *    `enabled: false`.
+ * 2. Pass every opencode event to handleEvent(). Child sessions get a pane
+ *    when they are created and lose it when they go idle, error out or are
+ *    deleted.
+ * 3. Call cleanup() once on shutdown. It waits for in-flight spawns, stops
+ *    the reaper and closes every pane it opened. Events after that are
+ *    ignored, so create a new manager to start over.
+ *
+ * To apply new config without closing panes, call handOver() on the old
+ * manager instead of cleanup() and pass the result to adopt() on the new one.
+ */
+import type { z } from 'zod';
+import { TmuxConfigSchema, type TmuxConfig } from './config';
+import type { PaneController } from './pane-controller';
+import type { StatusSource } from './status-source';
+import { TmuxSessionManager, type SessionHandover } from './tmux-session-manager';
+import type { PluginInput } from './types';
+import type { Clock } from './utils/clock';
+
+export interface SessionManagerOptions {
+  /** opencode SDK client, or anything implementing the session calls used */
+  client: PluginInput['client'];
+  /** Project directory the agents run in */
+  directory: string;
+  /** opencode server the agent panes attach to, e.g. http://localhost:4096 */
+  serverUrl: string;
+  /** Config fields to set, as in opentmux.json; the rest take their defaults */
+  config?: z.input<typeof TmuxConfigSchema>;
+  /** Time source for polling, debounces and grace periods (default: system clock) */
+  clock?: Clock;
+  /** How panes are opened and closed (default: tmux) */
+  panes?: PaneController;
+  /** Where session statuses come from (default: the opencode server at serverUrl) */
+  statuses?: StatusSource;
+  /**
+   * Close panes when the process gets SIGINT, SIGTERM, SIGHUP or SIGQUIT.
+   * Off by default: the host owns its shutdown and calls cleanup().
+   */
+  handleSignals?: boolean;
+}
+
+/**
+ * Creates a session manager. Throws a ZodError when options.config is
+ * invalid.
+ */
+export function createSessionManager(options: SessionManagerOptions): TmuxSessionManager {
+  const config = TmuxConfigSchema.parse(options.config ?? {});
+  return new TmuxSessionManager(
+    { client: options.client, directory: options.directory, serverUrl: options.serverUrl },
+    config,
+    options.serverUrl,
+    options.clock,
+    {
+      panes: options.panes,
+      statuses: options.statuses,
+      handleSignals: options.handleSignals ?? false,
+    },
+  );
+}
+
+export { TmuxSessionManager, TmuxConfigSchema };
+export type { TmuxConfig, PaneController, StatusSource, Clock, PluginInput, SessionHandover };
+export type { SpawnFailureReason } from './types';
+export { tmuxPaneController } from './pane-controller';
+export { createStatusSource, type SessionStatuses } from './status-source';
+export {
+  SpawnQueue,
+  type SpawnFn,
+  type SpawnQueueOptions,
+  type SpawnQueueStats,
+  type SpawnRequest,
+  type SpawnResult,
+} from './spawn-queue';
+export { systemClock } from './utils/clock';
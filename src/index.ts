@@ -1,8 +1,12 @@
+import * as path from 'node:path';
+import { fileURLToPath } from 'node:url';
 import type { Plugin } from './types';
-import { type TmuxConfig } from './config';
+import { TmuxConfigSchema, type TmuxConfig } from './config';
 import { TmuxSessionManager } from './tmux-session-manager';
-import { log, startTmuxCheck } from './utils';
+import { isInsideTmux, log, recordTmuxSession, setVersionOption, startTmuxCheck } from './utils';
+import { getBuildInfo } from './utils/build-info';
 import { loadConfig } from './utils/config-loader';
+import { isSameServer } from './utils/server-url';
 
 function detectServerUrl(): string {
   if (process.env.OPENCODE_PORT) {
@@ -12,43 +16,85 @@ function detectServerUrl(): string {
   return 'http://localhost:4096';
 }
 
-let isInitialized = false;
+/**
+ * The manager of the latest plugin instance. opencode can initialize the
+ * plugin again (a second config entry, a reload); the new instance takes
+ * over from the old one instead of both opening panes. The promise never
+ * rejects: it holds null when no manager could be created.
+ */
+let activeManager: { serverUrl: string; manager: Promise<TmuxSessionManager | null> } | null = null;
 
-const OpencodeAgentTmux: Plugin = async (ctx) => {
-  if (isInitialized) {
-    log('[plugin] duplicate initialization detected, skipping', {
-      directory: ctx.directory,
+function createOrNull(create: () => TmuxSessionManager): TmuxSessionManager | null {
+  try {
+    return create();
+  } catch (error) {
+    log('[plugin] failed to create session manager', { error: String(error) });
+    return null;
+  }
+}
+
+/**
+ * Replaces the previous instance's manager. For the same server its panes
+ * are handed to the new manager, which applies the new config to them;
+ * for another server (another project) they are closed. A failed handover
+ * closes the previous panes instead, so the new manager starts clean.
+ */
+async function replaceManager(
+  previous: typeof activeManager,
+  create: () => TmuxSessionManager,
+  serverUrl: string,
+): Promise<TmuxSessionManager | null> {
+  const old = previous ? await previous.manager : null;
+  if (!previous || !old) return createOrNull(create);
+
+  if (isSameServer(previous.serverUrl, serverUrl)) {
+    try {
+      const handover = await old.handOver();
+      const manager = createOrNull(create);
+      manager?.adopt(handover);
+      return manager;
+    } catch (error) {
+      log('[plugin] handover failed, closing previous panes', { error: String(error) });
+    }
+  } else {
+    log('[plugin] re-initialized for another server, closing previous panes', {
+      previous: previous.serverUrl,
+      serverUrl,
     });
-    return {
-      name: 'opentmux',
-      event: async () => {},
-    };
   }
-  isInitialized = true;
 
+  try {
+    await old.cleanup();
+  } catch (error) {
+    log('[plugin] failed to close previous panes', { error: String(error) });
+  }
+  return createOrNull(create);
+}
+
+/**
+ * The manager events for serverUrl go to: the latest instance's when it
+ * runs against the same server, so a replaced instance that opencode still
+ * calls forwards to its successor, and otherwise the instance's own.
+ */
+async function managerFor(
+  serverUrl: string,
+  own: Promise<TmuxSessionManager | null>,
+): Promise<TmuxSessionManager | null> {
+  const current = activeManager;
+  if (current && isSameServer(current.serverUrl, serverUrl)) return current.manager;
+  return own;
+}
+
+const OpencodeAgentTmux: Plugin = async (ctx) => {
   const config = loadConfig(ctx.directory);
 
-  const tmuxConfig: TmuxConfig = {
-    enabled: config.enabled,
-    layout: config.layout,
-    main_pane_size: config.main_pane_size,
-    spawn_delay_ms: config.spawn_delay_ms,
-    max_retry_attempts: config.max_retry_attempts,
-    layout_debounce_ms: config.layout_debounce_ms,
-    max_agents_per_column: config.max_agents_per_column,
-    reaper_enabled: config.reaper_enabled,
-    reaper_interval_ms: config.reaper_interval_ms,
-    reaper_min_zombie_checks: config.reaper_min_zombie_checks,
-    reaper_grace_period_ms: config.reaper_grace_period_ms,
-    reaper_auto_self_destruct: config.reaper_auto_self_destruct,
-    reaper_self_destruct_timeout_ms: config.reaper_self_destruct_timeout_ms,
-    rotate_port: config.rotate_port,
-    max_ports: config.max_ports,
-  };
+  const tmuxConfig: TmuxConfig = TmuxConfigSchema.parse(config);
 
   const serverUrl = ctx.serverUrl?.toString() || detectServerUrl();
+  const buildInfo = getBuildInfo(path.dirname(fileURLToPath(import.meta.url)));
 
   log('[plugin] initialized', {
+    buildInfo,
     tmuxConfig,
     directory: ctx.directory,
     serverUrl,
@@ -56,22 +102,32 @@ const OpencodeAgentTmux: Plugin = async (ctx) => {
 
   if (tmuxConfig.enabled) {
     startTmuxCheck();
+    if (isInsideTmux()) {
+      // Pin later commands to this tmux session before the manager runs any
+      await recordTmuxSession();
+      void setVersionOption(JSON.stringify(buildInfo));
+    }
   }
 
-  const tmuxSessionManager = new TmuxSessionManager(ctx, tmuxConfig, serverUrl);
+  const previous = activeManager;
+  if (previous) {
+    log('[plugin] re-initialized, taking over from the previous instance', {
+      directory: ctx.directory,
+    });
+  }
+  const tmuxSessionManager = replaceManager(
+    previous,
+    () => new TmuxSessionManager(ctx, tmuxConfig, serverUrl),
+    serverUrl,
+  );
+  activeManager = { serverUrl, manager: tmuxSessionManager };
 
   return {
     name: 'opentmux',
 
     event: async (input) => {
-      await tmuxSessionManager.onSessionCreated(
-        input.event as {
-          type: string;
-          properties?: {
-            info?: { id?: string; parentID?: string; title?: string };
-          };
-        },
-      );
+      const manager = await managerFor(serverUrl, tmuxSessionManager);
+      await manager?.handleEvent(input.event);
     },
   };
 };
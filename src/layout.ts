@@ -103,6 +103,96 @@ export function groupAgentsByColumn<T>(
   return columns;
 }
 
+/**
+ * Splits grid slots into columns of `rows` slots each, filling columns top to
+ * bottom in order. Unlike groupAgentsByColumn this never moves a slot to
+ * another column when slots are added, so a fixed grid keeps its shape.
+ *
+ * @param slots - Slots in column-major order
+ * @param rows - Slots per column
+ * @returns Array of columns, each containing up to `rows` slots
+ */
+export function groupIntoGridColumns<T>(slots: T[], rows: number): T[][] {
+  if (rows <= 0) {
+    throw new Error('rows must be positive');
+  }
+
+  const columns: T[][] = [];
+  for (let i = 0; i < slots.length; i += rows) {
+    columns.push(slots.slice(i, i + rows));
+  }
+  return columns;
+}
+
+/**
+ * Caps agents per column so every pane keeps at least minPaneHeight rows.
+ *
+ * N stacked panes need N * minPaneHeight rows plus N - 1 separator lines.
+ *
+ * @param windowHeight - Height of the window in rows
+ * @param maxAgentsPerColumn - Configured per-column limit
+ * @param minPaneHeight - Minimum rows per agent pane (0 disables the check)
+ * @returns Effective per-column limit (at least 1)
+ */
+export function maxAgentsPerColumnForHeight(
+  windowHeight: number,
+  maxAgentsPerColumn: number,
+  minPaneHeight: number,
+): number {
+  if (minPaneHeight <= 0) {
+    return maxAgentsPerColumn;
+  }
+  const fit = Math.floor((windowHeight + 1) / (minPaneHeight + 1));
+  return Math.max(1, Math.min(maxAgentsPerColumn, fit));
+}
+
+/**
+ * Converts a configured main pane size to cells along one window dimension.
+ * Leaves room for a separator and at least one cell of agent panes.
+ *
+ * @param size - Configured size
+ * @param unit - Whether size is a percentage of the window or a cell count
+ * @param windowSize - Window width or height in cells
+ */
+export function mainPaneCells(
+  size: number,
+  unit: 'percent' | 'cells',
+  windowSize: number,
+): number {
+  const cells = unit === 'cells' ? size : Math.floor((windowSize * size) / 100);
+  return Math.max(1, Math.min(windowSize - 2, cells));
+}
+
+/**
+ * Width of the main pane in the multi-column layout: an absolute width when
+ * configured, otherwise a percentage clamped to 30-80%.
+ */
+function mainColumnWidth(
+  windowWidth: number,
+  mainPanePercent: number,
+  mainPaneWidth?: number,
+): number {
+  const clampedPercent = Math.max(30, Math.min(80, mainPanePercent));
+  const desiredMainWidth = mainPaneWidth ?? Math.floor((windowWidth * clampedPercent) / 100);
+  return Math.max(0, Math.min(windowWidth - 2, desiredMainWidth));
+}
+
+/**
+ * Computes the width of the narrowest agent column for the multi-column layout.
+ * Mirrors the sizing used by buildMainVerticalMultiColumnLayoutString.
+ */
+export function narrowestAgentColumnWidth(
+  windowWidth: number,
+  mainPanePercent: number,
+  numColumns: number,
+  mainPaneWidth?: number,
+): number {
+  if (numColumns <= 0) return 0;
+  const mainWidth = mainColumnWidth(windowWidth, mainPanePercent, mainPaneWidth);
+  const rightWidth = Math.max(0, windowWidth - mainWidth - 1);
+  return Math.min(...splitSizes(rightWidth, numColumns));
+}
+
 type LayoutType = 'LEFTRIGHT' | 'TOPBOTTOM' | 'WINDOWPANE';
 
 interface LayoutCell {
@@ -115,6 +205,25 @@ interface LayoutCell {
   children?: LayoutCell[];
 }
 
+/**
+ * Picks the builtin layout for `layout: "auto"`: agents beside the main pane
+ * when the window is wide enough, below it when only tall enough, and a grid
+ * when it is neither.
+ *
+ * @param minWidth - Columns needed for main-vertical
+ * @param minHeight - Rows needed for main-horizontal
+ */
+export function autoLayoutForSize(
+  windowWidth: number,
+  windowHeight: number,
+  minWidth: number,
+  minHeight: number,
+): 'main-vertical' | 'main-horizontal' | 'tiled' {
+  if (windowWidth >= minWidth) return 'main-vertical';
+  if (windowHeight >= minHeight) return 'main-horizontal';
+  return 'tiled';
+}
+
 export function mainPanePercentForColumns(numColumns: number): number {
   if (numColumns <= 1) return 60;
   if (numColumns === 2) return 45;
@@ -130,6 +239,15 @@ export function layoutChecksum(layout: string): number {
   return csum;
 }
 
+/**
+ * Whether value is a tmux layout string, as printed by
+ * `tmux display -p '#{window_layout}'`: a checksum over a cell description.
+ */
+export function isTmuxLayoutString(value: string): boolean {
+  const match = /^([0-9a-f]{4}),(\d+x\d+,\d+,\d+[\d,x{}[\]]*)$/.exec(value);
+  return !!match && Number.parseInt(match[1], 16) === layoutChecksum(match[2]);
+}
+
 function dumpLayoutCell(cell: LayoutCell): string {
   const base =
     cell.wpId !== undefined
@@ -166,17 +284,18 @@ export function buildMainVerticalMultiColumnLayoutString(params: {
   mainPaneWpId: number;
   columns: number[][];
   mainPanePercent: number;
+  /** Absolute main pane width in cells; overrides mainPanePercent */
+  mainPaneWidth?: number;
 }): string {
-  const { windowWidth, windowHeight, mainPaneWpId, columns, mainPanePercent } = params;
+  const { windowWidth, windowHeight, mainPaneWpId, columns, mainPanePercent, mainPaneWidth } =
+    params;
 
   const numColumns = columns.length;
   if (numColumns <= 0) {
     throw new Error('columns must be non-empty');
   }
 
-  const clampedPercent = Math.max(30, Math.min(80, mainPanePercent));
-  const desiredMainWidth = Math.floor((windowWidth * clampedPercent) / 100);
-  const mainWidth = Math.max(0, Math.min(windowWidth - 2, desiredMainWidth));
+  const mainWidth = mainColumnWidth(windowWidth, mainPanePercent, mainPaneWidth);
   const rightWidth = Math.max(0, windowWidth - mainWidth - 1);
 
   const mainCell: LayoutCell = {
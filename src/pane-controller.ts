@@ -0,0 +1,61 @@
+import {
+  applyTmuxLayout,
+  capturePaneOutput,
+  closeTmuxPane,
+  hasAttachProcess,
+  listAgentPanes,
+  listAllPaneIds,
+  refreshAutoLayout,
+  removeGridPlaceholders,
+  setPaneStatusStyle,
+  setPaneTitle,
+  showTmuxMessage,
+  spawnTmuxPane,
+} from './utils';
+import { listProcesses } from './utils/process';
+
+/**
+ * The tmux operations TmuxSessionManager's pane lifecycle depends on. The
+ * manager takes one at construction so its logic can run against a fake.
+ */
+export interface PaneController {
+  spawnPane: typeof spawnTmuxPane;
+  closePane: typeof closeTmuxPane;
+  /** Panes tagged as agent panes, in every window */
+  listAgentPanes: typeof listAgentPanes;
+  /** Every live pane id, or null when tmux can't be asked */
+  listPaneIds: typeof listAllPaneIds;
+  /** Every process, or null when ps can't be read */
+  listProcesses: typeof listProcesses;
+  /** Whether the pane still runs its `opencode attach` process, given a listProcesses snapshot */
+  isAttached: typeof hasAttachProcess;
+  setTitle: typeof setPaneTitle;
+  /** Colors the pane border for an agent status */
+  setStatusStyle: typeof setPaneStatusStyle;
+  /** The pane's scrollback, or null when it can't be read */
+  captureOutput: typeof capturePaneOutput;
+  applyLayout: typeof applyTmuxLayout;
+  /** Re-applies the auto layout if the window changed shape since the last one */
+  refreshLayout: typeof refreshAutoLayout;
+  /** Kills the grid layout's placeholder panes */
+  removePlaceholders: typeof removeGridPlaceholders;
+  /** Shows a message in the tmux status line */
+  showMessage: typeof showTmuxMessage;
+}
+
+/** Drives real tmux panes through src/utils/tmux.ts */
+export const tmuxPaneController: PaneController = {
+  spawnPane: (...args) => spawnTmuxPane(...args),
+  closePane: (...args) => closeTmuxPane(...args),
+  listAgentPanes: () => listAgentPanes(),
+  listPaneIds: () => listAllPaneIds(),
+  listProcesses: () => listProcesses(),
+  isAttached: (...args) => hasAttachProcess(...args),
+  setTitle: (...args) => setPaneTitle(...args),
+  setStatusStyle: (...args) => setPaneStatusStyle(...args),
+  captureOutput: (paneId) => capturePaneOutput(paneId),
+  applyLayout: (...args) => applyTmuxLayout(...args),
+  refreshLayout: () => refreshAutoLayout(),
+  removePlaceholders: () => removeGridPlaceholders(),
+  showMessage: (text) => showTmuxMessage(text),
+};
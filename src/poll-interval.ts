@@ -0,0 +1,50 @@
+/**
+ * Pure functions for choosing the session status poll interval.
+ *
+ * Polling is fast right after a spawn (panes often close quickly), slow once
+ * every tracked session has been busy for a while, and normal otherwise.
+ */
+
+import { POLL_INTERVAL_MS } from './config';
+
+/** How long after a spawn the fast interval applies */
+export const FAST_POLL_WINDOW_MS = 30_000;
+
+/** How long all sessions must stay busy before backing off to the slow interval */
+export const SLOW_POLL_AFTER_BUSY_MS = 60_000;
+
+export interface PollActivity {
+  now: number;
+  /** When the most recent pane was spawned */
+  lastSpawnAt: number | null;
+  /** Since when every tracked session has been busy, or null if any isn't */
+  allBusySince: number | null;
+}
+
+export interface PollBounds {
+  /** Fast interval, used right after spawns */
+  minMs: number;
+  /** Slow interval, used for long-running busy sessions */
+  maxMs: number;
+}
+
+/**
+ * Computes the delay until the next status poll.
+ */
+export function computePollInterval(activity: PollActivity, bounds: PollBounds): number {
+  const minMs = Math.min(bounds.minMs, bounds.maxMs);
+  const maxMs = Math.max(bounds.minMs, bounds.maxMs);
+
+  if (activity.lastSpawnAt !== null && activity.now - activity.lastSpawnAt < FAST_POLL_WINDOW_MS) {
+    return minMs;
+  }
+
+  if (
+    activity.allBusySince !== null &&
+    activity.now - activity.allBusySince >= SLOW_POLL_AFTER_BUSY_MS
+  ) {
+    return maxMs;
+  }
+
+  return Math.min(maxMs, Math.max(minMs, POLL_INTERVAL_MS));
+}
@@ -1,15 +1,60 @@
+import { computeBackoffMs, DEFAULT_BACKOFF, type BackoffOptions } from './utils/backoff';
+import { sleep, systemClock, type Clock, type ClockTimer } from './utils/clock';
+import { Histogram, type HistogramSummary } from './utils/histogram';
 import { log } from './utils/logger';
+import type { SpawnFailureReason } from './types';
+
+export interface SpawnTiming {
+  /** Time from enqueue to dequeue */
+  queueWaitMs: number;
+  attempts: number;
+  /** Duration of each spawnFn call, in attempt order */
+  attemptDurationsMs: number[];
+  /** Time spent waiting between attempts */
+  backoffMs: number;
+  /** Time from dequeue to final result */
+  totalMs: number;
+}
 
 export interface SpawnResult {
   success: boolean;
   paneId?: string;
+  /** Set on failures */
+  reason?: SpawnFailureReason;
+  /** What went wrong on the last attempt, e.g. tmux's stderr or a thrown error */
+  error?: string;
+  /** Attempts reported by the spawn function itself, e.g. its own retries */
+  attempts?: number;
+  /** Set for items that were actually attempted */
+  timing?: SpawnTiming;
 }
 
 export interface SpawnRequest {
   sessionId: string;
   title: string;
+  /** Parent session, for grouping panes by parent */
+  parentId?: string;
   timestamp: number;
   retryCount: number;
+  /** Aborted when the caller cancels or a hung spawn is abandoned at shutdown */
+  signal: AbortSignal;
+}
+
+/** Recent timing distributions, for tuning spawn_delay_ms and retries */
+export interface SpawnQueueStats {
+  /** enqueue → processing start */
+  queueWaitMs: HistogramSummary;
+  /** processing start → final result, retries included */
+  spawnDurationMs: HistogramSummary;
+}
+
+/** A queued or in-flight item, for introspection */
+export interface SpawnQueueEntry {
+  sessionId: string;
+  title: string;
+  enqueuedAt: number;
+  retryCount: number;
+  inFlight: boolean;
 }
 
 export type SpawnFn = (request: SpawnRequest) => Promise<SpawnResult>;
@@ -19,6 +64,19 @@ export interface SpawnQueueOptions {
   spawnDelayMs?: number;
   maxRetries?: number;
   staleThresholdMs?: number;
+  /** Retry backoff; defaults to DEFAULT_BACKOFF */
+  backoff?: Partial<BackoffOptions>;
+  /** Random source for backoff jitter (for testing) */
+  random?: () => number;
+  /** Time source for timestamps and delays (for testing) */
+  clock?: Clock;
+  /** Queued items (not counting the in-flight one) before overflow applies; 0 or unset is unbounded */
+  maxQueueDepth?: number;
+  /**
+   * What a full queue does with a new item: fail it, or fail the oldest queued
+   * item to make room. Both fail with reason 'overflow'.
+   */
+  overflow?: 'reject-new' | 'drop-oldest';
   onQueueUpdate?: (pendingCount: number) => void;
   onQueueDrained?: () => void;
   /** Optional logger override for testing */
@@ -28,11 +86,24 @@ export interface SpawnQueueOptions {
 interface QueueItem {
   sessionId: string;
   title: string;
+  parentId?: string;
   enqueuedAt: number;
+  /** Failed attempts so far */
+  retryCount: number;
+  controller: AbortController;
   resolve: (result: SpawnResult) => void;
+  /** Detaches the caller's abort listener */
+  detach?: () => void;
+}
+
+export interface EnqueueOptions {
+  /**
+   * Cancels the spawn: a queued item is dropped, an in-flight one is aborted.
+   * Duplicate enqueues share the first caller's item, and so its signal.
+   */
+  signal?: AbortSignal;
 }
 
-const BASE_BACKOFF_MS = 250;
 const DEFAULT_STALE_THRESHOLD_MS = 30_000;
 
 export class SpawnQueue {
@@ -41,12 +112,20 @@ export class SpawnQueue {
   private readonly spawnDelayMs: number;
   private readonly maxRetries: number;
   private readonly staleThresholdMs: number;
+  private readonly backoff: BackoffOptions;
+  private readonly random: () => number;
+  private readonly clock: Clock;
+  private readonly maxQueueDepth: number;
+  private readonly overflow: 'reject-new' | 'drop-oldest';
   private readonly onQueueUpdate?: (pendingCount: number) => void;
   private readonly onQueueDrained?: () => void;
   private readonly logFn: (message: string, data?: unknown) => void;
   private isProcessing = false;
   private hasItemInFlight = false;
+  private inFlightItem: QueueItem | null = null;
   private isShutdown = false;
+  private readonly queueWait = new Histogram();
+  private readonly spawnDuration = new Histogram();
 
   /**
    * Map from sessionId to a pending promise for coalescing duplicate enqueues.
@@ -62,6 +141,11 @@ export class SpawnQueue {
     this.spawnDelayMs = options.spawnDelayMs ?? 300;
     this.maxRetries = options.maxRetries ?? 2;
     this.staleThresholdMs = options.staleThresholdMs ?? DEFAULT_STALE_THRESHOLD_MS;
+    this.backoff = { ...DEFAULT_BACKOFF, ...options.backoff };
+    this.random = options.random ?? Math.random;
+    this.clock = options.clock ?? systemClock;
+    this.maxQueueDepth = options.maxQueueDepth ?? 0;
+    this.overflow = options.overflow ?? 'reject-new';
     this.onQueueUpdate = options.onQueueUpdate;
     this.onQueueDrained = options.onQueueDrained;
     this.logFn = options.logFn ?? log;
@@ -70,14 +154,26 @@ export class SpawnQueue {
       spawnDelayMs: this.spawnDelayMs,
       maxRetries: this.maxRetries,
       staleThresholdMs: this.staleThresholdMs,
+      maxQueueDepth: this.maxQueueDepth,
+      overflow: this.overflow,
+      backoff: this.backoff,
     });
   }
 
-  enqueue(item: { sessionId: string; title: string }): Promise<SpawnResult> {
+  /**
+   * Queues a spawn. Every caller for the same sessionId, including ones that
+   * enqueue while it is queued or in flight, gets the same result; results
+   * are delivered by resolving a promise, so a caller that stopped waiting
+   * never holds up the queue.
+   */
+  enqueue(
+    item: { sessionId: string; title: string; parentId?: string },
+    options: EnqueueOptions = {},
+  ): Promise<SpawnResult> {
     // If shutdown, reject immediately
     if (this.isShutdown) {
       this.logFn('[spawn-queue] enqueue rejected (shutdown)', { sessionId: item.sessionId });
-      return Promise.resolve({ success: false });
+      return Promise.resolve({ success: false, reason: 'shutdown' });
     }
 
     const existing = this.pendingPromises.get(item.sessionId);
@@ -90,6 +186,27 @@ export class SpawnQueue {
       return existing.promise;
     }
 
+    if (options.signal?.aborted) {
+      this.logFn('[spawn-queue] enqueue rejected (aborted)', { sessionId: item.sessionId });
+      return Promise.resolve({ success: false, reason: 'aborted' });
+    }
+
+    if (this.isFull()) {
+      if (this.overflow === 'reject-new') {
+        this.logFn('[spawn-queue] enqueue rejected (overflow)', {
+          sessionId: item.sessionId,
+          queueDepth: this.queue.length,
+        });
+        return Promise.resolve({ success: false, reason: 'overflow' });
+      }
+      const oldest = this.queue.shift()!;
+      this.logFn('[spawn-queue] oldest item dropped (overflow)', {
+        sessionId: oldest.sessionId,
+        queueDepth: this.queue.length,
+      });
+      this.settle(oldest, { success: false, reason: 'overflow' });
+    }
+
     let resolveOuter!: (result: SpawnResult) => void;
     const promise = new Promise<SpawnResult>((resolve) => {
       resolveOuter = resolve;
@@ -97,12 +214,24 @@ export class SpawnQueue {
 
     this.pendingPromises.set(item.sessionId, { promise, resolve: resolveOuter });
 
-    this.queue.push({
+    const queueItem: QueueItem = {
       sessionId: item.sessionId,
       title: item.title,
-      enqueuedAt: Date.now(),
+      parentId: item.parentId,
+      enqueuedAt: this.clock.now(),
+      retryCount: 0,
+      controller: new AbortController(),
       resolve: resolveOuter,
-    });
+    };
+
+    const callerSignal = options.signal;
+    if (callerSignal) {
+      const onAbort = () => this.cancel(queueItem);
+      callerSignal.addEventListener('abort', onAbort, { once: true });
+      queueItem.detach = () => callerSignal.removeEventListener('abort', onAbort);
+    }
+
+    this.queue.push(queueItem);
 
     this.logFn('[spawn-queue] enqueued', {
       sessionId: item.sessionId,
@@ -120,6 +249,33 @@ export class SpawnQueue {
     return this.queue.length + (this.hasItemInFlight ? 1 : 0);
   }
 
+  /** Whether the next enqueue overflows maxQueueDepth */
+  isFull(): boolean {
+    return this.maxQueueDepth > 0 && this.queue.length >= this.maxQueueDepth;
+  }
+
+  /** The in-flight item, if any, then queued items in the order they will run */
+  list(): SpawnQueueEntry[] {
+    const entry = (item: QueueItem, inFlight: boolean): SpawnQueueEntry => ({
+      sessionId: item.sessionId,
+      title: item.title,
+      enqueuedAt: item.enqueuedAt,
+      retryCount: item.retryCount,
+      inFlight,
+    });
+    return [
+      ...(this.inFlightItem ? [entry(this.inFlightItem, true)] : []),
+      ...this.queue.map((item) => entry(item, false)),
+    ];
+  }
+
+  getStats(): SpawnQueueStats {
+    return {
+      queueWaitMs: this.queueWait.summary(),
+      spawnDurationMs: this.spawnDuration.summary(),
+    };
+  }
+
   /**
    * Shutdown the queue: stop processing new items and resolve all pending items as failed.
    */
@@ -139,14 +295,64 @@ export class SpawnQueue {
       this.logFn('[spawn-queue] shutdown - resolving queued item as failed', {
         sessionId: item.sessionId,
       });
-      item.resolve({ success: false });
-      this.pendingPromises.delete(item.sessionId);
+      this.settle(item, { success: false, reason: 'shutdown' });
     }
 
     this.notifyQueueUpdate();
     this.logFn('[spawn-queue] shutdown complete');
   }
 
+  /**
+   * Drops the items waiting their turn, resolving them as flushed. A spawn
+   * already in flight still finishes. Returns how many were dropped.
+   */
+  flush(): number {
+    const dropped = this.queue.splice(0);
+    for (const item of dropped) {
+      this.settle(item, { success: false, reason: 'flushed' });
+    }
+    if (dropped.length > 0) {
+      this.logFn('[spawn-queue] flushed queued items', { count: dropped.length });
+      this.notifyQueueUpdate();
+    }
+    return dropped.length;
+  }
+
+  /**
+   * Waits (bounded) for pending items to settle, typically the in-flight spawn
+   * left running after shutdown(). Callers awaiting enqueue() for those items
+   * are resumed before this resolves.
+   * Returns false if the timeout elapsed first; the in-flight spawn is then
+   * aborted so a hung tmux command doesn't outlive shutdown.
+   */
+  async drain(timeoutMs: number): Promise<boolean> {
+    const pending = Array.from(this.pendingPromises.values(), (entry) => entry.promise);
+    if (pending.length === 0) {
+      return true;
+    }
+
+    this.logFn('[spawn-queue] draining', { pending: pending.length, timeoutMs });
+
+    let timer: ClockTimer | undefined;
+    const timeout = new Promise<boolean>((resolve) => {
+      timer = this.clock.setTimeout(() => resolve(false), timeoutMs);
+    });
+
+    try {
+      const drained = await Promise.race([Promise.all(pending).then(() => true), timeout]);
+      this.logFn('[spawn-queue] drain finished', { drained });
+      if (!drained && this.inFlightItem) {
+        this.logFn('[spawn-queue] aborting in-flight spawn after drain timeout', {
+          sessionId: this.inFlightItem.sessionId,
+        });
+        this.inFlightItem.controller.abort();
+      }
+      return drained;
+    } finally {
+      this.clock.clearTimeout(timer);
+    }
+  }
+
   /**
    * Alias for shutdown() for interface consistency.
    */
@@ -154,6 +360,39 @@ export class SpawnQueue {
     this.shutdown();
   }
 
+  /**
+   * Cancels an item on behalf of its caller: drops it if still queued,
+   * aborts it if in flight.
+   */
+  private cancel(item: QueueItem): void {
+    const index = this.queue.indexOf(item);
+    if (index !== -1) {
+      this.queue.splice(index, 1);
+      this.logFn('[spawn-queue] queued item cancelled', { sessionId: item.sessionId });
+      this.settle(item, { success: false, reason: 'aborted' });
+      this.notifyQueueUpdate();
+      return;
+    }
+
+    if (this.inFlightItem === item) {
+      this.logFn('[spawn-queue] in-flight item cancelled', { sessionId: item.sessionId });
+      item.controller.abort();
+    }
+  }
+
+  /**
+   * Delivers an item's result to its callers. Safe to call more than once:
+   * later calls neither change the result nor forget a newer item for the
+   * same sessionId.
+   */
+  private settle(item: QueueItem, result: SpawnResult): void {
+    item.detach?.();
+    item.resolve(result);
+    if (this.pendingPromises.get(item.sessionId)?.resolve === item.resolve) {
+      this.pendingPromises.delete(item.sessionId);
+    }
+  }
+
   private notifyQueueUpdate(): void {
     this.onQueueUpdate?.(this.queue.length);
   }
@@ -168,18 +407,19 @@ export class SpawnQueue {
     while (this.queue.length > 0 && !this.isShutdown) {
       const item = this.queue.shift()!;
       this.hasItemInFlight = true;
+      this.inFlightItem = item;
       this.notifyQueueUpdate();
 
-      const waitTimeMs = Date.now() - item.enqueuedAt;
+      const waitTimeMs = this.clock.now() - item.enqueuedAt;
       if (waitTimeMs > this.staleThresholdMs) {
         this.logFn('[spawn-queue] stale item skipped', {
           sessionId: item.sessionId,
           waitTimeMs,
           thresholdMs: this.staleThresholdMs,
         });
-        item.resolve({ success: false });
-        this.pendingPromises.delete(item.sessionId);
+        this.settle(item, { success: false, reason: 'stale' });
         this.hasItemInFlight = false;
+        this.inFlightItem = null;
         continue;
       }
 
@@ -188,13 +428,17 @@ export class SpawnQueue {
         title: item.title,
       });
 
-      const result = await this.processItem(item);
-      item.resolve(result);
-      this.pendingPromises.delete(item.sessionId);
+      this.queueWait.record(waitTimeMs);
+      const result = await this.processItem(item, waitTimeMs);
+      if (result.timing) {
+        this.spawnDuration.record(result.timing.totalMs);
+      }
+      this.settle(item, result);
       this.hasItemInFlight = false;
+      this.inFlightItem = null;
 
       if (this.queue.length > 0 && !this.isShutdown) {
-        await this.delay(this.spawnDelayMs);
+        await sleep(this.spawnDelayMs, undefined, this.clock);
       }
     }
 
@@ -205,21 +449,39 @@ export class SpawnQueue {
 
   private notifyQueueDrained(): void {
     if (this.queue.length === 0 && !this.hasItemInFlight) {
-      this.logFn('[spawn-queue] queue drained');
+      this.logFn('[spawn-queue] queue drained', this.getStats());
       this.onQueueDrained?.();
     }
   }
 
-  private async processItem(item: QueueItem): Promise<SpawnResult> {
+  private async processItem(item: QueueItem, queueWaitMs: number): Promise<SpawnResult> {
     let retryCount = 0;
     let lastResult: SpawnResult = { success: false };
+    const startedAt = this.clock.now();
+    const attemptDurationsMs: number[] = [];
+    let backoffTotalMs = 0;
+
+    const withTiming = (result: SpawnResult): SpawnResult => ({
+      ...result,
+      timing: {
+        queueWaitMs,
+        attempts: attemptDurationsMs.length,
+        attemptDurationsMs,
+        backoffMs: backoffTotalMs,
+        totalMs: this.clock.now() - startedAt,
+      },
+    });
+
+    const signal = item.controller.signal;
 
-    while (retryCount <= this.maxRetries && !this.isShutdown) {
+    while (retryCount <= this.maxRetries && !this.isShutdown && !signal.aborted) {
       const request: SpawnRequest = {
         sessionId: item.sessionId,
         title: item.title,
+        parentId: item.parentId,
         timestamp: item.enqueuedAt,
         retryCount,
+        signal,
       };
 
       this.logFn('[spawn-queue] spawn attempt', {
@@ -228,42 +490,56 @@ export class SpawnQueue {
         maxAttempts: this.maxRetries + 1,
       });
 
+      const attemptStartedAt = this.clock.now();
       try {
         lastResult = await this.spawnFn(request);
-      } catch {
-        lastResult = { success: false };
+      } catch (err) {
+        lastResult = { success: false, reason: 'tmux_error', error: String(err) };
       }
+      attemptDurationsMs.push(this.clock.now() - attemptStartedAt);
 
       if (lastResult.success) {
+        const result = withTiming(lastResult);
         this.logFn('[spawn-queue] success', {
           sessionId: item.sessionId,
           paneId: lastResult.paneId,
           attempts: retryCount + 1,
+          timing: result.timing,
         });
-        return lastResult;
+        return result;
       }
 
       retryCount++;
-      if (retryCount <= this.maxRetries && !this.isShutdown) {
-        const backoffMs = BASE_BACKOFF_MS * Math.pow(2, retryCount - 1);
+      item.retryCount = retryCount;
+      if (retryCount <= this.maxRetries && !this.isShutdown && !signal.aborted) {
+        this.notifyQueueUpdate();
+        const backoffMs = computeBackoffMs(retryCount, this.backoff, this.random);
         this.logFn('[spawn-queue] retry wait', {
           sessionId: item.sessionId,
           backoffMs,
           nextAttempt: retryCount + 1,
         });
-        await this.delay(backoffMs);
+        // Count the time actually waited: an abort or shutdown cuts the wait short
+        const waitStartedAt = this.clock.now();
+        await sleep(backoffMs, signal, this.clock);
+        backoffTotalMs += this.clock.now() - waitStartedAt;
       }
     }
 
+    const reason: SpawnFailureReason = signal.aborted
+      ? 'aborted'
+      : this.isShutdown && retryCount === 0
+        ? 'shutdown'
+        : (lastResult.reason ?? 'tmux_error');
+    const result = withTiming({ ...lastResult, reason });
     this.logFn('[spawn-queue] final failure', {
       sessionId: item.sessionId,
       attempts: retryCount,
+      reason,
+      error: result.error,
+      timing: result.timing,
     });
 
-    return lastResult;
-  }
-
-  private delay(ms: number): Promise<void> {
-    return new Promise((resolve) => setTimeout(resolve, ms));
+    return result;
   }
 }
@@ -0,0 +1,131 @@
+import type { PluginInput } from './types';
+import { systemClock, type Clock } from './utils/clock';
+import { requestJson } from './utils/http';
+
+type OpencodeClient = PluginInput['client'];
+
+export type SessionStatuses = Record<string, { type: string }>;
+
+/**
+ * What TmuxSessionManager asks the opencode server. The manager takes one at
+ * construction so its logic can run against a fake.
+ */
+export interface StatusSource {
+  /** Status of every live session by id; rejects when the server doesn't answer */
+  fetchStatuses(): Promise<SessionStatuses>;
+  /** The session's title, or undefined when it can't be looked up */
+  fetchTitle(sessionId: string): Promise<unknown>;
+  /** Asks the server to stop the session's agent; whether it accepted */
+  abortSession(sessionId: string): Promise<boolean>;
+  isServerAlive(): Promise<boolean>;
+}
+
+export interface StatusSourceTimeouts {
+  statusMs: number;
+  titleMs: number;
+  abortMs: number;
+  healthMs: number;
+}
+
+export const DEFAULT_STATUS_SOURCE_TIMEOUTS: StatusSourceTimeouts = {
+  statusMs: 5000,
+  titleMs: 2000,
+  abortMs: 2000,
+  healthMs: 1500,
+};
+
+/**
+ * Statuses come from the plugin's SDK client, everything else from the
+ * server's HTTP API. Every request gives up after its timeout, so a wedged
+ * server can't stall polling.
+ */
+export function createStatusSource(
+  client: OpencodeClient,
+  serverUrl: string,
+  timeouts: Partial<StatusSourceTimeouts> = {},
+): StatusSource {
+  const limits = { ...DEFAULT_STATUS_SOURCE_TIMEOUTS, ...timeouts };
+  const sessionUrl = (sessionId: string, suffix = '') =>
+    new URL(`/session/${encodeURIComponent(sessionId)}${suffix}`, serverUrl).toString();
+
+  return {
+    async fetchStatuses() {
+      // The abort stops the HTTP request; the race covers clients that ignore it
+      const controller = new AbortController();
+      let timeout: ReturnType<typeof setTimeout> | undefined;
+      const timedOut = new Promise<never>((_, reject) => {
+        timeout = setTimeout(() => {
+          controller.abort();
+          reject(new Error(`session status timed out after ${limits.statusMs}ms`));
+        }, limits.statusMs);
+      });
+      try {
+        const result = await Promise.race([
+          client.session.status({ signal: controller.signal }),
+          timedOut,
+        ]);
+        return (result.data ?? {}) as SessionStatuses;
+      } finally {
+        clearTimeout(timeout);
+      }
+    },
+
+    async fetchTitle(sessionId) {
+      const response = await requestJson(sessionUrl(sessionId), { timeoutMs: limits.titleMs });
+      if (!response?.ok) return undefined;
+      return (response.body as { title?: unknown } | null)?.title;
+    },
+
+    async abortSession(sessionId) {
+      const response = await requestJson(sessionUrl(sessionId, '/abort'), {
+        method: 'POST',
+        timeoutMs: limits.abortMs,
+      });
+      return response?.ok ?? false;
+    },
+
+    async isServerAlive() {
+      const response = await requestJson(new URL('/health', serverUrl).toString(), {
+        timeoutMs: limits.healthMs,
+      });
+      return response?.ok ?? false;
+    },
+  };
+}
+
+/**
+ * One status snapshot shared by everything polling the same server, so the
+ * manager's poll and the reaper's scan don't each fetch it. Callers that ask
+ * while a fetch is running wait for it instead of starting another.
+ */
+export class SharedStatusCache {
+  private snapshot: { statuses: SessionStatuses; fetchedAt: number } | null = null;
+  private inFlight: Promise<SessionStatuses> | null = null;
+
+  constructor(
+    private readonly source: StatusSource,
+    private readonly clock: Clock = systemClock,
+  ) {}
+
+  /**
+   * The last snapshot if it is at most maxAgeMs old, otherwise a fresh one.
+   * Failed fetches reject for every waiting caller and aren't cached.
+   */
+  async get(maxAgeMs = 0): Promise<SessionStatuses> {
+    if (this.snapshot && this.clock.now() - this.snapshot.fetchedAt <= maxAgeMs) {
+      return this.snapshot.statuses;
+    }
+    if (!this.inFlight) {
+      this.inFlight = this.source
+        .fetchStatuses()
+        .then((statuses) => {
+          this.snapshot = { statuses, fetchedAt: this.clock.now() };
+          return statuses;
+        })
+        .finally(() => {
+          this.inFlight = null;
+        });
+    }
+    return this.inFlight;
+  }
+}
@@ -1,17 +1,74 @@
-import type { PluginInput } from './types';
+import type { PluginInput, SpawnFailureReason } from './types';
 import {
-  POLL_INTERVAL_MS,
   SESSION_MISSING_GRACE_MS,
   SESSION_TIMEOUT_MS,
   type TmuxConfig,
 } from './config';
-import { SpawnQueue, type SpawnRequest } from './spawn-queue';
-import { closeTmuxPane, isInsideTmux, log, spawnTmuxPane, applyTmuxLayout } from './utils';
+import { tmuxPaneController, type PaneController } from './pane-controller';
+import { computePollInterval } from './poll-interval';
+import {
+  SpawnQueue,
+  type SpawnQueueStats,
+  type SpawnRequest,
+  type SpawnResult,
+} from './spawn-queue';
+import {
+  closeTmuxControlClient,
+  formatStatusLine,
+  getCloseAllRequest,
+  getQueueFlushedAt,
+  hasTmuxControlClient,
+  isInsideTmux,
+  isSpawningPaused,
+  log,
+  onTmuxNotification,
+  setQueueOption,
+  setStatusLineText,
+  type AgentPane,
+  type PaneGroup,
+  type PaneStatus,
+} from './utils';
+import { systemClock, type Clock, type ClockTimer } from './utils/clock';
+import { createMetricsSink, type MetricsSink } from './utils/metrics';
+import { savePaneOutput } from './utils/pane-output';
+import { terminatePolicyFromConfig, type ProcessEntry } from './utils/process';
+import type { QueuedSpawn } from './utils/queue-report';
+import { isQuietTime } from './utils/quiet-hours';
+import { isSameServer } from './utils/server-url';
+import { exportTranscript } from './utils/transcript';
+import { appendSessionHistory, type SessionHistoryEntry } from './utils/session-history';
+import { createStatusSource, SharedStatusCache, type StatusSource } from './status-source';
 import { ZombieReaper } from './zombie-reaper';
 
-type OpencodeClient = PluginInput['client'];
+const SHUTDOWN_DRAIN_TIMEOUT_MS = 5000;
+const TRANSCRIPT_EXPORT_TIMEOUT_MS = 5000;
+
+/**
+ * What to tell the user when an agent gets no pane. Null for failures they
+ * caused or expect (opentmux disabled, outside tmux, shutting down).
+ */
+const SPAWN_FAILURE_MESSAGES: Record<SpawnFailureReason, string | null> = {
+  disabled: null,
+  not_in_tmux: null,
+  server_unreachable: "the opencode server isn't responding",
+  tmux_missing: "tmux isn't on PATH",
+  tmux_error: 'tmux failed to open it (see the log)',
+  aborted: null,
+  stale: 'it waited too long in the spawn queue',
+  flushed: null,
+  overflow: 'the spawn queue is full (max_queue_depth)',
+  shutdown: null,
+};
 
-interface TrackedSession {
+export type AgentStatus = 'busy' | 'idle' | 'error';
+
+const PANE_STATUS_BY_AGENT_STATUS: Record<AgentStatus, PaneStatus> = {
+  busy: 'working',
+  idle: 'idle',
+  error: 'error',
+};
+
+export interface TrackedSession {
   sessionId: string;
   paneId: string;
   parentId: string;
@@ -19,68 +76,230 @@ interface TrackedSession {
   createdAt: number;
   lastSeenAt: number;
   missingSince?: number;
+  status: AgentStatus;
+  attempts: number;
+  /** closeSession is killing the pane; it is no longer "closed externally" */
+  closing?: boolean;
 }
 
-interface SessionCreatedEvent {
+export interface SessionCreatedEvent {
   type: string;
   properties?: { info?: { id?: string; parentID?: string; title?: string } };
 }
 
+interface SessionDeletedEvent {
+  type: string;
+  properties?: { info?: { id?: string } };
+}
+
+interface SessionUpdatedEvent {
+  type: string;
+  properties?: { info?: { id?: string; title?: string } };
+}
+
+/** session.idle / session.error; older servers send info.id instead of sessionID */
+interface SessionStatusEvent {
+  type: string;
+  properties?: { sessionID?: string; info?: { id?: string }; error?: unknown };
+}
+
+/** Bound on remembered ended parents, used to catch spawns racing the parent's end */
+const MAX_ENDED_PARENTS = 100;
+
+/** Bound on sessions held without a pane; past it new sessions get none */
+const MAX_HELD_SESSIONS = 200;
+
+/**
+ * What a manager being replaced passes to its successor: the panes it
+ * tracks, the sessions it holds and the spawns still queued, so they
+ * aren't orphaned.
+ */
+export interface SessionHandover {
+  readonly sessions: readonly TrackedSession[];
+  readonly held: ReadonlyArray<{ event: SessionCreatedEvent; heldAt: number }>;
+  /** Sessions waiting in the spawn queue, for the successor to enqueue again */
+  readonly queued: readonly SessionCreatedEvent[];
+}
+
+/** Seams for tests and embedders; each defaults to the real tmux or opencode server */
+export interface TmuxSessionManagerDependencies {
+  panes?: PaneController;
+  statuses?: StatusSource;
+  /**
+   * Close panes on SIGINT, SIGTERM, SIGHUP, SIGQUIT and beforeExit (default
+   * true). Hosts that own their shutdown turn this off and call cleanup().
+   */
+  handleSignals?: boolean;
+}
+
+/**
+ * Readable text for a session.error payload. opencode sends errors as
+ * `{ name, data: { message } }`; anything else is serialized as is.
+ */
+function describeSessionError(error: unknown): string {
+  if (error === undefined || error === null) return 'unknown';
+  if (typeof error !== 'object') return String(error);
+
+  const { name, data } = error as { name?: unknown; data?: { message?: unknown } };
+  if (typeof name === 'string') {
+    return typeof data?.message === 'string' ? `${name}: ${data.message}` : name;
+  }
+  try {
+    return JSON.stringify(error);
+  } catch {
+    return String(error);
+  }
+}
+
 export class TmuxSessionManager {
-  private client: OpencodeClient;
+  private panes: PaneController;
+  private statuses: StatusSource;
+  /** Shared with the reaper, which reuses the snapshot of a recent poll */
+  private statusCache: SharedStatusCache;
+  private directory: string;
   private tmuxConfig: TmuxConfig;
   private serverUrl: string;
   private sessions = new Map<string, TrackedSession>();
   private pendingSessions = new Set<string>();
-  private pollInterval?: ReturnType<typeof setInterval>;
+  /** The created event of each session in the spawn queue, kept for handOver() */
+  private queuedEvents = new Map<string, SessionCreatedEvent>();
+  /** Set by handOver(), so spawns it cancels are passed on rather than failed */
+  private handingOver = false;
+  private endedParents = new Set<string>();
+  /** Child sessions held by spawnHoldReason(), spawned once nothing holds them */
+  private heldSessions = new Map<string, { event: SessionCreatedEvent; heldAt: number }>();
+  private pauseCheckTimer?: ClockTimer;
+  /** Last read of `opentmux pause`'s flag, shared by reads close together */
+  private pausedRead: { paused: Promise<boolean>; readAt: number } | null = null;
+  private parentTitles = new Map<string, string>();
+  private pollTimer?: ClockTimer;
+  private pollActive = false;
+  private pollInFlight = false;
+  private lastSpawnAt: number | null = null;
+  private allBusySince: number | null = null;
+  private lastStatusLine = '';
+  private lastQueueReport = '';
   private enabled = false;
   private shuttingDown = false;
   private spawnQueue: SpawnQueue;
-  private layoutDebounceTimer?: ReturnType<typeof setTimeout>;
-  private reaper: ZombieReaper;
+  private layoutDebounceTimer?: ClockTimer;
+  /** Null with remote_server, where the server's processes aren't visible */
+  private reaper: ZombieReaper | null;
+  private metrics: MetricsSink | null;
+  private unsubscribeNotifications?: () => void;
+  private clock: Clock;
+  private handleSignals: boolean;
+  /** Close-all requests up to this time were handled or predate this manager */
+  private closeAllHandledAt: number;
+  /** When polling last swept for orphaned panes; sweeps run at most every reaper_interval_ms */
+  private lastOrphanSweepAt = 0;
 
-  constructor(ctx: PluginInput, tmuxConfig: TmuxConfig, serverUrl: string) {
-    this.client = ctx.client;
+  constructor(
+    ctx: PluginInput,
+    tmuxConfig: TmuxConfig,
+    serverUrl: string,
+    clock: Clock = systemClock,
+    dependencies: TmuxSessionManagerDependencies = {},
+  ) {
+    this.panes = dependencies.panes ?? tmuxPaneController;
+    this.handleSignals = dependencies.handleSignals ?? true;
+    this.statuses = dependencies.statuses ?? createStatusSource(ctx.client, serverUrl);
+    this.statusCache = new SharedStatusCache(this.statuses, clock);
+    this.directory = ctx.directory;
     this.tmuxConfig = tmuxConfig;
     this.serverUrl = serverUrl;
+    this.clock = clock;
+    this.closeAllHandledAt = clock.now();
     this.enabled = tmuxConfig.enabled && isInsideTmux();
+    this.metrics = createMetricsSink(tmuxConfig.metrics_sink);
+
+    if (tmuxConfig.tmux_control_mode) {
+      // Panes exiting change the layout; poll right away instead of waiting.
+      // Resizes do too, which is when the auto layout may need to switch.
+      this.unsubscribeNotifications = onTmuxNotification((notification) => {
+        if (notification.name === 'layout-change' || notification.name === 'window-close') {
+          this.pollSoon();
+        }
+        if (notification.name === 'layout-change') {
+          void this.panes.refreshLayout().catch((err) =>
+            log('[tmux-session-manager] failed to refresh layout', { error: String(err) }),
+          );
+        }
+      });
+    }
 
     this.spawnQueue = new SpawnQueue({
-      spawnFn: (request: SpawnRequest) =>
-        spawnTmuxPane(request.sessionId, request.title, this.tmuxConfig, this.serverUrl),
+      spawnFn: (request: SpawnRequest) => this.spawnPane(request),
       spawnDelayMs: tmuxConfig.spawn_delay_ms,
       maxRetries: 0,
+      backoff: {
+        baseMs: tmuxConfig.spawn_backoff_base_ms,
+        maxMs: tmuxConfig.spawn_backoff_max_ms,
+        jitter: tmuxConfig.spawn_backoff_jitter,
+      },
+      maxQueueDepth: tmuxConfig.max_queue_depth,
+      // headless-track holds sessions instead of queueing them once the queue is full
+      overflow: tmuxConfig.queue_overflow_policy === 'drop-oldest' ? 'drop-oldest' : 'reject-new',
       onQueueUpdate: (pendingCount: number) => {
         log('[tmux-session-manager] queue update', { pendingCount });
+        this.publishStatusLine();
       },
       onQueueDrained: () => {
         this.scheduleDebouncedLayout();
       },
+      clock,
     });
 
-    this.reaper = new ZombieReaper(this.serverUrl, {
-      enabled: tmuxConfig.reaper_enabled,
-      intervalMs: tmuxConfig.reaper_interval_ms,
-      minZombieChecks: tmuxConfig.reaper_min_zombie_checks,
-      gracePeriodMs: tmuxConfig.reaper_grace_period_ms,
-      autoSelfDestruct: tmuxConfig.reaper_auto_self_destruct,
-      selfDestructTimeoutMs: tmuxConfig.reaper_self_destruct_timeout_ms,
-    });
+    this.reaper = tmuxConfig.remote_server
+      ? null
+      : new ZombieReaper(this.serverUrl, {
+          enabled: tmuxConfig.reaper_enabled,
+          intervalMs: tmuxConfig.reaper_interval_ms,
+          minZombieChecks: tmuxConfig.reaper_min_zombie_checks,
+          gracePeriodMs: tmuxConfig.reaper_grace_period_ms,
+          dryRun: tmuxConfig.reaper_dry_run,
+          autoSelfDestruct: tmuxConfig.reaper_auto_self_destruct,
+          selfDestructTimeoutMs: tmuxConfig.reaper_self_destruct_timeout_ms,
+          terminatePolicy: terminatePolicyFromConfig(tmuxConfig),
+          clock,
+          onReaped: () => {
+            this.metrics?.increment('zombies.reaped');
+            void this.metrics?.flush();
+          },
+          // Only share a snapshot the poll took moments ago; one from a whole
+          // reaper interval back can miss sessions that started since.
+          activeSessions: () =>
+            this.statusCache.get(tmuxConfig.poll_interval_min_ms ?? 500).then(
+              (statuses) => new Set(Object.keys(statuses)),
+              () => null,
+            ),
+        });
 
     log('[tmux-session-manager] initialized', {
       enabled: this.enabled,
       tmuxConfig: this.tmuxConfig,
       serverUrl: this.serverUrl,
     });
+    if (tmuxConfig.remote_server) {
+      log('[tmux-session-manager] remote_server: reaper and process checks off, using HTTP only', {
+        serverUrl: this.serverUrl,
+      });
+    }
 
     if (this.enabled) {
-      this.registerShutdownHandlers();
+      if (this.handleSignals) this.registerShutdownHandlers();
       
       // Start reaper
-      this.reaper.start();
-      void this.reaper.scanOnce().catch(err => 
+      this.reaper?.start();
+      void this.reaper?.scanOnce().catch(err => 
         log('[tmux-session-manager] initial reaper scan failed', { error: String(err) })
       );
+      if (tmuxConfig.reaper_enabled) {
+        this.lastOrphanSweepAt = this.clock.now();
+        void this.sweepOrphanedPanes().catch(err =>
+          log('[tmux-session-manager] initial pane sweep failed', { error: String(err) })
+        );
+      }
     }
   }
 
@@ -97,7 +316,11 @@ export class TmuxSessionManager {
     const parentId = info.parentID;
     const title = info.title ?? 'Subagent';
 
-    if (this.sessions.has(sessionId) || this.pendingSessions.has(sessionId)) {
+    if (
+      this.sessions.has(sessionId) ||
+      this.pendingSessions.has(sessionId) ||
+      this.heldSessions.has(sessionId)
+    ) {
       log('[tmux-session-manager] session already tracked or pending', { sessionId });
       return;
     }
@@ -105,16 +328,41 @@ export class TmuxSessionManager {
     this.pendingSessions.add(sessionId);
 
     try {
+      const holdReason = await this.spawnHoldReason();
+      if (holdReason) {
+        if (holdReason === 'queue_full') this.metrics?.increment('queue.overflow');
+        this.holdSession(sessionId, event, holdReason);
+        return;
+      }
+
+      // A restarted plugin sees session.created again for panes it opened before
+      const existing = await this.findExistingPane(sessionId);
+      if (existing) {
+        await this.adoptPane(existing, parentId, title);
+        return;
+      }
+
       log('[tmux-session-manager] child session created, spawning pane', {
         sessionId,
         parentId,
         title,
       });
 
-      const paneResult = await this.spawnQueue.enqueue({ sessionId, title });
+      const requestedAt = this.clock.now();
+      this.queuedEvents.set(sessionId, event);
+      const paneResult = await this.spawnQueue
+        .enqueue({ sessionId, title, parentId })
+        .finally(() => this.queuedEvents.delete(sessionId));
+      if (paneResult.reason === 'shutdown' && this.handingOver) {
+        log('[tmux-session-manager] queued spawn handed over', { sessionId });
+        return;
+      }
+      const attempts = paneResult.attempts ?? paneResult.timing?.attempts ?? 1;
+      this.recordSpawnMetrics(paneResult, attempts);
 
       if (paneResult.success && paneResult.paneId) {
-        const now = Date.now();
+        const now = this.clock.now();
+        this.lastSpawnAt = now;
         this.sessions.set(sessionId, {
           sessionId,
           paneId: paneResult.paneId,
@@ -122,6 +370,8 @@ export class TmuxSessionManager {
           title,
           createdAt: now,
           lastSeenAt: now,
+          status: 'busy',
+          attempts,
         });
 
         log('[tmux-session-manager] pane spawned', {
@@ -129,42 +379,683 @@ export class TmuxSessionManager {
           paneId: paneResult.paneId,
         });
 
-        this.startPolling();
+        // The parent ended while this pane was being spawned
+        if (this.endedParents.has(parentId)) {
+          await this.closeSession(sessionId, 'parent_deleted');
+          return;
+        }
+
+        await this.paintPane(paneResult.paneId, 'working');
+        this.publishStatusLine();
+        void this.enrichTitle(sessionId);
+
+        // A spawn that finishes during shutdown stays tracked so cleanup closes it.
+        if (!this.shuttingDown) {
+          this.startPolling();
+        }
+      } else if (
+        paneResult.reason === 'overflow' &&
+        this.tmuxConfig.queue_overflow_policy === 'headless-track' &&
+        !this.shuttingDown
+      ) {
+        // Lost the race for the last free slot: wait for the next one
+        this.holdSession(sessionId, event, 'queue_full');
       } else {
-        log('[tmux-session-manager] failed to spawn pane', { sessionId });
+        log('[tmux-session-manager] failed to spawn pane', {
+          sessionId,
+          reason: paneResult.reason,
+          error: paneResult.error,
+        });
+        const message = SPAWN_FAILURE_MESSAGES[paneResult.reason ?? 'tmux_error'];
+        if (message && !this.shuttingDown) {
+          void this.panes.showMessage(`opentmux: no pane for "${title}": ${message}`);
+        }
+        this.recordHistory({
+          sessionId,
+          parentId,
+          title,
+          paneId: null,
+          spawnedAt: requestedAt,
+          closedAt: this.clock.now(),
+          reason: this.shuttingDown
+            ? 'shutdown'
+            : paneResult.reason === 'flushed' || paneResult.reason === 'overflow'
+              ? paneResult.reason
+              : 'spawn_failed',
+          attempts,
+        });
       }
     } finally {
       this.pendingSessions.delete(sessionId);
     }
   }
 
-  private startPolling(): void {
-    if (this.pollInterval) return;
+  /**
+   * Holds a session until nothing holds new panes back. Past
+   * MAX_HELD_SESSIONS the session gets no pane instead, so a long pause or
+   * a flood of agents under headless-track can't grow the held set forever.
+   */
+  private holdSession(sessionId: string, event: SessionCreatedEvent, reason: string): void {
+    const info = event.properties?.info;
+    if (this.heldSessions.size >= MAX_HELD_SESSIONS) {
+      log('[tmux-session-manager] too many held sessions, no pane for session', {
+        sessionId,
+        reason,
+        held: this.heldSessions.size,
+      });
+      const now = this.clock.now();
+      this.recordHistory({
+        sessionId,
+        parentId: info?.parentID ?? '',
+        title: info?.title ?? 'Subagent',
+        paneId: null,
+        spawnedAt: now,
+        closedAt: now,
+        reason: 'overflow',
+        attempts: 0,
+      });
+      return;
+    }
+
+    log('[tmux-session-manager] holding session', { sessionId, parentId: info?.parentID, reason });
+    this.heldSessions.set(sessionId, { event, heldAt: this.clock.now() });
+    this.schedulePauseCheck();
+    this.publishStatusLine();
+  }
+
+  /**
+   * A live agent pane already attached to this session on this server.
+   */
+  private async findExistingPane(sessionId: string): Promise<AgentPane | undefined> {
+    const panes = await this.panes.listAgentPanes();
+    const candidates = panes.filter(
+      (pane) => pane.sessionId === sessionId && pane.serverUrl === this.serverUrl,
+    );
+    if (candidates.length === 0) return undefined;
+
+    // Without a ps snapshot nothing can be confirmed attached, so spawn anew
+    const processes = await this.listPaneProcesses();
+    if (processes === null) return undefined;
+    return candidates.find((pane) => this.isPaneAttached(pane, processes));
+  }
+
+  /**
+   * The process snapshot attach checks run against, or null when ps can't be
+   * read. With remote_server the pane's processes can't be inspected, so the
+   * snapshot is empty and isPaneAttached doesn't look at it.
+   */
+  private async listPaneProcesses(): Promise<ProcessEntry[] | null> {
+    return this.tmuxConfig.remote_server ? [] : this.panes.listProcesses();
+  }
+
+  /**
+   * Whether a pane still runs its attach process. With remote_server only a
+   * dead pane counts as gone.
+   */
+  private isPaneAttached(pane: AgentPane, processes: ProcessEntry[]): boolean {
+    return this.tmuxConfig.remote_server ? !pane.dead : this.panes.isAttached(pane, processes);
+  }
+
+  /** Closes an agent pane; with remote_server, without signalling its processes */
+  private closePane(paneId: string): Promise<boolean> {
+    return this.tmuxConfig.remote_server
+      ? this.panes.closePane(paneId, { killProcesses: false })
+      : this.panes.closePane(paneId);
+  }
+
+  /**
+   * Tracks an agent pane opened by an earlier plugin instance instead of
+   * spawning a duplicate.
+   */
+  private async adoptPane(pane: AgentPane, parentId: string, title: string): Promise<void> {
+    log('[tmux-session-manager] adopting existing pane', {
+      sessionId: pane.sessionId,
+      paneId: pane.paneId,
+    });
+    const now = this.clock.now();
+    this.sessions.set(pane.sessionId, {
+      sessionId: pane.sessionId,
+      paneId: pane.paneId,
+      parentId,
+      title,
+      createdAt: now,
+      lastSeenAt: now,
+      status: 'busy',
+      attempts: 0,
+    });
+    await this.paintPane(pane.paneId, 'working');
+    this.publishStatusLine();
+    void this.enrichTitle(pane.sessionId);
+    if (!this.shuttingDown) {
+      this.startPolling();
+    }
+  }
+
+  /**
+   * Handles a session being deleted: closes its own pane if it is an agent,
+   * and cascades to every agent pane descended from it.
+   */
+  async onSessionDeleted(event: SessionDeletedEvent): Promise<void> {
+    if (!this.enabled) return;
+    if (event.type !== 'session.deleted') return;
+
+    const sessionId = event.properties?.info?.id;
+    if (!sessionId) return;
+
+    this.rememberEndedParent(sessionId);
+    this.dropHeldSessions(sessionId);
+    await this.closeDescendants(sessionId);
+
+    if (this.sessions.has(sessionId)) {
+      await this.closeSession(sessionId, 'deleted');
+    }
+  }
 
-    this.pollInterval = setInterval(
-      () => this.pollSessions(),
-      POLL_INTERVAL_MS,
+  /**
+   * Handles pushed status events so panes react without waiting for a poll:
+   * idle closes the pane, error marks the agent as failed.
+   */
+  async onSessionStatusEvent(event: SessionStatusEvent): Promise<void> {
+    if (!this.enabled) return;
+
+    const sessionId = event.properties?.sessionID ?? event.properties?.info?.id;
+    if (!sessionId) return;
+
+    const tracked = this.sessions.get(sessionId);
+    if (!tracked) return;
+
+    tracked.lastSeenAt = this.clock.now();
+    tracked.missingSince = undefined;
+
+    switch (event.type) {
+      case 'session.idle':
+        await this.updateStatus(tracked, 'idle');
+        await this.closeSession(sessionId, 'idle_event');
+        break;
+      case 'session.error':
+        log('[tmux-session-manager] session reported an error', {
+          sessionId,
+          error: describeSessionError(event.properties?.error),
+        });
+        await this.updateStatus(tracked, 'error');
+        break;
+    }
+  }
+
+  private async updateStatus(tracked: TrackedSession, status: AgentStatus): Promise<void> {
+    if (tracked.status === status) return;
+    tracked.status = status;
+    await this.paintPane(tracked.paneId, PANE_STATUS_BY_AGENT_STATUS[status]);
+  }
+
+  /**
+   * Replaces the pane title the event carried (often just "Subagent") with
+   * the session's real title from the server, once it is available.
+   */
+  private async enrichTitle(sessionId: string): Promise<void> {
+    await this.applyTitle(sessionId, await this.statuses.fetchTitle(sessionId));
+  }
+
+  private async spawnPane(request: SpawnRequest): Promise<SpawnResult> {
+    const flushedAt = await getQueueFlushedAt();
+    if (flushedAt !== null && request.timestamp <= flushedAt) {
+      log('[tmux-session-manager] skipping flushed spawn', { sessionId: request.sessionId });
+      return { success: false, reason: 'flushed' };
+    }
+
+    const group =
+      (await this.ruleGroupFor(request)) ??
+      (this.tmuxConfig.group_by_parent && request.parentId
+        ? await this.paneGroupFor(request.parentId)
+        : undefined);
+    return this.panes.spawnPane(
+      request.sessionId,
+      request.title,
+      this.tmuxConfig,
+      this.serverUrl,
+      request.signal,
+      group,
+    );
+  }
+
+  /**
+   * The window the first matching window_rules entry sends this agent to.
+   * The parent's title is only looked up when a rule needs it.
+   */
+  private async ruleGroupFor(request: SpawnRequest): Promise<PaneGroup | undefined> {
+    for (const rule of this.tmuxConfig.window_rules ?? []) {
+      if (rule.title_match && !new RegExp(rule.title_match, 'i').test(request.title)) continue;
+      if (rule.parent_match) {
+        if (!request.parentId) continue;
+        const parentTitle = await this.parentTitle(request.parentId);
+        if (!new RegExp(rule.parent_match, 'i').test(parentTitle)) continue;
+      }
+      return { key: `rule:${rule.window}`, name: rule.window };
+    }
+    return undefined;
+  }
+
+  /**
+   * The window a child session's pane goes into when group_by_parent is on,
+   * named after the parent session's title.
+   */
+  private async paneGroupFor(parentId: string): Promise<PaneGroup> {
+    return { key: parentId, name: await this.parentTitle(parentId) };
+  }
+
+  /**
+   * A parent session's title, looked up once per parent; its id if untitled.
+   */
+  private async parentTitle(parentId: string): Promise<string> {
+    let name = this.parentTitles.get(parentId);
+    if (name === undefined) {
+      const title = await this.statuses.fetchTitle(parentId);
+      name = typeof title === 'string' && title.trim() ? title.trim() : parentId;
+      this.parentTitles.set(parentId, name);
+    }
+    return name;
+  }
+
+  /**
+   * Handles a session being renamed on the server (e.g. `opentmux session rename`)
+   * by updating the tracked title and the pane title.
+   */
+  async onSessionUpdated(event: SessionUpdatedEvent): Promise<void> {
+    if (!this.enabled) return;
+    if (event.type !== 'session.updated') return;
+
+    const info = event.properties?.info;
+    if (!info?.id) return;
+    await this.applyTitle(info.id, info.title);
+  }
+
+  private async applyTitle(sessionId: string, rawTitle: unknown): Promise<void> {
+    const title = typeof rawTitle === 'string' ? rawTitle.trim() : '';
+    const tracked = this.sessions.get(sessionId);
+    if (!tracked || !title || title === tracked.title) return;
+
+    log('[tmux-session-manager] updating pane title from server', {
+      sessionId,
+      from: tracked.title,
+      to: title,
+    });
+    tracked.title = title;
+    await this.panes.setTitle(tracked.paneId, title, this.tmuxConfig.pane_title_max_width);
+  }
+
+  /**
+   * Mirrors agent and queue counts into the tmux session's status option.
+   * Only writes when the text changes, so status bars can read it for free.
+   */
+  private publishStatusLine(): void {
+    if (!this.enabled) return;
+    this.publishQueue();
+    if (!this.tmuxConfig.status_line) return;
+
+    const text = formatStatusLine(
+      this.sessions.size,
+      this.spawnQueue.getPendingCount() + this.heldSessions.size,
+    );
+    if (text === this.lastStatusLine) return;
+    this.lastStatusLine = text;
+
+    void setStatusLineText(text).catch((err) =>
+      log('[tmux-session-manager] failed to publish status line', { error: String(err) }),
+    );
+  }
+
+  /**
+   * Spawns that have no pane yet: the one in flight, queued ones in the order
+   * they will run, then held ones.
+   */
+  getQueuedSpawns(): QueuedSpawn[] {
+    const queued: QueuedSpawn[] = this.spawnQueue.list().map((entry) => ({
+      sessionId: entry.sessionId,
+      title: entry.title,
+      state: entry.inFlight ? 'spawning' : 'queued',
+      since: entry.enqueuedAt,
+      retries: entry.retryCount,
+    }));
+    for (const [sessionId, { event, heldAt }] of this.heldSessions) {
+      queued.push({
+        sessionId,
+        title: event.properties?.info?.title ?? 'Subagent',
+        state: 'held',
+        since: heldAt,
+        retries: 0,
+      });
+    }
+    return queued;
+  }
+
+  /**
+   * Writes getQueuedSpawns() to the tmux session for `opentmux queue`, when
+   * it changed.
+   */
+  private publishQueue(): void {
+    const report = JSON.stringify(this.getQueuedSpawns());
+    if (report === this.lastQueueReport) return;
+    this.lastQueueReport = report;
+
+    void setQueueOption(report).catch((err) =>
+      log('[tmux-session-manager] failed to publish queue', { error: String(err) }),
+    );
+  }
+
+  /**
+   * Queue wait and spawn duration percentiles over recent spawns.
+   */
+  getSpawnStats(): SpawnQueueStats {
+    return this.spawnQueue.getStats();
+  }
+
+  private recordSpawnMetrics(result: SpawnResult, attempts: number): void {
+    if (!this.metrics) return;
+    if (result.success && result.paneId) {
+      this.metrics.increment('spawn.success');
+    } else {
+      this.metrics.increment('spawn.failure');
+      this.metrics.increment(`spawn.failure.${result.reason ?? 'tmux_error'}`);
+      if (result.reason === 'overflow') this.metrics.increment('queue.overflow');
+    }
+    if (attempts > 0) this.metrics.increment('spawn.attempts', attempts);
+    if (attempts > 1) this.metrics.increment('spawn.retries', attempts - 1);
+    const timing = result.timing;
+    if (timing) {
+      this.metrics.timing('queue.wait', timing.queueWaitMs);
+      this.metrics.timing('spawn.duration', timing.totalMs);
+    }
+    void this.metrics.flush();
+  }
+
+  /**
+   * Preserves what a pane showed before it is killed: its scrollback and,
+   * with export_on_close, the session transcript.
+   */
+  private async beforeClose(sessionId: string, paneId: string, title: string): Promise<void> {
+    await Promise.all([
+      this.captureOutput(sessionId, paneId),
+      this.exportTranscript(sessionId, title),
+    ]);
+  }
+
+  private async exportTranscript(sessionId: string, title: string): Promise<void> {
+    if (!this.tmuxConfig.export_on_close) return;
+
+    const savedTo = await exportTranscript(
+      this.serverUrl,
+      this.directory,
+      sessionId,
+      title,
+      TRANSCRIPT_EXPORT_TIMEOUT_MS,
     );
-    log('[tmux-session-manager] polling started');
+    if (savedTo) {
+      log('[tmux-session-manager] exported transcript', { sessionId, savedTo });
+    } else {
+      log('[tmux-session-manager] transcript export failed', { sessionId });
+    }
+  }
+
+  /**
+   * Saves a pane's scrollback before it is killed, when capture_output_on_close is on.
+   */
+  private async captureOutput(sessionId: string, paneId: string): Promise<void> {
+    if (!this.tmuxConfig.capture_output_on_close) return;
+
+    const output = await this.panes.captureOutput(paneId);
+    if (output === null) return;
+
+    const savedTo = savePaneOutput(sessionId, output, this.tmuxConfig.pane_output_dir);
+    log('[tmux-session-manager] captured pane output', { sessionId, paneId, savedTo });
+  }
+
+  private recordClosed(tracked: TrackedSession, reason: string, note?: string): void {
+    this.metrics?.increment(`pane.closed.${reason}`);
+    this.recordHistory({
+      sessionId: tracked.sessionId,
+      parentId: tracked.parentId,
+      title: tracked.title,
+      paneId: tracked.paneId,
+      spawnedAt: tracked.createdAt,
+      closedAt: this.clock.now(),
+      reason,
+      attempts: tracked.attempts,
+      note,
+    });
+  }
+
+  private recordHistory(entry: SessionHistoryEntry): void {
+    if (!this.tmuxConfig.session_history) return;
+    appendSessionHistory(entry);
+  }
+
+  private async paintPane(paneId: string, status: PaneStatus): Promise<void> {
+    if (!this.tmuxConfig.pane_status_colors) return;
+    await this.panes.setStatusStyle(paneId, status).catch((err) =>
+      log('[tmux-session-manager] failed to style pane', { paneId, error: String(err) }),
+    );
+  }
+
+  private async closeDescendants(parentId: string): Promise<void> {
+    const children = Array.from(this.sessions.values()).filter(
+      (tracked) => tracked.parentId === parentId,
+    );
+
+    for (const child of children) {
+      log('[tmux-session-manager] parent ended, closing child pane', {
+        parentId,
+        sessionId: child.sessionId,
+      });
+      this.rememberEndedParent(child.sessionId);
+      await this.closeDescendants(child.sessionId);
+      await this.closeSession(child.sessionId, 'parent_deleted');
+    }
+  }
+
+  /**
+   * Forgets held sessions that were deleted, or whose parent was, before
+   * spawning resumed.
+   */
+  private dropHeldSessions(sessionId: string): void {
+    for (const [heldId, { event }] of this.heldSessions) {
+      if (heldId === sessionId || event.properties?.info?.parentID === sessionId) {
+        this.heldSessions.delete(heldId);
+      }
+    }
+  }
+
+  private recordFlushedHold(sessionId: string, event: SessionCreatedEvent, heldAt: number): void {
+    log('[tmux-session-manager] dropping flushed held session', { sessionId });
+    this.recordHistory({
+      sessionId,
+      parentId: event.properties?.info?.parentID ?? '',
+      title: event.properties?.info?.title ?? 'Subagent',
+      paneId: null,
+      spawnedAt: heldAt,
+      closedAt: this.clock.now(),
+      reason: 'flushed',
+      attempts: 0,
+    });
+  }
+
+  /**
+   * Why new panes are held instead of opened right now, if they are:
+   * `opentmux pause`, one of the configured quiet_hours, or a full spawn queue
+   * with queue_overflow_policy headless-track.
+   */
+  private async spawnHoldReason(): Promise<'paused' | 'quiet_hours' | 'queue_full' | null> {
+    if (isQuietTime(this.tmuxConfig.quiet_hours ?? [], new Date(this.clock.now()))) {
+      return 'quiet_hours';
+    }
+    if (this.tmuxConfig.queue_overflow_policy === 'headless-track' && this.spawnQueue.isFull()) {
+      return 'queue_full';
+    }
+    return (await this.isPaused()) ? 'paused' : null;
+  }
+
+  /**
+   * Whether `opentmux pause` is on, read from tmux at most once per fast poll
+   * interval so a burst of session.created events shares one read.
+   */
+  private isPaused(): Promise<boolean> {
+    const now = this.clock.now();
+    const maxAgeMs = this.tmuxConfig.poll_interval_min_ms ?? 500;
+    if (!this.pausedRead || now - this.pausedRead.readAt >= maxAgeMs) {
+      this.pausedRead = { paused: isSpawningPaused(), readAt: now };
+    }
+    return this.pausedRead.paused;
+  }
+
+  /**
+   * Checks for `opentmux resume`, the end of quiet hours or room in the spawn
+   * queue at the fast poll interval while sessions are held, and spawns them
+   * once nothing holds them.
+   */
+  private schedulePauseCheck(): void {
+    if (this.pauseCheckTimer || this.shuttingDown) return;
+
+    this.pauseCheckTimer = this.clock.setTimeout(async () => {
+      this.pauseCheckTimer = undefined;
+      if (this.shuttingDown || this.heldSessions.size === 0) return;
+
+      if (await this.spawnHoldReason()) {
+        this.schedulePauseCheck();
+        return;
+      }
+
+      const flushedAt = await getQueueFlushedAt();
+      const held: SessionCreatedEvent[] = [];
+      for (const [sessionId, { event, heldAt }] of this.heldSessions) {
+        if (flushedAt === null || heldAt > flushedAt) {
+          held.push(event);
+        } else {
+          this.recordFlushedHold(sessionId, event, heldAt);
+        }
+      }
+      this.heldSessions.clear();
+      this.publishStatusLine();
+      log('[tmux-session-manager] spawning resumed, releasing held sessions', {
+        count: held.length,
+      });
+      await Promise.all(held.map((event) => this.onSessionCreated(event)));
+    }, this.tmuxConfig.poll_interval_min_ms ?? 500);
+  }
+
+  private rememberEndedParent(sessionId: string): void {
+    this.endedParents.add(sessionId);
+    this.parentTitles.delete(sessionId);
+    if (this.endedParents.size > MAX_ENDED_PARENTS) {
+      const oldest = this.endedParents.values().next().value;
+      if (oldest !== undefined) {
+        this.endedParents.delete(oldest);
+      }
+    }
+  }
+
+  /**
+   * Routes a plugin event to the matching handler.
+   */
+  async handleEvent(event: { type: string; properties?: unknown }): Promise<void> {
+    if (this.shuttingDown) return;
+    switch (event.type) {
+      case 'session.created':
+        await this.onSessionCreated(event as SessionCreatedEvent);
+        break;
+      case 'session.deleted':
+        await this.onSessionDeleted(event as SessionDeletedEvent);
+        break;
+      case 'session.updated':
+        await this.onSessionUpdated(event as SessionUpdatedEvent);
+        break;
+      case 'session.idle':
+      case 'session.error':
+        await this.onSessionStatusEvent(event as SessionStatusEvent);
+        break;
+    }
+  }
+
+  /**
+   * Starts polling, or reschedules the pending poll so a new spawn switches
+   * to the fast interval right away.
+   */
+  private startPolling(): void {
+    const wasActive = this.pollActive;
+    this.pollActive = true;
+
+    // A poll in progress schedules the next one when it finishes
+    if (!this.pollInFlight) {
+      this.scheduleNextPoll();
+    }
+
+    if (!wasActive) {
+      log('[tmux-session-manager] polling started');
+    }
   }
 
   private stopPolling(): void {
-    if (this.pollInterval) {
-      clearInterval(this.pollInterval);
-      this.pollInterval = undefined;
-      log('[tmux-session-manager] polling stopped');
+    if (!this.pollActive) return;
+
+    this.pollActive = false;
+    if (this.pollTimer) {
+      this.clock.clearTimeout(this.pollTimer);
+      this.pollTimer = undefined;
+    }
+    log('[tmux-session-manager] polling stopped');
+  }
+
+  private scheduleNextPoll(): void {
+    if (this.pollTimer) {
+      this.clock.clearTimeout(this.pollTimer);
+    }
+
+    const intervalMs = computePollInterval(
+      { now: this.clock.now(), lastSpawnAt: this.lastSpawnAt, allBusySince: this.allBusySince },
+      {
+        minMs: this.tmuxConfig.poll_interval_min_ms ?? 500,
+        maxMs: this.tmuxConfig.poll_interval_max_ms ?? 10_000,
+      },
+    );
+    this.pollTimer = this.clock.setTimeout(() => void this.runScheduledPoll(), intervalMs);
+  }
+
+  /**
+   * Brings the next poll forward to the fast interval, if polling is active.
+   */
+  private pollSoon(): void {
+    if (!this.pollActive || this.pollInFlight) return;
+
+    if (this.pollTimer) {
+      this.clock.clearTimeout(this.pollTimer);
+    }
+    this.pollTimer = this.clock.setTimeout(
+      () => void this.runScheduledPoll(),
+      this.tmuxConfig.poll_interval_min_ms ?? 500,
+    );
+  }
+
+  private async runScheduledPoll(): Promise<void> {
+    this.pollTimer = undefined;
+    this.pollInFlight = true;
+    try {
+      await this.pollSessions();
+    } finally {
+      this.pollInFlight = false;
+    }
+
+    if (this.pollActive) {
+      this.scheduleNextPoll();
     }
   }
 
   private scheduleDebouncedLayout(): void {
     if (this.layoutDebounceTimer) {
-      clearTimeout(this.layoutDebounceTimer);
+      this.clock.clearTimeout(this.layoutDebounceTimer);
     }
 
     const debounceMs = this.tmuxConfig.layout_debounce_ms ?? 150;
-    this.layoutDebounceTimer = setTimeout(() => {
+    this.layoutDebounceTimer = this.clock.setTimeout(() => {
       log('[tmux-session-manager] applying deferred layout after queue drain');
-      void applyTmuxLayout();
+      void this.panes.applyLayout();
     }, debounceMs);
   }
 
@@ -175,11 +1066,16 @@ export class TmuxSessionManager {
     }
 
     try {
-      const statusResult = await this.client.session.status();
-      const allStatuses = (statusResult.data ?? {}) as Record<
-        string,
-        { type: string }
-      >;
+      await this.dropClosedPanes();
+      await this.applyCloseAllRequest();
+      if (this.sessions.size === 0) {
+        this.stopPolling();
+        return;
+      }
+      // Without control-mode notifications a resize is only seen by polling
+      if (!hasTmuxControlClient()) await this.panes.refreshLayout();
+
+      const allStatuses = await this.statusCache.get();
       
       const statusCount = Object.keys(allStatuses).length;
       log('[tmux-session-manager] poll status', { 
@@ -187,9 +1083,14 @@ export class TmuxSessionManager {
         trackedSessions: this.sessions.size 
       });
 
-      const now = Date.now();
+      const now = this.clock.now();
       const sessionsToClose: { id: string; reason: string }[] = [];
 
+      const allBusy = Array.from(this.sessions.keys()).every(
+        (sessionId) => allStatuses[sessionId]?.type === 'busy',
+      );
+      this.allBusySince = allBusy ? (this.allBusySince ?? now) : null;
+
       for (const [sessionId, tracked] of this.sessions.entries()) {
         const status = allStatuses[sessionId];
 
@@ -198,6 +1099,9 @@ export class TmuxSessionManager {
         if (status) {
           tracked.lastSeenAt = now;
           tracked.missingSince = undefined;
+          if (status.type === 'busy') {
+            await this.updateStatus(tracked, 'busy');
+          }
         } else if (!tracked.missingSince) {
           tracked.missingSince = now;
         }
@@ -220,16 +1124,163 @@ export class TmuxSessionManager {
       for (const item of sessionsToClose) {
         await this.closeSession(item.id, item.reason);
       }
+
+      if (
+        this.tmuxConfig.reaper_enabled &&
+        now - this.lastOrphanSweepAt >= this.tmuxConfig.reaper_interval_ms
+      ) {
+        this.lastOrphanSweepAt = now;
+        await this.sweepOrphanedPanes(allStatuses);
+      }
     } catch (err) {
       log('[tmux-session-manager] poll error', { error: String(err) });
 
-      const serverAlive = await this.isServerAlive();
+      const serverAlive = await this.statuses.isServerAlive();
       if (!serverAlive) {
         await this.handleShutdown('server-unreachable');
       }
     }
   }
 
+  /**
+   * Stops tracking panes that were closed outside opentmux (e.g. `prefix + x`),
+   * so they are never killed or counted again.
+   */
+  async dropClosedPanes(): Promise<number> {
+    const livePanes = await this.panes.listPaneIds();
+    if (!livePanes) return 0;
+
+    let dropped = 0;
+    for (const [sessionId, tracked] of this.sessions.entries()) {
+      if (tracked.closing || livePanes.has(tracked.paneId)) continue;
+
+      log('[tmux-session-manager] pane closed externally', {
+        sessionId,
+        paneId: tracked.paneId,
+      });
+      this.recordClosed(tracked, 'pane_closed');
+      this.sessions.delete(sessionId);
+      dropped++;
+
+      if (this.tmuxConfig.abort_session_on_pane_close) {
+        await this.abortSession(sessionId);
+      }
+    }
+
+    if (dropped > 0) {
+      this.publishStatusLine();
+      this.scheduleDebouncedLayout();
+    }
+    return dropped;
+  }
+
+  /**
+   * Closes every tracked pane once `opentmux session close-all` asks for it,
+   * aborting their sessions too if the request says so. Requests made before
+   * this manager started are ignored.
+   */
+  private async applyCloseAllRequest(): Promise<void> {
+    const request = await getCloseAllRequest();
+    if (!request || request.at <= this.closeAllHandledAt) return;
+    this.closeAllHandledAt = request.at;
+    if (request.serverUrls && !request.serverUrls.some((url) => isSameServer(url, this.serverUrl))) {
+      return;
+    }
+
+    log('[tmux-session-manager] closing all panes on request', {
+      count: this.sessions.size,
+      reason: request.reason,
+      abort: request.abort,
+    });
+    // Spawns still waiting would open panes right after; drop them as flushed
+    this.spawnQueue.flush();
+    for (const [sessionId, { event, heldAt }] of this.heldSessions) {
+      this.recordFlushedHold(sessionId, event, heldAt);
+    }
+    this.heldSessions.clear();
+
+    for (const sessionId of [...this.sessions.keys()]) {
+      await this.closeSession(sessionId, 'close_all', request.reason);
+      if (request.abort) {
+        await this.abortSession(sessionId);
+      }
+    }
+    this.publishStatusLine();
+    this.scheduleDebouncedLayout();
+  }
+
+  /**
+   * Asks the server to stop a session's agent, so it doesn't keep working
+   * with nobody watching.
+   */
+  private async abortSession(sessionId: string): Promise<void> {
+    const ok = await this.statuses.abortSession(sessionId);
+    log('[tmux-session-manager] aborted session after its pane was closed', { sessionId, ok });
+  }
+
+  /**
+   * Closes agent panes for this server whose attach process has exited, or
+   * whose session no longer exists and isn't tracked (e.g. left behind by a
+   * crashed plugin instance). Panes of other servers are left alone.
+   */
+  async sweepOrphanedPanes(statuses?: Record<string, unknown>): Promise<number> {
+    const panes = await this.panes.listAgentPanes();
+    const ours = panes.filter((pane) => pane.serverUrl === this.serverUrl);
+    if (ours.length === 0) return 0;
+
+    // A failed ps would make every pane look detached, so don't sweep blind
+    const processes = await this.listPaneProcesses();
+    if (processes === null) {
+      log('[tmux-session-manager] skipping pane sweep, could not list processes');
+      return 0;
+    }
+
+    const activeSessions = statuses ?? (await this.statusCache.get());
+
+    const trackedPanes = new Map(
+      Array.from(this.sessions.values()).map((s) => [s.paneId, s.sessionId]),
+    );
+
+    let swept = 0;
+    for (const pane of ours) {
+      if (this.pendingSessions.has(pane.sessionId)) continue;
+
+      const attachGone = !this.isPaneAttached(pane, processes);
+      const sessionGone = !trackedPanes.has(pane.paneId) && !(pane.sessionId in activeSessions);
+      if (!attachGone && !sessionGone) continue;
+
+      const reason = attachGone ? 'attach_exited' : 'session_gone';
+      if (this.tmuxConfig.reaper_dry_run) {
+        log('[tmux-session-manager] dry run: would close orphaned pane', { ...pane, reason });
+        await this.paintPane(pane.paneId, 'orphaned');
+        continue;
+      }
+
+      log('[tmux-session-manager] closing orphaned pane', { ...pane, reason });
+      await this.beforeClose(
+        pane.sessionId,
+        pane.paneId,
+        this.sessions.get(pane.sessionId)?.title ?? pane.sessionId,
+      );
+      await this.closePane(pane.paneId);
+      const tracked = this.sessions.get(pane.sessionId);
+      if (tracked) {
+        this.recordClosed(tracked, reason);
+        this.sessions.delete(pane.sessionId);
+      }
+      swept++;
+    }
+
+    if (swept > 0) {
+      this.publishStatusLine();
+    }
+
+    if (swept > 0 && this.sessions.size === 0) {
+      this.stopPolling();
+    }
+    return swept;
+  }
+
   private registerShutdownHandlers(): void {
     const handler = (reason: string) => {
       void this.handleShutdown(reason);
@@ -249,24 +1300,7 @@ export class TmuxSessionManager {
     await this.cleanup();
   }
 
-  private async isServerAlive(): Promise<boolean> {
-    const healthUrl = new URL('/health', this.serverUrl).toString();
-    const controller = new AbortController();
-    const timeout = setTimeout(() => controller.abort(), 1500);
-
-    try {
-      const response = await fetch(healthUrl, { signal: controller.signal }).catch(
-        () => null,
-      );
-      return response?.ok ?? false;
-    } catch {
-      return false;
-    } finally {
-      clearTimeout(timeout);
-    }
-  }
-
-  private async closeSession(sessionId: string, reason: string): Promise<void> {
+  private async closeSession(sessionId: string, reason: string, note?: string): Promise<void> {
     const tracked = this.sessions.get(sessionId);
     if (!tracked) return;
 
@@ -276,13 +1310,17 @@ export class TmuxSessionManager {
       reason,
     });
 
-    await closeTmuxPane(tracked.paneId);
+    tracked.closing = true;
+    await this.beforeClose(sessionId, tracked.paneId, tracked.title);
+    await this.closePane(tracked.paneId);
+    this.recordClosed(tracked, reason, note);
     this.sessions.delete(sessionId);
     
     log('[tmux-session-manager] session closed', { 
       sessionId,
       remainingSessions: this.sessions.size 
     });
+    this.publishStatusLine();
 
     if (this.sessions.size === 0) {
       this.stopPolling();
@@ -293,32 +1331,90 @@ export class TmuxSessionManager {
     event: { type: string; properties?: unknown };
   }) => Promise<void> {
     return async (input) => {
-      await this.onSessionCreated(input.event as SessionCreatedEvent);
+      await this.handleEvent(input.event);
     };
   }
 
-  async cleanup(): Promise<void> {
-    this.stopPolling();
-    this.spawnQueue.shutdown();
+  /**
+   * Stops this manager without closing its panes and returns what it
+   * tracks, for a replacement created with new config to adopt().
+   */
+  async handOver(): Promise<SessionHandover> {
+    this.handingOver = true;
+    // Captured before stop() settles them, so the successor can enqueue them again
+    const queued = this.spawnQueue
+      .list()
+      .filter((entry) => !entry.inFlight)
+      .map((entry) => this.queuedEvents.get(entry.sessionId))
+      .filter((event): event is SessionCreatedEvent => event !== undefined);
+    await this.stop({ finalReap: false });
+    const handover: SessionHandover = {
+      sessions: [...this.sessions.values()],
+      held: [...this.heldSessions.values()],
+      queued,
+    };
+    this.sessions.clear();
+    this.heldSessions.clear();
+    await this.closeMetrics();
+    this.unsubscribeNotifications?.();
 
-    if (this.layoutDebounceTimer) {
-      clearTimeout(this.layoutDebounceTimer);
-      this.layoutDebounceTimer = undefined;
+    log('[tmux-session-manager] handed over', {
+      sessions: handover.sessions.length,
+      held: handover.held.length,
+      queued: handover.queued.length,
+    });
+    return handover;
+  }
+
+  /**
+   * Takes over the panes and held sessions of the manager this one replaces,
+   * and enqueues the spawns it had queued.
+   */
+  adopt(handover: SessionHandover): void {
+    if (!this.enabled || this.shuttingDown) return;
+
+    for (const tracked of handover.sessions) {
+      this.sessions.set(tracked.sessionId, { ...tracked, closing: undefined });
     }
-    
-    // Shutdown reaper (runs final scan)
-    if (this.reaper) {
-      await this.reaper.shutdown().catch(err => 
-        log('[tmux-session-manager] reaper shutdown error', { error: String(err) })
+    for (const held of handover.held) {
+      const sessionId = held.event.properties?.info?.id;
+      if (sessionId && !this.sessions.has(sessionId)) this.heldSessions.set(sessionId, held);
+    }
+
+    log('[tmux-session-manager] adopted sessions', {
+      sessions: handover.sessions.length,
+      held: this.heldSessions.size,
+      queued: handover.queued.length,
+    });
+    if (this.sessions.size > 0) this.startPolling();
+    if (this.heldSessions.size > 0) this.schedulePauseCheck();
+    this.publishStatusLine();
+
+    for (const event of handover.queued) {
+      void this.onSessionCreated(event).catch((err) =>
+        log('[tmux-session-manager] failed to enqueue handed-over spawn', { error: String(err) }),
       );
     }
+  }
+
+  async cleanup(): Promise<void> {
+    await this.stop();
+    this.heldSessions.clear();
 
     if (this.sessions.size > 0) {
       log('[tmux-session-manager] closing all panes', {
         count: this.sessions.size,
       });
+      await Promise.all(
+        Array.from(this.sessions.values(), (tracked) =>
+          this.beforeClose(tracked.sessionId, tracked.paneId, tracked.title),
+        ),
+      );
+      for (const tracked of this.sessions.values()) {
+        this.recordClosed(tracked, 'shutdown');
+      }
       const closePromises = Array.from(this.sessions.values()).map((s) =>
-        closeTmuxPane(s.paneId).catch((err) =>
+        this.closePane(s.paneId).catch((err) =>
           log('[tmux-session-manager] cleanup error for pane', {
             paneId: s.paneId,
             error: String(err),
@@ -327,8 +1423,60 @@ export class TmuxSessionManager {
       );
       await Promise.all(closePromises);
       this.sessions.clear();
+      this.publishStatusLine();
     }
 
-    log('[tmux-session-manager] cleanup complete');
+    if (this.tmuxConfig.layout_mode === 'grid') {
+      await this.panes.removePlaceholders().catch((err) =>
+        log('[tmux-session-manager] failed to remove grid placeholders', { error: String(err) }),
+      );
+    }
+
+    await this.closeMetrics();
+    this.unsubscribeNotifications?.();
+    closeTmuxControlClient();
+
+    log('[tmux-session-manager] cleanup complete', { spawnStats: this.spawnQueue.getStats() });
+  }
+
+  /**
+   * Stops polling, spawning and the reaper; shared by cleanup() and
+   * handOver(). A handover skips the reaper's final scan, since the
+   * successor takes over the panes it would judge.
+   */
+  private async stop(options: { finalReap?: boolean } = {}): Promise<void> {
+    this.shuttingDown = true;
+    this.stopPolling();
+    this.spawnQueue.shutdown();
+
+    // Let in-flight spawns finish so the panes they create are closed or handed over.
+    const drained = await this.spawnQueue.drain(SHUTDOWN_DRAIN_TIMEOUT_MS);
+    if (!drained) {
+      log('[tmux-session-manager] in-flight spawn still running after drain timeout');
+    }
+
+    if (this.layoutDebounceTimer) {
+      this.clock.clearTimeout(this.layoutDebounceTimer);
+      this.layoutDebounceTimer = undefined;
+    }
+
+    if (this.pauseCheckTimer) {
+      this.clock.clearTimeout(this.pauseCheckTimer);
+      this.pauseCheckTimer = undefined;
+    }
+    
+    // Shutdown reaper (runs final scan unless handing over)
+    if (this.reaper) {
+      await this.reaper.shutdown({ finalScan: options.finalReap ?? true }).catch(err => 
+        log('[tmux-session-manager] reaper shutdown error', { error: String(err) })
+      );
+    }
+  }
+
+  private async closeMetrics(): Promise<void> {
+    if (this.metrics) {
+      await this.metrics.flush();
+      this.metrics.close();
+    }
   }
 }
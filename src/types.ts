@@ -3,7 +3,8 @@ export interface PluginInput {
   serverUrl?: URL | string;
   client: {
     session: {
-      status(): Promise<{ data?: Record<string, { type: string }> }>;
+      /** signal aborts the request, e.g. once it has timed out */
+      status(options?: { signal?: AbortSignal }): Promise<{ data?: Record<string, { type: string }> }>;
       subscribe(callback: (event: { type: string; properties?: unknown }) => void): () => void;
     };
   };
@@ -17,3 +18,19 @@ export interface PluginOutput {
 }
 
 export type Plugin = (ctx: PluginInput) => Promise<PluginOutput>;
+
+/**
+ * Why a spawn produced no pane. The first four come from the spawn function
+ * before it touches tmux; the rest from tmux itself or from the queue.
+ */
+export type SpawnFailureReason =
+  | 'disabled'
+  | 'not_in_tmux'
+  | 'server_unreachable'
+  | 'tmux_missing'
+  | 'tmux_error'
+  | 'aborted'
+  | 'stale'
+  | 'flushed'
+  | 'overflow'
+  | 'shutdown';
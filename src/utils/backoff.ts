@@ -0,0 +1,30 @@
+export interface BackoffOptions {
+  /** Delay before the first retry */
+  baseMs: number;
+  /** Upper bound for any single delay */
+  maxMs: number;
+  /** Fraction (0-1) of each delay that is randomized */
+  jitter: number;
+}
+
+export const DEFAULT_BACKOFF: BackoffOptions = {
+  baseMs: 250,
+  maxMs: 5000,
+  jitter: 0.2,
+};
+
+/**
+ * Computes the delay before retry number `attempt` (1-based): exponential
+ * growth from baseMs, capped at maxMs, then reduced by up to `jitter` of the
+ * delay so concurrent failures don't retry in lockstep.
+ */
+export function computeBackoffMs(
+  attempt: number,
+  options: BackoffOptions = DEFAULT_BACKOFF,
+  random: () => number = Math.random,
+): number {
+  const exponential = options.baseMs * Math.pow(2, Math.max(0, attempt - 1));
+  const capped = Math.min(options.maxMs, exponential);
+  const jitter = Math.min(1, Math.max(0, options.jitter));
+  return Math.round(capped * (1 - jitter * random()));
+}
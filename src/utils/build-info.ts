@@ -0,0 +1,83 @@
+import { compareVersions, getInstalledVersion } from './upgrade';
+
+/*
+ * Replaced at build time by tsup's `define` (see tsup.config.ts). Unbuilt
+ * sources (tests, `bun src/...`) leave them undefined.
+ */
+declare const __OPENTMUX_VERSION__: string | undefined;
+declare const __OPENTMUX_COMMIT__: string | undefined;
+declare const __OPENTMUX_BUILD_DATE__: string | undefined;
+
+export interface BuildInfo {
+  version: string;
+  commit: string;
+  buildDate: string;
+}
+
+/**
+ * Version, commit and build date of this copy of opentmux. Falls back to the
+ * nearest package.json version when running from source.
+ */
+export function getBuildInfo(fromDir?: string): BuildInfo {
+  const builtVersion = typeof __OPENTMUX_VERSION__ === 'string' ? __OPENTMUX_VERSION__ : null;
+  return {
+    version: builtVersion ?? (fromDir ? getInstalledVersion(fromDir) : null) ?? 'dev',
+    commit: typeof __OPENTMUX_COMMIT__ === 'string' ? __OPENTMUX_COMMIT__ : 'unknown',
+    buildDate: typeof __OPENTMUX_BUILD_DATE__ === 'string' ? __OPENTMUX_BUILD_DATE__ : 'unknown',
+  };
+}
+
+export function formatBuildInfo(info: BuildInfo): string {
+  return `${info.version} (commit ${info.commit}, built ${info.buildDate})`;
+}
+
+/**
+ * Parses build info published by the plugin. Returns null for anything else.
+ */
+export function parseBuildInfo(text: string): BuildInfo | null {
+  try {
+    const parsed = JSON.parse(text) as Partial<BuildInfo>;
+    if (typeof parsed.version !== 'string') return null;
+    return {
+      version: parsed.version,
+      commit: typeof parsed.commit === 'string' ? parsed.commit : 'unknown',
+      buildDate: typeof parsed.buildDate === 'string' ? parsed.buildDate : 'unknown',
+    };
+  } catch {
+    return null;
+  }
+}
+
+/**
+ * Whether two copies of opentmux differ in version. Commits are only
+ * compared when both are known, so source checkouts don't warn on every run.
+ */
+export function isVersionMismatch(a: BuildInfo, b: BuildInfo): boolean {
+  if (compareVersions(a.version, b.version) !== 0) return true;
+  return a.commit !== 'unknown' && b.commit !== 'unknown' && a.commit !== b.commit;
+}
+
+/**
+ * Oldest opencode release believed to accept `opencode attach --session`.
+ * Not yet pinned to the release that added the flag, so older versions only
+ * get a warning.
+ */
+export const MIN_OPENCODE_VERSION = '0.15.0';
+
+/**
+ * Pulls the version out of `opencode --version` output, e.g. "0.15.3" or
+ * "opencode v1.0.2". Returns null when there is none.
+ */
+export function parseOpencodeVersion(output: string): string | null {
+  return /\bv?(\d+\.\d+\.\d+(?:-[\w.]+)?)/.exec(output)?.[1] ?? null;
+}
+
+/**
+ * Whether `opencode --version` output names a release that can run agent
+ * panes. Output without a recognizable version is given the benefit of the
+ * doubt, so custom builds keep working.
+ */
+export function isSupportedOpencodeVersion(output: string): boolean {
+  const version = parseOpencodeVersion(output);
+  return version === null || compareVersions(version, MIN_OPENCODE_VERSION) >= 0;
+}
@@ -0,0 +1,163 @@
+/**
+ * A small command table for the opentmux launcher: argument parsing, per-command
+ * help, and matching. Anything that doesn't match a command is passed through to
+ * opencode, so unknown top-level arguments are never errors here.
+ */
+
+export interface FlagSpec {
+  name: string;
+  /** Placeholder for the flag's value, e.g. "<id>"; boolean flags have none */
+  value?: string;
+  description: string;
+  required?: boolean;
+}
+
+export interface ParsedArgs {
+  flags: Record<string, string | true>;
+  positionals: string[];
+}
+
+export interface CommandSpec {
+  /** Words that select the command, e.g. ["session", "focus"] */
+  path: string[];
+  /** Positional usage, e.g. "<1-9|next|prev> [window]" */
+  args?: string;
+  /** Completion candidates for the first positional */
+  choices?: string[];
+  summary: string;
+  flags?: FlagSpec[];
+  /** Keep flags this command doesn't know as positionals (for pass-through) */
+  allowUnknownFlags?: boolean;
+  /** Return false to hand the invocation to opencode instead */
+  accepts?: (rest: string[]) => boolean;
+  run: (parsed: ParsedArgs) => number | Promise<number>;
+}
+
+export type ParseResult = { ok: true; parsed: ParsedArgs } | { ok: false; error: string };
+
+/**
+ * Finds the command whose path prefixes the arguments (longest path wins).
+ * Returns the command and the arguments after its path.
+ */
+export function matchCommand(
+  commands: CommandSpec[],
+  args: string[],
+): { command: CommandSpec; rest: string[] } | null {
+  let best: { command: CommandSpec; rest: string[] } | null = null;
+
+  for (const command of commands) {
+    const matches = command.path.every((word, index) => args[index] === word);
+    if (!matches) continue;
+
+    const rest = args.slice(command.path.length);
+    if (command.accepts && !command.accepts(rest)) continue;
+    if (!best || command.path.length > best.command.path.length) {
+      best = { command, rest };
+    }
+  }
+
+  return best;
+}
+
+/**
+ * Parses a command's flags and positionals. Supports `--flag value` and `--flag=value`.
+ */
+export function parseArgs(command: CommandSpec, rest: string[]): ParseResult {
+  const parsed: ParsedArgs = { flags: {}, positionals: [] };
+  const known = new Map((command.flags ?? []).map((flag) => [flag.name, flag]));
+
+  for (let i = 0; i < rest.length; i++) {
+    const arg = rest[i];
+    if (!arg.startsWith('--')) {
+      parsed.positionals.push(arg);
+      continue;
+    }
+
+    const [name, inlineValue] = arg.includes('=') ? arg.split(/=(.*)/s, 2) : [arg, undefined];
+    const flag = known.get(name);
+    if (!flag) {
+      if (command.allowUnknownFlags) {
+        parsed.positionals.push(arg);
+        continue;
+      }
+      return { ok: false, error: `unknown flag ${name}` };
+    }
+
+    if (!flag.value) {
+      parsed.flags[name] = true;
+      continue;
+    }
+
+    const value = inlineValue ?? rest[++i];
+    if (value === undefined || (inlineValue === undefined && value.startsWith('--'))) {
+      return { ok: false, error: `${name} expects a value ${flag.value}` };
+    }
+    parsed.flags[name] = value;
+  }
+
+  for (const flag of command.flags ?? []) {
+    if (flag.required && parsed.flags[flag.name] === undefined) {
+      return { ok: false, error: `missing required flag ${flag.name} ${flag.value ?? ''}`.trim() };
+    }
+  }
+
+  return { ok: true, parsed };
+}
+
+/** String value of a flag, or undefined for missing/boolean flags */
+export function flagString(parsed: ParsedArgs, name: string): string | undefined {
+  const value = parsed.flags[name];
+  return typeof value === 'string' ? value : undefined;
+}
+
+export function formatUsage(bin: string, command: CommandSpec): string {
+  const flags = (command.flags ?? []).map((flag) => {
+    const text = flag.value ? `${flag.name} ${flag.value}` : flag.name;
+    return flag.required ? text : `[${text}]`;
+  });
+  return [bin, ...command.path, command.args, ...flags].filter(Boolean).join(' ');
+}
+
+function formatRows(rows: Array<[string, string]>): string[] {
+  const width = Math.max(...rows.map(([left]) => left.length));
+  return rows.map(([left, right]) => `  ${left.padEnd(width)}  ${right}`);
+}
+
+export function formatCommandHelp(bin: string, command: CommandSpec): string {
+  const lines = [`Usage: ${formatUsage(bin, command)}`, '', command.summary];
+  if (command.flags && command.flags.length > 0) {
+    lines.push(
+      '',
+      'Flags:',
+      ...formatRows(
+        command.flags.map((flag) => [
+          flag.value ? `${flag.name} ${flag.value}` : flag.name,
+          flag.description,
+        ]),
+      ),
+    );
+  }
+  return `${lines.join('\n')}\n`;
+}
+
+export function formatHelp(bin: string, commands: CommandSpec[], globalFlags: FlagSpec[]): string {
+  const lines = [
+    'Usage:',
+    `  ${bin} [opencode arguments...]   Start opencode inside tmux with agent panes`,
+    `  ${bin} <command> [flags]`,
+    '',
+    'Commands:',
+    ...formatRows(
+      commands.map((command) => [[...command.path, command.args].filter(Boolean).join(' '), command.summary]),
+    ),
+    '',
+    'Global flags:',
+    ...formatRows(
+      globalFlags.map((flag) => [flag.value ? `${flag.name} ${flag.value}` : flag.name, flag.description]),
+    ),
+    '',
+    `Run \`${bin} help <command>\` for a command's flags. Anything else is passed to opencode;`,
+    'run `opencode --help` for its commands and flags.',
+  ];
+  return `${lines.join('\n')}\n`;
+}
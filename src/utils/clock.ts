@@ -0,0 +1,39 @@
+/** Opaque handle returned by Clock.setTimeout and Clock.setInterval */
+export type ClockTimer = unknown;
+
+/**
+ * Time source for the manager, spawn queue and reaper. Production code uses
+ * systemClock; tests inject a fake to step through timeouts, grace periods
+ * and debounces without sleeping.
+ */
+export interface Clock {
+  now(): number;
+  setTimeout(callback: () => void, ms: number): ClockTimer;
+  clearTimeout(timer: ClockTimer | undefined): void;
+  setInterval(callback: () => void, ms: number): ClockTimer;
+  clearInterval(timer: ClockTimer | undefined): void;
+}
+
+export const systemClock: Clock = {
+  now: () => Date.now(),
+  setTimeout: (callback, ms) => setTimeout(callback, ms),
+  clearTimeout: (timer) => clearTimeout(timer as ReturnType<typeof setTimeout> | undefined),
+  setInterval: (callback, ms) => setInterval(callback, ms),
+  clearInterval: (timer) => clearInterval(timer as ReturnType<typeof setInterval> | undefined),
+};
+
+/**
+ * Resolves after ms on the given clock, or as soon as signal aborts. Never
+ * rejects, so callers check signal.aborted afterwards.
+ */
+export function sleep(ms: number, signal?: AbortSignal, clock: Clock = systemClock): Promise<void> {
+  return new Promise((resolve) => {
+    const timer = clock.setTimeout(done, ms);
+    function done() {
+      clock.clearTimeout(timer);
+      signal?.removeEventListener('abort', done);
+      resolve();
+    }
+    signal?.addEventListener('abort', done, { once: true });
+  });
+}
@@ -0,0 +1,215 @@
+import type { CommandSpec } from './cli';
+
+/**
+ * Shell completion scripts for the opentmux launcher's own commands.
+ * Session ids are completed dynamically from the open agent panes via
+ * `opentmux completion --sessions`.
+ */
+
+export const COMPLETION_SHELLS = ['bash', 'zsh', 'fish'] as const;
+
+export type CompletionShell = (typeof COMPLETION_SHELLS)[number];
+
+export interface CompletionCommand {
+  name: string;
+  flags?: string[];
+  subcommands?: CompletionCommand[];
+}
+
+/** Flags whose value is an agent session id */
+const SESSION_FLAGS = ['--id', '--session'];
+
+function flagNames(command: CommandSpec): string[] | undefined {
+  const flags = (command.flags ?? []).map((flag) => flag.name);
+  return flags.length > 0 ? flags : undefined;
+}
+
+/**
+ * Builds the completion tree from the launcher's command table. Commands that
+ * are themselves flags (like --reap) are left out.
+ */
+export function completionTree(commands: CommandSpec[]): CompletionCommand[] {
+  const tree: CompletionCommand[] = [];
+
+  for (const command of commands) {
+    const [name, subName] = command.path;
+    if (name.startsWith('-')) continue;
+
+    let node = tree.find((candidate) => candidate.name === name);
+    if (!node) {
+      node = { name };
+      tree.push(node);
+    }
+
+    if (subName) {
+      node.subcommands = [...(node.subcommands ?? []), { name: subName, flags: flagNames(command) }];
+      continue;
+    }
+
+    node.flags = flagNames(command);
+    if (command.choices) {
+      node.subcommands = command.choices.map((choice) => ({ name: choice }));
+    }
+  }
+
+  return tree;
+}
+
+function names(commands: CompletionCommand[]): string {
+  return commands.map((command) => command.name).join(' ');
+}
+
+function bashScript(tree: CompletionCommand[], bin: string): string {
+  const cases = tree.map((command) => {
+    if (command.subcommands) {
+      const subCases = command.subcommands
+        .filter((sub) => sub.flags)
+        .map((sub) => `        ${sub.name}) words="${sub.flags!.join(' ')}" ;;`)
+        .join('\n');
+      const lines = [
+        `    ${command.name})`,
+        `      if [[ $COMP_CWORD -eq 2 ]]; then`,
+        `        words="${names(command.subcommands)}"`,
+      ];
+      if (subCases) {
+        lines.push('      else', `        case "\${COMP_WORDS[2]}" in`, subCases, '        esac');
+      }
+      lines.push('      fi', '      ;;');
+      return lines.join('\n');
+    }
+    return `    ${command.name}) words="${(command.flags ?? []).join(' ')}" ;;`;
+  }).join('\n');
+
+  return `# opentmux bash completion
+_opentmux() {
+  local cur="\${COMP_WORDS[COMP_CWORD]}"
+  local prev="\${COMP_WORDS[COMP_CWORD-1]}"
+  local words=""
+
+  case "$prev" in
+    ${SESSION_FLAGS.join('|')})
+      COMPREPLY=($(compgen -W "$(${bin} completion --sessions 2>/dev/null)" -- "$cur"))
+      return
+      ;;
+  esac
+
+  if [[ $COMP_CWORD -eq 1 ]]; then
+    words="${names(tree)}"
+  else
+    case "\${COMP_WORDS[1]}" in
+${cases}
+    esac
+  fi
+
+  COMPREPLY=($(compgen -W "$words" -- "$cur"))
+}
+complete -o default -F _opentmux ${bin}
+`;
+}
+
+function zshScript(tree: CompletionCommand[], bin: string): string {
+  const cases = tree.map((command) => {
+    if (command.subcommands) {
+      const subCases = command.subcommands
+        .filter((sub) => sub.flags)
+        .map((sub) => `        ${sub.name}) candidates=(${sub.flags!.join(' ')}) ;;`)
+        .join('\n');
+      const lines = [
+        `    ${command.name})`,
+        `      if (( CURRENT == 3 )); then`,
+        `        candidates=(${names(command.subcommands)})`,
+      ];
+      if (subCases) {
+        lines.push('      else', '        case "$words[3]" in', subCases, '        esac');
+      }
+      lines.push('      fi', '      ;;');
+      return lines.join('\n');
+    }
+    return `    ${command.name}) candidates=(${(command.flags ?? []).join(' ')}) ;;`;
+  }).join('\n');
+
+  return `#compdef ${bin}
+# opentmux zsh completion
+_opentmux() {
+  local -a candidates
+
+  case "$words[CURRENT-1]" in
+    ${SESSION_FLAGS.join('|')})
+      candidates=(\${(f)"$(${bin} completion --sessions 2>/dev/null)"})
+      compadd -a candidates
+      return
+      ;;
+  esac
+
+  if (( CURRENT == 2 )); then
+    candidates=(${names(tree)})
+  else
+    case "$words[2]" in
+${cases}
+    esac
+  fi
+
+  compadd -a candidates
+}
+compdef _opentmux ${bin}
+`;
+}
+
+function fishScript(tree: CompletionCommand[], bin: string): string {
+  const lines = [
+    '# opentmux fish completion',
+    `complete -c ${bin} -f`,
+    `complete -c ${bin} -n '__fish_use_subcommand' -a '${names(tree)}'`,
+  ];
+
+  const flagLines = (condition: string, flags: string[]) =>
+    flags.map((flag) => {
+      const dynamic = SESSION_FLAGS.includes(flag)
+        ? ` -xa '(${bin} completion --sessions 2>/dev/null)'`
+        : '';
+      return `complete -c ${bin} -n '${condition}' -l ${flag.replace(/^--/, '')}${dynamic}`;
+    });
+
+  for (const command of tree) {
+    const inCommand = `__fish_seen_subcommand_from ${command.name}`;
+    if (command.subcommands) {
+      const subNames = names(command.subcommands);
+      lines.push(
+        `complete -c ${bin} -n '${inCommand}; and not __fish_seen_subcommand_from ${subNames}' -a '${subNames}'`,
+      );
+      for (const sub of command.subcommands) {
+        if (sub.flags) {
+          const inSubcommand = `${inCommand}; and __fish_seen_subcommand_from ${sub.name}`;
+          lines.push(...flagLines(inSubcommand, sub.flags));
+        }
+      }
+    } else if (command.flags) {
+      lines.push(...flagLines(inCommand, command.flags));
+    }
+  }
+
+  return `${lines.join('\n')}\n`;
+}
+
+export function isCompletionShell(value: string | undefined): value is CompletionShell {
+  return COMPLETION_SHELLS.includes(value as CompletionShell);
+}
+
+/**
+ * Builds the completion script for a shell from the launcher's command table.
+ */
+export function generateCompletion(
+  shell: CompletionShell,
+  commands: CommandSpec[],
+  bin = 'opentmux',
+): string {
+  const tree = completionTree(commands);
+  switch (shell) {
+    case 'bash':
+      return bashScript(tree, bin);
+    case 'zsh':
+      return zshScript(tree, bin);
+    case 'fish':
+      return fishScript(tree, bin);
+  }
+}
@@ -1,45 +1,279 @@
 import * as fs from 'node:fs';
 import * as path from 'node:path';
-import { PluginConfigSchema, type PluginConfig } from '../config';
+import { parse as parseToml } from 'smol-toml';
+import { parse as parseYaml } from 'yaml';
+import type { z } from 'zod';
+import { layoutReferenceError, PluginConfigSchema, type PluginConfig } from '../config';
+import { log } from './logger';
 
-function log(message: string, data?: unknown) {
-  // Simple logger for config loading
-  // In real app, might want to use the unified logger
+export interface ConfigValidationResult {
+  path: string;
+  config: PluginConfig | null;
+  errors: string[];
+  unknownKeys: string[];
+  /** Maps an unknown key to the known key it most likely meant */
+  suggestions: Record<string, string>;
 }
 
-export function loadConfig(directory?: string): PluginConfig {
-  const configPaths: string[] = [];
-
-  if (directory) {
-    configPaths.push(
-      path.join(directory, 'opentmux.json'),
-      path.join(directory, 'opencode-agent-tmux.json')
-    );
-  }
-
-  configPaths.push(
-    path.join(
-      process.env.HOME ?? '',
-      '.config',
-      'opencode',
-      'opentmux.json',
-    )
+const KNOWN_KEYS = Object.keys(PluginConfigSchema.shape);
+
+/** Environment variable naming an explicit config file */
+export const CONFIG_ENV_VAR = 'OPENTMUX_CONFIG';
+
+/** Supported config file extensions, in lookup order */
+const CONFIG_EXTENSIONS = ['.json', '.toml', '.yaml', '.yml'];
+
+function squash(key: string): string {
+  return key.toLowerCase().replace(/[^a-z0-9]/g, '');
+}
+
+function suggestKey(key: string): string | undefined {
+  const squashed = squash(key);
+  return KNOWN_KEYS.find((known) => squash(known) === squashed);
+}
+
+function withExtensions(basePath: string): string[] {
+  return CONFIG_EXTENSIONS.map((ext) => `${basePath}${ext}`);
+}
+
+/**
+ * Lists candidate project config files in lookup order.
+ */
+export function getProjectConfigPaths(directory: string): string[] {
+  return [
+    ...withExtensions(path.join(directory, 'opentmux')),
+    path.join(directory, 'opencode-agent-tmux.json'),
+  ];
+}
+
+/**
+ * Lists candidate global config files in lookup order.
+ */
+export function getGlobalConfigPaths(): string[] {
+  return withExtensions(
+    path.join(process.env.HOME ?? '', '.config', 'opencode', 'opentmux'),
   );
+}
+
+/**
+ * Lists candidate config files: project files first, global files last.
+ */
+export function getConfigPaths(directory?: string): string[] {
+  return [
+    ...(directory ? getProjectConfigPaths(directory) : []),
+    ...getGlobalConfigPaths(),
+  ];
+}
+
+/**
+ * Parses config file contents, picking the format by file extension.
+ */
+export function parseConfigText(text: string, configPath: string): unknown {
+  switch (path.extname(configPath).toLowerCase()) {
+    case '.toml':
+      return parseToml(text);
+    case '.yaml':
+    case '.yml':
+      return parseYaml(text);
+    default:
+      return JSON.parse(text);
+  }
+}
+
+function readConfigFile(configPath: string): unknown {
+  return parseConfigText(fs.readFileSync(configPath, 'utf-8'), configPath);
+}
+
+function formatIssues(error: z.ZodError): string[] {
+  return error.issues.map((issue) => {
+    const where = issue.path.length > 0 ? issue.path.join('.') : '<root>';
+    return `${where}: ${issue.message}`;
+  });
+}
+
+/**
+ * Validates raw config data against the schema.
+ * Unknown keys are reported but do not make the config invalid.
+ */
+export function validateConfig(raw: unknown, source = '<inline>'): ConfigValidationResult {
+  const result: ConfigValidationResult = {
+    path: source,
+    config: null,
+    errors: [],
+    unknownKeys: [],
+    suggestions: {},
+  };
+
+  if (!raw || typeof raw !== 'object' || Array.isArray(raw)) {
+    result.errors.push('config must be an object/table at the top level');
+    return result;
+  }
+
+  for (const key of Object.keys(raw)) {
+    if (KNOWN_KEYS.includes(key)) continue;
+    result.unknownKeys.push(key);
+    const suggestion = suggestKey(key);
+    if (suggestion) {
+      result.suggestions[key] = suggestion;
+    }
+  }
+
+  const parsed = PluginConfigSchema.safeParse(raw);
+  if (parsed.success) {
+    result.config = parsed.data;
+  } else {
+    result.errors.push(...formatIssues(parsed.error));
+  }
+
+  return result;
+}
+
+/**
+ * Reads and validates a single config file.
+ */
+export function validateConfigFile(configPath: string): ConfigValidationResult {
+  let raw: unknown;
+  try {
+    raw = readConfigFile(configPath);
+  } catch (err) {
+    return {
+      path: configPath,
+      config: null,
+      errors: [`failed to read config: ${err instanceof Error ? err.message : String(err)}`],
+      unknownKeys: [],
+      suggestions: {},
+    };
+  }
 
-  for (const configPath of configPaths) {
+  return validateConfig(raw, configPath);
+}
+
+/**
+ * Formats unknown-key warnings, including "did you mean" hints.
+ */
+export function formatUnknownKeys(result: ConfigValidationResult): string[] {
+  return result.unknownKeys.map((key) => {
+    const suggestion = result.suggestions[key];
+    return suggestion
+      ? `unknown key "${key}" (did you mean "${suggestion}"?)`
+      : `unknown key "${key}"`;
+  });
+}
+
+export interface ConfigLayer {
+  path: string;
+  raw: Record<string, unknown>;
+}
+
+export interface LoadedConfig {
+  config: PluginConfig;
+  /** Files that contributed to the config, lowest precedence first */
+  sources: string[];
+  /** Why the merged config was rejected in favour of the defaults; empty if it wasn't */
+  errors: string[];
+}
+
+function isPlainObject(value: unknown): value is Record<string, unknown> {
+  return !!value && typeof value === 'object' && !Array.isArray(value);
+}
+
+/**
+ * Deep-merges config layers. Later layers win, but only for the keys they set.
+ */
+export function mergeConfigLayers(
+  layers: Record<string, unknown>[],
+): Record<string, unknown> {
+  const merged: Record<string, unknown> = {};
+
+  for (const layer of layers) {
+    for (const [key, value] of Object.entries(layer)) {
+      const current = merged[key];
+      merged[key] =
+        isPlainObject(current) && isPlainObject(value)
+          ? mergeConfigLayers([current, value])
+          : value;
+    }
+  }
+
+  return merged;
+}
+
+/**
+ * Reads the first global and first project config found, global first.
+ * A file named by OPENTMUX_CONFIG (set by `opentmux --config`) takes the
+ * place of the project config. Layers that fail validation on their own are
+ * logged and skipped.
+ */
+export function readConfigLayers(directory?: string): ConfigLayer[] {
+  const globalPath = getGlobalConfigPaths().find((configPath) => fs.existsSync(configPath));
+  const projectPath =
+    process.env[CONFIG_ENV_VAR] ||
+    (directory
+      ? getProjectConfigPaths(directory).find((configPath) => fs.existsSync(configPath))
+      : undefined);
+
+  const layers: ConfigLayer[] = [];
+
+  for (const configPath of [globalPath, projectPath]) {
+    if (!configPath || !fs.existsSync(configPath)) continue;
+
+    let raw: unknown;
     try {
-      if (fs.existsSync(configPath)) {
-        const content = fs.readFileSync(configPath, 'utf-8');
-        const parsed = JSON.parse(content);
-        const result = PluginConfigSchema.safeParse(parsed);
-        if (result.success) {
-          return result.data;
-        }
-      }
+      raw = readConfigFile(configPath);
     } catch (err) {
-      // ignore
+      log('[config-loader] unreadable config, skipping', {
+        path: configPath,
+        error: String(err),
+      });
+      continue;
+    }
+
+    const result = validateConfig(raw, configPath);
+    if (result.unknownKeys.length > 0) {
+      log('[config-loader] ignoring unknown config keys', {
+        path: configPath,
+        warnings: formatUnknownKeys(result),
+      });
     }
+
+    if (!result.config) {
+      log('[config-loader] invalid config, skipping', {
+        path: configPath,
+        errors: result.errors,
+      });
+      continue;
+    }
+
+    layers.push({ path: configPath, raw: raw as Record<string, unknown> });
+  }
+
+  return layers;
+}
+
+/**
+ * Loads the effective config: project settings overlaid on global settings.
+ */
+export function loadConfigWithSources(directory?: string): LoadedConfig {
+  const layers = readConfigLayers(directory);
+  const merged = mergeConfigLayers(layers.map((layer) => layer.raw));
+  const result = PluginConfigSchema.safeParse(merged);
+
+  // A layout preset may come from either layer, so it's only checked here
+  const layoutError = result.success ? layoutReferenceError(result.data) : null;
+  const errors = result.success ? [] : formatIssues(result.error);
+  if (layoutError) errors.push(layoutError);
+
+  if (!result.success || layoutError) {
+    log('[config-loader] merged config invalid, using defaults', {
+      sources: layers.map((layer) => layer.path),
+      errors,
+    });
+    return { config: PluginConfigSchema.parse({}), sources: [], errors };
   }
 
-  return PluginConfigSchema.parse({});
+  return { config: result.data, sources: layers.map((layer) => layer.path), errors: [] };
+}
+
+export function loadConfig(directory?: string): PluginConfig {
+  return loadConfigWithSources(directory).config;
 }
@@ -0,0 +1,44 @@
+export interface HistogramSummary {
+  count: number;
+  p50: number;
+  p95: number;
+  max: number;
+}
+
+/** Samples kept per histogram; older samples are dropped first */
+export const DEFAULT_HISTOGRAM_SAMPLES = 500;
+
+/**
+ * Nearest-rank percentile of an ascending array; 0 when empty.
+ */
+export function percentile(sorted: number[], p: number): number {
+  if (sorted.length === 0) return 0;
+  const rank = Math.ceil((Math.min(100, Math.max(0, p)) / 100) * sorted.length);
+  return sorted[Math.max(0, rank - 1)];
+}
+
+/**
+ * Bounded window of duration samples with percentile summaries.
+ */
+export class Histogram {
+  private samples: number[] = [];
+
+  constructor(private readonly maxSamples = DEFAULT_HISTOGRAM_SAMPLES) {}
+
+  record(value: number): void {
+    this.samples.push(value);
+    if (this.samples.length > this.maxSamples) {
+      this.samples.shift();
+    }
+  }
+
+  summary(): HistogramSummary {
+    const sorted = [...this.samples].sort((a, b) => a - b);
+    return {
+      count: sorted.length,
+      p50: percentile(sorted, 50),
+      p95: percentile(sorted, 95),
+      max: sorted.length > 0 ? sorted[sorted.length - 1] : 0,
+    };
+  }
+}
@@ -0,0 +1,35 @@
+/** Time budget for a request to the opencode server when the caller sets none */
+export const DEFAULT_HTTP_TIMEOUT_MS = 5000;
+
+export interface HttpResponse {
+  ok: boolean;
+  status: number;
+  statusText: string;
+  /** Parsed JSON body, or null when the body isn't JSON */
+  body: unknown;
+}
+
+/**
+ * The HTTP client the plugin uses to talk to the opencode server. The
+ * timeout covers connecting, the response and reading the body, so a wedged
+ * server can't hold up polling; network errors and timeouts resolve to null
+ * instead of throwing. Connections are kept alive and reused by the
+ * runtime's fetch.
+ */
+export async function requestJson(
+  url: string,
+  options: { method?: string; timeoutMs?: number } = {},
+): Promise<HttpResponse | null> {
+  const controller = new AbortController();
+  const timeout = setTimeout(() => controller.abort(), options.timeoutMs ?? DEFAULT_HTTP_TIMEOUT_MS);
+
+  try {
+    const response = await fetch(url, { method: options.method, signal: controller.signal });
+    const body = (await response.json().catch(() => null)) as unknown;
+    return { ok: response.ok, status: response.status, statusText: response.statusText, body };
+  } catch {
+    return null;
+  } finally {
+    clearTimeout(timeout);
+  }
+}
@@ -1,11 +1,38 @@
 export { log } from './logger';
 export {
   applyTmuxLayout,
+  capturePaneOutput,
+  closeTmuxControlClient,
   closeTmuxPane,
+  focusTmuxPane,
+  formatStatusLine,
+  getCloseAllRequest,
+  getQueueFlushedAt,
   getTmuxPath,
+  hasAttachProcess,
+  hasTmuxControlClient,
   isInsideTmux,
+  isSpawningPaused,
+  listAgentPanes,
+  listAllPaneIds,
+  onTmuxNotification,
+  recordTmuxSession,
+  refreshAutoLayout,
+  removeGridPlaceholders,
   resetServerCheck,
+  setPaneStatusStyle,
+  setPaneTitle,
+  setQueueOption,
+  setSpawningPaused,
+  setStatusLineText,
+  setVersionOption,
+  showTmuxMessage,
   spawnTmuxPane,
   startTmuxCheck,
+  type AgentPane,
+  type CloseAllRequest,
+  type PaneGroup,
+  type PaneStatus,
   type SpawnPaneResult,
 } from './tmux';
+export { truncateTitle } from './title';
@@ -0,0 +1,56 @@
+/**
+ * Helpers for the agent-pane jump keybindings installed by `opentmux bind-keys`.
+ *
+ * Bindings don't name panes directly; they call back into `opentmux jump`,
+ * which looks up the tagged agent panes at the time of the key press, so the
+ * bindings stay correct as panes come and go.
+ */
+
+/** tmux key table holding the jump bindings */
+export const KEY_TABLE = 'opentmux';
+
+export interface JumpPane {
+  paneId: string;
+  active: boolean;
+}
+
+/**
+ * Builds the tmux commands that install the key table.
+ * `prefix + <key>` enters the table; then 1-9 jump, n/p cycle.
+ */
+export function buildBindKeyCommands(opentmuxCommand: string, key: string): string[][] {
+  const jump = (target: string) => `${opentmuxCommand} jump ${target} '#{window_id}'`;
+
+  const commands: string[][] = [['bind-key', key, 'switch-client', '-T', KEY_TABLE]];
+  for (let n = 1; n <= 9; n++) {
+    commands.push(['bind-key', '-T', KEY_TABLE, String(n), 'run-shell', jump(String(n))]);
+  }
+  commands.push(['bind-key', '-T', KEY_TABLE, 'n', 'run-shell', jump('next')]);
+  commands.push(['bind-key', '-T', KEY_TABLE, 'p', 'run-shell', jump('prev')]);
+  return commands;
+}
+
+/**
+ * Resolves a jump target ("1".."9", "next", "prev") to an agent pane id.
+ * Panes are expected in window order.
+ */
+export function resolveJumpTarget(panes: JumpPane[], target: string): string | null {
+  if (panes.length === 0) return null;
+
+  if (target === 'next' || target === 'prev') {
+    const current = panes.findIndex((pane) => pane.active);
+    const step = target === 'next' ? 1 : -1;
+    // From a non-agent pane, next starts at the first agent and prev at the last
+    const index =
+      current === -1
+        ? target === 'next'
+          ? 0
+          : panes.length - 1
+        : (current + step + panes.length) % panes.length;
+    return panes[index].paneId;
+  }
+
+  const n = Number.parseInt(target, 10);
+  if (!Number.isInteger(n) || n < 1 || n > panes.length) return null;
+  return panes[n - 1].paneId;
+}
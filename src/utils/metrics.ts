@@ -0,0 +1,151 @@
+import { createSocket, type Socket } from 'node:dgram';
+import * as os from 'node:os';
+import { log } from './logger';
+
+/**
+ * Optional push-style metrics, for environments (CI jobs, throwaway containers)
+ * that live too briefly to be scraped.
+ *
+ * `metrics_sink` selects the backend:
+ * - `statsd://host:port[/prefix]` sends counters and timers over UDP as they happen
+ * - `pushgateway+http://host:port` (or `+https`) pushes accumulated values to a
+ *   Prometheus Pushgateway on each flush
+ */
+
+export interface MetricsSink {
+  increment(name: string, value?: number): void;
+  timing(name: string, ms: number): void;
+  /** Pushes buffered values; a no-op for backends that send immediately */
+  flush(): Promise<void>;
+  close(): void;
+}
+
+export const DEFAULT_METRICS_PREFIX = 'opentmux';
+const PUSH_TIMEOUT_MS = 2000;
+
+class StatsdSink implements MetricsSink {
+  private socket: Socket | null = null;
+
+  constructor(
+    private host: string,
+    private port: number,
+    private prefix: string,
+  ) {}
+
+  increment(name: string, value = 1): void {
+    this.send(`${this.prefix}.${name}:${value}|c`);
+  }
+
+  timing(name: string, ms: number): void {
+    this.send(`${this.prefix}.${name}:${Math.round(ms)}|ms`);
+  }
+
+  async flush(): Promise<void> {}
+
+  close(): void {
+    this.socket?.close();
+    this.socket = null;
+  }
+
+  private send(line: string): void {
+    try {
+      if (!this.socket) {
+        this.socket = createSocket(this.host.includes(':') ? 'udp6' : 'udp4');
+        // Never keep the process alive just for metrics
+        this.socket.unref();
+        this.socket.on('error', (err) => log('[metrics] statsd error', { error: String(err) }));
+      }
+      this.socket.send(line, this.port, this.host);
+    } catch (err) {
+      log('[metrics] statsd send failed', { error: String(err) });
+    }
+  }
+}
+
+/** Converts a dotted metric name to a Prometheus-safe one */
+function toPromName(prefix: string, name: string): string {
+  return `${prefix}_${name}`.replace(/[^a-zA-Z0-9_]/g, '_');
+}
+
+class PushgatewaySink implements MetricsSink {
+  private counters = new Map<string, number>();
+  private timings = new Map<string, { sum: number; count: number }>();
+
+  constructor(
+    private url: string,
+    private prefix: string,
+  ) {}
+
+  increment(name: string, value = 1): void {
+    this.counters.set(name, (this.counters.get(name) ?? 0) + value);
+  }
+
+  timing(name: string, ms: number): void {
+    const current = this.timings.get(name) ?? { sum: 0, count: 0 };
+    this.timings.set(name, { sum: current.sum + ms, count: current.count + 1 });
+  }
+
+  async flush(): Promise<void> {
+    if (this.counters.size === 0 && this.timings.size === 0) return;
+    try {
+      const response = await fetch(this.url, {
+        method: 'PUT',
+        headers: { 'Content-Type': 'text/plain; version=0.0.4' },
+        body: this.render(),
+        signal: AbortSignal.timeout(PUSH_TIMEOUT_MS),
+      });
+      if (!response.ok) {
+        log('[metrics] pushgateway rejected metrics', { status: response.status });
+      }
+    } catch (err) {
+      log('[metrics] pushgateway push failed', { error: String(err) });
+    }
+  }
+
+  close(): void {}
+
+  render(): string {
+    const lines: string[] = [];
+    for (const [name, value] of this.counters) {
+      const metric = `${toPromName(this.prefix, name)}_total`;
+      lines.push(`# TYPE ${metric} counter`, `${metric} ${value}`);
+    }
+    for (const [name, { sum, count }] of this.timings) {
+      const metric = `${toPromName(this.prefix, name)}_ms`;
+      lines.push(`# TYPE ${metric} summary`, `${metric}_sum ${sum}`, `${metric}_count ${count}`);
+    }
+    return `${lines.join('\n')}\n`;
+  }
+}
+
+/**
+ * Builds the sink described by a `metrics_sink` value, or null when unset or unrecognized.
+ */
+export function createMetricsSink(spec: string | undefined): MetricsSink | null {
+  if (!spec) return null;
+
+  try {
+    if (spec.startsWith('statsd://')) {
+      const url = new URL(spec);
+      const prefix = url.pathname.replace(/^\/+|\/+$/g, '') || DEFAULT_METRICS_PREFIX;
+      const host = url.hostname.replace(/^\[|\]$/g, '');
+      return new StatsdSink(host, Number.parseInt(url.port || '8125', 10), prefix);
+    }
+
+    if (spec.startsWith('pushgateway+')) {
+      const base = new URL(spec.slice('pushgateway+'.length));
+      const instance = encodeURIComponent(`${os.hostname()}-${process.pid}`);
+      const pushUrl = new URL(
+        `${base.pathname.replace(/\/+$/, '')}/metrics/job/${DEFAULT_METRICS_PREFIX}/instance/${instance}`,
+        base,
+      );
+      return new PushgatewaySink(pushUrl.toString(), DEFAULT_METRICS_PREFIX);
+    }
+  } catch (err) {
+    log('[metrics] invalid metrics_sink', { spec, error: String(err) });
+    return null;
+  }
+
+  log('[metrics] unsupported metrics_sink', { spec });
+  return null;
+}
@@ -0,0 +1,46 @@
+import * as fs from 'node:fs';
+import * as os from 'node:os';
+import * as path from 'node:path';
+import { getRegistryPath } from './server-registry';
+
+/**
+ * Directory holding captured pane output; pane_output_dir when set
+ * (with `~` expanded), otherwise `output/` next to the server registry.
+ */
+export function getPaneOutputDir(configured?: string): string {
+  if (!configured) {
+    return path.join(path.dirname(getRegistryPath()), 'output');
+  }
+  if (configured === '~' || configured.startsWith('~/')) {
+    return path.join(os.homedir(), configured.slice(1));
+  }
+  return path.resolve(configured);
+}
+
+export function getPaneOutputPath(sessionId: string, configured?: string): string {
+  const safeId = sessionId.replace(/[^A-Za-z0-9_-]/g, '_');
+  return path.join(getPaneOutputDir(configured), `${safeId}.log`);
+}
+
+/**
+ * Writes captured output for a session, replacing any earlier capture.
+ * Returns the file path, or null if it could not be written.
+ */
+export function savePaneOutput(sessionId: string, output: string, configured?: string): string | null {
+  const outputPath = getPaneOutputPath(sessionId, configured);
+  try {
+    fs.mkdirSync(path.dirname(outputPath), { recursive: true });
+    fs.writeFileSync(outputPath, output);
+    return outputPath;
+  } catch {
+    return null;
+  }
+}
+
+export function readPaneOutput(sessionId: string, configured?: string): string | null {
+  try {
+    return fs.readFileSync(getPaneOutputPath(sessionId, configured), 'utf-8');
+  } catch {
+    return null;
+  }
+}
@@ -0,0 +1,21 @@
+/**
+ * opencode commands that can talk to an already running server, and the
+ * arguments that point them at one.
+ */
+const SERVER_ARGS: Record<string, (serverUrl: string) => string[]> = {
+  run: (serverUrl) => ['--attach', serverUrl],
+};
+
+/**
+ * Arguments for a command passed through to opencode, pointed at the server
+ * on `port` when the command can use one and doesn't already name one.
+ */
+export function passthroughArgs(args: string[], port: number): string[] {
+  const serverArgs = SERVER_ARGS[args[0]];
+  if (!serverArgs) return args;
+
+  const namesServer = args.some((arg) =>
+    ['--attach', '--port'].some((flag) => arg === flag || arg.startsWith(`${flag}=`)),
+  );
+  return namesServer ? args : [...args, ...serverArgs(`http://localhost:${port}`)];
+}
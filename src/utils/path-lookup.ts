@@ -0,0 +1,55 @@
+import { accessSync, constants, realpathSync, statSync } from 'node:fs';
+import { delimiter, extname, join } from 'node:path';
+
+const DEFAULT_PATHEXT = '.COM;.EXE;.BAT;.CMD';
+
+function isExecutableFile(file: string, windows: boolean): boolean {
+  try {
+    if (!statSync(file).isFile()) return false;
+    if (!windows) accessSync(file, constants.X_OK);
+    return true;
+  } catch {
+    return false;
+  }
+}
+
+/**
+ * Every executable named `name` on PATH, in PATH order, like `which -a`.
+ * On Windows each PATHEXT extension is tried, as `where` does. Needs no
+ * shell, so it works on minimal images too.
+ */
+export function findExecutablesOnPath(
+  name: string,
+  env: NodeJS.ProcessEnv = process.env,
+  platform: NodeJS.Platform = process.platform,
+): string[] {
+  const windows = platform === 'win32';
+  const pathValue = (windows ? (env.PATH ?? env.Path) : env.PATH) ?? '';
+  const separator = windows ? ';' : delimiter;
+  const names =
+    windows && !extname(name)
+      ? (env.PATHEXT ?? DEFAULT_PATHEXT).split(';').filter(Boolean).map((ext) => name + ext.toLowerCase())
+      : [name];
+
+  const found: string[] = [];
+  for (const dir of pathValue.split(separator)) {
+    if (!dir) continue;
+    for (const candidate of names) {
+      const file = join(dir, candidate);
+      if (!found.includes(file) && isExecutableFile(file, windows)) {
+        found.push(file);
+      }
+    }
+  }
+  return found;
+}
+
+/** file with symlinks resolved, or null when it doesn't exist */
+export function realPathOrNull(file: string | undefined): string | null {
+  if (!file) return null;
+  try {
+    return realpathSync(file);
+  } catch {
+    return null;
+  }
+}
@@ -0,0 +1,46 @@
+import type { PluginConfig } from '../config';
+
+export interface PortRange {
+  start: number;
+  /** Inclusive */
+  end: number;
+}
+
+type PortConfig = Pick<PluginConfig, 'port' | 'max_ports' | 'port_range' | 'port_exclude'>;
+
+/**
+ * Resolves the port range opentmux may use for opencode servers.
+ * An explicit port_range wins; otherwise the legacy port + max_ports scheme applies.
+ */
+export function resolvePortRange(config: PortConfig): PortRange {
+  if (config.port_range) {
+    return { start: config.port_range.start, end: config.port_range.end };
+  }
+
+  const start = config.port || Number.parseInt(process.env.OPENCODE_PORT || '4096', 10);
+  return { start, end: start + (config.max_ports || 10) };
+}
+
+/**
+ * Lists the ports in the configured range, minus port_exclude, in ascending order.
+ */
+export function getCandidatePorts(config: PortConfig): number[] {
+  const { start, end } = resolvePortRange(config);
+  const excluded = new Set(config.port_exclude ?? []);
+
+  const ports: number[] = [];
+  for (let port = start; port <= end; port++) {
+    if (!excluded.has(port)) {
+      ports.push(port);
+    }
+  }
+  return ports;
+}
+
+export function formatPortRange(config: PortConfig): string {
+  const { start, end } = resolvePortRange(config);
+  const excluded = config.port_exclude ?? [];
+  return excluded.length > 0
+    ? `${start}-${end} (excluding ${excluded.join(', ')})`
+    : `${start}-${end}`;
+}
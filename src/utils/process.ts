@@ -1,5 +1,14 @@
-import { execSync } from 'node:child_process';
+import { exec, execSync } from 'node:child_process';
+import { readFileSync } from 'node:fs';
 import { platform } from 'node:os';
+import { promisify } from 'node:util';
+import type { TmuxConfig } from '../config';
+
+/**
+ * Environment variable set on every opencode server started by opentmux.
+ * Only processes carrying it are considered safe to kill automatically.
+ */
+export const OWNER_ENV_VAR = 'OPENTMUX_OWNER';
 
 /**
  * Safely executes a shell command and returns the output.
@@ -17,6 +26,49 @@ export function safeExec(command: string): string | null {
   }
 }
 
+const execAsync = promisify(exec);
+
+/**
+ * Like safeExec, without blocking the event loop while the command runs.
+ */
+export async function safeExecAsync(command: string): Promise<string | null> {
+  try {
+    const { stdout } = await execAsync(command, { encoding: 'utf-8' });
+    return stdout.trim();
+  } catch {
+    return null;
+  }
+}
+
+export interface ProcessEntry {
+  pid: number;
+  ppid: number;
+  command: string;
+}
+
+/**
+ * Lists every process with its parent and command line, from one `ps` run.
+ * Null when ps can't be run.
+ */
+export async function listProcesses(): Promise<ProcessEntry[] | null> {
+  if (platform() === 'win32') return null;
+  const output = await safeExecAsync('ps -A -o pid=,ppid=,command=');
+  if (output === null) return null;
+
+  const entries: ProcessEntry[] = [];
+  for (const line of output.split('\n')) {
+    const match = /^\s*(\d+)\s+(\d+)\s+(.*)$/.exec(line);
+    if (match) {
+      entries.push({
+        pid: Number.parseInt(match[1], 10),
+        ppid: Number.parseInt(match[2], 10),
+        command: match[3],
+      });
+    }
+  }
+  return entries;
+}
+
 /**
  * Gets PIDs listening on a specific TCP port.
  */
@@ -31,6 +83,24 @@ export function getListeningPids(port: number): number[] {
     .filter((value) => Number.isFinite(value));
 }
 
+/**
+ * Remote ports of the established TCP connections a process holds.
+ * Empty when there are none or lsof is unavailable, so callers can't tell
+ * those apart and should treat empty as "unknown".
+ */
+export function getConnectedPorts(pid: number): number[] {
+  if (platform() === 'win32') return [];
+  const output = safeExec(`lsof -nP -a -p ${pid} -iTCP -sTCP:ESTABLISHED -Fn`);
+  if (!output) return [];
+
+  const ports = new Set<number>();
+  for (const line of output.split('\n')) {
+    const match = /^n.*->.*:(\d+)$/.exec(line.trim());
+    if (match) ports.add(Number.parseInt(match[1], 10));
+  }
+  return [...ports];
+}
+
 /**
  * Checks if a process with the given PID is currently running.
  */
@@ -61,6 +131,33 @@ export function getProcessCommand(pid: number): string | null {
   return output && output.length > 0 ? output : null;
 }
 
+/**
+ * Checks whether a process carries the opentmux ownership marker,
+ * either in its environment or on its command line. Always false on
+ * Windows, where neither can be read; see isOpentmuxServer for the
+ * server registry fallback.
+ */
+export function isOwnedProcess(pid: number): boolean {
+  if (platform() === 'win32') return false;
+
+  const marker = `${OWNER_ENV_VAR}=`;
+
+  if (platform() === 'linux') {
+    try {
+      const environ = readFileSync(`/proc/${pid}/environ`, 'utf-8');
+      if (environ.split('\0').some((entry) => entry.startsWith(marker))) {
+        return true;
+      }
+    } catch {
+      // Not readable (other user or gone); fall back to ps
+    }
+  }
+
+  // `ps eww` appends the environment to the command on macOS/BSD
+  const output = safeExec(`ps eww -o command= -p ${pid}`);
+  return !!output && output.includes(marker);
+}
+
 /**
  * Gets the immediate child PIDs of a process.
  */
@@ -77,6 +174,31 @@ export function getProcessChildren(pid: number): number[] {
     .filter((value) => Number.isFinite(value));
 }
 
+/**
+ * Gets every PID below a process: children, grandchildren and so on.
+ */
+export function getProcessDescendants(pid: number): number[] {
+  const found: number[] = [];
+  const queue = [pid];
+  while (queue.length > 0) {
+    for (const child of getProcessChildren(queue.shift() as number)) {
+      if (child === pid || found.includes(child)) continue;
+      found.push(child);
+      queue.push(child);
+    }
+  }
+  return found;
+}
+
+/**
+ * Gets the process group a process belongs to, or null if unknown.
+ */
+export function getProcessGroupId(pid: number): number | null {
+  if (platform() === 'win32') return null;
+  const pgid = Number.parseInt(safeExec(`ps -o pgid= -p ${pid}`) ?? '', 10);
+  return Number.isFinite(pgid) ? pgid : null;
+}
+
 /**
  * Safely sends a signal to a process.
  * Returns true if the signal was sent (or process is already dead), false on error.
@@ -92,19 +214,130 @@ export function safeKill(pid: number, signal: NodeJS.Signals | number = 'SIGTERM
   }
 }
 
+/**
+ * Like safeKill, but signals every process in the group led by pgid.
+ */
+export function safeKillGroup(pgid: number, signal: NodeJS.Signals | number = 'SIGTERM'): boolean {
+  if (platform() === 'win32') return safeKill(pgid, signal);
+  try {
+    process.kill(-pgid, signal);
+    return true;
+  } catch (err: any) {
+    if (err.code === 'ESRCH') return true;
+    return false;
+  }
+}
+
+function isGroupAlive(pgid: number): boolean {
+  try {
+    process.kill(-pgid, 0);
+    return true;
+  } catch {
+    return false;
+  }
+}
+
 /**
  * Waits for a process to exit within a given timeout.
  * Returns true if process exited, false if timeout reached.
  */
 export async function waitForProcessExit(pid: number, timeoutMs: number = 2000): Promise<boolean> {
+  return waitUntilGone(() => isProcessAlive(pid), timeoutMs);
+}
+
+async function waitUntilGone(isAlive: () => boolean, timeoutMs: number): Promise<boolean> {
   const start = Date.now();
   
   while (Date.now() - start < timeoutMs) {
-    if (!isProcessAlive(pid)) return true;
+    if (!isAlive()) return true;
     await new Promise(resolve => setTimeout(resolve, 100));
   }
   
-  return !isProcessAlive(pid);
+  return !isAlive();
+}
+
+/**
+ * How a process is asked to stop before it is killed outright.
+ */
+export interface TerminatePolicy {
+  /** Time each signal gets to work before the next, stronger one is sent */
+  graceMs: number;
+  /** Send SIGINT before SIGTERM; opencode shuts down more cleanly on it */
+  interruptFirst: boolean;
+}
+
+export const DEFAULT_TERMINATE_POLICY: TerminatePolicy = { graceMs: 2000, interruptFirst: false };
+
+/** How long to wait for a process to disappear after SIGKILL */
+const KILL_WAIT_MS = 1000;
+
+export function terminatePolicyFromConfig(
+  config: Pick<TmuxConfig, 'kill_grace_period_ms' | 'kill_interrupt_first'>,
+): TerminatePolicy {
+  return { graceMs: config.kill_grace_period_ms, interruptFirst: config.kill_interrupt_first };
+}
+
+export interface TerminateOptions {
+  /**
+   * Also stop the processes the target started, such as bun workers: its
+   * whole process group when it leads one, otherwise its descendants.
+   */
+  group?: boolean;
+}
+
+interface SignalTarget {
+  signal(signal: NodeJS.Signals): void;
+  isAlive(): boolean;
+}
+
+/**
+ * What terminateProcess signals. A group is only signalled as a whole when
+ * pid leads it and it isn't our own, so a server started in the launcher's
+ * group never takes the launcher down with it. Descendants are listed up
+ * front because they are reparented once pid exits.
+ */
+function signalTarget(pid: number, options: TerminateOptions): SignalTarget {
+  if (options.group) {
+    const pgid = getProcessGroupId(pid);
+    if (pgid === pid && pgid !== getProcessGroupId(process.pid)) {
+      return {
+        signal: (signal) => safeKillGroup(pgid, signal),
+        isAlive: () => isGroupAlive(pgid),
+      };
+    }
+    const members = [pid, ...getProcessDescendants(pid)];
+    return {
+      signal: (signal) => members.forEach((member) => safeKill(member, signal)),
+      isAlive: () => members.some(isProcessAlive),
+    };
+  }
+  return {
+    signal: (signal) => safeKill(pid, signal),
+    isAlive: () => isProcessAlive(pid),
+  };
+}
+
+/**
+ * Stops a process by escalating: SIGINT (if the policy asks for it), then
+ * SIGTERM, then SIGKILL, waiting the grace period after each.
+ * Returns true once the process (and with options.group, everything it
+ * started) is gone.
+ */
+export async function terminateProcess(
+  pid: number,
+  policy: TerminatePolicy = DEFAULT_TERMINATE_POLICY,
+  options: TerminateOptions = {},
+): Promise<boolean> {
+  const target = signalTarget(pid, options);
+  const signals: NodeJS.Signals[] = policy.interruptFirst ? ['SIGINT', 'SIGTERM'] : ['SIGTERM'];
+  for (const signal of signals) {
+    if (!target.isAlive()) return true;
+    target.signal(signal);
+    if (await waitUntilGone(() => target.isAlive(), policy.graceMs)) return true;
+  }
+
+  target.signal('SIGKILL');
+  return waitUntilGone(() => target.isAlive(), KILL_WAIT_MS);
 }
 
 /**
@@ -0,0 +1,37 @@
+/**
+ * A spawn that hasn't produced a pane yet, as the plugin publishes it for
+ * `opentmux queue`.
+ */
+export interface QueuedSpawn {
+  sessionId: string;
+  title: string;
+  /** spawning: split-window is running; queued: waiting its turn; held: paused or quiet hours */
+  state: 'spawning' | 'queued' | 'held';
+  /** When the session was queued or held, in ms since the epoch */
+  since: number;
+  /** Failed attempts so far */
+  retries: number;
+}
+
+function isQueuedSpawn(value: unknown): value is QueuedSpawn {
+  if (!value || typeof value !== 'object') return false;
+  const entry = value as Partial<QueuedSpawn>;
+  return (
+    typeof entry.sessionId === 'string' &&
+    typeof entry.since === 'number' &&
+    (entry.state === 'spawning' || entry.state === 'queued' || entry.state === 'held')
+  );
+}
+
+/**
+ * Parses a published queue report. Returns null for anything else, e.g. an
+ * unset option.
+ */
+export function parseQueueReport(text: string): QueuedSpawn[] | null {
+  try {
+    const parsed = JSON.parse(text) as unknown;
+    return Array.isArray(parsed) ? parsed.filter(isQueuedSpawn) : null;
+  } catch {
+    return null;
+  }
+}
@@ -0,0 +1,23 @@
+/** One quiet-hours range in local time, e.g. "22:00-08:00" */
+export const QUIET_HOURS_PATTERN = /^([01]\d|2[0-3]):[0-5]\d-([01]\d|2[0-3]):[0-5]\d$/;
+
+function minutesOf(time: string): number {
+  const [hours, minutes] = time.split(':').map(Number);
+  return hours * 60 + minutes;
+}
+
+/**
+ * Whether now falls in any of the ranges. A range whose end is before its
+ * start runs past midnight; one whose start equals its end is empty. Times are
+ * local, so the boundaries follow the machine's clock and timezone.
+ */
+export function isQuietTime(ranges: readonly string[], now: Date = new Date()): boolean {
+  const current = now.getHours() * 60 + now.getMinutes();
+  return ranges.some((range) => {
+    if (!QUIET_HOURS_PATTERN.test(range)) return false;
+    const [start, end] = range.split('-').map(minutesOf);
+    return start <= end
+      ? current >= start && current < end
+      : current >= start || current < end;
+  });
+}
@@ -0,0 +1,159 @@
+import * as fs from 'node:fs';
+import * as os from 'node:os';
+import * as path from 'node:path';
+import { getProcessStartTime, isOwnedProcess, isProcessAlive } from './process';
+
+/**
+ * An opencode server started by the opentmux launcher.
+ */
+export interface ServerRecord {
+  port: number;
+  /** PID of the opencode server, when the launcher spawned it directly */
+  pid: number | null;
+  /** Directory the launcher was started in */
+  project: string;
+  launcherPid: number;
+  startedAt: number;
+}
+
+/**
+ * Location of the launcher's runtime state file.
+ */
+export function getRegistryPath(): string {
+  const stateHome = process.env.XDG_STATE_HOME || path.join(os.homedir(), '.local', 'state');
+  return path.join(stateHome, 'opentmux', 'servers.json');
+}
+
+function isServerRecord(value: unknown): value is ServerRecord {
+  if (!value || typeof value !== 'object') return false;
+  const record = value as Partial<ServerRecord>;
+  return typeof record.port === 'number' && typeof record.project === 'string';
+}
+
+export function readServerRecords(): ServerRecord[] {
+  try {
+    const parsed = JSON.parse(fs.readFileSync(getRegistryPath(), 'utf-8')) as unknown;
+    return Array.isArray(parsed) ? parsed.filter(isServerRecord) : [];
+  } catch {
+    return [];
+  }
+}
+
+function writeServerRecords(records: ServerRecord[]): void {
+  const registryPath = getRegistryPath();
+  fs.mkdirSync(path.dirname(registryPath), { recursive: true });
+
+  // Write-then-rename so concurrent launchers never read a torn file
+  const tmpPath = `${registryPath}.${process.pid}.tmp`;
+  fs.writeFileSync(tmpPath, JSON.stringify(records, null, 2));
+  fs.renameSync(tmpPath, registryPath);
+}
+
+/**
+ * Records a server, replacing any previous record for the same port.
+ */
+export function recordServer(record: ServerRecord): void {
+  try {
+    const records = readServerRecords().filter((r) => r.port !== record.port);
+    records.push(record);
+    writeServerRecords(records);
+  } catch {
+    // State is best-effort; the launcher must still start
+  }
+}
+
+export function removeServerRecord(port: number): void {
+  try {
+    const records = readServerRecords();
+    const remaining = records.filter((r) => r.port !== port);
+    if (remaining.length !== records.length) {
+      writeServerRecords(remaining);
+    }
+  } catch {
+    // Ignore
+  }
+}
+
+/** How far a server's start may be from its record for the record to be about it */
+const RECORD_START_SLACK_MS = 60_000;
+
+/**
+ * Whether pid is a server opentmux started: it carries the OPENTMUX_OWNER
+ * marker, or it is the recorded server for port (by PID, or listening on a
+ * port recorded without one) and started when the record was written.
+ * The registry covers servers started before the marker existed; the start
+ * time check keeps a reused PID or a later server on the port from counting.
+ */
+export function isOpentmuxServer(pid: number, port: number): boolean {
+  if (isOwnedProcess(pid)) return true;
+
+  const record = readServerRecords().find((r) => r.port === port);
+  if (!record || (record.pid !== null && record.pid !== pid)) return false;
+
+  const startedAt = getProcessStartTime(pid);
+  return startedAt !== null && Math.abs(startedAt - record.startedAt) <= RECORD_START_SLACK_MS;
+}
+
+export function findServerRecords(project: string): ServerRecord[] {
+  const resolved = path.resolve(project);
+  return readServerRecords().filter((r) => path.resolve(r.project) === resolved);
+}
+
+function getPortLockPath(port: number): string {
+  return path.join(path.dirname(getRegistryPath()), 'ports', `${port}.lock`);
+}
+
+/**
+ * Reserves a port for this launcher until the server on it is up.
+ * Locks held by processes that no longer exist are taken over.
+ * Returns false if another live launcher holds the port.
+ */
+export function acquirePortLock(port: number): boolean {
+  const lockPath = getPortLockPath(port);
+
+  for (let attempt = 0; attempt < 2; attempt++) {
+    try {
+      fs.mkdirSync(path.dirname(lockPath), { recursive: true });
+      fs.writeFileSync(lockPath, String(process.pid), { flag: 'wx' });
+      return true;
+    } catch (err) {
+      if ((err as NodeJS.ErrnoException).code !== 'EEXIST') {
+        // Can't create locks at all (read-only state dir); don't block launching
+        return true;
+      }
+    }
+
+    let ownerPid = Number.NaN;
+    try {
+      ownerPid = Number.parseInt(fs.readFileSync(lockPath, 'utf-8').trim(), 10);
+    } catch {
+      // Lock vanished between open and read; retry
+      continue;
+    }
+
+    if (ownerPid === process.pid) return true;
+    if (Number.isFinite(ownerPid) && isProcessAlive(ownerPid)) return false;
+
+    try {
+      fs.unlinkSync(lockPath);
+    } catch {
+      // Another launcher took it over first
+    }
+  }
+
+  return false;
+}
+
+/**
+ * Releases a port reservation held by this process.
+ */
+export function releasePortLock(port: number): void {
+  const lockPath = getPortLockPath(port);
+  try {
+    if (fs.readFileSync(lockPath, 'utf-8').trim() === String(process.pid)) {
+      fs.unlinkSync(lockPath);
+    }
+  } catch {
+    // Already released
+  }
+}
@@ -0,0 +1,40 @@
+const LOOPBACK_HOSTS = new Set(['localhost', '127.0.0.1', '::1', '[::1]', '0.0.0.0', '::', '[::]']);
+
+export interface ServerAddress {
+  /** Lowercase host; every loopback spelling becomes 127.0.0.1 */
+  host: string;
+  port: number;
+}
+
+/**
+ * Parses an opencode server URL into the address a client actually connects
+ * to. A missing scheme means http, a missing port the scheme's default, and
+ * path, query and trailing slash are ignored. localhost, 127.x, ::1 and the
+ * wildcard addresses all mean this machine, so they compare equal.
+ */
+export function parseServerAddress(url: string | null | undefined): ServerAddress | null {
+  if (!url) return null;
+  const withScheme = /^[a-z][a-z0-9+.-]*:\/\//i.test(url) ? url : `http://${url}`;
+  let parsed: URL;
+  try {
+    parsed = new URL(withScheme);
+  } catch {
+    return null;
+  }
+  if (parsed.protocol !== 'http:' && parsed.protocol !== 'https:') return null;
+
+  const hostname = parsed.hostname.toLowerCase();
+  const host = LOOPBACK_HOSTS.has(hostname) || hostname.startsWith('127.') ? '127.0.0.1' : hostname;
+  const port = parsed.port
+    ? Number.parseInt(parsed.port, 10)
+    : parsed.protocol === 'https:' ? 443 : 80;
+  return { host, port };
+}
+
+/** Whether two server URLs point at the same host and port */
+export function isSameServer(a: string | null | undefined, b: string | null | undefined): boolean {
+  const left = parseServerAddress(a);
+  const right = parseServerAddress(b);
+  if (!left || !right) return false;
+  return left.host === right.host && left.port === right.port;
+}
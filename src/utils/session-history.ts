@@ -0,0 +1,85 @@
+import * as fs from 'node:fs';
+import * as path from 'node:path';
+import { getRegistryPath } from './server-registry';
+
+/**
+ * One finished agent pane (or failed spawn), for post-mortem inspection.
+ */
+export interface SessionHistoryEntry {
+  sessionId: string;
+  parentId: string;
+  title: string;
+  /** Null when the pane never spawned */
+  paneId: string | null;
+  spawnedAt: number;
+  closedAt: number;
+  /** Why the pane went away: idle, missing_too_long, timeout, deleted, spawn_failed, ... */
+  reason: string;
+  /** Spawn attempts it took, including retries */
+  attempts: number;
+  /** What the user gave as the reason, e.g. `opentmux session close-all --reason` */
+  note?: string;
+}
+
+/** Entries kept when the history file is compacted */
+export const MAX_HISTORY_ENTRIES = 500;
+
+/** File size past which it is compacted, a few times what MAX_HISTORY_ENTRIES entries take */
+export const MAX_HISTORY_BYTES = 256 * 1024;
+
+export function getHistoryPath(): string {
+  return path.join(path.dirname(getRegistryPath()), 'history.jsonl');
+}
+
+function isHistoryEntry(value: unknown): value is SessionHistoryEntry {
+  if (!value || typeof value !== 'object') return false;
+  const entry = value as Partial<SessionHistoryEntry>;
+  return typeof entry.sessionId === 'string' && typeof entry.closedAt === 'number';
+}
+
+/**
+ * Reads history entries, oldest first. With a limit, only the newest entries are returned.
+ */
+export function readSessionHistory(limit?: number): SessionHistoryEntry[] {
+  let text: string;
+  try {
+    text = fs.readFileSync(getHistoryPath(), 'utf-8');
+  } catch {
+    return [];
+  }
+
+  const entries: SessionHistoryEntry[] = [];
+  for (const line of text.split('\n')) {
+    if (!line.trim()) continue;
+    try {
+      const parsed = JSON.parse(line) as unknown;
+      if (isHistoryEntry(parsed)) entries.push(parsed);
+    } catch {
+      // Skip torn or corrupt lines
+    }
+  }
+
+  return limit !== undefined ? entries.slice(-limit) : entries;
+}
+
+/**
+ * Appends an entry. Once the file grows past MAX_HISTORY_BYTES it is
+ * compacted to the newest MAX_HISTORY_ENTRIES, so it behaves like a ring
+ * buffer without reading or rewriting the file on every close.
+ */
+export function appendSessionHistory(entry: SessionHistoryEntry): void {
+  const historyPath = getHistoryPath();
+  try {
+    fs.mkdirSync(path.dirname(historyPath), { recursive: true });
+    fs.appendFileSync(historyPath, `${JSON.stringify(entry)}\n`);
+
+    if (fs.statSync(historyPath).size >= MAX_HISTORY_BYTES) {
+      const kept = readSessionHistory(MAX_HISTORY_ENTRIES);
+      const tmpPath = `${historyPath}.${process.pid}.tmp`;
+      fs.writeFileSync(tmpPath, kept.map((e) => JSON.stringify(e)).join('\n') + '\n');
+      fs.renameSync(tmpPath, historyPath);
+    }
+  } catch {
+    // History is best-effort
+  }
+}
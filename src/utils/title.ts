@@ -0,0 +1,78 @@
+/** Default maximum pane title width, in terminal columns */
+export const DEFAULT_TITLE_MAX_WIDTH = 30;
+
+const ELLIPSIS = '…';
+
+const segmenter = new Intl.Segmenter(undefined, { granularity: 'grapheme' });
+
+/** Ranges of East Asian wide / fullwidth code points */
+const WIDE_RANGES: Array<[number, number]> = [
+  [0x1100, 0x115f],
+  [0x2e80, 0x303e],
+  [0x3041, 0x33ff],
+  [0x3400, 0x4dbf],
+  [0x4e00, 0x9fff],
+  [0xa000, 0xa4cf],
+  [0xac00, 0xd7a3],
+  [0xf900, 0xfaff],
+  [0xfe30, 0xfe4f],
+  [0xff00, 0xff60],
+  [0xffe0, 0xffe6],
+  [0x20000, 0x3fffd],
+];
+
+function splitGraphemes(text: string): string[] {
+  return Array.from(segmenter.segment(text), (segment) => segment.segment);
+}
+
+/**
+ * Terminal columns taken by a single grapheme cluster.
+ */
+export function graphemeWidth(grapheme: string): number {
+  const codePoint = grapheme.codePointAt(0) ?? 0;
+  if (codePoint < 0x20 || (codePoint >= 0x7f && codePoint < 0xa0)) return 0;
+  if (/\p{Extended_Pictographic}/u.test(grapheme)) return 2;
+  if (/^\p{Mark}+$/u.test(grapheme)) return 0;
+  return WIDE_RANGES.some(([start, end]) => codePoint >= start && codePoint <= end) ? 2 : 1;
+}
+
+/**
+ * Terminal columns taken by a string.
+ */
+export function displayWidth(text: string): number {
+  return splitGraphemes(text).reduce((width, grapheme) => width + graphemeWidth(grapheme), 0);
+}
+
+function takeWidth(graphemes: string[], maxWidth: number, fromEnd: boolean): string {
+  const ordered = fromEnd ? [...graphemes].reverse() : graphemes;
+  const taken: string[] = [];
+  let width = 0;
+
+  for (const grapheme of ordered) {
+    const next = graphemeWidth(grapheme);
+    if (width + next > maxWidth) break;
+    taken.push(grapheme);
+    width += next;
+  }
+
+  return (fromEnd ? taken.reverse() : taken).join('');
+}
+
+/**
+ * Shortens a pane title to at most maxWidth terminal columns by cutting from
+ * the middle, so both the start and the distinguishing suffix (often an agent
+ * name or index) stay visible. Never splits a character or grapheme cluster.
+ */
+export function truncateTitle(title: string, maxWidth = DEFAULT_TITLE_MAX_WIDTH): string {
+  const trimmed = title.trim();
+  if (displayWidth(trimmed) <= maxWidth) return trimmed;
+  if (maxWidth < 1) return '';
+  if (maxWidth === 1) return ELLIPSIS;
+
+  const graphemes = splitGraphemes(trimmed);
+  const available = maxWidth - 1;
+  const tailWidth = Math.floor(available / 3);
+  const head = takeWidth(graphemes, available - tailWidth, false).trimEnd();
+  const tail = tailWidth > 0 ? takeWidth(graphemes, tailWidth, true).trimStart() : '';
+  return `${head}${ELLIPSIS}${tail}`;
+}
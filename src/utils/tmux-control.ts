@@ -0,0 +1,202 @@
+import { spawn, type ChildProcess } from 'node:child_process';
+import { log } from './logger';
+
+/**
+ * A persistent tmux control-mode client (`tmux -C`), so commands share one
+ * connection instead of forking a tmux process each.
+ *
+ * The client attaches with `no-output,ignore-size`, so it never receives pane
+ * output and never affects window sizes. Besides command replies, tmux sends
+ * notifications such as `%layout-change` and `%window-close` over the same
+ * connection.
+ */
+
+export interface ControlResult {
+  exitCode: number;
+  stdout: string;
+  stderr: string;
+  timedOut?: boolean;
+}
+
+export interface ControlNotification {
+  /** Notification name without the leading %, e.g. "layout-change" */
+  name: string;
+  args: string[];
+}
+
+interface ControlBlock {
+  error: boolean;
+  lines: string[];
+}
+
+interface PendingCommand {
+  resolve: (result: ControlResult) => void;
+  /** Timed out; its reply is still consumed to keep replies in order */
+  abandoned: boolean;
+  timer?: ReturnType<typeof setTimeout>;
+}
+
+const SAFE_ARG = /^[A-Za-z0-9_%@:.,/=+-]+$/;
+
+/**
+ * Quotes one argument for a tmux command line.
+ */
+export function quoteTmuxArg(arg: string): string {
+  if (SAFE_ARG.test(arg)) return arg;
+  const escaped = arg.replace(/[\\"$]/g, (c) => `\\${c}`).replace(/\n/g, '\\n');
+  return `"${escaped}"`;
+}
+
+/**
+ * Incremental parser for control-mode output. Replies are wrapped in
+ * `%begin`/`%end` (or `%error`) guards; anything else starting with % is a
+ * notification. Only replies to this client's own commands (flags 1) are
+ * reported as blocks.
+ */
+export class ControlParser {
+  private buffer = '';
+  private current: { lines: string[]; own: boolean } | null = null;
+
+  constructor(
+    private readonly onBlock: (block: ControlBlock) => void,
+    private readonly onNotification: (notification: ControlNotification) => void,
+  ) {}
+
+  feed(chunk: string): void {
+    this.buffer += chunk;
+    let newline = this.buffer.indexOf('\n');
+    while (newline !== -1) {
+      const line = this.buffer.slice(0, newline).replace(/\r$/, '');
+      this.buffer = this.buffer.slice(newline + 1);
+      this.handleLine(line);
+      newline = this.buffer.indexOf('\n');
+    }
+  }
+
+  private handleLine(line: string): void {
+    if (this.current) {
+      const guard = /^%(end|error) \d+ \d+ (\d+)$/.exec(line);
+      if (guard) {
+        const { lines, own } = this.current;
+        this.current = null;
+        if (own) this.onBlock({ error: guard[1] === 'error', lines });
+        return;
+      }
+      this.current.lines.push(line);
+      return;
+    }
+
+    const begin = /^%begin \d+ \d+ (\d+)$/.exec(line);
+    if (begin) {
+      this.current = { lines: [], own: (Number.parseInt(begin[1], 10) & 1) === 1 };
+      return;
+    }
+
+    if (line.startsWith('%')) {
+      const [name, ...args] = line.slice(1).split(' ');
+      this.onNotification({ name, args });
+    }
+  }
+}
+
+export class TmuxControlClient {
+  private proc: ChildProcess | null = null;
+  private readonly pending: PendingCommand[] = [];
+  private closed = false;
+
+  constructor(
+    private readonly tmux: string,
+    private readonly onNotification: (notification: ControlNotification) => void = () => {},
+  ) {}
+
+  get alive(): boolean {
+    return this.proc !== null && !this.closed;
+  }
+
+  /**
+   * Starts the client attached to the session of the pane we run in.
+   */
+  start(): boolean {
+    if (this.proc) return this.alive;
+
+    const target = process.env.TMUX_PANE;
+    const args = ['-C', 'attach-session', ...(target ? ['-t', target] : []), '-f', 'no-output,ignore-size'];
+
+    try {
+      this.proc = spawn(this.tmux, args, { stdio: ['pipe', 'pipe', 'ignore'] });
+    } catch (err) {
+      log('[tmux-control] failed to start', { error: String(err) });
+      this.closed = true;
+      return false;
+    }
+
+    const parser = new ControlParser(
+      (block) => this.settleNext(block),
+      (notification) => this.onNotification(notification),
+    );
+    this.proc.stdout?.setEncoding('utf-8');
+    this.proc.stdout?.on('data', (chunk: string) => parser.feed(chunk));
+    this.proc.on('error', (err) => this.fail(String(err)));
+    this.proc.on('close', () => this.fail('client exited'));
+    // Must not keep the plugin process alive on its own
+    this.proc.unref();
+
+    log('[tmux-control] started', { args });
+    return true;
+  }
+
+  /**
+   * Runs one tmux command (no `;` command lists) and resolves with its output.
+   */
+  run(args: string[], timeoutMs: number): Promise<ControlResult> {
+    if (!this.alive || !this.proc?.stdin) {
+      return Promise.resolve({ exitCode: 1, stdout: '', stderr: 'control client not running' });
+    }
+
+    return new Promise((resolve) => {
+      const command: PendingCommand = { resolve, abandoned: false };
+      if (timeoutMs > 0) {
+        command.timer = setTimeout(() => {
+          command.abandoned = true;
+          log('[tmux-control] command timed out', { args, timeoutMs });
+          resolve({ exitCode: 1, stdout: '', stderr: '', timedOut: true });
+        }, timeoutMs);
+      }
+      this.pending.push(command);
+      this.proc!.stdin!.write(`${args.map(quoteTmuxArg).join(' ')}\n`);
+    });
+  }
+
+  close(): void {
+    if (this.closed) return;
+    this.proc?.stdin?.end();
+    this.fail('closed');
+  }
+
+  private settleNext(block: ControlBlock): void {
+    const command = this.pending.shift();
+    if (!command) return;
+    clearTimeout(command.timer);
+    if (command.abandoned) return;
+
+    const text = block.lines.length > 0 ? `${block.lines.join('\n')}\n` : '';
+    command.resolve(
+      block.error
+        ? { exitCode: 1, stdout: '', stderr: text }
+        : { exitCode: 0, stdout: text, stderr: '' },
+    );
+  }
+
+  private fail(reason: string): void {
+    if (!this.closed) {
+      log('[tmux-control] stopped', { reason, pending: this.pending.length });
+    }
+    this.closed = true;
+    for (const command of this.pending.splice(0)) {
+      clearTimeout(command.timer);
+      if (!command.abandoned) {
+        command.resolve({ exitCode: 1, stdout: '', stderr: `control client ${reason}` });
+      }
+    }
+  }
+}
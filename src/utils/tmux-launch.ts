@@ -0,0 +1,40 @@
+import { basename } from 'node:path';
+
+/**
+ * Expands `{project}` in a tmux_session_name template to the project
+ * directory's name, reduced to characters that are safe in tmux targets
+ * (e.g. "~/code/My App" gives "My-App").
+ */
+export function expandSessionName(template: string, projectDir: string): string {
+  const project =
+    basename(projectDir)
+      .replace(/[^\w-]+/g, '-')
+      .replace(/^-+|-+$/g, '') || 'project';
+  return template.replaceAll('{project}', project);
+}
+
+/**
+ * tmux arguments for starting opencode from outside tmux. With a session
+ * name, an existing session of that name gets a new window (and is attached)
+ * instead of every launch creating another numbered session. Detached, nothing
+ * is attached and a new session's name is printed, for `tmux attach` later.
+ */
+export function tmuxLaunchArgs(
+  shellCommand: string,
+  sessionName: string | undefined,
+  sessionExists: boolean,
+  detached = false,
+): string[] {
+  const named = sessionName ? ['-s', sessionName] : [];
+  if (!sessionName || !sessionExists) {
+    return detached
+      ? ['new-session', '-d', '-P', '-F', '#{session_name}', ...named, shellCommand]
+      : ['new-session', ...named, shellCommand];
+  }
+
+  // `=` makes tmux match the name exactly rather than as a prefix
+  const target = `=${sessionName}`;
+  return detached
+    ? ['new-window', '-d', '-t', `${target}:`, shellCommand]
+    : ['new-window', '-t', `${target}:`, shellCommand, ';', 'attach-session', '-t', target];
+}
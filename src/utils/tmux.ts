@@ -1,46 +1,211 @@
 import { spawn } from 'node:child_process';
-import type { TmuxConfig, TmuxLayout } from '../config';
 import {
+  AUTO_LAYOUT,
+  TmuxLayoutSchema,
+  type FocusOnSpawn,
+  type TmuxConfig,
+  type TmuxLayout,
+} from '../config';
+import type { SpawnFailureReason } from '../types';
+import {
+  autoLayoutForSize,
   buildMainVerticalMultiColumnLayoutString,
   groupAgentsByColumn,
+  groupIntoGridColumns,
+  isTmuxLayoutString,
+  mainPaneCells,
   mainPanePercentForColumns,
+  maxAgentsPerColumnForHeight,
+  narrowestAgentColumnWidth,
 } from '../layout';
+import { computeBackoffMs } from './backoff';
+import { sleep } from './clock';
+import { requestJson } from './http';
 import { log } from './logger';
-import { 
-  getProcessChildren, 
-  getProcessCommand, 
-  safeKill, 
-  waitForProcessExit 
+import { truncateTitle } from './title';
+import { TmuxControlClient, type ControlNotification } from './tmux-control';
+import {
+  DEFAULT_TERMINATE_POLICY,
+  getProcessChildren,
+  getProcessCommand,
+  terminatePolicyFromConfig,
+  terminateProcess,
+  type ProcessEntry,
 } from './process';
 
-const BASE_BACKOFF_MS = 250;
+/** First wait before checking whether a zoomed window was unzoomed; doubles per check */
+const ZOOM_RETRY_BASE_MS = 1000;
+const ZOOM_RETRY_MAX_MS = 30_000;
+/** Checks before giving up; the next spawn or close lays the window out again */
+const ZOOM_RETRY_MAX_ATTEMPTS = 8;
+const DEFAULT_TMUX_COMMAND_TIMEOUT_MS = 5000;
+
+/** Pane user options that mark a pane as an opentmux agent pane */
+export const PANE_SESSION_OPTION = '@opentmux_session';
+export const PANE_SERVER_OPTION = '@opentmux_server';
+
+/** Pane user option marking an empty grid slot (layout_mode "grid") */
+export const PANE_PLACEHOLDER_OPTION = '@opentmux_placeholder';
+
+const PLACEHOLDER_TITLE = 'waiting for agent';
+// Run through sh so it works whatever the user's default-shell is
+const PLACEHOLDER_COMMAND =
+  "sh -c 'printf \"\\n  waiting for agent...\\n\"; while :; do sleep 3600; done'";
+
+/** Session user option set by `opentmux pause`; new agents wait for resume while it is on */
+export const PAUSED_OPTION = '@opentmux_paused';
+
+/** Session user option set by `opentmux queue flush`: agents queued before this time (ms) get no pane */
+export const QUEUE_FLUSHED_OPTION = '@opentmux_queue_flushed_at';
+
+/** Session user option set by `opentmux session close-all`, as JSON (see CloseAllRequest) */
+export const CLOSE_ALL_OPTION = '@opentmux_close_all';
+
+/** Window user option naming the group a window belongs to (group_by_parent, window_rules) */
+export const GROUP_WINDOW_OPTION = '@opentmux_group';
+
+/** Session user option holding the status-line summary, for `#{@opentmux_status}` */
+export const STATUS_OPTION = '@opentmux_status';
+
+/** Session user option where the plugin publishes its build info, as JSON */
+export const VERSION_OPTION = '@opentmux_version';
+
+/** Session user option listing spawns that have no pane yet, as JSON, for `opentmux queue` */
+export const QUEUE_OPTION = '@opentmux_queue';
+
+/**
+ * Escapes text for tmux arguments that are format-expanded, such as
+ * display-message text, window names and pane titles, so `#(...)` in a
+ * session title prints instead of running a shell command.
+ */
+export function escapeTmuxFormat(text: string): string {
+  return text.replace(/#/g, '##');
+}
 
 let tmuxPath: string | null = null;
 let tmuxChecked = false;
 
 let storedConfig: TmuxConfig | null = null;
 
+let zoomRetryTimer: ReturnType<typeof setTimeout> | null = null;
+let zoomRetryAttempts = 0;
+/** A layout was skipped because the window was zoomed and hasn't run since */
+let layoutDeferredForZoom = false;
+/** Set while the zoom retry timer calls applyTmuxLayout */
+let retryingZoomedLayout = false;
+
+/** What `layout: "auto"` last resolved to for opencode's window */
+let lastAutoLayout: TmuxLayout | null = null;
+
 let serverAvailable: boolean | null = null;
 let serverCheckUrl: string | null = null;
 
+/** The tmux session and pane opencode was started in, recorded at plugin init */
+let ownSessionId: string | null = null;
+let ownPaneId: string | null = null;
+
 interface SpawnResult {
   exitCode: number;
   stdout: string;
   stderr: string;
+  /** The command was killed after exceeding its timeout */
+  timedOut?: boolean;
+}
+
+let controlClient: TmuxControlClient | null = null;
+const notificationListeners = new Set<(notification: ControlNotification) => void>();
+
+/**
+ * Subscribes to tmux control-mode notifications (e.g. `layout-change`).
+ * Only delivered while tmux_control_mode is on. Returns an unsubscribe function.
+ */
+export function onTmuxNotification(
+  listener: (notification: ControlNotification) => void,
+): () => void {
+  notificationListeners.add(listener);
+  return () => notificationListeners.delete(listener);
+}
+
+/**
+ * Whether a control-mode client is connected, so onTmuxNotification
+ * listeners hear about layout changes as they happen.
+ */
+export function hasTmuxControlClient(): boolean {
+  return controlClient?.alive ?? false;
+}
+
+/**
+ * Returns the control-mode client for a single tmux command, or null when the
+ * command should run as its own process: control mode off, not tmux itself,
+ * flags like -V, command lists, or abortable commands.
+ */
+function controlClientFor(command: string[], signal?: AbortSignal): TmuxControlClient | null {
+  if (!storedConfig?.tmux_control_mode || !isInsideTmux()) return null;
+  if (!tmuxPath || command[0] !== tmuxPath) return null;
+  if (signal || command.length < 2 || command[1].startsWith('-') || command.includes(';')) {
+    return null;
+  }
+
+  if (!controlClient) {
+    controlClient = new TmuxControlClient(tmuxPath, (notification) => {
+      applyLayoutAfterUnzoom(notification);
+      for (const listener of notificationListeners) listener(notification);
+    });
+    controlClient.start();
+  }
+  // A dead client is not restarted; commands fall back to subprocesses
+  return controlClient.alive ? controlClient : null;
+}
+
+/**
+ * Detaches the control-mode client, if one was started.
+ */
+export function closeTmuxControlClient(): void {
+  controlClient?.close();
+  controlClient = null;
 }
 
 async function spawnAsync(
   command: string[],
-  options?: { ignoreOutput?: boolean },
+  options?: { ignoreOutput?: boolean; signal?: AbortSignal; timeoutMs?: number },
+): Promise<SpawnResult> {
+  const timeoutMs =
+    options?.timeoutMs ?? storedConfig?.tmux_command_timeout_ms ?? DEFAULT_TMUX_COMMAND_TIMEOUT_MS;
+
+  const client = controlClientFor(command, options?.signal);
+  if (client) {
+    const result = await client.run(command.slice(1), timeoutMs);
+    return options?.ignoreOutput ? { ...result, stdout: '', stderr: '' } : result;
+  }
+
+  return spawnProcess(command, { ...options, timeoutMs });
+}
+
+async function spawnProcess(
+  command: string[],
+  options: { ignoreOutput?: boolean; signal?: AbortSignal; timeoutMs: number },
 ): Promise<SpawnResult> {
   return new Promise((resolve) => {
     const [cmd, ...args] = command;
-    const proc = spawn(cmd, args, { stdio: 'pipe' });
+    // An aborted signal kills the child; the 'error' handler resolves as a failure
+    const proc = spawn(cmd, args, { stdio: 'pipe', signal: options.signal });
+
+    // A wedged tmux server must not block the caller forever
+    const { timeoutMs } = options;
+    let timedOut = false;
+    const timer =
+      timeoutMs > 0
+        ? setTimeout(() => {
+            timedOut = true;
+            log('[tmux] spawnAsync: command timed out, killing', { command, timeoutMs });
+            proc.kill('SIGKILL');
+          }, timeoutMs)
+        : undefined;
 
     let stdout = '';
     let stderr = '';
 
-    if (!options?.ignoreOutput) {
+    if (!options.ignoreOutput) {
       proc.stdout?.on('data', (data: Buffer) => {
         stdout += data.toString();
       });
@@ -50,18 +215,22 @@ async function spawnAsync(
     }
 
     proc.on('close', (code) => {
+      clearTimeout(timer);
       resolve({
-        exitCode: code ?? 1,
+        exitCode: timedOut ? 1 : code ?? 1,
         stdout,
         stderr,
+        timedOut,
       });
     });
 
     proc.on('error', () => {
+      clearTimeout(timer);
       resolve({
         exitCode: 1,
         stdout,
         stderr,
+        timedOut,
       });
     });
   });
@@ -77,18 +246,7 @@ async function isServerRunning(serverUrl: string): Promise<boolean> {
   const maxAttempts = 2;
 
   for (let attempt = 1; attempt <= maxAttempts; attempt++) {
-    const controller = new AbortController();
-    const timeout = setTimeout(() => controller.abort(), timeoutMs);
-
-    let response: Response | null = null;
-    try {
-      response = await fetch(healthUrl, { signal: controller.signal }).catch(
-        () => null,
-      );
-    } finally {
-      clearTimeout(timeout);
-    }
-
+    const response = await requestJson(healthUrl, { timeoutMs });
     const available = response?.ok ?? false;
     if (available) {
       serverCheckUrl = serverUrl;
@@ -168,13 +326,95 @@ export function isInsideTmux(): boolean {
   return !!process.env.TMUX;
 }
 
+/** The pane opencode runs in: $TMUX_PANE, or the one recorded at init */
+function agentPane(): string | null {
+  return process.env.TMUX_PANE ?? ownPaneId;
+}
+
+/**
+ * `-t` arguments naming the window opencode runs in, through its own pane.
+ * Without them tmux picks whichever window is active in the session, which
+ * may have nothing to do with opencode.
+ */
+function agentWindowTarget(): string[] {
+  const pane = agentPane();
+  return pane ? ['-t', pane] : [];
+}
+
+/**
+ * `-t` arguments naming the tmux session opentmux was launched in, for
+ * session options. With several sessions attached, the default is whichever
+ * session tmux considers current.
+ */
+function agentSessionTarget(): string[] {
+  return ownSessionId ? ['-t', ownSessionId] : agentWindowTarget();
+}
+
+/**
+ * Records the tmux session and pane opencode runs in, so every later command
+ * targets them even if the user switches to another session meanwhile.
+ */
+export async function recordTmuxSession(): Promise<string | null> {
+  const tmux = await getTmuxPath();
+  if (!tmux) return null;
+
+  const pane = process.env.TMUX_PANE;
+  const result = await spawnAsyncFn([
+    tmux,
+    'display-message',
+    '-p',
+    ...(pane ? ['-t', pane] : []),
+    '#{session_id} #{pane_id}',
+  ]);
+  const [sessionId, paneId] = result.stdout.trim().split(' ');
+  if (result.exitCode !== 0 || !sessionId?.startsWith('$')) {
+    log('[tmux] recordTmuxSession: could not read the current session', {
+      stderr: result.stderr.trim(),
+    });
+    return null;
+  }
+
+  ownSessionId = sessionId;
+  ownPaneId = paneId ?? null;
+  log('[tmux] recordTmuxSession: recorded', { sessionId, paneId });
+  return sessionId;
+}
+
+export function resetTmuxSession(): void {
+  ownSessionId = null;
+  ownPaneId = null;
+}
+
+/**
+ * Value for tmux's main-pane-width/main-pane-height option: main_pane_width
+ * or main_pane_height in main_pane_size_unit when set, else main_pane_size percent.
+ */
+function mainPaneSizeOption(layout: TmuxLayout, config: TmuxConfig): string {
+  const absolute = layout === 'main-horizontal' ? config.main_pane_height : config.main_pane_width;
+  if (absolute !== undefined) {
+    return config.main_pane_size_unit === 'cells' ? `${absolute}` : `${Math.min(absolute, 100)}%`;
+  }
+  const percent =
+    layout === 'main-vertical' ? mainPanePercentForColumns(1) : (config.main_pane_size ?? 60);
+  return `${percent}%`;
+}
+
+/**
+ * Main pane width in cells for the multi-column and grid layouts, when
+ * main_pane_width is configured.
+ */
+function configuredMainPaneWidth(config: TmuxConfig, windowWidth: number): number | undefined {
+  if (config.main_pane_width === undefined) return undefined;
+  return mainPaneCells(config.main_pane_width, config.main_pane_size_unit ?? 'percent', windowWidth);
+}
+
 async function applyLayout(
   tmux: string,
   layout: TmuxLayout,
-  mainPaneSize: number,
+  mainPaneSize: string,
 ): Promise<void> {
   try {
-    await spawnAsyncFn([tmux, 'select-layout', layout]);
+    await spawnAsyncFn([tmux, 'select-layout', ...agentWindowTarget(), layout]);
 
     if (layout === 'main-horizontal' || layout === 'main-vertical') {
       const sizeOption =
@@ -183,10 +423,11 @@ async function applyLayout(
       await spawnAsyncFn([
         tmux,
         'set-window-option',
+        ...agentWindowTarget(),
         sizeOption,
-        `${mainPaneSize}%`,
+        mainPaneSize,
       ]);
-      await spawnAsyncFn([tmux, 'select-layout', layout]);
+      await spawnAsyncFn([tmux, 'select-layout', ...agentWindowTarget(), layout]);
     }
 
     log('[tmux] applyLayout: applied', { layout, mainPaneSize });
@@ -196,18 +437,27 @@ async function applyLayout(
 }
 
 async function getCurrentPaneId(tmux: string): Promise<string | null> {
+  const pane = agentPane();
+  if (pane) return pane;
   const result = await spawnAsyncFn([tmux, 'display-message', '-p', '#{pane_id}']);
   const paneId = result.stdout.trim();
   return paneId ? paneId : null;
 }
 
+interface WindowSize {
+  width: number;
+  height: number;
+}
+
 async function getWindowSize(
   tmux: string,
-): Promise<{ width: number; height: number } | null> {
+  target: string[] = agentWindowTarget(),
+): Promise<WindowSize | null> {
   const result = await spawnAsyncFn([
     tmux,
     'display-message',
     '-p',
+    ...target,
     '#{window_width} #{window_height}',
   ]);
   const parts = result.stdout.trim().split(/\s+/);
@@ -219,7 +469,7 @@ async function getWindowSize(
 }
 
 async function listPaneIds(tmux: string): Promise<string[]> {
-  const result = await spawnAsyncFn([tmux, 'list-panes', '-F', '#{pane_id}']);
+  const result = await spawnAsyncFn([tmux, 'list-panes', ...agentWindowTarget(), '-F', '#{pane_id}']);
   return result.stdout
     .split('\n')
     .map((l) => l.trim())
@@ -232,30 +482,132 @@ function paneWpId(paneId: string): number | null {
   return Number.isFinite(n) ? n : null;
 }
 
+interface PaneMinimums {
+  height: number;
+  width: number;
+}
+
+/**
+ * Grows agent panes that ended up below the configured minimum size.
+ * tmux takes the space from neighbouring panes.
+ */
+async function enforceMinPaneSizes(tmux: string, minimums: PaneMinimums): Promise<void> {
+  if (minimums.height <= 0 && minimums.width <= 0) return;
+
+  const currentPaneId = await getCurrentPaneId(tmux);
+  const result = await spawnAsyncFn([
+    tmux,
+    'list-panes',
+    ...agentWindowTarget(),
+    '-F',
+    '#{pane_id} #{pane_width} #{pane_height}',
+  ]);
+
+  for (const line of result.stdout.split('\n')) {
+    const [paneId, widthStr, heightStr] = line.trim().split(/\s+/);
+    if (!paneId || paneId === currentPaneId) continue;
+
+    const width = Number(widthStr);
+    const height = Number(heightStr);
+
+    if (minimums.height > 0 && height < minimums.height) {
+      await spawnAsyncFn([tmux, 'resize-pane', '-t', paneId, '-y', String(minimums.height)]);
+    }
+    if (minimums.width > 0 && width < minimums.width) {
+      await spawnAsyncFn([tmux, 'resize-pane', '-t', paneId, '-x', String(minimums.width)]);
+    }
+  }
+}
+
+/**
+ * What config.layout names: a builtin layout, or the tmux layout string of a
+ * raw or preset custom layout. `auto` picks a builtin from the window size.
+ * An unknown preset, or `auto` without a size, falls back to main-vertical.
+ */
+function resolveLayout(
+  config: TmuxConfig,
+  size: WindowSize | null,
+): { builtin: TmuxLayout } | { custom: string } {
+  const layout = config.layout ?? 'main-vertical';
+  if (layout === AUTO_LAYOUT) {
+    if (!size) return { builtin: 'main-vertical' };
+    return {
+      builtin: autoLayoutForSize(
+        size.width,
+        size.height,
+        config.auto_layout_min_width ?? 160,
+        config.auto_layout_min_height ?? 40,
+      ),
+    };
+  }
+  const builtin = TmuxLayoutSchema.safeParse(layout);
+  if (builtin.success) return { builtin: builtin.data };
+  if (isTmuxLayoutString(layout)) return { custom: layout };
+  const preset = config.layouts?.[layout];
+  return preset ? { custom: preset } : { builtin: 'main-vertical' };
+}
+
+/**
+ * Applies a custom layout string to a window. tmux rejects it unless the
+ * window has exactly as many panes as the layout has cells; it rescales the
+ * layout to the window's size itself.
+ */
+async function applyCustomLayout(tmux: string, target: string[], layout: string): Promise<boolean> {
+  const result = await spawnAsyncFn([tmux, 'select-layout', ...target, layout]);
+  if (result.exitCode === 0) return true;
+
+  log('[tmux] applyCustomLayout: tmux rejected the layout, using main-vertical', {
+    stderr: result.stderr.trim(),
+  });
+  await spawnAsyncFn([tmux, 'select-layout', ...target, 'main-vertical']);
+  return false;
+}
+
+type MultiColumnOutcome = 'applied' | 'failed' | 'too-small';
+
 async function tryApplyMainVerticalMultiColumnLayout(
   tmux: string,
   maxAgentsPerColumn: number,
-): Promise<boolean> {
+  minimums: PaneMinimums,
+  config: TmuxConfig,
+): Promise<MultiColumnOutcome> {
   const size = await getWindowSize(tmux);
-  if (!size) return false;
+  if (!size) return 'failed';
 
   const currentPaneId = await getCurrentPaneId(tmux);
-  if (!currentPaneId) return false;
+  if (!currentPaneId) return 'failed';
 
   const panes = await listPaneIds(tmux);
-  if (panes.length < 2) return false;
+  if (panes.length < 2) return 'failed';
 
   const mainPaneId = panes.includes(currentPaneId) ? currentPaneId : (panes[0] ?? currentPaneId);
   const agentPaneIds = panes.filter((id) => id !== mainPaneId);
-  const columns = groupAgentsByColumn(agentPaneIds, maxAgentsPerColumn);
+  const perColumn = maxAgentsPerColumnForHeight(size.height, maxAgentsPerColumn, minimums.height);
+  const columns = groupAgentsByColumn(agentPaneIds, perColumn);
   
   if (columns.length === 0) {
-    return false;
+    return 'failed';
   }
 
   const mainPanePercent = mainPanePercentForColumns(columns.length);
+  const mainPaneWidth = configuredMainPaneWidth(config, size.width);
+  const columnWidth = narrowestAgentColumnWidth(
+    size.width,
+    mainPanePercent,
+    columns.length,
+    mainPaneWidth,
+  );
+  if (minimums.width > 0 && columnWidth < minimums.width) {
+    log('[tmux] applyTmuxLayout: agent columns would be too narrow', {
+      columns: columns.length,
+      columnWidth,
+      minWidth: minimums.width,
+    });
+    return 'too-small';
+  }
+
   const mainWp = paneWpId(mainPaneId);
-  if (mainWp === null) return false;
+  if (mainWp === null) return 'failed';
 
   const wpColumns: number[][] = [];
   for (const col of columns) {
@@ -271,7 +623,7 @@ async function tryApplyMainVerticalMultiColumnLayout(
     }
   }
   
-  if (wpColumns.length === 0) return false;
+  if (wpColumns.length === 0) return 'failed';
 
   const layoutString = buildMainVerticalMultiColumnLayoutString({
     windowWidth: size.width,
@@ -279,32 +631,308 @@ async function tryApplyMainVerticalMultiColumnLayout(
     mainPaneWpId: mainWp,
     columns: wpColumns,
     mainPanePercent,
+    mainPaneWidth,
   });
 
-  const result = await spawnAsyncFn([tmux, 'select-layout', layoutString]);
+  const result = await spawnAsyncFn([tmux, 'select-layout', ...agentWindowTarget(), layoutString]);
   if (result.exitCode === 0) {
     log('[tmux] applyTmuxLayout: applied custom layout', {
       columns: wpColumns.length,
       mainPanePercent,
+      mainPaneWidth,
     });
-    return true;
+    return 'applied';
   }
 
   log('[tmux] applyTmuxLayout: custom layout failed', {
     exitCode: result.exitCode,
     stderr: result.stderr.trim(),
   });
-  return false;
+  return 'failed';
+}
+
+/**
+ * Where agents go once opencode's window can't fit another one at
+ * min_agent_pane_width, instead of squeezing everything into a tiled layout.
+ */
+const OVERFLOW_GROUP: PaneGroup = { key: 'overflow', name: 'agents' };
+
+/**
+ * Whether one more agent fits in opencode's window at the configured
+ * minimum pane size. Only main-vertical puts agents side by side in columns,
+ * so other layouts always have room.
+ */
+async function agentWindowHasRoom(tmux: string, config: TmuxConfig): Promise<boolean> {
+  const minWidth = config.min_agent_pane_width ?? 0;
+  if (minWidth <= 0) return true;
+
+  const size = await getWindowSize(tmux);
+  if (!size) return true;
+  const resolved = resolveLayout(config, size);
+  if (!('builtin' in resolved) || resolved.builtin !== 'main-vertical') return true;
+
+  // The window's panes, opencode's included, are its agents once one more is added
+  const agents = (await listPaneIds(tmux)).length;
+  const perColumn = maxAgentsPerColumnForHeight(
+    size.height,
+    config.max_agents_per_column ?? 3,
+    config.min_agent_pane_height ?? 0,
+  );
+  const columns = Math.ceil(agents / perColumn);
+  const columnWidth = narrowestAgentColumnWidth(
+    size.width,
+    mainPanePercentForColumns(columns),
+    columns,
+    configuredMainPaneWidth(config, size.width),
+  );
+  return columnWidth >= minWidth;
+}
+
+/**
+ * Windows holding a group of agents (group_by_parent, window_rules, or the
+ * overflow window), in the tmux session opencode runs in.
+ */
+async function listGroupWindows(
+  tmux: string,
+): Promise<Array<{ windowId: string; key: string }>> {
+  const result = await spawnAsyncFn([
+    tmux,
+    'list-windows',
+    ...agentWindowTarget(),
+    '-F',
+    `#{window_id}\t#{${GROUP_WINDOW_OPTION}}`,
+  ]);
+  if (result.exitCode !== 0) return [];
+
+  return result.stdout
+    .split('\n')
+    .map((line) => line.split('\t'))
+    .filter(([windowId, key]) => windowId && key)
+    .map(([windowId, key]) => ({ windowId, key }));
+}
+
+/**
+ * Lays out each group window with the configured tmux layout. Group windows
+ * have no opencode pane, so the first agent is the main pane.
+ */
+async function applyGroupLayouts(tmux: string, config: TmuxConfig): Promise<void> {
+  const auto = config.layout === AUTO_LAYOUT;
+  for (const { windowId } of await listGroupWindows(tmux)) {
+    const size = auto ? await getWindowSize(tmux, ['-t', windowId]) : null;
+    const layout = resolveLayout(config, size);
+    if ('custom' in layout) {
+      await applyCustomLayout(tmux, ['-t', windowId], layout.custom);
+    } else {
+      await spawnAsyncFn([tmux, 'select-layout', '-t', windowId, layout.builtin]);
+    }
+  }
+}
+
+interface GridSlot {
+  paneId: string;
+  placeholder: boolean;
+}
+
+/**
+ * Lists the panes of the agents area (every pane but the main one) in slot
+ * order: column by column, top to bottom.
+ */
+async function listGridSlots(tmux: string): Promise<GridSlot[] | null> {
+  const mainPaneId = await getCurrentPaneId(tmux);
+  if (!mainPaneId) return null;
+
+  const result = await spawnAsyncFn([
+    tmux,
+    'list-panes',
+    ...agentWindowTarget(),
+    '-F',
+    `#{pane_id}\t#{pane_left}\t#{pane_top}\t#{${PANE_PLACEHOLDER_OPTION}}`,
+  ]);
+  if (result.exitCode !== 0) return null;
+
+  return result.stdout
+    .split('\n')
+    .map((line) => line.trim().split('\t'))
+    .filter(([paneId]) => paneId && paneId !== mainPaneId)
+    .map(([paneId, left, top, placeholder]) => ({
+      paneId,
+      left: Number(left),
+      top: Number(top),
+      placeholder: placeholder === '1',
+    }))
+    .sort((a, b) => a.left - b.left || a.top - b.top)
+    .map(({ paneId, placeholder }) => ({ paneId, placeholder }));
+}
+
+function gridCapacity(config: TmuxConfig): number {
+  return (config.grid_rows ?? 2) * (config.grid_columns ?? 2);
+}
+
+async function markPlaceholder(tmux: string, paneId: string): Promise<void> {
+  await spawnAsyncFn(
+    [
+      tmux,
+      'set-option', '-p', '-t', paneId, PANE_PLACEHOLDER_OPTION, '1',
+      ';',
+      'select-pane', '-t', paneId, '-T', PLACEHOLDER_TITLE,
+    ],
+    { ignoreOutput: true },
+  );
+}
+
+/**
+ * Lays the agents area out as columns of grid_rows slots next to the main pane.
+ * Slots beyond the grid's capacity go into extra columns.
+ */
+async function applyGridLayout(tmux: string, config: TmuxConfig): Promise<boolean> {
+  const size = await getWindowSize(tmux);
+  const mainPaneId = await getCurrentPaneId(tmux);
+  const slots = await listGridSlots(tmux);
+  if (!size || !mainPaneId || !slots || slots.length === 0) return false;
+
+  const mainWp = paneWpId(mainPaneId);
+  const slotWps = slots.map((slot) => paneWpId(slot.paneId));
+  if (mainWp === null || slotWps.some((wp) => wp === null)) return false;
+
+  const layoutString = buildMainVerticalMultiColumnLayoutString({
+    windowWidth: size.width,
+    windowHeight: size.height,
+    mainPaneWpId: mainWp,
+    columns: groupIntoGridColumns(slotWps as number[], config.grid_rows ?? 2),
+    mainPanePercent: config.main_pane_size ?? 60,
+    mainPaneWidth: configuredMainPaneWidth(config, size.width),
+  });
+
+  const result = await spawnAsyncFn([tmux, 'select-layout', ...agentWindowTarget(), layoutString]);
+  log('[tmux] applyGridLayout', {
+    slots: slots.length,
+    exitCode: result.exitCode,
+    stderr: result.stderr.trim(),
+  });
+  return result.exitCode === 0;
+}
+
+/**
+ * Creates placeholder panes for any missing grid slots and returns the slots.
+ * Only the first spawn (or one after the user killed a slot) pays for a
+ * re-layout; later spawns and closes reuse slots in place.
+ */
+async function ensureGridSlots(tmux: string, config: TmuxConfig): Promise<GridSlot[]> {
+  const slots = await listGridSlots(tmux);
+  if (!slots) return [];
+
+  const missing = gridCapacity(config) - slots.length;
+  if (missing <= 0) return slots;
+
+  for (let i = 0; i < missing; i++) {
+    const split = () =>
+      spawnAsyncFn([
+        tmux,
+        'split-window',
+        ...agentWindowTarget(),
+        '-d',
+        '-P',
+        '-F',
+        '#{pane_id}',
+        PLACEHOLDER_COMMAND,
+      ]);
+    let result = await split();
+    if (result.exitCode !== 0) {
+      // No room left to split: spread the panes out and try once more
+      await spawnAsyncFn([tmux, 'select-layout', ...agentWindowTarget(), 'tiled']);
+      result = await split();
+    }
+
+    const paneId = result.stdout.trim();
+    if (result.exitCode !== 0 || !paneId) {
+      log('[tmux] ensureGridSlots: could not create slot', { stderr: result.stderr.trim() });
+      break;
+    }
+    await markPlaceholder(tmux, paneId);
+  }
+
+  await applyGridLayout(tmux, config);
+  return (await listGridSlots(tmux)) ?? [];
+}
+
+/**
+ * Kills the placeholder panes of the grid, e.g. when the plugin shuts down.
+ */
+export async function removeGridPlaceholders(): Promise<void> {
+  const tmux = await getTmuxPath();
+  if (!tmux) return;
+
+  const slots = await listGridSlots(tmux);
+  for (const slot of slots ?? []) {
+    if (slot.placeholder) {
+      await spawnAsyncFn([tmux, 'kill-pane', '-t', slot.paneId], { ignoreOutput: true });
+    }
+  }
+}
+
+async function isWindowZoomed(tmux: string): Promise<boolean> {
+  const result = await spawnAsyncFn([
+    tmux,
+    'display-message',
+    '-p',
+    ...agentWindowTarget(),
+    '#{window_zoomed_flag}',
+  ]);
+  return result.stdout.trim() === '1';
+}
+
+/**
+ * Re-runs applyTmuxLayout once the window may have been unzoomed. With a
+ * control-mode client tmux reports the unzoom as a layout change, so nothing
+ * polls; otherwise checks back off and stop after ZOOM_RETRY_MAX_ATTEMPTS.
+ * Only one retry is pending at a time; later requests collapse into it.
+ */
+function scheduleZoomRetry(isRetry: boolean): void {
+  layoutDeferredForZoom = true;
+  if (!isRetry) zoomRetryAttempts = 0;
+  if (zoomRetryTimer || controlClient?.alive) return;
+
+  if (zoomRetryAttempts >= ZOOM_RETRY_MAX_ATTEMPTS) {
+    log('[tmux] scheduleZoomRetry: window still zoomed, waiting for the next spawn or close');
+    return;
+  }
+  const delayMs = Math.min(ZOOM_RETRY_MAX_MS, ZOOM_RETRY_BASE_MS * 2 ** zoomRetryAttempts);
+  zoomRetryAttempts++;
+
+  zoomRetryTimer = setTimeout(() => {
+    zoomRetryTimer = null;
+    retryingZoomedLayout = true;
+    void applyTmuxLayout();
+  }, delayMs);
+  zoomRetryTimer.unref?.();
+}
+
+/**
+ * Runs a layout deferred for zoom when tmux reports an unzoomed layout
+ * change (`%layout-change <window> <layout> <visible-layout> <flags>`).
+ */
+function applyLayoutAfterUnzoom(notification: ControlNotification): void {
+  if (!layoutDeferredForZoom || notification.name !== 'layout-change') return;
+  if ((notification.args[3] ?? '').includes('Z')) return;
+  void applyTmuxLayout();
 }
 
 /**
  * Applies tmux layout using the stored config.
  * Exported for deferred layout after spawn queue drains.
  * Falls back to tmux built-in layout on failure.
+ * While the window is zoomed the layout is deferred (unless disabled in config),
+ * so the user isn't unzoomed mid-edit. `opentmux layout` passes its own config,
+ * since the launcher never spawned a pane.
  */
-export async function applyTmuxLayout(): Promise<void> {
-  if (!storedConfig) {
-    log('[tmux] applyTmuxLayout: no stored config, skipping');
+export async function applyTmuxLayout(
+  config: TmuxConfig | null = storedConfig,
+): Promise<void> {
+  const isZoomRetry = retryingZoomedLayout;
+  retryingZoomedLayout = false;
+
+  if (!config) {
+    log('[tmux] applyTmuxLayout: no config, skipping');
     return;
   }
 
@@ -314,37 +942,162 @@ export async function applyTmuxLayout(): Promise<void> {
     return;
   }
 
-  const layout = storedConfig.layout ?? 'main-vertical';
-  const maxAgentsPerColumn = storedConfig.max_agents_per_column ?? 3;
-  const mainPaneSize =
-    layout === 'main-vertical' ? mainPanePercentForColumns(1) : (storedConfig.main_pane_size ?? 60);
+  if ((config.defer_layout_when_zoomed ?? true) && (await isWindowZoomed(tmux))) {
+    log('[tmux] applyTmuxLayout: window zoomed, deferring layout');
+    scheduleZoomRetry(isZoomRetry);
+    return;
+  }
+  layoutDeferredForZoom = false;
+
+  await applyGroupLayouts(tmux, config);
+
+  if (config.layout_mode === 'grid') {
+    if (await applyGridLayout(tmux, config)) return;
+    log('[tmux] applyTmuxLayout: grid layout failed, using tiled');
+    await spawnAsyncFn([tmux, 'select-layout', ...agentWindowTarget(), 'tiled']);
+    return;
+  }
+
+  const auto = config.layout === AUTO_LAYOUT;
+  const resolved = resolveLayout(config, auto ? await getWindowSize(tmux) : null);
+  if ('custom' in resolved) {
+    await applyCustomLayout(tmux, agentWindowTarget(), resolved.custom);
+    return;
+  }
+
+  let layout = resolved.builtin;
+  if (auto) {
+    lastAutoLayout = layout;
+  }
+  const maxAgentsPerColumn = config.max_agents_per_column ?? 3;
+  const minimums: PaneMinimums = {
+    height: config.min_agent_pane_height ?? 0,
+    width: config.min_agent_pane_width ?? 0,
+  };
 
   try {
     if (layout === 'main-vertical') {
-      const applied = await tryApplyMainVerticalMultiColumnLayout(
+      const outcome = await tryApplyMainVerticalMultiColumnLayout(
         tmux,
         maxAgentsPerColumn,
+        minimums,
+        config,
       );
-      if (applied) {
+      if (outcome === 'applied') {
         return;
       }
+      if (outcome === 'too-small') {
+        log('[tmux] applyTmuxLayout: minimum pane size not satisfiable, using tiled');
+        layout = 'tiled';
+      }
     }
-    await applyLayout(tmux, layout, mainPaneSize);
+    await applyLayout(tmux, layout, mainPaneSizeOption(layout, config));
+    await enforceMinPaneSizes(tmux, minimums);
   } catch (err) {
     log('[tmux] applyTmuxLayout: failed, falling back to built-in layout', {
       error: String(err),
     });
     try {
-      await spawnAsyncFn([tmux, 'select-layout', layout === 'tiled' ? 'tiled' : 'main-vertical']);
+      await spawnAsyncFn([
+        tmux,
+        'select-layout',
+        ...agentWindowTarget(),
+        layout === 'tiled' ? 'tiled' : 'main-vertical',
+      ]);
     } catch (fallbackErr) {
       log('[tmux] applyTmuxLayout: fallback also failed', { error: String(fallbackErr) });
     }
   }
 }
 
+export interface LayoutPreview {
+  width: number;
+  height: number;
+  panes: number;
+  /** The window's layout now, as `#{window_layout}` prints it */
+  current: string;
+  /** What applyTmuxLayout would use: a builtin layout, `grid`, or a custom layout string */
+  next: string;
+}
+
+/**
+ * Describes opencode's window and the layout applyTmuxLayout would give it
+ * under config, without changing anything.
+ */
+export async function previewTmuxLayout(config: TmuxConfig): Promise<LayoutPreview | null> {
+  const tmux = await getTmuxPath();
+  if (!tmux) return null;
+
+  const size = await getWindowSize(tmux);
+  if (!size) return null;
+
+  const current = await spawnAsyncFn([
+    tmux,
+    'display-message',
+    '-p',
+    ...agentWindowTarget(),
+    '#{window_layout}',
+  ]);
+  const resolved = resolveLayout(config, size);
+  return {
+    ...size,
+    panes: (await listPaneIds(tmux)).length,
+    current: current.stdout.trim(),
+    next:
+      config.layout_mode === 'grid'
+        ? 'grid'
+        : 'custom' in resolved
+          ? resolved.custom
+          : resolved.builtin,
+  };
+}
+
+/**
+ * With `layout: "auto"`, re-applies the layout when the window has been
+ * resized across a threshold, so agents move below the main pane (or back)
+ * as the terminal narrows or widens. The manager calls it on control-mode
+ * layout changes, or from its poll loop when there is no control client.
+ */
+export async function refreshAutoLayout(): Promise<boolean> {
+  if (!storedConfig || storedConfig.layout !== AUTO_LAYOUT) return false;
+  if (storedConfig.layout_mode === 'grid') return false;
+
+  const tmux = await getTmuxPath();
+  if (!tmux) return false;
+
+  const size = await getWindowSize(tmux);
+  if (!size) return false;
+
+  const resolved = resolveLayout(storedConfig, size);
+  if (!('builtin' in resolved) || resolved.builtin === lastAutoLayout) return false;
+
+  log('[tmux] refreshAutoLayout: window resized, switching layout', {
+    from: lastAutoLayout,
+    to: resolved.builtin,
+    width: size.width,
+    height: size.height,
+  });
+  await applyTmuxLayout();
+  return true;
+}
+
+/** A window agent panes are grouped into instead of opencode's own */
+export interface PaneGroup {
+  /** Identifies the window: the parent session id, or `rule:<window>` for window_rules */
+  key: string;
+  /** Window name, e.g. the parent session's title */
+  name: string;
+}
+
 export interface SpawnPaneResult {
   success: boolean;
   paneId?: string;
+  /** Set on failures */
+  reason?: SpawnFailureReason;
+  /** What went wrong on the last attempt: tmux's stderr, a timeout or a thrown error */
+  error?: string;
+  /** split-window attempts made, including retries */
+  attempts?: number;
 }
 
 // For testing: allows mocking spawnAsync
@@ -358,41 +1111,177 @@ export function resetSpawnAsyncFn(): void {
   spawnAsyncFn = spawnAsync;
 }
 
+/**
+ * Applies the focus_on_spawn policy after a split.
+ * split-window runs with -d, but if the new pane still ended up active and the
+ * policy says to keep focus, the previously active pane is restored.
+ */
+async function applySpawnFocus(
+  tmux: string,
+  paneId: string,
+  policy: FocusOnSpawn,
+): Promise<void> {
+  const result = await spawnAsyncFn([
+    tmux,
+    'list-panes',
+    ...agentWindowTarget(),
+    '-F',
+    '#{pane_active} #{pane_id}',
+  ]);
+  const panes = result.stdout
+    .split('\n')
+    .map((line) => line.trim().split(/\s+/))
+    .filter((parts) => parts.length === 2);
+  if (panes.length === 0) return;
+
+  const activePaneId = panes.find(([active]) => active === '1')?.[1];
+  const isFirstAgent = panes.length === 2;
+  const shouldFocus = policy === 'always' || (policy === 'first' && isFirstAgent);
+
+  if (shouldFocus && activePaneId !== paneId) {
+    log('[tmux] applySpawnFocus: focusing new pane', { paneId, policy });
+    await spawnAsyncFn([tmux, 'select-pane', '-t', paneId], { ignoreOutput: true });
+  } else if (!shouldFocus && activePaneId === paneId) {
+    log('[tmux] applySpawnFocus: restoring previous pane focus', { paneId });
+    await spawnAsyncFn([tmux, 'select-pane', '-l', ...agentWindowTarget()], { ignoreOutput: true });
+  }
+}
+
+/**
+ * Tags an agent pane with its session and server so it can be found later,
+ * even by a new plugin instance after a crash. Best-effort: an untagged pane
+ * still works, it just can't be swept.
+ */
+async function tagAgentPane(tmux: string, paneId: string, sessionId: string, serverUrl: string): Promise<void> {
+  try {
+    await spawnAsyncFn(
+      [
+        tmux,
+        'set-option', '-p', '-t', paneId, PANE_SESSION_OPTION, sessionId,
+        ';',
+        'set-option', '-p', '-t', paneId, PANE_SERVER_OPTION, serverUrl,
+      ],
+      { ignoreOutput: true },
+    );
+  } catch (err) {
+    log('[tmux] tagAgentPane: failed to tag pane', { paneId, error: String(err) });
+  }
+}
+
+/**
+ * `-t` arguments naming opencode's window by window id, for commands like
+ * new-window that don't accept a pane as their target.
+ */
+async function agentWindowIdTarget(tmux: string): Promise<string[]> {
+  const pane = agentPane();
+  if (!pane) return [];
+  const result = await spawnAsyncFn([tmux, 'display-message', '-p', '-t', pane, '#{window_id}']);
+  const windowId = result.stdout.trim();
+  return windowId ? ['-t', windowId] : [];
+}
+
+async function spawnArgs(
+  tmux: string,
+  opencodeCmd: string,
+  config: TmuxConfig,
+  slot: GridSlot | undefined,
+  group: PaneGroup | undefined,
+  groupWindow: string | undefined,
+): Promise<string[]> {
+  if (groupWindow) {
+    return ['split-window', '-t', groupWindow, '-d', '-P', '-F', '#{pane_id}', opencodeCmd];
+  }
+  if (group) {
+    const name = escapeTmuxFormat(truncateTitle(group.name, config.pane_title_max_width));
+    return [
+      'new-window',
+      '-a',
+      ...(await agentWindowIdTarget(tmux)),
+      '-d',
+      '-n',
+      name,
+      '-P',
+      '-F',
+      '#{pane_id}',
+      opencodeCmd,
+    ];
+  }
+  if (slot) {
+    return ['respawn-pane', '-k', '-t', slot.paneId, opencodeCmd];
+  }
+  return ['split-window', '-h', ...agentWindowTarget(), '-d', '-P', '-F', '#{pane_id}', opencodeCmd];
+}
+
 async function attemptSpawnPane(
   sessionId: string,
   description: string,
   config: TmuxConfig,
   tmux: string,
   serverUrl: string,
+  signal?: AbortSignal,
+  group?: PaneGroup,
 ): Promise<SpawnPaneResult> {
-  const opencodeCmd = `opencode attach ${serverUrl} --session ${sessionId}`;
+  const attachCmd = `opencode attach ${serverUrl} --session ${sessionId}`;
+  const opencodeCmd = config.attach_command_prefix
+    ? `${config.attach_command_prefix} ${attachCmd}`
+    : attachCmd;
+
+  // Agents that no longer fit next to opencode go into the overflow window
+  if (!group && config.layout_mode !== 'grid' && !(await agentWindowHasRoom(tmux, config))) {
+    log('[tmux] attemptSpawnPane: no room at the minimum pane size, using the overflow window');
+    return attemptSpawnPane(sessionId, description, config, tmux, serverUrl, signal, OVERFLOW_GROUP);
+  }
 
-  const args = [
-    'split-window',
-    '-h',
-    '-d',
-    '-P',
-    '-F',
-    '#{pane_id}',
-    opencodeCmd,
-  ];
+  // Grouped agents go into their parent's window, created on its first agent
+  const groupWindow = group
+    ? (await listGroupWindows(tmux)).find((window) => window.key === group.key)?.windowId
+    : undefined;
+
+  // In grid mode the agent takes over the first free slot, so nothing moves
+  const slot =
+    config.layout_mode === 'grid' && !group
+      ? (await ensureGridSlots(tmux, config)).find((candidate) => candidate.placeholder)
+      : undefined;
+  if (config.layout_mode === 'grid' && !group && !slot) {
+    log('[tmux] attemptSpawnPane: grid is full, adding a pane', { capacity: gridCapacity(config) });
+  }
+
+  const args = await spawnArgs(tmux, opencodeCmd, config, slot, group, groupWindow);
 
   log('[tmux] attemptSpawnPane: executing', { tmux, args, opencodeCmd });
 
-  const result = await spawnAsyncFn([tmux, ...args]);
-  const paneId = result.stdout.trim();
+  // Only the split is abortable: once a pane exists, finish setting it up so
+  // the caller gets its id and can close it.
+  const result = await spawnAsyncFn([tmux, ...args], { signal });
+  const paneId = slot ? slot.paneId : result.stdout.trim();
 
   log('[tmux] attemptSpawnPane: split result', {
     exitCode: result.exitCode,
     paneId,
     stderr: result.stderr.trim(),
+    timedOut: result.timedOut ?? false,
   });
 
   if (result.exitCode === 0 && paneId) {
-    await spawnAsyncFn(
-      [tmux, 'select-pane', '-t', paneId, '-T', description.slice(0, 30)],
-      { ignoreOutput: true },
-    );
+    if (slot) {
+      await spawnAsyncFn([tmux, 'set-option', '-p', '-u', '-t', paneId, PANE_PLACEHOLDER_OPTION], {
+        ignoreOutput: true,
+      });
+    }
+    const title = escapeTmuxFormat(truncateTitle(description, config.pane_title_max_width));
+    await spawnAsyncFn([tmux, 'select-pane', '-t', paneId, '-T', title], { ignoreOutput: true });
+
+    if (group) {
+      if (!groupWindow) {
+        await spawnAsyncFn(
+          [tmux, 'set-option', '-w', '-t', paneId, GROUP_WINDOW_OPTION, group.key],
+          { ignoreOutput: true },
+        );
+      }
+    } else {
+      await applySpawnFocus(tmux, paneId, config.focus_on_spawn ?? 'never');
+    }
+    await tagAgentPane(tmux, paneId, sessionId, serverUrl);
 
     log('[tmux] attemptSpawnPane: SUCCESS, pane created', {
       paneId,
@@ -400,7 +1289,13 @@ async function attemptSpawnPane(
     return { success: true, paneId };
   }
 
-  return { success: false };
+  return {
+    success: false,
+    reason: signal?.aborted ? 'aborted' : 'tmux_error',
+    error:
+      result.stderr.trim() ||
+      (result.timedOut ? 'split-window timed out' : `split-window exited with ${result.exitCode}`),
+  };
 }
 
 export async function spawnTmuxPane(
@@ -408,22 +1303,25 @@ export async function spawnTmuxPane(
   description: string,
   config: TmuxConfig,
   serverUrl: string,
+  signal?: AbortSignal,
+  group?: PaneGroup,
 ): Promise<SpawnPaneResult> {
   log('[tmux] spawnTmuxPane called', {
     sessionId,
     description,
+    group,
     config,
     serverUrl,
   });
 
   if (!config.enabled) {
     log('[tmux] spawnTmuxPane: config.enabled is false, skipping');
-    return { success: false };
+    return { success: false, reason: 'disabled' };
   }
 
   if (!isInsideTmux()) {
     log('[tmux] spawnTmuxPane: not inside tmux, skipping');
-    return { success: false };
+    return { success: false, reason: 'not_in_tmux' };
   }
 
   const serverRunning = await isServerRunning(serverUrl);
@@ -433,68 +1331,460 @@ export async function spawnTmuxPane(
       serverUrl,
       hint: `Start opencode with --port ${defaultPort}`,
     });
-    return { success: false };
+    return { success: false, reason: 'server_unreachable' };
   }
 
   const tmux = await getTmuxPath();
   if (!tmux) {
     log('[tmux] spawnTmuxPane: tmux binary not found, skipping');
-    return { success: false };
+    return { success: false, reason: 'tmux_missing' };
   }
 
   storedConfig = config;
 
   const maxRetries = config.max_retry_attempts ?? 2;
   let attempt = 0;
-  let lastResult: SpawnPaneResult = { success: false };
+  let lastResult: SpawnPaneResult = { success: false, reason: 'tmux_error' };
 
   while (attempt <= maxRetries) {
+    if (signal?.aborted) {
+      log('[tmux] spawnTmuxPane: aborted', { sessionId, attempt: attempt + 1 });
+      return { success: false, reason: 'aborted' };
+    }
+
     try {
-      lastResult = await attemptSpawnPane(sessionId, description, config, tmux, serverUrl);
+      lastResult = await attemptSpawnPane(
+        sessionId,
+        description,
+        config,
+        tmux,
+        serverUrl,
+        signal,
+        group,
+      );
 
       if (lastResult.success) {
-        return lastResult;
+        return { ...lastResult, attempts: attempt + 1 };
       }
 
       log('[tmux] spawnTmuxPane: attempt failed', {
         attempt: attempt + 1,
         maxRetries,
+        reason: lastResult.reason,
+        error: lastResult.error,
       });
     } catch (err) {
       log('[tmux] spawnTmuxPane: exception on attempt', {
         attempt: attempt + 1,
         error: String(err),
       });
-      lastResult = { success: false };
+      lastResult = { success: false, reason: 'tmux_error', error: String(err) };
     }
 
     attempt++;
     if (attempt <= maxRetries) {
-      const backoffMs = BASE_BACKOFF_MS * Math.pow(2, attempt - 1);
+      const backoffMs = computeBackoffMs(attempt, {
+        baseMs: config.spawn_backoff_base_ms ?? 250,
+        maxMs: config.spawn_backoff_max_ms ?? 5000,
+        jitter: config.spawn_backoff_jitter ?? 0.2,
+      });
       log('[tmux] spawnTmuxPane: waiting before retry', { backoffMs, attempt });
-      await new Promise((resolve) => setTimeout(resolve, backoffMs));
+      await sleep(backoffMs, signal);
     }
   }
 
-  log('[tmux] spawnTmuxPane: all retries exhausted', { attempts: attempt });
-  return lastResult;
+  log('[tmux] spawnTmuxPane: all retries exhausted', {
+    attempts: attempt,
+    reason: lastResult.reason,
+    error: lastResult.error,
+  });
+  return { ...lastResult, attempts: attempt };
 }
 
-export async function closeTmuxPane(paneId: string): Promise<boolean> {
-  log('[tmux] closeTmuxPane called', { paneId });
+export interface AgentPane {
+  paneId: string;
+  sessionId: string;
+  serverUrl: string;
+  /** PID of the process tmux started in the pane */
+  pid: number | null;
+  /** The pane's command has exited (only possible with remain-on-exit) */
+  dead: boolean;
+}
 
-  if (!paneId) {
-    log('[tmux] closeTmuxPane: no paneId provided');
+/**
+ * Lists panes tagged as opentmux agent panes across the whole tmux server.
+ */
+export async function listAgentPanes(): Promise<AgentPane[]> {
+  const tmux = await getTmuxPath();
+  if (!tmux) return [];
+
+  const format = [
+    '#{pane_id}',
+    '#{pane_dead}',
+    '#{pane_pid}',
+    `#{${PANE_SESSION_OPTION}}`,
+    `#{${PANE_SERVER_OPTION}}`,
+  ].join('\t');
+
+  const result = await spawnAsyncFn([tmux, 'list-panes', '-a', '-F', format]);
+  if (result.exitCode !== 0) return [];
+
+  const panes: AgentPane[] = [];
+  for (const line of result.stdout.split('\n')) {
+    const [paneId, dead, pid, sessionId, serverUrl] = line.trim().split('\t');
+    if (!paneId || !sessionId) continue;
+    const parsedPid = Number.parseInt(pid ?? '', 10);
+    panes.push({
+      paneId,
+      sessionId,
+      serverUrl: serverUrl ?? '',
+      pid: Number.isFinite(parsedPid) ? parsedPid : null,
+      dead: dead === '1',
+    });
+  }
+  return panes;
+}
+
+/**
+ * Ids of every pane on the tmux server, or null if they couldn't be listed
+ * (so callers never mistake a tmux failure for all panes being gone).
+ */
+export async function listAllPaneIds(): Promise<Set<string> | null> {
+  const tmux = await getTmuxPath();
+  if (!tmux) return null;
+
+  const result = await spawnAsyncFn([tmux, 'list-panes', '-a', '-F', '#{pane_id}']);
+  if (result.exitCode !== 0) return null;
+
+  return new Set(
+    result.stdout
+      .split('\n')
+      .map((line) => line.trim())
+      .filter(Boolean),
+  );
+}
+
+/**
+ * Checks whether an agent pane still runs its `opencode attach` process,
+ * either as the pane process itself or as a child of the pane's shell.
+ * Takes a listProcesses() snapshot so a sweep runs ps once, not per pane.
+ */
+export function hasAttachProcess(pane: AgentPane, processes: ProcessEntry[]): boolean {
+  if (pane.dead || pane.pid === null) return false;
+  return processes.some(
+    (entry) =>
+      (entry.pid === pane.pid || entry.ppid === pane.pid) && entry.command.includes('opencode'),
+  );
+}
+
+/**
+ * Formats the status-line summary, e.g. "⚙ 3 agents | 1 queued".
+ * Empty when there is nothing to report, so the status bar stays clean.
+ */
+export function formatStatusLine(agents: number, queued: number): string {
+  if (agents === 0 && queued === 0) return '';
+  const parts = [`⚙ ${agents} ${agents === 1 ? 'agent' : 'agents'}`];
+  if (queued > 0) {
+    parts.push(`${queued} queued`);
+  }
+  return parts.join(' | ');
+}
+
+/**
+ * Publishes the status-line summary as a user option on opentmux's tmux session.
+ */
+export async function setStatusLineText(text: string): Promise<boolean> {
+  const tmux = await getTmuxPath();
+  if (!tmux) return false;
+
+  const result = await spawnAsyncFn(
+    [tmux, 'set-option', '-q', ...agentSessionTarget(), STATUS_OPTION, text],
+    { ignoreOutput: true },
+  );
+  return result.exitCode === 0;
+}
+
+/**
+ * Flashes a message in the status line of clients showing opentmux's session.
+ */
+export async function showTmuxMessage(text: string): Promise<boolean> {
+  const tmux = await getTmuxPath();
+  if (!tmux) return false;
+
+  const result = await spawnAsyncFn(
+    [tmux, 'display-message', ...agentSessionTarget(), escapeTmuxFormat(text)],
+    { ignoreOutput: true },
+  );
+  return result.exitCode === 0;
+}
+
+/**
+ * Publishes the plugin's build info on opentmux's tmux session, so the
+ * launcher can tell when opencode loaded a different opentmux version.
+ */
+export async function setVersionOption(text: string): Promise<boolean> {
+  const tmux = await getTmuxPath();
+  if (!tmux) return false;
+
+  const result = await spawnAsyncFn(
+    [tmux, 'set-option', '-q', ...agentSessionTarget(), VERSION_OPTION, text],
+    { ignoreOutput: true },
+  );
+  return result.exitCode === 0;
+}
+
+/**
+ * Publishes the spawn queue on opentmux's tmux session for `opentmux queue`.
+ */
+export async function setQueueOption(text: string): Promise<boolean> {
+  const tmux = await getTmuxPath();
+  if (!tmux) return false;
+
+  const result = await spawnAsyncFn(
+    [tmux, 'set-option', '-q', ...agentSessionTarget(), QUEUE_OPTION, text],
+    { ignoreOutput: true },
+  );
+  return result.exitCode === 0;
+}
+
+/**
+ * Whether `opentmux pause` is in effect for opentmux's tmux session.
+ */
+export async function isSpawningPaused(): Promise<boolean> {
+  const tmux = await getTmuxPath();
+  if (!tmux) return false;
+
+  const result = await spawnAsyncFn([
+    tmux,
+    'show-options',
+    '-qv',
+    ...agentSessionTarget(),
+    PAUSED_OPTION,
+  ]);
+  return result.exitCode === 0 && result.stdout.trim() === '1';
+}
+
+/**
+ * When `opentmux queue flush` last ran for opentmux's tmux session, in ms
+ * since the epoch, or null if it never did.
+ */
+export async function getQueueFlushedAt(): Promise<number | null> {
+  const tmux = await getTmuxPath();
+  if (!tmux) return null;
+
+  const result = await spawnAsyncFn([
+    tmux,
+    'show-options',
+    '-qv',
+    ...agentSessionTarget(),
+    QUEUE_FLUSHED_OPTION,
+  ]);
+  const flushedAt = Number.parseInt(result.stdout.trim(), 10);
+  return result.exitCode === 0 && Number.isFinite(flushedAt) ? flushedAt : null;
+}
+
+/**
+ * Fails every agent queued or held before `at` in the current tmux session.
+ */
+export async function setQueueFlushedAt(at: number): Promise<boolean> {
+  const tmux = await getTmuxPath();
+  if (!tmux) return false;
+
+  const result = await spawnAsyncFn(
+    [tmux, 'set-option', '-q', ...agentSessionTarget(), QUEUE_FLUSHED_OPTION, String(at)],
+    { ignoreOutput: true },
+  );
+  return result.exitCode === 0;
+}
+
+/** A request from `opentmux session close-all` to close every agent pane */
+export interface CloseAllRequest {
+  /** When it was made, in ms since the epoch */
+  at: number;
+  /** Only plugins attached to these servers act on it; every plugin when unset */
+  serverUrls?: string[];
+  /** Recorded in session history next to each closed pane */
+  reason?: string;
+  /** Also abort the panes' sessions on the server */
+  abort: boolean;
+}
+
+/**
+ * The latest `opentmux session close-all` request for opentmux's tmux
+ * session, or null if there never was one.
+ */
+export async function getCloseAllRequest(): Promise<CloseAllRequest | null> {
+  const tmux = await getTmuxPath();
+  if (!tmux) return null;
+
+  const result = await spawnAsyncFn([tmux, 'show-options', '-qv', ...agentSessionTarget(), CLOSE_ALL_OPTION]);
+  if (result.exitCode !== 0 || !result.stdout.trim()) return null;
+  try {
+    const request = JSON.parse(result.stdout.trim()) as Partial<CloseAllRequest>;
+    if (typeof request.at !== 'number') return null;
+    return {
+      at: request.at,
+      serverUrls: Array.isArray(request.serverUrls)
+        ? request.serverUrls.filter((url): url is string => typeof url === 'string')
+        : undefined,
+      reason: typeof request.reason === 'string' ? request.reason : undefined,
+      abort: request.abort === true,
+    };
+  } catch {
+    return null;
+  }
+}
+
+/**
+ * Asks the plugin in the current tmux session to close every agent pane.
+ */
+export async function setCloseAllRequest(request: CloseAllRequest): Promise<boolean> {
+  const tmux = await getTmuxPath();
+  if (!tmux) return false;
+
+  const result = await spawnAsyncFn(
+    [tmux, 'set-option', '-q', ...agentSessionTarget(), CLOSE_ALL_OPTION, JSON.stringify(request)],
+    { ignoreOutput: true },
+  );
+  return result.exitCode === 0;
+}
+
+/**
+ * Pauses or resumes spawning agent panes in the current tmux session.
+ */
+export async function setSpawningPaused(paused: boolean): Promise<boolean> {
+  const tmux = await getTmuxPath();
+  if (!tmux) return false;
+
+  const args = paused
+    ? ['set-option', '-q', ...agentSessionTarget(), PAUSED_OPTION, '1']
+    : ['set-option', '-q', '-u', ...agentSessionTarget(), PAUSED_OPTION];
+  const result = await spawnAsyncFn([tmux, ...args], { ignoreOutput: true });
+  return result.exitCode === 0;
+}
+
+/**
+ * Replaces an agent pane's title.
+ */
+export async function setPaneTitle(
+  paneId: string,
+  title: string,
+  maxWidth?: number,
+): Promise<boolean> {
+  const tmux = await getTmuxPath();
+  if (!tmux) return false;
+
+  const result = await spawnAsyncFn(
+    [tmux, 'select-pane', '-t', paneId, '-T', escapeTmuxFormat(truncateTitle(title, maxWidth))],
+    { ignoreOutput: true },
+  );
+  return result.exitCode === 0;
+}
+
+/**
+ * Brings a pane to the foreground: switches to its window, selects it, and
+ * unzooms the window if another pane is zoomed.
+ */
+export async function focusTmuxPane(paneId: string): Promise<boolean> {
+  const tmux = await getTmuxPath();
+  if (!tmux) return false;
+
+  const state = await spawnAsyncFn([
+    tmux, 'display-message', '-p', '-t', paneId, '#{window_zoomed_flag} #{pane_active}',
+  ]);
+  if (state.exitCode !== 0) {
+    log('[tmux] focusTmuxPane: pane not found', { paneId });
     return false;
   }
 
+  const [zoomed, active] = state.stdout.trim().split(' ');
+  if (zoomed === '1' && active !== '1') {
+    await spawnAsyncFn([tmux, 'resize-pane', '-Z', '-t', paneId], { ignoreOutput: true });
+  }
+
+  const result = await spawnAsyncFn(
+    [tmux, 'select-window', '-t', paneId, ';', 'select-pane', '-t', paneId],
+    { ignoreOutput: true },
+  );
+  return result.exitCode === 0;
+}
+
+export type PaneStatus = 'working' | 'idle' | 'error' | 'orphaned';
+
+/** Background tints per agent status; subtle so pane text stays readable */
+const PANE_STATUS_STYLES: Record<PaneStatus, string> = {
+  working: 'bg=colour17',
+  idle: 'bg=colour22',
+  error: 'bg=colour52',
+  orphaned: 'bg=colour236',
+};
+
+/**
+ * Tints an agent pane to reflect its status.
+ */
+export async function setPaneStatusStyle(paneId: string, status: PaneStatus): Promise<boolean> {
   const tmux = await getTmuxPath();
-  if (!tmux) {
-    log('[tmux] closeTmuxPane: tmux binary not found');
+  if (!tmux) return false;
+
+  const result = await spawnAsyncFn(
+    [tmux, 'select-pane', '-t', paneId, '-P', PANE_STATUS_STYLES[status]],
+    { ignoreOutput: true },
+  );
+  if (result.exitCode !== 0) {
+    log('[tmux] setPaneStatusStyle: failed', { paneId, status });
     return false;
   }
+  return true;
+}
 
-  // PID-level termination
+/**
+ * Returns a pane's full scrollback as plain text, or null if it can't be read.
+ */
+export async function capturePaneOutput(paneId: string): Promise<string | null> {
+  const tmux = await getTmuxPath();
+  if (!tmux) return null;
+
+  const result = await spawnAsyncFn([tmux, 'capture-pane', '-p', '-J', '-S', '-', '-t', paneId]);
+  if (result.exitCode !== 0) {
+    log('[tmux] capturePaneOutput: failed', { paneId, stderr: result.stderr });
+    return null;
+  }
+  return result.stdout;
+}
+
+/**
+ * Turns a closed agent's pane back into a placeholder instead of killing it,
+ * so the rest of the grid stays put. Panes beyond the grid's capacity, or
+ * outside the grid (e.g. in a group window), are killed as usual. Returns
+ * true if the slot was freed.
+ */
+async function freeGridSlot(tmux: string, paneId: string, config: TmuxConfig): Promise<boolean> {
+  const slots = await listGridSlots(tmux);
+  if (!slots || slots.length > gridCapacity(config)) return false;
+  if (!slots.some((slot) => slot.paneId === paneId)) return false;
+
+  const result = await spawnAsyncFn([tmux, 'respawn-pane', '-k', '-t', paneId, PLACEHOLDER_COMMAND]);
+  if (result.exitCode !== 0) return false;
+
+  await spawnAsyncFn(
+    [
+      tmux,
+      'set-option', '-p', '-u', '-t', paneId, PANE_SESSION_OPTION,
+      ';',
+      'set-option', '-p', '-u', '-t', paneId, PANE_SERVER_OPTION,
+    ],
+    { ignoreOutput: true },
+  );
+  await markPlaceholder(tmux, paneId);
+  log('[tmux] closeTmuxPane: freed grid slot', { paneId });
+  return true;
+}
+
+/**
+ * Stops the `opencode attach` processes under a pane's shell, so they don't
+ * outlive the pane.
+ */
+async function killPaneAttachProcesses(tmux: string, paneId: string): Promise<void> {
   try {
     const pidResult = await spawnAsyncFn([tmux, 'list-panes', '-t', paneId, '-F', '#{pane_pid}']);
     if (pidResult.exitCode === 0) {
@@ -508,12 +1798,9 @@ export async function closeTmuxPane(paneId: string): Promise<boolean> {
           if (command && command.includes('opencode')) {
             log('[tmux] closeTmuxPane: killing child attach process', { childPid, command });
             
-            safeKill(childPid, 'SIGTERM');
-            const exited = await waitForProcessExit(childPid, 2000);
-            
-            if (!exited) {
-              log('[tmux] closeTmuxPane: process did not exit, sending SIGKILL', { childPid });
-              safeKill(childPid, 'SIGKILL');
+            const policy = storedConfig ? terminatePolicyFromConfig(storedConfig) : DEFAULT_TERMINATE_POLICY;
+            if (!(await terminateProcess(childPid, policy))) {
+              log('[tmux] closeTmuxPane: attach process survived SIGKILL', { childPid });
             }
           }
         }
@@ -523,6 +1810,33 @@ export async function closeTmuxPane(paneId: string): Promise<boolean> {
     log('[tmux] closeTmuxPane: error during PID termination', { error: String(err) });
     // Continue to close pane anyway
   }
+}
+
+export async function closeTmuxPane(
+  paneId: string,
+  options?: { killProcesses?: boolean },
+): Promise<boolean> {
+  log('[tmux] closeTmuxPane called', { paneId });
+
+  if (!paneId) {
+    log('[tmux] closeTmuxPane: no paneId provided');
+    return false;
+  }
+
+  const tmux = await getTmuxPath();
+  if (!tmux) {
+    log('[tmux] closeTmuxPane: tmux binary not found');
+    return false;
+  }
+
+  // PID-level termination, unless the pane's processes aren't ours to see (remote_server)
+  if (options?.killProcesses ?? true) {
+    await killPaneAttachProcesses(tmux, paneId);
+  }
+
+  if (storedConfig?.layout_mode === 'grid' && (await freeGridSlot(tmux, paneId, storedConfig))) {
+    return true;
+  }
 
   try {
     const result = await spawnAsyncFn([tmux, 'kill-pane', '-t', paneId]);
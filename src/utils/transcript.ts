@@ -0,0 +1,70 @@
+import * as fs from 'node:fs';
+import * as path from 'node:path';
+import { requestJson } from './http';
+
+/** Message shape returned by the opencode `/session/{id}/message` endpoint (fields we use) */
+export interface TranscriptMessage {
+  info?: { role?: string; time?: { created?: number } };
+  parts?: Array<{ type?: string; text?: string; tool?: string }>;
+}
+
+export function getTranscriptPath(directory: string, sessionId: string): string {
+  const safeId = sessionId.replace(/[^A-Za-z0-9_-]/g, '_');
+  return path.join(directory, '.opentmux', 'transcripts', `${safeId}.md`);
+}
+
+/**
+ * Renders session messages as markdown: one heading per message, text parts
+ * verbatim, tool calls as a one-line note.
+ */
+export function renderTranscript(
+  sessionId: string,
+  title: string,
+  messages: TranscriptMessage[],
+): string {
+  const lines = [`# ${title}`, '', `Session: \`${sessionId}\``, ''];
+
+  for (const message of messages) {
+    const role = message.info?.role === 'user' ? 'User' : 'Assistant';
+    const created = message.info?.time?.created;
+    lines.push(created ? `## ${role} (${new Date(created).toISOString()})` : `## ${role}`, '');
+
+    for (const part of message.parts ?? []) {
+      if (part.type === 'text' && part.text?.trim()) {
+        lines.push(part.text.trim(), '');
+      } else if (part.type === 'tool' && part.tool) {
+        lines.push(`_Tool: ${part.tool}_`, '');
+      }
+    }
+  }
+
+  return lines.join('\n');
+}
+
+/**
+ * Fetches a session's messages from the server and writes the markdown
+ * transcript. Returns the file path, or null if the export failed.
+ */
+export async function exportTranscript(
+  serverUrl: string,
+  directory: string,
+  sessionId: string,
+  title: string,
+  timeoutMs: number,
+): Promise<string | null> {
+  const url = new URL(`/session/${encodeURIComponent(sessionId)}/message`, serverUrl).toString();
+  const response = await requestJson(url, { timeoutMs });
+  if (!response?.ok) return null;
+
+  const messages = response.body;
+  if (!Array.isArray(messages)) return null;
+
+  try {
+    const transcriptPath = getTranscriptPath(directory, sessionId);
+    fs.mkdirSync(path.dirname(transcriptPath), { recursive: true });
+    fs.writeFileSync(transcriptPath, renderTranscript(sessionId, title, messages));
+    return transcriptPath;
+  } catch {
+    return null;
+  }
+}
@@ -0,0 +1,69 @@
+import * as fs from 'node:fs';
+import * as path from 'node:path';
+
+export const PACKAGE_NAME = 'opentmux';
+const REGISTRY_URL = 'https://registry.npmjs.org';
+const REGISTRY_TIMEOUT_MS = 5000;
+
+export interface ReleaseInfo {
+  version: string;
+}
+
+/**
+ * Compares dotted versions numerically (1.10.0 > 1.9.2). A prerelease sorts
+ * before its release. Returns <0, 0, or >0.
+ */
+export function compareVersions(a: string, b: string): number {
+  const [coreA, preA] = a.replace(/^v/, '').split('-', 2);
+  const [coreB, preB] = b.replace(/^v/, '').split('-', 2);
+  const partsA = coreA.split('.').map((n) => Number.parseInt(n, 10) || 0);
+  const partsB = coreB.split('.').map((n) => Number.parseInt(n, 10) || 0);
+
+  for (let i = 0; i < Math.max(partsA.length, partsB.length); i++) {
+    const diff = (partsA[i] ?? 0) - (partsB[i] ?? 0);
+    if (diff !== 0) return diff;
+  }
+
+  if (preA === preB) return 0;
+  if (preA === undefined) return 1;
+  if (preB === undefined) return -1;
+  return preA < preB ? -1 : 1;
+}
+
+/**
+ * Reads the installed opentmux version from the package.json nearest to `fromDir`.
+ */
+export function getInstalledVersion(fromDir: string): string | null {
+  let dir = fromDir;
+  for (;;) {
+    try {
+      const pkg = JSON.parse(fs.readFileSync(path.join(dir, 'package.json'), 'utf-8')) as {
+        name?: string;
+        version?: string;
+      };
+      if (pkg.name === PACKAGE_NAME && pkg.version) return pkg.version;
+    } catch {
+      // Keep walking up
+    }
+    const parent = path.dirname(dir);
+    if (parent === dir) return null;
+    dir = parent;
+  }
+}
+
+/**
+ * Looks up the latest published opentmux release on the npm registry.
+ */
+export async function fetchLatestRelease(registryUrl = REGISTRY_URL): Promise<ReleaseInfo | null> {
+  try {
+    const response = await fetch(`${registryUrl.replace(/\/+$/, '')}/${PACKAGE_NAME}/latest`, {
+      signal: AbortSignal.timeout(REGISTRY_TIMEOUT_MS),
+    });
+    if (!response.ok) return null;
+
+    const payload = (await response.json()) as { version?: unknown };
+    return typeof payload.version === 'string' ? { version: payload.version } : null;
+  } catch {
+    return null;
+  }
+}
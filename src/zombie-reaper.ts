@@ -1,14 +1,20 @@
 import {
   findProcessIds,
+  getConnectedPorts,
   getProcessCommand,
-  isProcessAlive,
-  safeKill,
-  waitForProcessExit,
+  getProcessStartTime,
   getListeningPids,
+  terminateProcess,
+  DEFAULT_TERMINATE_POLICY,
+  type TerminatePolicy,
 } from './utils/process';
+import { systemClock, type Clock, type ClockTimer } from './utils/clock';
+import { loadConfig } from './utils/config-loader';
+import { requestJson } from './utils/http';
 import { log } from './utils/logger';
-
-const OPENCODE_PORT_START = 4096;
+import { getCandidatePorts } from './utils/ports';
+import { isOpentmuxServer } from './utils/server-registry';
+import { isSameServer, parseServerAddress } from './utils/server-url';
 
 export interface ReaperOptions {
   enabled: boolean;
@@ -17,12 +23,93 @@ export interface ReaperOptions {
   gracePeriodMs: number;
   autoSelfDestruct?: boolean;
   selfDestructTimeoutMs?: number;
-  maxPorts?: number;
+  /** Ports to scan for inactive servers (defaults to the configured port range) */
+  ports?: number[];
+  /** Reap opencode servers even if opentmux didn't start them */
+  force?: boolean;
+  /** Report what would be reaped without killing anything */
+  dryRun?: boolean;
+  /** Time source for the scan interval, grace period and idle timeout (for testing) */
+  clock?: Clock;
+  /**
+   * Active session ids on serverUrl, or null when it can't be reached. Lets
+   * the plugin share its status polling with the reaper; defaults to
+   * fetching `/session/status` directly.
+   */
+  activeSessions?: () => Promise<Set<string> | null>;
+  /** How reaped processes are stopped (defaults to SIGTERM, then SIGKILL after 2s) */
+  terminatePolicy?: TerminatePolicy;
+  /** Called for each zombie the periodic scan kills, e.g. to count reaps */
+  onReaped?: (sessionId: string) => void;
+}
+
+export type ReapReason =
+  | 'zombie-attach'
+  | 'stuck-server-attach'
+  | 'unreachable-server'
+  | 'inactive-server'
+  | 'server-error';
+
+/**
+ * A process the reaper has decided to kill.
+ */
+export interface ReapCandidate {
+  pid: number;
+  command: string;
+  sessionId: string | null;
+  port: number | null;
+  reason: ReapReason;
+  /** How long the process has been running, if known */
+  ageMs: number | null;
+}
+
+function describeCandidate(
+  pid: number,
+  reason: ReapReason,
+  details: { command?: string; sessionId?: string; port?: number } = {},
+): ReapCandidate {
+  const startedAt = getProcessStartTime(pid);
+  return {
+    pid,
+    command: details.command ?? getProcessCommand(pid) ?? '',
+    sessionId: details.sessionId ?? null,
+    port: details.port ?? null,
+    reason,
+    ageMs: startedAt !== null && Number.isFinite(startedAt) ? Date.now() - startedAt : null,
+  };
+}
+
+function formatAge(ms: number | null): string {
+  if (ms === null) return '?';
+  const minutes = Math.floor(ms / 60_000);
+  if (minutes < 1) return `${Math.floor(ms / 1000)}s`;
+  if (minutes < 60) return `${minutes}m`;
+  const hours = Math.floor(minutes / 60);
+  if (hours < 24) return `${hours}h${minutes % 60}m`;
+  return `${Math.floor(hours / 24)}d${hours % 24}h`;
+}
+
+/**
+ * Formats a reap candidate as a single audit line.
+ */
+export function formatReapCandidate(candidate: ReapCandidate): string {
+  const command =
+    candidate.command.length > 80 ? `${candidate.command.slice(0, 77)}...` : candidate.command;
+  return [
+    `PID ${candidate.pid}`,
+    `session ${candidate.sessionId ?? '-'}`,
+    `port ${candidate.port ?? '-'}`,
+    `reason ${candidate.reason}`,
+    `age ${formatAge(candidate.ageMs)}`,
+    `cmd ${command}`,
+  ].join('  ');
 }
 
 interface ZombieCandidate {
   count: number;
   firstDetectedAt: number;
+  /** Set once a dry-run report has been logged for this process */
+  reported?: boolean;
 }
 
 interface AttachProcess {
@@ -35,14 +122,17 @@ interface AttachProcess {
 export class ZombieReaper {
   private serverUrl: string;
   private options: ReaperOptions;
-  private pollInterval?: ReturnType<typeof setInterval>;
+  private clock: Clock;
+  private pollInterval?: ClockTimer;
   private candidates = new Map<number, ZombieCandidate>();
   private isScanning = false;
-  private lastActivityTime: number = Date.now();
+  private lastActivityTime: number;
 
   constructor(serverUrl: string, options: ReaperOptions) {
     this.serverUrl = serverUrl;
     this.options = options;
+    this.clock = options.clock ?? systemClock;
+    this.lastActivityTime = this.clock.now();
   }
 
   /**
@@ -58,17 +148,28 @@ export class ZombieReaper {
       ...options
     } as ReaperOptions;
 
-    log('[zombie-reaper] starting manual global reap');
+    const dryRun = opts.dryRun ?? false;
+    log('[zombie-reaper] starting manual global reap', { dryRun });
     const reaper = new ZombieReaper('', opts); // Dummy URL, we won't use instance scan
+    if (dryRun) {
+      console.log('Dry run: listing what would be reaped. Nothing will be killed.');
+    }
     
-    // 1. Reap inactive servers first
-    // Default to 10 ports if not specified
-    const maxPorts = options.maxPorts || 10;
-    const endPort = OPENCODE_PORT_START + maxPorts;
+    // 1. Reap inactive servers first, within the configured port range
+    const ports = options.ports ?? getCandidatePorts(loadConfig(process.cwd()));
     
-    const reapedServers = await ZombieReaper.reapServers(OPENCODE_PORT_START, endPort);
+    const reapedServers = await ZombieReaper.reapServers(
+      ports,
+      options.force ?? false,
+      dryRun,
+      opts.terminatePolicy,
+    );
     if (reapedServers > 0) {
-      console.log(`Reaped ${reapedServers} inactive opencode servers.`);
+      console.log(
+        dryRun
+          ? `Would reap ${reapedServers} inactive opencode servers.`
+          : `Reaped ${reapedServers} inactive opencode servers.`,
+      );
     }
 
     // 2. Reap zombie attach processes
@@ -115,8 +216,13 @@ export class ZombieReaper {
          console.warn(`[zombie-reaper] Cleaning up ${procs.length} zombies attached to stuck server.`);
          
          for (const p of procs) {
-            console.log(`🧟 Zombie detected (Stuck Server): PID ${p.pid} (Session ${p.sessionId} on ${url})`);
-            await reaper.forceKill(p.pid);
+            if (dryRun) {
+              const candidate = describeCandidate(p.pid, 'stuck-server-attach', p);
+              console.log(`Would reap: ${formatReapCandidate(candidate)}`);
+            } else {
+              console.log(`🧟 Zombie detected (Stuck Server): PID ${p.pid} (Session ${p.sessionId} on ${url})`);
+              await reaper.forceKill(p.pid);
+            }
             reapedCount++;
          }
          continue;
@@ -124,8 +230,17 @@ export class ZombieReaper {
 
       for (const p of procs) {
         if (!activeSessions.has(p.sessionId)) {
-          console.log(`🧟 Zombie detected: PID ${p.pid} (Session ${p.sessionId} on ${url})`);
-          await reaper.forceKill(p.pid);
+          if (reaper.isConnectedElsewhere(p.pid, url)) {
+            console.log(`⚠️  Skipping PID ${p.pid}: connected to a server other than ${url}`);
+            continue;
+          }
+          if (dryRun) {
+            const candidate = describeCandidate(p.pid, 'zombie-attach', p);
+            console.log(`Would reap: ${formatReapCandidate(candidate)}`);
+          } else {
+            console.log(`🧟 Zombie detected: PID ${p.pid} (Session ${p.sessionId} on ${url})`);
+            await reaper.forceKill(p.pid);
+          }
           reapedCount++;
         } else {
           // console.log(`✅ Active: PID ${p.pid} (Session ${p.sessionId})`);
@@ -133,15 +248,21 @@ export class ZombieReaper {
       }
     }
     
-    console.log(`Reap complete. Killed ${reapedCount} zombies.`);
+    console.log(
+      dryRun
+        ? `Dry run complete. Would kill ${reapedCount} zombies.`
+        : `Reap complete. Killed ${reapedCount} zombies.`,
+    );
   }
 
   private async forceKill(pid: number): Promise<void> {
-     // Direct kill for CLI
-     try {
-       process.kill(pid, 'SIGTERM');
-       // await waitForProcessExit... but for CLI we might just fire and forget or wait briefly
-     } catch {}
+    if (!(await terminateProcess(pid, this.terminatePolicy))) {
+      console.error(`[zombie-reaper] Failed to kill PID ${pid}`);
+    }
+  }
+
+  private get terminatePolicy(): TerminatePolicy {
+    return this.options.terminatePolicy ?? DEFAULT_TERMINATE_POLICY;
   }
 
   start(): void {
@@ -149,19 +270,24 @@ export class ZombieReaper {
     if (this.pollInterval) return;
 
     log('[zombie-reaper] starting', this.options);
-    this.pollInterval = setInterval(() => this.scanOnce(), this.options.intervalMs);
+    this.pollInterval = this.clock.setInterval(() => this.scanOnce(), this.options.intervalMs);
   }
 
   stop(): void {
     if (this.pollInterval) {
-      clearInterval(this.pollInterval);
+      this.clock.clearInterval(this.pollInterval);
       this.pollInterval = undefined;
       log('[zombie-reaper] stopped');
     }
   }
 
-  async shutdown(): Promise<void> {
+  /** Stops the reaper, after one final scan unless finalScan is false */
+  async shutdown(options: { finalScan?: boolean } = {}): Promise<void> {
     this.stop();
+    if (options.finalScan === false) {
+      log('[zombie-reaper] shutting down without a final scan');
+      return;
+    }
     log('[zombie-reaper] shutting down, running final scan');
     await this.scanOnce();
   }
@@ -178,14 +304,14 @@ export class ZombieReaper {
       }
 
       // Filter processes that belong to THIS server
-      const myProcesses = processes.filter(p => this.areUrlsEqual(p.targetUrl, this.serverUrl));
+      const myProcesses = processes.filter(p => isSameServer(p.targetUrl, this.serverUrl));
       
       if (myProcesses.length > 0) {
-        this.lastActivityTime = Date.now();
+        this.lastActivityTime = this.clock.now();
       } else {
         // No active clients connected to this server
         if (this.options.autoSelfDestruct && this.options.selfDestructTimeoutMs) {
-          const idleTime = Date.now() - this.lastActivityTime;
+          const idleTime = this.clock.now() - this.lastActivityTime;
           if (idleTime > this.options.selfDestructTimeoutMs) {
             log('[zombie-reaper] Server abandoned (no clients). Self-destructing.', { 
               idleTimeMs: idleTime,
@@ -208,7 +334,7 @@ export class ZombieReaper {
       }
 
       // Fetch active sessions from server
-      const activeSessions = await this.fetchActiveSessions(this.serverUrl);
+      const activeSessions = await this.ownActiveSessions();
       if (activeSessions === null) {
         log('[zombie-reaper] server unreachable, skipping scan');
         return;
@@ -219,13 +345,26 @@ export class ZombieReaper {
       for (const proc of myProcesses) {
         currentPids.add(proc.pid);
         
+        if (!activeSessions.has(proc.sessionId) && this.isConnectedElsewhere(proc.pid)) {
+          log('[zombie-reaper] skipping process connected to another server', {
+            pid: proc.pid,
+            sessionId: proc.sessionId,
+          });
+          this.candidates.delete(proc.pid);
+          continue;
+        }
+
         const isZombie = !activeSessions.has(proc.sessionId);
         
         if (isZombie) {
           this.markAsZombie(proc.pid);
           
           if (this.shouldKill(proc.pid)) {
-            await this.reapProcess(proc);
+            if (this.options.dryRun) {
+              this.reportDryRun(proc);
+            } else {
+              await this.reapProcess(proc);
+            }
           }
         } else {
           // It's active, remove from candidates if it was there
@@ -253,27 +392,18 @@ export class ZombieReaper {
       }
   }
 
-  private areUrlsEqual(url1: string | null, url2: string): boolean {
-    if (!url1) return false;
-    try {
-      // Helper to normalize URL strings
-      const normalize = (u: string) => {
-        // Add protocol if missing
-        if (!u.match(/^https?:\/\//)) {
-          u = `http://${u}`;
-        }
-        const urlObj = new URL(u);
-        // Normalize localhost to 127.0.0.1 for comparison
-        if (urlObj.hostname === 'localhost') {
-          urlObj.hostname = '127.0.0.1';
-        }
-        return urlObj.origin;
-      };
-
-      return normalize(url1) === normalize(url2);
-    } catch {
-      return url1 === url2;
-    }
+  /**
+   * Whether lsof shows the process talking to some other server and not to
+   * ours. A client started for this URL can still have been pointed
+   * elsewhere (a port reused after a restart, a forwarded connection), and
+   * such a process is not ours to judge. No connection data means no
+   * evidence either way.
+   */
+  private isConnectedElsewhere(pid: number, serverUrl: string = this.serverUrl): boolean {
+    const address = parseServerAddress(serverUrl);
+    if (!address) return false;
+    const ports = getConnectedPorts(pid);
+    return ports.length > 0 && !ports.includes(address.port);
   }
 
   async findAllAttachProcesses(): Promise<AttachProcess[]> {
@@ -311,32 +441,33 @@ export class ZombieReaper {
 
   // Exposed for testing
   async classifyProcess(sessionId: string): Promise<'active' | 'zombie' | 'unknown'> {
-    const activeSessions = await this.fetchActiveSessions(this.serverUrl);
+    const activeSessions = await this.ownActiveSessions();
     if (activeSessions === null) return 'unknown';
     return activeSessions.has(sessionId) ? 'active' : 'zombie';
   }
 
+  private ownActiveSessions(): Promise<Set<string> | null> {
+    return this.options.activeSessions?.() ?? this.fetchActiveSessions(this.serverUrl);
+  }
+
   private async fetchActiveSessions(url: string): Promise<Set<string> | null> {
     const statusUrl = new URL('/session/status', url).toString();
-    const controller = new AbortController();
-    const timeout = setTimeout(() => controller.abort(), 2000);
 
     try {
-      const response = await fetch(statusUrl, { signal: controller.signal }).catch(err => {
-        // Log network errors (like ECONNREFUSED) for debugging
-        if (process.env.DEBUG || process.env.VERBOSE) {
-           console.error(`[zombie-reaper] Fetch error for ${statusUrl}:`, err);
-        }
-        return null;
-      });
+      const response = await requestJson(statusUrl, { timeoutMs: 2000 });
       if (!response?.ok) {
-        if ((process.env.DEBUG || process.env.VERBOSE) && response) {
-           console.error(`[zombie-reaper] Server returned ${response.status} ${response.statusText} for ${statusUrl}`);
+        if (process.env.DEBUG || process.env.VERBOSE) {
+           // Network errors (like ECONNREFUSED) and timeouts leave no response
+           console.error(
+             response
+               ? `[zombie-reaper] Server returned ${response.status} ${response.statusText} for ${statusUrl}`
+               : `[zombie-reaper] No response from ${statusUrl}`,
+           );
         }
         return null;
       }
 
-      const payload = (await response.json().catch(() => null)) as unknown;
+      const payload = response.body;
       if (!payload || typeof payload !== 'object') return null;
 
       const data = (payload as { data?: unknown }).data;
@@ -384,8 +515,6 @@ export class ZombieReaper {
       return new Set(Object.keys(data));
     } catch {
       return null;
-    } finally {
-      clearTimeout(timeout);
     }
   }
 
@@ -396,7 +525,7 @@ export class ZombieReaper {
     } else {
       this.candidates.set(pid, {
         count: 1,
-        firstDetectedAt: Date.now(),
+        firstDetectedAt: this.clock.now(),
       });
     }
   }
@@ -406,40 +535,55 @@ export class ZombieReaper {
     if (!candidate) return false;
 
     const meetsCount = candidate.count >= this.options.minZombieChecks;
-    const meetsGrace = Date.now() - candidate.firstDetectedAt >= this.options.gracePeriodMs;
+    const meetsGrace = this.clock.now() - candidate.firstDetectedAt >= this.options.gracePeriodMs;
 
     return meetsCount && meetsGrace;
   }
 
+  private reportDryRun(proc: AttachProcess): void {
+    const candidate = this.candidates.get(proc.pid);
+    if (!candidate || candidate.reported) return;
+    candidate.reported = true;
+    log('[zombie-reaper] dry run: would reap zombie', describeCandidate(proc.pid, 'zombie-attach', proc));
+  }
+
   private async reapProcess(proc: AttachProcess): Promise<void> {
     log('[zombie-reaper] REAPING ZOMBIE', { pid: proc.pid, sessionId: proc.sessionId });
     
-    safeKill(proc.pid, 'SIGTERM');
-    const exited = await waitForProcessExit(proc.pid, 2000);
-    
-    if (!exited) {
-      log('[zombie-reaper] force killing zombie', { pid: proc.pid });
-      safeKill(proc.pid, 'SIGKILL');
+    if (!(await terminateProcess(proc.pid, this.terminatePolicy))) {
+      log('[zombie-reaper] zombie survived SIGKILL', { pid: proc.pid });
     }
     
     this.candidates.delete(proc.pid);
+    this.options.onReaped?.(proc.sessionId);
   }
 
-  static async reapServers(startPort: number, endPort: number): Promise<number> {
+  static async reapServers(
+    ports: number[],
+    force = false,
+    dryRun = false,
+    policy: TerminatePolicy = DEFAULT_TERMINATE_POLICY,
+  ): Promise<number> {
     let reapedCount = 0;
-    console.log(`Scanning ports ${startPort}-${endPort} for inactive servers...`);
+    if (ports.length === 0) return 0;
+    console.log(`Scanning ports ${ports[0]}-${ports[ports.length - 1]} for inactive servers...`);
 
-    for (let port = startPort; port <= endPort; port++) {
+    for (const port of ports) {
       const pids = getListeningPids(port);
       if (pids.length === 0) continue;
 
       for (const pid of pids) {
-        // Verify it's an opencode process (safety check via command name)
+        // Verify it's an opencode process (safety check via command name).
+        // opencode launched through node/bun still has "opencode" in its
+        // command line; other node/bun dev servers must not be touched.
         const cmd = getProcessCommand(pid) || '';
-        // We look for 'opencode' or 'node' (since it might be running via node)
-        // If it's some other random service, we shouldn't touch it.
-        const isSuspicious = cmd.includes('opencode') || cmd.includes('node') || cmd.includes('bun');
-        if (!isSuspicious) continue;
+        if (!cmd.includes('opencode')) continue;
+
+        // Only servers opentmux started carry the ownership marker
+        if (!force && !isOpentmuxServer(pid, port)) {
+          console.log(`[zombie-reaper] Skipping port ${port} (PID ${pid} was not started by opentmux; use --force)`);
+          continue;
+        }
 
         // Verify via HTTP
         const url = `http://127.0.0.1:${port}`;
@@ -447,8 +591,9 @@ export class ZombieReaper {
         const reaper = new ZombieReaper(url, { 
             enabled: true, intervalMs: 0, minZombieChecks: 0, gracePeriodMs: 0 
         });
-        
-          try {
+
+        let reason: ReapReason;
+        try {
             // Retry logic: try 3 times with delay
             let sessions = null;
             for (let i = 0; i < 3; i++) {
@@ -456,73 +601,53 @@ export class ZombieReaper {
                 if (sessions !== null) break;
                 if (i < 2) await new Promise(r => setTimeout(r, 1000));
             }
-            
-            // If sessions is null, it means fetch failed (unreachable/stuck)
-            if (sessions === null) {
-                console.log(`[zombie-reaper] Server on port ${port} (PID ${pid}) is unreachable/stuck after 3 retries. Killing...`);
-                try {
-                  safeKill(pid, 'SIGTERM');
-                  const exited = await waitForProcessExit(pid, 2000);
-                  if (!exited) {
-                    console.log(`[zombie-reaper] Force killing server on port ${port} (PID ${pid})...`);
-                    safeKill(pid, 'SIGKILL');
-                    await waitForProcessExit(pid, 1000);
-                    if (isProcessAlive(pid)) {
-                      console.error(`[zombie-reaper] CRITICAL: Failed to kill PID ${pid} on port ${port}`);
-                    }
-                  }
-                } catch (err) {
-                  console.error(`[zombie-reaper] Error killing PID ${pid}:`, err);
-                }
-                reapedCount++;
-                continue;
-            }
 
-            // If sessions exist and non-empty, protect servers with active sessions
-            if (sessions.size > 0) {
+            if (sessions === null) {
+                // Fetch failed (unreachable/stuck)
+                reason = 'unreachable-server';
+            } else if (sessions.size > 0) {
+                // Protect servers with active sessions
                 console.log(`[zombie-reaper] Skipping port ${port} (Has ${sessions.size} active session(s))`);
                 continue;
-            }
-
-            // If sessions is empty (reachable but no agents)
-            if (sessions.size === 0) {
-                console.log(`[zombie-reaper] Found inactive server on port ${port} (PID ${pid}). Killing...`);
-                try {
-                  safeKill(pid, 'SIGTERM');
-                  const exited = await waitForProcessExit(pid, 2000);
-                  if (!exited) {
-                    console.log(`[zombie-reaper] Force killing server on port ${port} (PID ${pid})...`);
-                    safeKill(pid, 'SIGKILL');
-                    await waitForProcessExit(pid, 1000);
-                    if (isProcessAlive(pid)) {
-                      console.error(`[zombie-reaper] CRITICAL: Failed to kill PID ${pid} on port ${port}`);
-                    }
-                  }
-                } catch (err) {
-                  console.error(`[zombie-reaper] Error killing PID ${pid}:`, err);
-                }
-                reapedCount++;
+            } else {
+                // Reachable but no agents
+                reason = 'inactive-server';
             }
         } catch (e) {
-            console.log(`[zombie-reaper] Server on port ${port} (PID ${pid}) error. Killing...`);
-            try {
-              safeKill(pid, 'SIGTERM');
-              const exited = await waitForProcessExit(pid, 2000);
-              if (!exited) {
-                console.log(`[zombie-reaper] Force killing server on port ${port} (PID ${pid})...`);
-                safeKill(pid, 'SIGKILL');
-                await waitForProcessExit(pid, 1000);
-                if (isProcessAlive(pid)) {
-                  console.error(`[zombie-reaper] CRITICAL: Failed to kill PID ${pid} on port ${port}`);
-                }
-              }
-            } catch (err) {
-              console.error(`[zombie-reaper] Error killing PID ${pid}:`, err);
-            }
+            reason = 'server-error';
+        }
+
+        if (dryRun) {
+            const candidate = describeCandidate(pid, reason, { command: cmd, port });
+            console.log(`Would reap: ${formatReapCandidate(candidate)}`);
             reapedCount++;
+            continue;
         }
+
+        switch (reason) {
+          case 'unreachable-server':
+            console.log(`[zombie-reaper] Server on port ${port} (PID ${pid}) is unreachable/stuck after 3 retries. Killing...`);
+            break;
+          case 'inactive-server':
+            console.log(`[zombie-reaper] Found inactive server on port ${port} (PID ${pid}). Killing...`);
+            break;
+          default:
+            console.log(`[zombie-reaper] Server on port ${port} (PID ${pid}) error. Killing...`);
+        }
+        await ZombieReaper.killServer(pid, port, policy);
+        reapedCount++;
       }
     }
     return reapedCount;
   }
+
+  private static async killServer(pid: number, port: number, policy: TerminatePolicy): Promise<void> {
+    try {
+      if (!(await terminateProcess(pid, policy, { group: true }))) {
+        console.error(`[zombie-reaper] CRITICAL: Failed to kill PID ${pid} on port ${port}`);
+      }
+    } catch (err) {
+      console.error(`[zombie-reaper] Error killing PID ${pid}:`, err);
+    }
+  }
 }
@@ -1,15 +1,34 @@
+import { execSync } from 'node:child_process';
+import { readFileSync } from 'node:fs';
 import { defineConfig } from 'tsup';
 
+const pkg = JSON.parse(readFileSync('package.json', 'utf-8')) as { version: string };
+
+function gitCommit(): string {
+  try {
+    return execSync('git rev-parse --short HEAD', { encoding: 'utf-8', stdio: ['ignore', 'pipe', 'ignore'] }).trim();
+  } catch {
+    return 'unknown';
+  }
+}
+
 export default defineConfig({
   entry: {
     index: 'src/index.ts',
+    embed: 'src/embed.ts',
     'bin/opentmux': 'src/bin/opentmux.ts',
     'scripts/install': 'src/scripts/install.ts',
     'scripts/update-plugins': 'src/scripts/update-plugins.ts',
   },
   format: ['esm'],
   dts: {
-    entry: ['src/index.ts']
+    entry: ['src/index.ts', 'src/embed.ts']
+  },
+  // Build info reported by `opentmux version` (see src/utils/build-info.ts)
+  define: {
+    __OPENTMUX_VERSION__: JSON.stringify(pkg.version),
+    __OPENTMUX_COMMIT__: JSON.stringify(gitCommit()),
+    __OPENTMUX_BUILD_DATE__: JSON.stringify(new Date().toISOString()),
   },
   clean: true,
   splitting: false,